@@ -0,0 +1,209 @@
+package mongoparser
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Advisory lint rules, reported as LintInfo unless configured otherwise. They
+// flag patterns that work but tend to cause trouble in production.
+const (
+	// Array property in a $jsonSchema without maxItems
+	LintUnboundedArray = "unbounded-array"
+	// Index whose keys are a prefix of another index on the same collection
+	LintRedundantIndex = "redundant-index"
+	// Case-insensitive $regex filter, which can't use an index efficiently,
+	// on an operation without a collation
+	LintRegexWithoutCollation = "regex-without-collation"
+	// Object $jsonSchema that doesn't set additionalProperties
+	LintOpenSchema = "open-schema"
+)
+
+// Index defined by a linted script
+type lintIndex struct {
+	line       int
+	collection string
+	keys       bson.D
+	options    *options.IndexOptions
+}
+
+// Runs the advisory rules over the operations of a script
+func (l *linter) advise(operations []lintOperation) {
+	var indexes []lintIndex
+	for _, lo := range operations {
+		op := lo.op
+		switch op.Type {
+		case "createCollection":
+			l.adviseValidator(lo.line, op.Collection, op.Validator)
+		case "createIndex":
+			if keys, ok := op.IndexSpec.(bson.D); ok {
+				indexes = append(indexes, lintIndex{lo.line, op.Collection, keys, op.IndexOptions})
+			}
+		case "createIndexes":
+			for _, model := range op.IndexModels {
+				if keys, ok := model.Keys.(bson.D); ok {
+					indexes = append(indexes, lintIndex{lo.line, op.Collection, keys, model.Options})
+				}
+			}
+		case "update", "delete", "count", "findAndModify":
+			if len(op.Arguments) > 0 && !hasCollation(op) {
+				if field, ok := caseInsensitiveRegex(op.Arguments[0], ""); ok {
+					l.report(lo.line, LintRegexWithoutCollation, "%s on %s filters %s with a case-insensitive $regex; a case-insensitive collation and index are faster", op.Operation, op.Collection, field)
+				}
+			}
+		}
+	}
+	l.adviseIndexes(indexes)
+}
+
+// Flags open schemas and unbounded arrays in a collection validator
+func (l *linter) adviseValidator(line int, collection string, validator interface{}) {
+	doc, ok := validator.(bson.D)
+	if !ok {
+		return
+	}
+	value, ok := lookupKey(doc, "$jsonSchema")
+	if !ok {
+		return
+	}
+	schema, ok := value.(bson.D)
+	if !ok {
+		return
+	}
+
+	if containsType(schema, "object") {
+		if _, ok := lookupKey(schema, "additionalProperties"); !ok {
+			l.report(line, LintOpenSchema, "validator for %s does not set additionalProperties, so documents may contain any other field", collection)
+		}
+	}
+	for _, path := range unboundedArrays(schema, "") {
+		l.report(line, LintUnboundedArray, "validator for %s allows %s to grow without limit; set maxItems", collection, path)
+	}
+}
+
+// Reports whether a schema's bsonType or type includes the given type
+func containsType(schema bson.D, name string) bool {
+	for _, keyword := range []string{"bsonType", "type"} {
+		if value, ok := lookupKey(schema, keyword); ok {
+			for _, typeName := range schemaTypeNames(value) {
+				if typeName == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Lists the paths of array properties without maxItems
+func unboundedArrays(schema bson.D, path string) []string {
+	var paths []string
+	if path != "" && containsType(schema, "array") {
+		if _, ok := lookupKey(schema, "maxItems"); !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	if value, ok := lookupKey(schema, "properties"); ok {
+		if properties, ok := value.(bson.D); ok {
+			for _, property := range properties {
+				if sub, ok := property.Value.(bson.D); ok {
+					paths = append(paths, unboundedArrays(sub, joinPath(path, property.Key))...)
+				}
+			}
+		}
+	}
+	if value, ok := lookupKey(schema, "items"); ok {
+		if sub, ok := value.(bson.D); ok {
+			paths = append(paths, unboundedArrays(sub, path+"[]")...)
+		}
+	}
+	return paths
+}
+
+// Flags indexes made redundant by a compound index starting with the same keys
+func (l *linter) adviseIndexes(indexes []lintIndex) {
+	for _, index := range indexes {
+		if hasIndexBehavior(index.options) {
+			continue
+		}
+		for _, other := range indexes {
+			if other.collection != index.collection || len(other.keys) <= len(index.keys) {
+				continue
+			}
+			if isKeyPrefix(index.keys, other.keys) {
+				l.report(index.line, LintRedundantIndex, "index %s on %s is a prefix of index %s and can be dropped", formatKeys(index.keys), index.collection, formatKeys(other.keys))
+				break
+			}
+		}
+	}
+}
+
+// Reports whether index options do more than speed up queries, so the index
+// is needed even when another index covers its keys
+func hasIndexBehavior(opts *options.IndexOptions) bool {
+	if opts == nil {
+		return false
+	}
+	return (opts.Unique != nil && *opts.Unique) ||
+		(opts.Sparse != nil && *opts.Sparse) ||
+		opts.PartialFilterExpression != nil ||
+		opts.ExpireAfterSeconds != nil ||
+		opts.Collation != nil
+}
+
+// Reports whether keys are the leading keys of other, with the same directions
+func isKeyPrefix(keys, other bson.D) bool {
+	for i, key := range keys {
+		if key.Key != other[i].Key || !valuesEqual(key.Value, other[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether an operation sets a collation
+func hasCollation(op MongoOperation) bool {
+	switch {
+	case op.UpdateOptions != nil && op.UpdateOptions.Collation != nil:
+		return true
+	case op.DeleteOptions != nil && op.DeleteOptions.Collation != nil:
+		return true
+	case op.FindAndModifyOptions != nil && op.FindAndModifyOptions.Collation != nil:
+		return true
+	}
+	return false
+}
+
+// Finds a field filtered with a case-insensitive $regex, looking into $and,
+// $or and $nor clauses
+func caseInsensitiveRegex(filter bson.D, path string) (string, bool) {
+	for _, elem := range filter {
+		switch value := elem.Value.(type) {
+		case bson.D:
+			if pattern, ok := lookupKey(value, "$regex"); ok {
+				flags, _ := lookupKey(value, "$options")
+				if text, _ := flags.(string); strings.Contains(text, "i") {
+					return joinPath(path, elem.Key), true
+				}
+				if text, _ := pattern.(string); strings.HasPrefix(text, "(?i)") {
+					return joinPath(path, elem.Key), true
+				}
+			}
+		case bson.A:
+			if elem.Key != "$and" && elem.Key != "$or" && elem.Key != "$nor" {
+				continue
+			}
+			for _, clause := range value {
+				if doc, ok := clause.(bson.D); ok {
+					if field, ok := caseInsensitiveRegex(doc, path); ok {
+						return field, true
+					}
+				}
+			}
+		}
+	}
+	return "", false
+}
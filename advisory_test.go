@@ -0,0 +1,72 @@
+package mongoparser
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const advisoryScript = `// METADATA:
+// {"name": "orders"}
+
+db.createCollection("orders", {
+  validator: {
+    $jsonSchema: {
+      bsonType: "object", required: ["status"],
+      properties: {
+        items: { bsonType: "array", maxItems: 100 },
+        tags: { bsonType: "array", items: { bsonType: "string" } },
+        shipping: { bsonType: "object", properties: { stops: { bsonType: ["array", "null"] } } }
+      }
+    }
+  }
+});
+
+db.orders.createIndex({ customerId: 1 }, { name: "customer" });
+db.orders.createIndex({ customerId: 1, createdAt: -1 }, { name: "customer_created" });
+db.orders.createIndex({ customerId: -1 }, { name: "customer_desc" });
+db.orders.createIndex({ status: 1 }, { name: "status_unique", unique: true });
+db.orders.createIndex({ status: 1, total: 1 }, { name: "status_total" });
+
+db.orders.updateMany({ $or: [{ email: { $regex: "^ana@", $options: "i" } }] }, { $set: { vip: true } });
+db.orders.deleteMany({ email: { $regex: "^bob@", $options: "i" } }, { collation: { locale: "en", strength: 2 } });
+db.orders.countDocuments({ name: { $regex: "(?i)smith" } });
+`
+
+func TestAdvisoryRules(t *testing.T) {
+	issues := NewParser().Lint(advisoryScript)
+
+	expected := []struct {
+		line int
+		rule string
+	}{
+		{4, LintOpenSchema},
+		{4, LintUnboundedArray},
+		{4, LintUnboundedArray},
+		{17, LintRedundantIndex},
+		{23, LintRegexWithoutCollation},
+		{25, LintRegexWithoutCollation},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, e := range expected {
+		if issues[i].Line != e.line || issues[i].Rule != e.rule || issues[i].Severity != LintInfo {
+			t.Errorf("Issue %d: expected line %d info %s, got %s", i, e.line, e.rule, issues[i])
+		}
+	}
+}
+
+func TestUnboundedArrays(t *testing.T) {
+	schema := parseValidator(t, `{
+		properties: {
+			tags: { bsonType: "array" },
+			matrix: { bsonType: "array", maxItems: 3, items: { bsonType: "array" } }
+		}
+	}`)[0].Value
+
+	paths := unboundedArrays(schema.(bson.D), "")
+	if len(paths) != 2 || paths[0] != "tags" || paths[1] != "matrix[]" {
+		t.Errorf("Expected tags and matrix[], got %v", paths)
+	}
+}
@@ -0,0 +1,42 @@
+package mongoparser
+
+import (
+	"strings"
+	"time"
+)
+
+// Prefix of comments annotating the statement that follows them:
+//
+//	// mongoparser:ignore
+//	// mongoparser:continue-on-error
+//	// mongoparser:timeout=30s
+const annotationPrefix = "// mongoparser:"
+
+// Reports whether a comment line annotates the next statement
+func isAnnotation(line string) bool {
+	return strings.HasPrefix(line, annotationPrefix)
+}
+
+// Applies annotation comments to the operation parsed from the statement they
+// precede. Unknown or malformed annotations are reported as warnings.
+func (p *Parser) annotate(op *MongoOperation, annotations []string) {
+	for _, line := range annotations {
+		annotation := strings.TrimSpace(strings.TrimPrefix(line, annotationPrefix))
+		name, value, _ := strings.Cut(annotation, "=")
+		switch name {
+		case "ignore":
+			op.Ignored = true
+		case "continue-on-error":
+			op.ContinueOnError = true
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				p.warnf("ignoring annotation %q: timeout must be a positive duration such as 30s", annotation)
+				continue
+			}
+			op.Timeout = timeout
+		default:
+			p.warnf("ignoring unknown annotation %q", annotation)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mongoparser
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`// mongoparser:ignore
+db.legacy.drop();
+// mongoparser:continue-on-error
+// mongoparser:timeout=30s
+db.users.createIndex({ email: 1 });
+db.users.createIndex({ name: 1 });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 3 {
+		t.Fatalf("Expected 3 operations, got %d", len(ops))
+	}
+	if !ops[0].Ignored || ops[0].ContinueOnError {
+		t.Errorf("Expected first statement to be ignored only, got %+v", ops[0])
+	}
+	if ops[1].Ignored || !ops[1].ContinueOnError || ops[1].Timeout != 30*time.Second {
+		t.Errorf("Expected second statement to continue on error with a timeout, got %+v", ops[1])
+	}
+	if ops[2].Ignored || ops[2].ContinueOnError || ops[2].Timeout != 0 {
+		t.Errorf("Expected annotations to apply to one statement only, got %+v", ops[2])
+	}
+}
+
+func TestParseAnnotationsInvalid(t *testing.T) {
+	var warnings []string
+	parser := NewParser()
+	parser.warnings = &warnings
+	ops, err := parser.parseJavaScriptOperations("// mongoparser:timeout=soon\n// mongoparser:retry\nprint(1);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].Timeout != 0 {
+		t.Fatalf("Expected the statement without a timeout, got %+v", ops)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "positive duration") || !strings.Contains(warnings[1], "unknown annotation") {
+		t.Errorf("Expected warnings for both annotations, got %q", warnings)
+	}
+}
+
+func TestExecuteAnnotations(t *testing.T) {
+	script := `// mongoparser:ignore
+assert(false, "ignored");
+// mongoparser:continue-on-error
+assert.eq(1, 2);
+// mongoparser:continue-on-error
+// mongoparser:timeout=10ms
+sleep(60000);
+print("done");`
+
+	result := NewParser().ExecuteScript(context.Background(), nil, script)
+	if !result.Success {
+		t.Fatalf("Expected script to succeed, got %v", result.Error)
+	}
+
+	statuses := make([]string, 0, len(result.Operations))
+	for _, op := range result.Operations {
+		statuses = append(statuses, op.Status)
+	}
+	expected := []string{StatusSkipped, StatusFailed, StatusFailed, StatusSucceeded}
+	if strings.Join(statuses, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected statuses %v, got %v", expected, statuses)
+	}
+	if err := result.Operations[2].Error; err == nil || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Errorf("Expected sleep to time out, got %v", err)
+	}
+	if len(result.Warnings) != 2 {
+		t.Errorf("Expected a warning per tolerated failure, got %q", result.Warnings)
+	}
+}
@@ -0,0 +1,342 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Matches var/let/const assignments of an operation result
+var assignmentPattern = regexp.MustCompile(`^(?:var|let|const)\s+([A-Za-z_$][\w$]*)\s*=\s*(.+)$`)
+
+// Matches a variable reference with an optional field path, e.g. res or res.ok
+var variablePattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[\w$]+)*$`)
+
+// Comparison operators of assert conditions, longest first so === wins over ==
+var comparisonOperators = []string{"===", "!==", "==", "!=", "<=", ">=", "<", ">"}
+
+// Comparators of the two-operand assert helpers
+var assertComparators = map[string]string{
+	"assert.eq":  "==",
+	"assert.neq": "!=",
+	"assert.lt":  "<",
+	"assert.lte": "<=",
+	"assert.gt":  ">",
+	"assert.gte": ">=",
+}
+
+// Reports a failed assert statement; the script fails with it
+type AssertionError struct {
+	Message string
+}
+
+func (e *AssertionError) Error() string {
+	return "assertion failed: " + e.Message
+}
+
+// A condition checked by an assert statement
+type Assertion struct {
+	// One of ==, !=, <, <=, >, >= comparing two operands, or "" to check that a
+	// single operand is truthy
+	Comparator string    `json:"comparator,omitempty"`
+	Operands   []Operand `json:"operands"`
+	Message    string    `json:"message,omitempty"`
+	// Set by commandWorked and commandFailed, which check whether the operand's
+	// operation succeeds instead of inspecting its result
+	ExpectWorked *bool `json:"expect_worked,omitempty"`
+}
+
+// A value an assertion inspects: a literal, a variable bound by an earlier
+// statement (optionally followed by a field path) or an operation run when
+// the assertion is checked
+type Operand struct {
+	Text      string          `json:"text"`
+	Value     interface{}     `json:"value,omitempty"`
+	Variable  string          `json:"variable,omitempty"`
+	Operation *MongoOperation `json:"operation,omitempty"`
+}
+
+// Reports whether name is an assert helper this parser understands
+func isAssertHelper(name string) bool {
+	switch name {
+	case "assert", "assert.commandWorked", "assert.writeOK", "assert.commandFailed":
+		return true
+	}
+	_, ok := assertComparators[name]
+	return ok
+}
+
+// Parses assert(cond, msg), assert.eq(a, b, msg) and friends, and
+// assert.commandWorked(x) / assert.commandFailed(x)
+func (p *Parser) parseAssert(name, argsString string) (*MongoOperation, error) {
+	args := p.splitArguments(argsString)
+	assertion := &Assertion{}
+
+	operandCount := 1
+	switch name {
+	case "assert":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("assert requires a condition")
+		}
+		if left, comparator, right, ok := splitComparison(args[0]); ok {
+			assertion.Comparator = comparator
+			args = append([]string{left, right}, args[1:]...)
+			operandCount = 2
+		}
+	case "assert.commandWorked", "assert.writeOK", "assert.commandFailed":
+		worked := name != "assert.commandFailed"
+		assertion.ExpectWorked = &worked
+	default:
+		assertion.Comparator = assertComparators[name]
+		operandCount = 2
+	}
+
+	if len(args) < operandCount {
+		return nil, fmt.Errorf("%s requires %d arguments", name, operandCount)
+	}
+	for _, arg := range args[:operandCount] {
+		operand, err := p.parseOperand(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		assertion.Operands = append(assertion.Operands, operand)
+	}
+	if len(args) > operandCount {
+		var message interface{}
+		if err := p.parseJSONLikeString(args[operandCount], &message); err == nil {
+			assertion.Message = renderValue(message, false)
+		} else {
+			assertion.Message = strings.TrimSpace(args[operandCount])
+		}
+	}
+
+	return &MongoOperation{
+		Type:      "assert",
+		Operation: name,
+		Assertion: assertion,
+	}, nil
+}
+
+// Parses an assertion operand
+func (p *Parser) parseOperand(text string) (Operand, error) {
+	text = strings.TrimSpace(text)
+	operand := Operand{Text: text}
+	switch {
+	case strings.HasPrefix(text, "db."):
+		op, err := p.parseMongoStatement(text)
+		if err != nil {
+			return operand, err
+		}
+		if op == nil {
+			return operand, fmt.Errorf("unsupported operation %s", text)
+		}
+		op.Statement = text
+		operand.Operation = op
+	case variablePattern.MatchString(text) && text != "true" && text != "false" && text != "null":
+		operand.Variable = text
+	default:
+		if err := p.parseJSONLikeString(text, &operand.Value); err != nil {
+			return operand, fmt.Errorf("cannot evaluate %s: %w", text, err)
+		}
+	}
+	return operand, nil
+}
+
+// Splits a condition at its top-level comparison operator
+func splitComparison(condition string) (left, comparator, right string, ok bool) {
+	depth := 0
+	var quote rune
+	for i, char := range condition {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+			continue
+		case char == '"' || char == '\'':
+			quote = char
+			continue
+		case char == '(' || char == '{' || char == '[':
+			depth++
+			continue
+		case char == ')' || char == '}' || char == ']':
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+		for _, operator := range comparisonOperators {
+			if strings.HasPrefix(condition[i:], operator) {
+				// Strict and loose equality behave the same on parsed values
+				comparator = strings.Replace(strings.Replace(operator, "===", "==", 1), "!==", "!=", 1)
+				return strings.TrimSpace(condition[:i]), comparator, strings.TrimSpace(condition[i+len(operator):]), true
+			}
+		}
+	}
+	return "", "", "", false
+}
+
+// Checks an assertion against the results bound so far, running operand
+// operations as needed
+func (p *Parser) executeAssert(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	assertion := op.Assertion
+	if assertion == nil || len(assertion.Operands) == 0 {
+		return nil, fmt.Errorf("assert operation requires a condition")
+	}
+
+	if assertion.ExpectWorked != nil {
+		_, err := p.evaluateOperand(ctx, db, assertion.Operands[0])
+		if *assertion.ExpectWorked && err != nil {
+			return nil, p.assertionFailed(assertion, fmt.Sprintf("command failed: %v", err))
+		}
+		if !*assertion.ExpectWorked && err == nil {
+			return nil, p.assertionFailed(assertion, fmt.Sprintf("command worked when it should have failed: %s", assertion.Operands[0].Text))
+		}
+		return true, nil
+	}
+
+	values := make([]interface{}, len(assertion.Operands))
+	for i, operand := range assertion.Operands {
+		value, err := p.evaluateOperand(ctx, db, operand)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	if assertion.Comparator == "" {
+		if !truthy(values[0]) {
+			return nil, p.assertionFailed(assertion, fmt.Sprintf("%s is not truthy", assertion.Operands[0].Text))
+		}
+		return true, nil
+	}
+
+	holds, err := compareValues(values[0], values[1], assertion.Comparator)
+	if err != nil {
+		return nil, err
+	}
+	if !holds {
+		return nil, p.assertionFailed(assertion, fmt.Sprintf("expected %s %s %s, got %s and %s",
+			assertion.Operands[0].Text, assertion.Comparator, assertion.Operands[1].Text,
+			renderValue(values[0], false), renderValue(values[1], false)))
+	}
+	return true, nil
+}
+
+// Builds the error of a failed assertion, preferring the script's message
+func (p *Parser) assertionFailed(assertion *Assertion, detail string) error {
+	if assertion.Message != "" {
+		return &AssertionError{Message: assertion.Message + ": " + detail}
+	}
+	return &AssertionError{Message: detail}
+}
+
+// Returns the value of an assertion operand
+func (p *Parser) evaluateOperand(ctx context.Context, db *mongo.Database, operand Operand) (interface{}, error) {
+	switch {
+	case operand.Operation != nil:
+		nested := *operand.Operation
+		nested.Collection = p.collectionName(nested.Collection)
+		nested, err := p.applyMiddleware(nested)
+		if err != nil {
+			return nil, err
+		}
+		return p.executeMongoOperation(ctx, db, nested)
+	case operand.Variable != "":
+		path := strings.Split(operand.Variable, ".")
+		value, ok := p.variables[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s is not defined", path[0])
+		}
+		for _, field := range path[1:] {
+			doc, ok := asMap(value)
+			if !ok {
+				return nil, fmt.Errorf("cannot read %s of %s", field, operand.Variable)
+			}
+			value = doc[field]
+		}
+		return value, nil
+	default:
+		return operand.Value, nil
+	}
+}
+
+// Applies a comparison operator to two values
+func compareValues(a, b interface{}, comparator string) (bool, error) {
+	switch comparator {
+	case "==":
+		return valuesEqual(a, b), nil
+	case "!=":
+		return !valuesEqual(a, b), nil
+	}
+
+	numA, okA := toFloat64(a)
+	numB, okB := toFloat64(b)
+	if okA && okB {
+		switch comparator {
+		case "<":
+			return numA < numB, nil
+		case "<=":
+			return numA <= numB, nil
+		case ">":
+			return numA > numB, nil
+		case ">=":
+			return numA >= numB, nil
+		}
+	}
+	strA, okA := a.(string)
+	strB, okB := b.(string)
+	if okA && okB {
+		switch comparator {
+		case "<":
+			return strA < strB, nil
+		case "<=":
+			return strA <= strB, nil
+		case ">":
+			return strA > strB, nil
+		case ">=":
+			return strA >= strB, nil
+		}
+	}
+	return false, fmt.Errorf("cannot compare %v %s %v", a, comparator, b)
+}
+
+// Reports whether a value is truthy in the JavaScript sense
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case bson.D:
+		// Command replies report success through their ok field
+		if ok, found := lookupKey(v, "ok"); found {
+			return truthy(ok)
+		}
+		return true
+	}
+	if num, ok := toFloat64(value); ok {
+		return num != 0
+	}
+	return true
+}
+
+// Returns the operations an assertion or transaction runs on its behalf
+func nestedOperations(op MongoOperation) []MongoOperation {
+	nested := append([]MongoOperation(nil), op.Transaction...)
+	if op.Assertion == nil {
+		return nested
+	}
+	for _, operand := range op.Assertion.Operands {
+		if operand.Operation != nil {
+			nested = append(nested, *operand.Operation)
+		}
+	}
+	return nested
+}
@@ -0,0 +1,112 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseAssertStatements(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+var res = db.users.countDocuments({ active: true });
+assert(res > 2, "expected active users");
+assert.eq(db.users.countDocuments({}), 5);
+assert.commandWorked(db.users.insertOne({ name: "Ada" }));
+assert(res);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 5 {
+		t.Fatalf("Expected 5 operations, got %d", len(ops))
+	}
+	if ops[0].Type != "count" || ops[0].Variable != "res" {
+		t.Errorf("Expected count bound to res, got %s bound to %q", ops[0].Type, ops[0].Variable)
+	}
+
+	cond := ops[1].Assertion
+	if cond.Comparator != ">" || cond.Operands[0].Variable != "res" || cond.Operands[1].Value != float64(2) {
+		t.Errorf("Unexpected assertion %+v", cond)
+	}
+	if cond.Message != "expected active users" {
+		t.Errorf("Expected message to be parsed, got %q", cond.Message)
+	}
+
+	if eq := ops[2].Assertion; eq.Comparator != "==" || eq.Operands[0].Operation == nil || eq.Operands[0].Operation.Type != "count" {
+		t.Errorf("Expected assert.eq with a count operand, got %+v", eq)
+	}
+	if worked := ops[3].Assertion; worked.ExpectWorked == nil || !*worked.ExpectWorked {
+		t.Errorf("Expected commandWorked assertion, got %+v", worked)
+	}
+	if truthiness := ops[4].Assertion; truthiness.Comparator != "" || len(truthiness.Operands) != 1 {
+		t.Errorf("Expected truthiness assertion, got %+v", truthiness)
+	}
+}
+
+func TestSplitComparison(t *testing.T) {
+	tests := []struct {
+		condition  string
+		left       string
+		comparator string
+		right      string
+		ok         bool
+	}{
+		{"res === 3", "res", "==", "3", true},
+		{"res.ok !== 0", "res.ok", "!=", "0", true},
+		{`db.users.countDocuments({ age: { $gt: 3 } }) >= 1`, `db.users.countDocuments({ age: { $gt: 3 } })`, ">=", "1", true},
+		{`name == "a<b"`, "name", "==", `"a<b"`, true},
+		{"res", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		left, comparator, right, ok := splitComparison(tt.condition)
+		if left != tt.left || comparator != tt.comparator || right != tt.right || ok != tt.ok {
+			t.Errorf("splitComparison(%q) = %q, %q, %q, %v", tt.condition, left, comparator, right, ok)
+		}
+	}
+}
+
+func TestExecuteAssert(t *testing.T) {
+	parser := NewParser()
+	run := *parser
+	run.variables = map[string]interface{}{"count": int64(3)}
+
+	tests := []struct {
+		statement string
+		pass      bool
+	}{
+		{`assert(count == 3)`, true},
+		{`assert.eq(count, 3)`, true},
+		{`assert.gt(count, 5, "too few")`, false},
+		{`assert(count)`, true},
+		{`assert(0, "zero")`, false},
+		{`assert.neq("a", "b")`, true},
+	}
+
+	for _, tt := range tests {
+		op, _, err := run.parseShellStatement(tt.statement)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.statement, err)
+		}
+		_, err = run.executeAssert(context.Background(), nil, *op)
+		var assertionErr *AssertionError
+		if tt.pass && err != nil {
+			t.Errorf("%s: expected to pass, got %v", tt.statement, err)
+		}
+		if !tt.pass && !errors.As(err, &assertionErr) {
+			t.Errorf("%s: expected an assertion error, got %v", tt.statement, err)
+		}
+	}
+
+	op, _, _ := run.parseShellStatement(`assert.gt(count, 5, "too few")`)
+	_, err := run.executeAssert(context.Background(), nil, *op)
+	if err == nil || err.Error() != "assertion failed: too few: expected count > 5, got 3 and 5" {
+		t.Errorf("Unexpected assertion message: %v", err)
+	}
+
+	op, _, _ = run.parseShellStatement(`assert(missing)`)
+	if _, err := run.executeAssert(context.Background(), nil, *op); err == nil {
+		t.Error("Expected undefined variable to fail")
+	}
+}
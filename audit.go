@@ -0,0 +1,147 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// Documents of an insert recorded in its audit entry; the rest are only counted
+	maxAuditDocuments = 10
+	// Replaces the values of sensitive fields in audit entries
+	redactedValue = "[REDACTED]"
+)
+
+// Field names whose values are never written to the audit log
+var defaultRedactedFields = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "credential"}
+
+// Records an executed operation in the audit collection. Audit failures are
+// reported as warnings and never fail the script.
+func (p *Parser) auditOperation(ctx context.Context, db *mongo.Database, result ScriptResult, op MongoOperation, opResult OperationResult) {
+	if p.auditCollection == "" {
+		return
+	}
+
+	entry := bson.D{
+		{Key: "executedAt", Value: time.Now()},
+		{Key: "actor", Value: p.auditActor},
+		{Key: "database", Value: db.Name()},
+		{Key: "script", Value: result.Name},
+		{Key: "version", Value: result.Version},
+		{Key: "type", Value: op.Type},
+		{Key: "operation", Value: op.Operation},
+		{Key: "collection", Value: op.Collection},
+		{Key: "arguments", Value: p.auditArguments(op)},
+		{Key: "status", Value: opResult.Status},
+		{Key: "durationMS", Value: durationMS(opResult.Duration)},
+	}
+	// Only the position of the statement is recorded: its text would bypass redaction
+	if op.Location != nil {
+		entry = append(entry, bson.E{Key: "lines", Value: bson.D{
+			{Key: "start", Value: op.Location.StartLine},
+			{Key: "end", Value: op.Location.EndLine},
+		}})
+	}
+	if opResult.Error != nil {
+		entry = append(entry, bson.E{Key: "error", Value: opResult.Error.Error()})
+	} else if opResult.Result != nil {
+		entry = append(entry, bson.E{Key: "result", Value: fmt.Sprint(opResult.Result)})
+	}
+
+	// Record the entry even when the script's context was cancelled
+	auditCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := db.Collection(p.auditCollection).InsertOne(auditCtx, entry); err != nil {
+		p.warnf("failed to write audit entry for %s on %s: %v", op.Operation, op.Collection, err)
+	}
+}
+
+// Collects the sanitized arguments of an operation for its audit entry
+func (p *Parser) auditArguments(op MongoOperation) bson.D {
+	var args bson.D
+
+	docs := op.Arguments
+	if op.Type == "insert" && len(docs) > maxAuditDocuments {
+		args = append(args, bson.E{Key: "documentCount", Value: len(docs)})
+		docs = docs[:maxAuditDocuments]
+	}
+	if len(docs) > 0 {
+		sanitized := make(bson.A, 0, len(docs))
+		for _, doc := range docs {
+			// Fields encrypted client-side must not show up in plain text either
+			for _, path := range p.encryptedFields[op.Collection] {
+				doc, _ = transformPath(doc, strings.Split(path, "."), func(interface{}) (interface{}, error) {
+					return redactedValue, nil
+				})
+			}
+			sanitized = append(sanitized, redact(doc))
+		}
+		args = append(args, bson.E{Key: "documents", Value: sanitized})
+	}
+	if len(op.UpdatePipeline) > 0 {
+		args = append(args, bson.E{Key: "pipeline", Value: op.UpdatePipeline})
+	}
+	if keys := indexKeys(op); len(keys) > 0 {
+		args = append(args, bson.E{Key: "indexKeys", Value: keys})
+	}
+	if op.Validator != nil {
+		args = append(args, bson.E{Key: "validator", Value: op.Validator})
+	}
+	return args
+}
+
+// Returns a copy of value with sensitive fields replaced at any depth
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		out := make(bson.D, 0, len(v))
+		for _, elem := range v {
+			if isSensitiveField(elem.Key) {
+				out = append(out, bson.E{Key: elem.Key, Value: redactedValue})
+				continue
+			}
+			out = append(out, bson.E{Key: elem.Key, Value: redact(elem.Value)})
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, 0, len(v))
+		for _, item := range v {
+			out = append(out, redact(item))
+		}
+		return out
+	case []interface{}:
+		return redact(bson.A(v))
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if isSensitiveField(key) {
+				out[key] = redactedValue
+				continue
+			}
+			out[key] = redact(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// Reports whether a field name looks like it holds a secret
+func isSensitiveField(key string) bool {
+	// Match the last path segment so "auth.password" is caught too
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		key = key[i+1:]
+	}
+	key = strings.ToLower(key)
+	for _, field := range defaultRedactedFields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package mongoparser
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAuditArguments(t *testing.T) {
+	parser := NewParser(WithAuditLog("audit", "deploy-bot"))
+
+	docs := make([]bson.D, 12)
+	for i := range docs {
+		docs[i] = bson.D{
+			{Key: "name", Value: "user"},
+			{Key: "password", Value: "hunter2"},
+			{Key: "auth", Value: bson.D{{Key: "apiKey", Value: "abc"}, {Key: "provider", Value: "local"}}},
+		}
+	}
+	op := MongoOperation{Type: "insert", Operation: "insertMany", Collection: "users", Arguments: docs}
+
+	args := parser.auditArguments(op)
+	count, _ := lookupKey(args, "documentCount")
+	if count != 12 {
+		t.Errorf("Expected document count 12, got %v", count)
+	}
+
+	value, _ := lookupKey(args, "documents")
+	recorded := value.(bson.A)
+	if len(recorded) != maxAuditDocuments {
+		t.Fatalf("Expected %d recorded documents, got %d", maxAuditDocuments, len(recorded))
+	}
+
+	first := recorded[0].(bson.D)
+	if password, _ := lookupKey(first, "password"); password != redactedValue {
+		t.Errorf("Expected password to be redacted, got %v", password)
+	}
+	auth, _ := lookupKey(first, "auth")
+	if apiKey, _ := lookupKey(auth.(bson.D), "apiKey"); apiKey != redactedValue {
+		t.Errorf("Expected nested apiKey to be redacted, got %v", apiKey)
+	}
+	if provider, _ := lookupKey(auth.(bson.D), "provider"); provider != "local" {
+		t.Errorf("Expected provider to be kept, got %v", provider)
+	}
+
+	// The operation's own documents must stay untouched
+	if password, _ := lookupKey(docs[0], "password"); password != "hunter2" {
+		t.Errorf("Expected original document to be unchanged, got %v", password)
+	}
+}
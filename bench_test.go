@@ -0,0 +1,78 @@
+package mongoparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Builds a seed script with n insertOne statements
+func benchmarkScript(n int) string {
+	var b strings.Builder
+	b.WriteString("db.createCollection(\"users\", { validator: { $jsonSchema: { bsonType: \"object\", required: [\"name\"] } } });\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "db.users.insertOne({\n  name: 'user %d',\n  email: \"user%d@example.com\",\n  tags: [\"a\", \"b\",],\n  profile: { age: %d, active: true },\n});\n", i, i, i%90)
+	}
+	return b.String()
+}
+
+func TestAddQuotesToKeys(t *testing.T) {
+	parser := NewParser()
+
+	tests := map[string]string{
+		`{ name: 1, $set: { "a.b": true } }`: `{ "name": 1, "$set": { "a.b": true} }`,
+		`{ label: "key: value", n: null }`:   `{ "label": "key: value", "n": null}`,
+		`{ _id :1 }`:                         `{ "_id":1 }`,
+	}
+	for input, expected := range tests {
+		if got := parser.addQuotesToKeys(input); got != expected {
+			t.Errorf("addQuotesToKeys(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func BenchmarkSplitIntoStatements(b *testing.B) {
+	parser := NewParser()
+	script := benchmarkScript(10000)
+	b.SetBytes(int64(len(script)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		parser.splitIntoStatements(script)
+	}
+}
+
+func BenchmarkAddQuotesToKeys(b *testing.B) {
+	parser := NewParser()
+	script := benchmarkScript(10000)
+	b.SetBytes(int64(len(script)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		parser.addQuotesToKeys(script)
+	}
+}
+
+func BenchmarkSplitArguments(b *testing.B) {
+	parser := NewParser()
+	args := `{ status: "archived", "tags": ["a", "b", "c"] }, { $set: { archived: true, reason: 'cleanup, old' } }, { upsert: true }`
+	b.SetBytes(int64(len(args)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		parser.splitArguments(args)
+	}
+}
+
+func BenchmarkParseJavaScriptOperations(b *testing.B) {
+	parser := NewParser()
+	script := benchmarkScript(1000)
+	b.SetBytes(int64(len(script)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.parseJavaScriptOperations(script); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
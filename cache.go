@@ -0,0 +1,158 @@
+package mongoparser
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"strings"
+	"sync"
+)
+
+// Hit and miss counters of the parsed-script cache
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// A fully parsed script as stored in the cache
+type parsedScript struct {
+	leading    string
+	blank      bool
+	operations []MongoOperation
+	warnings   []string
+	readErr    error
+}
+
+// Least-recently-used cache of parsed scripts keyed by content checksum
+type parseCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	key    [sha256.Size]byte
+	script *parsedScript
+}
+
+// Creates a cache holding at most size parsed scripts
+func newParseCache(size int) *parseCache {
+	return &parseCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Returns the cached script for key and marks it as recently used
+func (c *parseCache) get(key [sha256.Size]byte) (*parsedScript, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).script, true
+}
+
+// Stores a parsed script, evicting the least recently used one when full
+func (c *parseCache) add(key [sha256.Size]byte, script *parsedScript) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).script = script
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, script: script})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Returns a snapshot of the cache counters
+func (c *parseCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+// Returns parse cache statistics; all zero when caching is disabled
+func (p *Parser) CacheStats() CacheStats {
+	if p.cache == nil {
+		return CacheStats{}
+	}
+	return p.cache.stats()
+}
+
+// Returns the parsed form of a script from the cache, parsing and storing it on
+// a miss. Warnings raised while parsing are replayed on every execution.
+func (p *Parser) cachedScript(jsContent string) *parsedScript {
+	key := sha256.Sum256([]byte(jsContent))
+	if script, ok := p.cache.get(key); ok {
+		for _, warning := range script.warnings {
+			p.warnf("%s", warning)
+		}
+		return script
+	}
+
+	var before int
+	if p.warnings != nil {
+		before = len(*p.warnings)
+	}
+
+	script := p.parseContent(jsContent)
+	if p.warnings != nil {
+		script.warnings = append([]string(nil), (*p.warnings)[before:]...)
+	}
+
+	p.cache.add(key, script)
+	return script
+}
+
+// Parses a whole script up front
+func (p *Parser) parseContent(jsContent string) *parsedScript {
+	source := &statementSource{parser: p, scanner: newStatementScanner(strings.NewReader(jsContent))}
+	script := &parsedScript{leading: source.header(), blank: source.empty()}
+	for op := source.next(); op != nil; op = source.next() {
+		script.operations = append(script.operations, *op)
+	}
+	script.readErr = source.err()
+	return script
+}
+
+// Returns a fresh cursor over the cached operations
+func (s *parsedScript) source() operationSource {
+	return &cachedSource{script: s}
+}
+
+// Iterates a cached script; operations are copied so executions cannot affect each other
+type cachedSource struct {
+	script   *parsedScript
+	position int
+}
+
+func (s *cachedSource) header() string { return s.script.leading }
+func (s *cachedSource) empty() bool    { return s.script.blank }
+func (s *cachedSource) err() error     { return s.script.readErr }
+
+// Returns a copy of the next cached operation
+func (s *cachedSource) next() *MongoOperation {
+	if s.position >= len(s.script.operations) {
+		return nil
+	}
+	op := s.script.operations[s.position]
+	s.position++
+	return &op
+}
@@ -0,0 +1,75 @@
+package mongoparser
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestParseCacheEviction(t *testing.T) {
+	cache := newParseCache(2)
+	a, b, c := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b")), sha256.Sum256([]byte("c"))
+
+	cache.add(a, &parsedScript{})
+	cache.add(b, &parsedScript{})
+	if _, ok := cache.get(a); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	cache.add(c, &parsedScript{})
+
+	if _, ok := cache.get(b); ok {
+		t.Error("Expected least recently used entry b to be evicted")
+	}
+	if _, ok := cache.get(a); !ok {
+		t.Error("Expected recently used entry a to survive eviction")
+	}
+
+	stats := cache.stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCachedScript(t *testing.T) {
+	parser := NewParser(WithParseCache(10))
+	script := `// METADATA:
+// { "name": "tenant_setup" }
+db.users.createIndex({ email: 1 }, { unique: true });
+db.users.renameCollection("people");
+db.users.insertOne({ name: "admin" });`
+
+	run := *parser
+	run.warnings = &[]string{}
+	first := run.cachedScript(script)
+	if len(first.operations) != 2 || len(*run.warnings) != 1 {
+		t.Fatalf("Expected 2 operations and 1 warning, got %d and %v", len(first.operations), *run.warnings)
+	}
+
+	run.warnings = &[]string{}
+	second := run.cachedScript(script)
+	if second != first {
+		t.Error("Expected the second lookup to return the cached script")
+	}
+	if len(*run.warnings) != 1 {
+		t.Errorf("Expected parse warnings to be replayed, got %v", *run.warnings)
+	}
+
+	// Cursors hand out copies so executions cannot modify the cached operations
+	source := second.source()
+	op := source.next()
+	op.Collection = "changed"
+	if second.operations[0].Collection != "users" {
+		t.Error("Expected cached operation to be unaffected by changes to its copy")
+	}
+
+	parser.ExecuteScript(context.Background(), nil, "// METADATA:\n// { \"name\": \"noop\" }\n")
+	result := parser.ExecuteScript(context.Background(), nil, "// METADATA:\n// { \"name\": \"noop\" }\n")
+	if !result.Success || result.Name != "noop" {
+		t.Errorf("Expected cached comment-only script to succeed, got %+v", result)
+	}
+
+	stats := parser.CacheStats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
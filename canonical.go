@@ -0,0 +1,165 @@
+package mongoparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Returns a canonical text form of an operation that depends only on what the
+// operation does, not on how its statement was written: whitespace, key
+// quoting, quote style, equivalent number spellings and the statement's
+// position all produce the same form. Unset fields are left out so the form stays stable as fields are added.
+func CanonicalOperation(op MongoOperation) string {
+	var b strings.Builder
+	writeCanonical(&b, reflect.ValueOf(op))
+	return b.String()
+}
+
+// Returns the SHA-256 of an operation's canonical form as hex
+func OperationHash(op MongoOperation) string {
+	sum := sha256.Sum256([]byte(CanonicalOperation(op)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the SHA-256 of the canonical forms of a script's operations, which
+// is the same for scripts that differ only in formatting and comments. It
+// fails when a statement can't be parsed, since its content would not count.
+func (p *Parser) CanonicalChecksum(js string) (string, error) {
+	ops, err := p.ParseScript(js)
+	if err != nil {
+		return "", fmt.Errorf("cannot compute canonical checksum: %w", err)
+	}
+
+	hash := sha256.New()
+	for _, op := range ops {
+		hash.Write([]byte(CanonicalOperation(op)))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+var (
+	documentType  = reflect.TypeOf(bson.D{})
+	operationType = reflect.TypeOf(MongoOperation{})
+	timeType      = reflect.TypeOf(time.Time{})
+	stringerType  = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// Writes the canonical form of a value: documents keep their key order, maps
+// are sorted by key, and structs list their set exported fields
+func writeCanonical(b *strings.Builder, v reflect.Value) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			b.WriteString("null")
+			return
+		}
+		if v.Kind() == reflect.Pointer && v.Type().Implements(stringerType) && !hasExportedFields(v.Elem()) {
+			b.WriteString(strconv.Quote(v.Interface().(fmt.Stringer).String()))
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == documentType:
+		b.WriteByte('{')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			elem := v.Index(i).Interface().(bson.E)
+			b.WriteString(strconv.Quote(elem.Key))
+			b.WriteByte(':')
+			writeCanonical(b, reflect.ValueOf(&elem.Value).Elem())
+		}
+		b.WriteByte('}')
+		return
+	case v.Type() == timeType:
+		b.WriteString(strconv.Quote(v.Interface().(time.Time).UTC().Format(time.RFC3339Nano)))
+		return
+	case v.Type().Implements(stringerType) && !hasExportedFields(v):
+		// Durations, ObjectIDs, decimals and the like
+		b.WriteString(strconv.Quote(v.Interface().(fmt.Stringer).String()))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		b.WriteByte('{')
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || v.Field(i).IsZero() || (v.Type() == operationType && (field.Name == "Statement" || field.Name == "Location" || field.Name == "Comment")) {
+				continue
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			b.WriteString(field.Name)
+			b.WriteByte(':')
+			writeCanonical(b, v.Field(i))
+		}
+		b.WriteByte('}')
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(fmt.Sprint(key)))
+			b.WriteByte(':')
+			writeCanonical(b, v.MapIndex(key))
+		}
+		b.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			b.WriteString(strconv.Quote(hex.EncodeToString(v.Bytes())))
+			return
+		}
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, v.Index(i))
+		}
+		b.WriteByte(']')
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	case reflect.Float32, reflect.Float64:
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	default:
+		// Functions and channels carry no script content
+		b.WriteString("null")
+	}
+}
+
+// Reports whether a struct has exported fields to describe it by
+func hasExportedFields(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestCanonicalChecksum(t *testing.T) {
+	parser := NewParser()
+	original := `// METADATA:
+// {"name": "users", "version": "1.0.0"}
+db.users.createIndex({ email: 1 }, { unique: true, name: "email_unique" });
+db.users.insertOne({ name: "Ana", age: 30 });`
+
+	equivalent := []string{
+		// Whitespace, key quoting, quote style and comments
+		`db.users.createIndex( {"email":1},{ "unique" : true, 'name': 'email_unique' } );
+// seed one user
+db.users.insertOne({
+  "name": "Ana",
+  "age": 30.0,
+});`,
+		// Metadata changes don't change what runs
+		"// METADATA:\n// {\"name\": \"users\", \"version\": \"1.0.1\"}\n" + original,
+	}
+	different := []string{
+		strings.Replace(original, "age: 30", "age: 31", 1),
+		strings.Replace(original, "email: 1", "email: -1", 1),
+		strings.Replace(original, "name: \"Ana\", age: 30", "age: 30, name: \"Ana\"", 1),
+		"// mongoparser: continue-on-error\n" + original,
+	}
+
+	expected, err := parser.CanonicalChecksum(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range equivalent {
+		if got, err := parser.CanonicalChecksum(script); err != nil || got != expected {
+			t.Errorf("Expected the same checksum for:\n%s\n(%v)", script, err)
+		}
+	}
+	for _, script := range different {
+		if got, err := parser.CanonicalChecksum(script); err != nil || got == expected {
+			t.Errorf("Expected a different checksum for:\n%s\n(%v)", script, err)
+		}
+	}
+
+	if _, err := parser.CanonicalChecksum(`db.users.insertOne({ name: "Ana" ]);`); err == nil {
+		t.Error("Expected a statement that fails to parse to prevent a canonical checksum")
+	}
+}
+
+func TestCanonicalOperation(t *testing.T) {
+	five, _ := primitive.ParseDecimal128("5")
+	op := MongoOperation{
+		Type:           "insert",
+		Collection:     "users",
+		Operation:      "insertOne",
+		Arguments:      []bson.D{{{Key: "b", Value: int32(1)}, {Key: "a", Value: bson.A{five, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}}}},
+		ReadPreference: readpref.SecondaryPreferred(),
+		Statement:      "db.users.insertOne(...)",
+	}
+	expected := `{Type:"insert",Collection:"users",Operation:"insertOne",Arguments:[{"b":1,"a":["5","2024-01-02T03:04:05Z"]}],ReadPreference:"secondaryPreferred"}`
+	if got := CanonicalOperation(op); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+
+	op.Arguments[0][0].Value = 1.0
+	op.Statement = "db.users.insertOne({ b: 1.0, ... })"
+	if got := CanonicalOperation(op); got != expected {
+		t.Errorf("Expected numbers and statement text not to matter, got %s", got)
+	}
+	if len(OperationHash(op)) != 64 {
+		t.Error("Expected a hex-encoded SHA-256 hash")
+	}
+}
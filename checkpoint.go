@@ -0,0 +1,145 @@
+package mongoparser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Progress of a script that failed part way, persisted so the next run resumes there
+type checkpoint struct {
+	Name string `bson:"_id"`
+	// Number of operations that completed before the failure
+	Completed int `bson:"completed"`
+	// Checksum of the statements of the completed operations
+	Checksum string    `bson:"checksum"`
+	FailedAt time.Time `bson:"failedAt"`
+	Error    string    `bson:"error"`
+}
+
+// Tracks the operations of one execution against its checkpoint. A nil tracker
+// disables checkpointing.
+type checkpointTracker struct {
+	parser     *Parser
+	collection *mongo.Collection
+	name       string
+	resume     *checkpoint
+	position   int
+	prefix     hash.Hash
+}
+
+// Loads the checkpoint of a named script. Scripts without a metadata name
+// can't be matched across runs and execute without checkpoints.
+func (p *Parser) openCheckpoints(ctx context.Context, db *mongo.Database, name string) (*checkpointTracker, error) {
+	if p.checkpointCollection == "" {
+		return nil, nil
+	}
+	if name == "" {
+		p.warnf("script has no metadata name, executing without checkpoints")
+		return nil, nil
+	}
+
+	tracker := &checkpointTracker{
+		parser:     p,
+		collection: db.Collection(p.checkpointCollection),
+		name:       name,
+		prefix:     sha256.New(),
+	}
+
+	var saved checkpoint
+	err := tracker.collection.FindOne(ctx, bson.D{{Key: "_id", Value: name}}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return tracker, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint for %s: %w", name, err)
+	}
+
+	tracker.resume = &saved
+	return tracker, nil
+}
+
+// Reports whether op completed in a previous run and must be skipped. Once the
+// checkpoint is reached the completed statements are compared with the ones
+// the checkpoint was taken for, so an edited script is not resumed blindly.
+func (t *checkpointTracker) skip(op *MongoOperation) (bool, error) {
+	if t == nil || t.resume == nil {
+		return false, nil
+	}
+	if t.position < t.resume.Completed {
+		t.advance(op)
+		return true, nil
+	}
+	if err := t.verify(); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// Checks that the skipped statements match the checkpoint and stops resuming
+func (t *checkpointTracker) verify() error {
+	if t == nil || t.resume == nil {
+		return nil
+	}
+	if t.position < t.resume.Completed {
+		return fmt.Errorf("script %s has %d operations but its checkpoint expects at least %d", t.name, t.position, t.resume.Completed)
+	}
+	if checksum := hex.EncodeToString(t.prefix.Sum(nil)); checksum != t.resume.Checksum {
+		return fmt.Errorf("script %s changed before its checkpoint at operation %d; remove the checkpoint to run it from the start", t.name, t.resume.Completed+1)
+	}
+	t.parser.warnf("resuming %s after %d operations completed in a previous run", t.name, t.resume.Completed)
+	t.resume = nil
+	return nil
+}
+
+// Records a completed operation
+func (t *checkpointTracker) advance(op *MongoOperation) {
+	if t == nil {
+		return
+	}
+	t.prefix.Write([]byte(op.Statement))
+	t.prefix.Write([]byte{'\n'})
+	t.position++
+}
+
+// Persists the checkpoint after an operation failed
+func (t *checkpointTracker) fail(ctx context.Context, cause error) {
+	if t == nil {
+		return
+	}
+
+	saved := checkpoint{
+		Name:      t.name,
+		Completed: t.position,
+		Checksum:  hex.EncodeToString(t.prefix.Sum(nil)),
+		FailedAt:  time.Now(),
+		Error:     cause.Error(),
+	}
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := t.collection.ReplaceOne(saveCtx, bson.D{{Key: "_id", Value: t.name}}, saved, options.Replace().SetUpsert(true)); err != nil {
+		t.parser.warnf("failed to save checkpoint for %s: %v", t.name, err)
+	}
+}
+
+// Removes the checkpoint once the script completed
+func (t *checkpointTracker) finish(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	if err := t.verify(); err != nil {
+		return err
+	}
+	if _, err := t.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: t.name}}); err != nil {
+		t.parser.warnf("failed to remove checkpoint for %s: %v", t.name, err)
+	}
+	return nil
+}
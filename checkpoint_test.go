@@ -0,0 +1,58 @@
+package mongoparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointTrackerResume(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+db.users.insertOne({ name: "a" });
+db.users.insertOne({ name: "b" });
+db.users.insertOne({ name: "c" });`)
+	if err != nil || len(ops) != 3 {
+		t.Fatalf("Expected 3 operations, got %d (%v)", len(ops), err)
+	}
+
+	// Checkpoint taken after the first two operations completed
+	recorded := &checkpointTracker{parser: parser, name: "seed", prefix: sha256.New()}
+	recorded.advance(&ops[0])
+	recorded.advance(&ops[1])
+	saved := &checkpoint{Name: "seed", Completed: 2, Checksum: hex.EncodeToString(recorded.prefix.Sum(nil))}
+
+	tracker := &checkpointTracker{parser: parser, name: "seed", resume: saved, prefix: sha256.New()}
+	for i, expected := range []bool{true, true, false} {
+		skip, err := tracker.skip(&ops[i])
+		if err != nil {
+			t.Fatalf("skip(%d) failed: %v", i, err)
+		}
+		if skip != expected {
+			t.Errorf("skip(%d) = %t, expected %t", i, skip, expected)
+		}
+	}
+
+	// An edited statement before the checkpoint must not be resumed
+	edited := ops[1]
+	edited.Statement = `db.users.insertOne({ name: "B" });`
+	tracker = &checkpointTracker{parser: parser, name: "seed", resume: saved, prefix: sha256.New()}
+	tracker.skip(&ops[0])
+	tracker.skip(&edited)
+	if _, err := tracker.skip(&ops[2]); err == nil || !strings.Contains(err.Error(), "changed before its checkpoint") {
+		t.Errorf("Expected edited script to be rejected, got %v", err)
+	}
+
+	// A script shorter than its checkpoint is rejected when it finishes
+	tracker = &checkpointTracker{parser: parser, name: "seed", resume: saved, prefix: sha256.New()}
+	tracker.skip(&ops[0])
+	if err := tracker.verify(); err == nil {
+		t.Error("Expected a script shorter than its checkpoint to be rejected")
+	}
+
+	var disabled *checkpointTracker
+	if skip, err := disabled.skip(&ops[0]); skip || err != nil {
+		t.Errorf("Expected nil tracker to never skip, got %t, %v", skip, err)
+	}
+}
@@ -0,0 +1,116 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// Documents sent per insert call unless configured otherwise
+	defaultInsertChunkSize = 1000
+	// Payload budget per insert call, leaving headroom below the 16MB message
+	// limit for the command envelope
+	maxInsertChunkBytes = 16*1024*1024 - 64*1024
+)
+
+// A half-open range of documents sent in one insert call
+type chunk struct {
+	start, end int
+}
+
+// Splits documents into consecutive chunks of at most size documents and
+// maxInsertChunkBytes bytes. A document larger than the byte budget gets a
+// chunk of its own and is left for the server to accept or reject.
+func chunkDocuments(docs []bson.D, size int) []chunk {
+	if size <= 0 {
+		size = defaultInsertChunkSize
+	}
+
+	var chunks []chunk
+	start, bytes := 0, 0
+	for i, doc := range docs {
+		docBytes := documentSize(doc)
+		if i > start && (i-start >= size || bytes+docBytes > maxInsertChunkBytes) {
+			chunks = append(chunks, chunk{start: start, end: i})
+			start, bytes = i, 0
+		}
+		bytes += docBytes
+	}
+	if start < len(docs) {
+		chunks = append(chunks, chunk{start: start, end: len(docs)})
+	}
+	return chunks
+}
+
+// Returns the encoded BSON size of a document, or 0 if it cannot be encoded
+// (the driver reports the encoding error when the document is sent)
+func documentSize(doc bson.D) int {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return 0
+	}
+	return len(raw)
+}
+
+// Reports insert progress to the configured callback, if any
+func (p *Parser) reportInsertProgress(op MongoOperation, done, total int) {
+	if p.insertProgress != nil {
+		p.insertProgress(op, done, total)
+	}
+}
+
+// Inserts the documents next produces in chunks of the configured size, so
+// generated or streamed documents never sit in memory at once. next returns
+// io.EOF once exhausted; total is the expected number of documents, or 0 when
+// unknown, and is passed on to the progress callback.
+func (p *Parser) insertStream(ctx context.Context, db *mongo.Database, op MongoOperation, total int, next func() (bson.D, error)) (int64, error) {
+	size := p.insertChunkSize
+	if size <= 0 {
+		size = defaultInsertChunkSize
+	}
+
+	// Progress is reported for the whole stream rather than per chunk
+	batchRun := *p
+	batchRun.insertProgress = nil
+
+	var inserted int64
+	for done := false; !done; {
+		batch := MongoOperation{
+			Type:         "insert",
+			Collection:   op.Collection,
+			Operation:    "insertMany",
+			WriteConcern: op.WriteConcern,
+		}
+		for len(batch.Arguments) < size {
+			doc, err := next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err != nil {
+				return inserted, fmt.Errorf("document %d: %w", inserted+int64(len(batch.Arguments))+1, err)
+			}
+			batch.Arguments = append(batch.Arguments, doc)
+		}
+		if len(batch.Arguments) == 0 {
+			break
+		}
+
+		if inserted > 0 {
+			if err := p.throttle(ctx, false); err != nil {
+				return inserted, err
+			}
+		}
+		if _, err := batchRun.executeInsert(ctx, db, batch); err != nil {
+			return inserted, fmt.Errorf("failed to insert documents %d-%d: %w", inserted+1, inserted+int64(len(batch.Arguments)), err)
+		}
+		inserted += int64(len(batch.Arguments))
+		p.reportInsertProgress(op, int(inserted), total)
+	}
+	return inserted, nil
+}
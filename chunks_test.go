@@ -0,0 +1,32 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestChunkDocuments(t *testing.T) {
+	docs := make([]bson.D, 2500)
+	for i := range docs {
+		docs[i] = bson.D{{Key: "n", Value: i}}
+	}
+
+	chunks := chunkDocuments(docs, 0)
+	if len(chunks) != 3 || chunks[0] != (chunk{0, 1000}) || chunks[2] != (chunk{2000, 2500}) {
+		t.Errorf("Unexpected chunks by count: %v", chunks)
+	}
+
+	// Four ~6MB documents must not share a chunk beyond the message budget
+	large := strings.Repeat("x", 6*1024*1024)
+	big := []bson.D{{{Key: "a", Value: large}}, {{Key: "b", Value: large}}, {{Key: "c", Value: large}}, {{Key: "d", Value: large}}}
+	chunks = chunkDocuments(big, 100)
+	if len(chunks) != 2 || chunks[0] != (chunk{0, 2}) || chunks[1] != (chunk{2, 4}) {
+		t.Errorf("Unexpected chunks by size: %v", chunks)
+	}
+
+	if chunks := chunkDocuments(nil, 10); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for no documents, got %v", chunks)
+	}
+}
@@ -0,0 +1,55 @@
+// Command migrationgen compares two versions of a schema script and writes
+// the migration moving a database from the first to the second:
+//
+//	git show HEAD~1:schema.js > /tmp/schema-old.js
+//	migrationgen -out migrations/004_schema.js /tmp/schema-old.js schema.js
+//
+// Dropped collections are marked DESTRUCTIVE; the parser refuses them when
+// the migration runs unless they are allowed or confirmed.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+)
+
+func main() {
+	out := flag.String("out", "", "file to write; standard output when empty")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("migrationgen: ")
+	if flag.NArg() != 2 {
+		log.Fatal("usage: migrationgen [-out file] old.js new.js")
+	}
+
+	var scripts [2]string
+	for i, name := range flag.Args() {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		scripts[i] = string(content)
+	}
+
+	migration, err := mongoparser.NewParser().GenerateMigration(scripts[0], scripts[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if migration == "" {
+		log.Print("schemas are identical; nothing to migrate")
+		return
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.WriteString(migration)
+	} else {
+		err = os.WriteFile(*out, []byte(migration), 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
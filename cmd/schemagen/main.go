@@ -0,0 +1,73 @@
+// Command schemagen generates code from the collection validators declared by
+// migration scripts. Run it from go:generate next to the models it writes:
+//
+//	//go:generate go run github.com/artumont/MongoDBParser/cmd/schemagen -dir ../migrations -out models_gen.go "**/*.js"
+//
+// With -lang ts it writes TypeScript declarations instead, and with -lang
+// mermaid an entity-relationship diagram:
+//
+//	schemagen -dir migrations -lang ts -out web/src/models.d.ts "**/*.js"
+//
+// Patterns are matched below -dir, and scripts are read in the order
+// Runner.Discover uses.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory the script patterns are relative to")
+	lang := flag.String("lang", "go", "language to generate: go, ts or mermaid")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated Go file")
+	out := flag.String("out", "", "file to write; standard output when empty")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("schemagen: ")
+	if flag.NArg() == 0 {
+		log.Fatal("no script patterns given")
+	}
+
+	scripts, err := mongoparser.LoadScripts(os.DirFS(*dir), flag.Args()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	registry, err := mongoparser.NewParser().SchemaRegistry(scripts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	switch *lang {
+	case "go":
+		if *pkg == "" {
+			log.Fatal("-package is required outside go generate")
+		}
+		err = registry.WriteGo(&buf, *pkg)
+	case "ts":
+		err = registry.WriteTypeScript(&buf)
+	case "mermaid":
+		err = registry.WriteMermaid(&buf)
+	default:
+		err = fmt.Errorf("unsupported language %q", *lang)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*out, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
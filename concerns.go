@@ -0,0 +1,173 @@
+package mongoparser
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Read and write concerns applied to operations that do not set their own
+type concernDefaults struct {
+	writeConcern   *writeconcern.WriteConcern
+	readConcern    *readconcern.ReadConcern
+	readPreference *readpref.ReadPref
+}
+
+// Reads writeConcern, readConcern and readPreference from a statement options document
+func (p *Parser) parseConcernOptions(opts map[string]interface{}, op *MongoOperation) error {
+	if value, ok := opts["writeConcern"]; ok {
+		wc, err := p.parseWriteConcern(value)
+		if err != nil {
+			return err
+		}
+		op.WriteConcern = wc
+	}
+	if value, ok := opts["readConcern"]; ok {
+		rc, err := p.parseReadConcern(value)
+		if err != nil {
+			return err
+		}
+		op.ReadConcern = rc
+	}
+	if value, ok := opts["readPreference"]; ok {
+		rp, err := p.parseReadPreference(value)
+		if err != nil {
+			return err
+		}
+		op.ReadPreference = rp
+	}
+	return nil
+}
+
+// Converts a { w, j, wtimeout } document into a driver write concern
+func (p *Parser) parseWriteConcern(value interface{}) (*writeconcern.WriteConcern, error) {
+	doc, ok := asMap(value)
+	if !ok {
+		return nil, fmt.Errorf("writeConcern must be a document")
+	}
+
+	wc := &writeconcern.WriteConcern{}
+	if w, ok := doc["w"]; ok {
+		switch v := w.(type) {
+		case string:
+			wc.W = v
+		default:
+			num, err := p.convertToNumber(v)
+			if err != nil {
+				return nil, fmt.Errorf("writeConcern w must be a string or an integer")
+			}
+			wInt, ok := num.(int)
+			if !ok {
+				return nil, fmt.Errorf("writeConcern w must be a string or an integer")
+			}
+			wc.W = wInt
+		}
+	}
+	if j, ok := doc["j"]; ok {
+		journal, ok := j.(bool)
+		if !ok {
+			return nil, fmt.Errorf("writeConcern j must be a boolean")
+		}
+		wc.Journal = &journal
+	}
+	if timeout, ok := doc["wtimeout"]; ok {
+		ms, err := p.convertToInt64(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid writeConcern wtimeout: %w", err)
+		}
+		wc.WTimeout = time.Duration(ms) * time.Millisecond
+	}
+
+	return wc, nil
+}
+
+// Converts a { level } document or a bare level string into a driver read concern
+func (p *Parser) parseReadConcern(value interface{}) (*readconcern.ReadConcern, error) {
+	level, ok := value.(string)
+	if !ok {
+		doc, isDoc := asMap(value)
+		if !isDoc {
+			return nil, fmt.Errorf("readConcern must be a document or a level string")
+		}
+		level, ok = doc["level"].(string)
+		if !ok {
+			return nil, fmt.Errorf("readConcern requires a level")
+		}
+	}
+
+	switch level {
+	case "local", "available", "majority", "linearizable", "snapshot":
+		return &readconcern.ReadConcern{Level: level}, nil
+	default:
+		return nil, fmt.Errorf("unsupported readConcern level '%s'", level)
+	}
+}
+
+// Converts a read preference mode string into a driver read preference
+func (p *Parser) parseReadPreference(value interface{}) (*readpref.ReadPref, error) {
+	modeStr, ok := value.(string)
+	if !ok {
+		doc, isDoc := asMap(value)
+		if !isDoc {
+			return nil, fmt.Errorf("readPreference must be a mode string or a document")
+		}
+		modeStr, ok = doc["mode"].(string)
+		if !ok {
+			return nil, fmt.Errorf("readPreference requires a mode")
+		}
+	}
+
+	mode, err := readpref.ModeFromString(modeStr)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported readPreference '%s'", modeStr)
+	}
+	return readpref.New(mode)
+}
+
+// Builds script-level concern defaults from metadata
+func (p *Parser) parseConcernDefaults(metadata *ScriptMetadata) (concernDefaults, error) {
+	var defaults concernDefaults
+	if metadata == nil {
+		return defaults, nil
+	}
+
+	if metadata.WriteConcern != nil {
+		wc, err := p.parseWriteConcern(metadata.WriteConcern)
+		if err != nil {
+			return defaults, fmt.Errorf("invalid metadata write_concern: %w", err)
+		}
+		defaults.writeConcern = wc
+	}
+	if metadata.ReadConcern != "" {
+		rc, err := p.parseReadConcern(metadata.ReadConcern)
+		if err != nil {
+			return defaults, fmt.Errorf("invalid metadata read_concern: %w", err)
+		}
+		defaults.readConcern = rc
+	}
+	if metadata.ReadPreference != "" {
+		rp, err := p.parseReadPreference(metadata.ReadPreference)
+		if err != nil {
+			return defaults, fmt.Errorf("invalid metadata read_preference: %w", err)
+		}
+		defaults.readPreference = rp
+	}
+
+	return defaults, nil
+}
+
+// Fills in concerns the operation did not set explicitly
+func (d concernDefaults) apply(op *MongoOperation) {
+	if op.WriteConcern == nil {
+		op.WriteConcern = d.writeConcern
+	}
+	if op.ReadConcern == nil {
+		op.ReadConcern = d.readConcern
+	}
+	if op.ReadPreference == nil {
+		op.ReadPreference = d.readPreference
+	}
+}
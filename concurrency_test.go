@@ -0,0 +1,39 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestParserConcurrentUse(t *testing.T) {
+	parser := NewParser(WithParseCache(4), WithRateLimit(1e6))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Unsupported statements produce per-execution warnings without touching the database
+			script := fmt.Sprintf("// METADATA:\n// { \"name\": \"tenant_%d\" }\ndb.tenant_%d.renameCollection(\"archived\");\n", i%6, i%6)
+			for j := 0; j < 20; j++ {
+				result := parser.ExecuteScript(context.Background(), nil, script)
+				if !result.Success || result.Name != fmt.Sprintf("tenant_%d", i%6) {
+					t.Errorf("Unexpected result: %+v", result)
+					return
+				}
+				if len(result.Warnings) != 1 {
+					t.Errorf("Expected exactly this execution's warning, got %v", result.Warnings)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := parser.CacheStats()
+	if stats.Hits+stats.Misses != 16*20 {
+		t.Errorf("Expected every execution to consult the cache, got %+v", stats)
+	}
+}
@@ -0,0 +1,269 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Checks the order of the whole script before running it: operations that
+// index or write to a collection before the script's createCollection for it
+// would create the collection implicitly, without its validator and options,
+// and indexes on collections that are never created and don't exist usually
+// mean a misspelt name. Both fail the script with a *DependencyError. With
+// reorder, createCollection statements are moved ahead of the first operation
+// that needs them instead, unless the collection is dropped in between.
+func WithDependencyCheck(reorder bool) Option {
+	return func(p *Parser) {
+		p.dependencyCheck = true
+		p.reorderDependencies = reorder
+	}
+}
+
+// An operation that uses a collection before, or without, the script creating it
+type DependencyIssue struct {
+	Operation  string
+	Collection string
+	// Statement using the collection
+	Location *SourceLocation
+	// createCollection statement further down the script, or nil when the
+	// script never creates the collection and it doesn't exist
+	CreatedAt *SourceLocation
+}
+
+// Describes the issue, with the line of the statement when known
+func (i DependencyIssue) String() string {
+	var b strings.Builder
+	if i.Location != nil {
+		fmt.Fprintf(&b, "%s: ", i.Location)
+	}
+	if i.CreatedAt == nil {
+		fmt.Fprintf(&b, "%s on %s, which is never created and doesn't exist", i.Operation, i.Collection)
+	} else {
+		fmt.Fprintf(&b, "%s on %s runs before the collection is created", i.Operation, i.Collection)
+		if i.CreatedAt.StartLine > 0 {
+			fmt.Fprintf(&b, " on line %d", i.CreatedAt.StartLine)
+		}
+	}
+	return b.String()
+}
+
+// Lists the operations of a script that depend on collections in the wrong order
+type DependencyError struct {
+	Issues []DependencyIssue
+}
+
+// Formats every issue, separated by semicolons
+func (e *DependencyError) Error() string {
+	lines := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		lines = append(lines, issue.String())
+	}
+	return fmt.Sprintf("script has %d operation ordering problems: %s", len(e.Issues), strings.Join(lines, "; "))
+}
+
+// Operation types that implicitly create the collection they write to
+var implicitCreateTypes = map[string]bool{
+	"insert": true, "seed": true, "import": true, "update": true, "findAndModify": true,
+}
+
+// Operation types that define indexes on an existing or implicitly created collection
+var indexTypes = map[string]bool{
+	"createIndex": true, "createIndexes": true, "createSearchIndex": true,
+}
+
+// Returns the collections an operation would create implicitly if they were
+// missing, including those of the operations of a transaction
+func dependentCollections(op MongoOperation) []string {
+	var collections []string
+	if (implicitCreateTypes[op.Type] || indexTypes[op.Type]) && op.Collection != "" {
+		collections = append(collections, op.Collection)
+	}
+	for _, nested := range op.Transaction {
+		collections = append(collections, dependentCollections(nested)...)
+	}
+	return collections
+}
+
+// Moves createCollection operations ahead of the first operation that would
+// otherwise create their collection implicitly. Operations keep their
+// relative order otherwise, and a createCollection isn't moved past a drop of
+// its collection. The returned issues are the moves that were made.
+func OrderOperations(ops []MongoOperation) ([]MongoOperation, []DependencyIssue) {
+	return orderOperations(ops, func(name string) string { return name })
+}
+
+// Reorders operations as OrderOperations does, telling collections apart by
+// the names collection returns for them
+func orderOperations(ops []MongoOperation, collection func(string) string) ([]MongoOperation, []DependencyIssue) {
+	plan := planOrder(ops, collection)
+	if len(plan.moves) == 0 {
+		return ops, nil
+	}
+
+	moved := make(map[int][]int, len(plan.moves))
+	for from := range ops {
+		if to, ok := plan.moves[from]; ok {
+			moved[to] = append(moved[to], from)
+		}
+	}
+	ordered := make([]MongoOperation, 0, len(ops))
+	for i, op := range ops {
+		for _, from := range moved[i] {
+			ordered = append(ordered, ops[from])
+		}
+		if _, ok := plan.moves[i]; !ok {
+			ordered = append(ordered, op)
+		}
+	}
+
+	var issues []DependencyIssue
+	for _, issue := range plan.issues {
+		if issue.movable {
+			issues = append(issues, issue.DependencyIssue)
+		}
+	}
+	return ordered, issues
+}
+
+// Ordering problem found by planOrder
+type orderIssue struct {
+	DependencyIssue
+	// Whether moving the createCollection fixes it
+	movable bool
+}
+
+// Result of planOrder
+type orderPlan struct {
+	// Target position of each createCollection that has to move, by its position
+	moves  map[int]int
+	issues []orderIssue
+	// First operation indexing each collection the script neither creates
+	// nor writes to before indexing it
+	uncreated []MongoOperation
+}
+
+// Finds operations that need a collection the script creates only later.
+// Collections are told apart by the names collection returns, so script
+// names mapping to the same collection count as one.
+func planOrder(ops []MongoOperation, collection func(string) string) orderPlan {
+	plan := orderPlan{moves: map[int]int{}}
+	// First operation using each collection not created yet
+	firstUse := map[string]int{}
+	created := map[string]bool{}
+	dropped := map[string]bool{}
+
+	for i, op := range ops {
+		key := collection(op.Collection)
+		switch op.Type {
+		case "createCollection":
+			if use, ok := firstUse[key]; ok {
+				movable := !dropped[key]
+				plan.issues = append(plan.issues, orderIssue{
+					DependencyIssue: DependencyIssue{
+						Operation:  ops[use].Operation,
+						Collection: op.Collection,
+						Location:   ops[use].Location,
+						CreatedAt:  createdAt(op),
+					},
+					movable: movable,
+				})
+				if movable {
+					plan.moves[i] = use
+				}
+				delete(firstUse, key)
+			}
+			created[key] = true
+			plan.uncreated = slices.DeleteFunc(plan.uncreated, func(indexed MongoOperation) bool {
+				return collection(indexed.Collection) == key
+			})
+			continue
+		case "dropCollection":
+			if _, ok := firstUse[key]; ok {
+				dropped[key] = true
+			}
+			created[key] = false
+		case "dropDatabase":
+			for used := range firstUse {
+				dropped[used] = true
+			}
+			created = map[string]bool{}
+		}
+
+		if _, used := firstUse[key]; indexTypes[op.Type] && !used && !created[key] {
+			plan.uncreated = append(plan.uncreated, op)
+		}
+		for _, dependent := range dependentCollections(op) {
+			dependent = collection(dependent)
+			if _, ok := firstUse[dependent]; !ok && !created[dependent] {
+				firstUse[dependent] = i
+			}
+		}
+	}
+	return plan
+}
+
+// Returns where a createCollection statement sits, or an empty location when unknown
+func createdAt(op MongoOperation) *SourceLocation {
+	if op.Location != nil {
+		return op.Location
+	}
+	return &SourceLocation{}
+}
+
+// Returns the operations in the order they should run, or a *DependencyError
+// listing the issues that can't, or per the configuration shouldn't, be fixed
+// by reordering. db is asked which collections exist only when the script
+// indexes collections it never creates.
+func (p *Parser) checkDependencies(ctx context.Context, db *mongo.Database, ops []MongoOperation) ([]MongoOperation, error) {
+	plan := planOrder(ops, p.collectionName)
+
+	var issues []DependencyIssue
+	for _, issue := range plan.issues {
+		if !p.reorderDependencies || !issue.movable {
+			issues = append(issues, issue.DependencyIssue)
+		}
+	}
+
+	if len(plan.uncreated) > 0 {
+		names, err := db.ListCollectionNames(ctx, bson.D{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list collections: %w", err)
+		}
+		for _, op := range plan.uncreated {
+			// Collections exist under their prefixed or mapped names
+			if !slices.Contains(names, p.collectionName(op.Collection)) {
+				issues = append(issues, DependencyIssue{Operation: op.Operation, Collection: op.Collection, Location: op.Location})
+			}
+		}
+	}
+
+	if len(issues) > 0 {
+		return nil, &DependencyError{Issues: issues}
+	}
+	if p.reorderDependencies {
+		ordered, moved := orderOperations(ops, p.collectionName)
+		for _, issue := range moved {
+			p.warnf("moved createCollection of %s ahead of %s", issue.Collection, issue.Operation)
+		}
+		return ordered, nil
+	}
+	return ops, nil
+}
+
+// Checks the order of a script's operations against db without executing
+// them, returning a *DependencyError when operations use collections before
+// the script creates them or index collections that never exist. Issues that
+// WithDependencyCheck(true) would fix by reordering aren't reported.
+func (p *Parser) CheckDependencies(ctx context.Context, db *mongo.Database, jsContent string) error {
+	ops, err := p.parseJavaScriptOperations(jsContent)
+	if err != nil {
+		return fmt.Errorf("failed to parse JavaScript operations: %w", err)
+	}
+	_, err = p.checkDependencies(ctx, db, ops)
+	return err
+}
@@ -0,0 +1,103 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOrderOperations(t *testing.T) {
+	ops, err := NewParser().ParseScript(`db.users.createIndex({ email: 1 });
+db.users.insertOne({ email: "a@example.com" });
+db.orders.insertOne({ total: 1 });
+db.createCollection("users", { validator: { $jsonSchema: { required: ["email"] } } });
+db.createCollection("orders");`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+
+	ordered, issues := OrderOperations(ops)
+	var sequence []string
+	for _, op := range ordered {
+		sequence = append(sequence, op.Operation+" "+op.Collection)
+	}
+	expected := "createCollection users, createIndex users, insertOne users, createCollection orders, insertOne orders"
+	if strings.Join(sequence, ", ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(sequence, ", "))
+	}
+
+	if len(issues) != 2 {
+		t.Fatalf("Expected both moves to be reported, got %v", issues)
+	}
+	if issues[0].String() != "line 1: createIndex on users runs before the collection is created on line 4" {
+		t.Errorf("Unexpected issue: %s", issues[0])
+	}
+}
+
+func TestOrderOperationsKeepsDrops(t *testing.T) {
+	ops, err := NewParser().ParseScript(`db.users.drop();
+db.createCollection("users");
+db.users.insertOne({ a: 1 });`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+	if ordered, issues := OrderOperations(ops); len(issues) != 0 || ordered[0].Type != "dropCollection" {
+		t.Errorf("Expected a correctly ordered script to be left alone, got %v", issues)
+	}
+
+	ops, err = NewParser().ParseScript(`db.users.insertOne({ a: 1 });
+db.users.drop();
+db.createCollection("users");`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+	if ordered, issues := OrderOperations(ops); len(issues) != 0 || ordered[2].Type != "createCollection" {
+		t.Errorf("Expected createCollection not to move past a drop, got %v", issues)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	script := `db.users.createIndex({ email: 1 });
+db.users.drop();
+db.createCollection("users");
+db.orders.insertOne({ total: 1 });
+db.createCollection("orders");`
+	ops, err := NewParser().ParseScript(script)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+
+	_, err = NewParser(WithDependencyCheck(false)).checkDependencies(context.Background(), nil, ops)
+	var dependencyErr *DependencyError
+	if !errors.As(err, &dependencyErr) || len(dependencyErr.Issues) != 2 {
+		t.Fatalf("Expected both ordering problems to be reported, got %v", err)
+	}
+
+	p := NewParser(WithDependencyCheck(true))
+	p.warnings = &[]string{}
+	_, err = p.checkDependencies(context.Background(), nil, ops)
+	if !errors.As(err, &dependencyErr) || len(dependencyErr.Issues) != 1 || dependencyErr.Issues[0].Collection != "users" {
+		t.Fatalf("Expected only the problem reordering can't fix, got %v", err)
+	}
+}
+
+func TestCheckDependenciesMappedCollections(t *testing.T) {
+	// people and users are the same collection once mapped
+	ops, err := NewParser().ParseScript(`db.people.insertOne({ email: "a@example.com" });
+db.createCollection("users");`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+
+	p := NewParser(WithDependencyCheck(false), WithCollectionMap(map[string]string{"people": "app_users", "users": "app_users"}))
+	_, err = p.checkDependencies(context.Background(), nil, ops)
+	var dependencyErr *DependencyError
+	if !errors.As(err, &dependencyErr) || len(dependencyErr.Issues) != 1 || dependencyErr.Issues[0].Operation != "insertOne" {
+		t.Fatalf("Expected the insert into the mapped collection to be reported, got %v", err)
+	}
+
+	if _, err := NewParser(WithDependencyCheck(false)).checkDependencies(context.Background(), nil, ops); err != nil {
+		t.Errorf("Expected unmapped collections to be independent, got %v", err)
+	}
+}
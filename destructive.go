@@ -0,0 +1,48 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Decides whether a destructive operation may run
+type ConfirmFunc func(ctx context.Context, op MongoOperation) bool
+
+// Describes why an operation destroys data or schema, or returns "" when it doesn't.
+// Dropping collections, all indexes or the database and deleteMany with an empty
+// filter count as destructive.
+func DestructiveReason(op MongoOperation) string {
+	switch op.Type {
+	case "dropDatabase":
+		return "drops the whole database"
+	case "dropCollection":
+		return fmt.Sprintf("drops collection %s with all its documents", op.Collection)
+	case "dropIndexes":
+		return fmt.Sprintf("drops every index on %s", op.Collection)
+	case "delete":
+		if op.Operation == "deleteMany" && (len(op.Arguments) == 0 || len(op.Arguments[0]) == 0) {
+			return fmt.Sprintf("deletes every document in %s", op.Collection)
+		}
+	}
+	return ""
+}
+
+// Reports whether an operation destroys data or schema
+func IsDestructive(op MongoOperation) bool {
+	return DestructiveReason(op) != ""
+}
+
+// Refuses destructive operations unless they are allowed outright or confirmed
+func (p *Parser) confirmDestructive(ctx context.Context, op MongoOperation) error {
+	reason := DestructiveReason(op)
+	if reason == "" || p.allowDestructive {
+		return nil
+	}
+
+	if p.confirmDestructiveOp != nil && p.confirmDestructiveOp(ctx, op) {
+		log.Printf("%s on %s %s; confirmed", op.Operation, op.Collection, reason)
+		return nil
+	}
+	return fmt.Errorf("refusing destructive operation %s: it %s; allow it with WithAllowDestructive or confirm it with WithDestructiveConfirm", op.Operation, reason)
+}
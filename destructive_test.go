@@ -0,0 +1,75 @@
+package mongoparser
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseDropOperations(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+db.sessions.drop();
+db.users.dropIndex("email_1");
+db.users.dropIndex({ created_at: -1 });
+db.users.dropIndexes();
+db.dropDatabase();`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"dropCollection", "dropIndex", "dropIndex", "dropIndexes", "dropDatabase"}
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected %d operations, got %d", len(expected), len(ops))
+	}
+	for i, op := range ops {
+		if op.Type != expected[i] {
+			t.Errorf("Operation %d: expected type %s, got %s", i, expected[i], op.Type)
+		}
+	}
+
+	if ops[1].IndexSpec != "email_1" {
+		t.Errorf("Expected index name, got %v", ops[1].IndexSpec)
+	}
+	if keys, ok := ops[2].IndexSpec.(bson.D); !ok || keys[0].Key != "created_at" {
+		t.Errorf("Expected index key pattern, got %v", ops[2].IndexSpec)
+	}
+}
+
+func TestConfirmDestructive(t *testing.T) {
+	emptyDelete := MongoOperation{Type: "delete", Collection: "users", Operation: "deleteMany", Arguments: []bson.D{{}}}
+	filteredDelete := MongoOperation{Type: "delete", Collection: "users", Operation: "deleteMany", Arguments: []bson.D{{{Key: "active", Value: false}}}}
+	drop := MongoOperation{Type: "dropCollection", Collection: "users", Operation: "drop"}
+
+	if !IsDestructive(emptyDelete) || IsDestructive(filteredDelete) || !IsDestructive(drop) {
+		t.Error("Unexpected destructive classification")
+	}
+
+	ctx := context.Background()
+	if err := NewParser().confirmDestructive(ctx, drop); err == nil {
+		t.Error("Expected destructive operation to be refused by default")
+	}
+	if err := NewParser().confirmDestructive(ctx, filteredDelete); err != nil {
+		t.Errorf("Expected filtered delete to run, got %v", err)
+	}
+	if err := NewParser(WithAllowDestructive()).confirmDestructive(ctx, drop); err != nil {
+		t.Errorf("Expected allowed destructive operation to run, got %v", err)
+	}
+
+	var asked []string
+	confirm := WithDestructiveConfirm(func(ctx context.Context, op MongoOperation) bool {
+		asked = append(asked, op.Operation)
+		return op.Type == "dropCollection"
+	})
+	parser := NewParser(confirm)
+	if err := parser.confirmDestructive(ctx, drop); err != nil {
+		t.Errorf("Expected confirmed drop to run, got %v", err)
+	}
+	if err := parser.confirmDestructive(ctx, emptyDelete); err == nil {
+		t.Error("Expected declined delete to be refused")
+	}
+	if len(asked) != 2 {
+		t.Errorf("Expected confirmation to be asked twice, got %v", asked)
+	}
+}
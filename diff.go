@@ -0,0 +1,278 @@
+package mongoparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Statement needed to move a database from one declared schema to another
+type SchemaChange struct {
+	Collection string
+	// Why the change is needed, written as a comment above the statement
+	Description string
+	// Script statement applying the change; empty when it can't be applied
+	// by a statement and is only reported
+	Statement string
+	// Whether the statement destroys data, which the parser refuses unless
+	// allowed or confirmed
+	Destructive bool
+}
+
+// Returns the statements moving a database from the schema declared by from
+// to the one declared by to, collection by collection: new collections are
+// created with their statement, changed validators are applied by repeating
+// the createCollection statement, which collMod applies under
+// WithValidatorReconciliation, indexes are dropped, created or rebuilt, and
+// collections missing from to are dropped.
+func DiffSchemas(from, to *SchemaRegistry) []SchemaChange {
+	names := from.Collections()
+	for _, name := range to.Collections() {
+		if _, ok := from.collections[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []SchemaChange
+	for _, name := range names {
+		old, inOld := from.collections[name]
+		current, inNew := to.collections[name]
+		switch {
+		case !inNew:
+			changes = append(changes, SchemaChange{
+				Collection:  name,
+				Description: fmt.Sprintf("%s is no longer declared; dropping it deletes its documents and must be confirmed", name),
+				Statement:   fmt.Sprintf("db.%s.drop();", name),
+				Destructive: true,
+			})
+			continue
+		case !inOld:
+			old = &CollectionSchema{Name: name}
+			if current.Statement != "" {
+				changes = append(changes, SchemaChange{Collection: name, Description: fmt.Sprintf("New collection %s", name), Statement: current.Statement})
+			}
+		default:
+			changes = append(changes, collectionChanges(old, current)...)
+		}
+		changes = append(changes, indexChanges(old, current)...)
+	}
+	return changes
+}
+
+// Returns the changes to the validator and options of a collection declared
+// by both schemas
+func collectionChanges(old, current *CollectionSchema) []SchemaChange {
+	if current.Statement == "" {
+		return nil
+	}
+	var changes []SchemaChange
+
+	op := MongoOperation{Validator: current.Validator, CollOptions: current.Options}
+	previous := bson.D{}
+	if old.Validator != nil {
+		previous = append(previous, bson.E{Key: "validator", Value: old.Validator})
+	}
+	if old.Options != nil {
+		for _, setting := range []struct {
+			field string
+			value interface{}
+		}{
+			{"validationLevel", old.Options.ValidationLevel},
+			{"validationAction", old.Options.ValidationAction},
+			{"expireAfterSeconds", old.Options.ExpireAfterSeconds},
+		} {
+			if value := dereference(setting.value); value != nil {
+				previous = append(previous, bson.E{Key: setting.field, Value: value})
+			}
+		}
+	}
+	if _, differences := validatorChanges(previous, op); len(differences) > 0 {
+		changes = append(changes, SchemaChange{
+			Collection:  current.Name,
+			Description: fmt.Sprintf("Validator settings of %s changed (%s); run with WithValidatorReconciliation to apply it with collMod", current.Name, strings.Join(differences, "; ")),
+			Statement:   current.Statement,
+		})
+	}
+
+	// Other options are fixed once the collection exists
+	if CanonicalOperation(MongoOperation{CollOptions: fixedOptions(old)}) != CanonicalOperation(MongoOperation{CollOptions: fixedOptions(current)}) {
+		changes = append(changes, SchemaChange{
+			Collection:  current.Name,
+			Description: fmt.Sprintf("Options of %s other than its validator changed; they only apply when the collection is recreated", current.Name),
+		})
+	}
+	return changes
+}
+
+// Returns a collection's options without those collMod can change
+func fixedOptions(collection *CollectionSchema) *options.CreateCollectionOptions {
+	if collection.Options == nil {
+		return nil
+	}
+	opts := *collection.Options
+	opts.Validator, opts.ValidationLevel, opts.ValidationAction, opts.ExpireAfterSeconds = nil, nil, nil, nil
+	return &opts
+}
+
+// Returns the statements dropping, creating and rebuilding indexes so the
+// collection's indexes match the current schema
+func indexChanges(old, current *CollectionSchema) []SchemaChange {
+	name := current.Name
+	var drops, creates []SchemaChange
+	kept := make(map[string]bool)
+
+	for _, index := range old.Indexes {
+		replacement, ok := current.Index(index.Name)
+		if !ok {
+			for _, candidate := range current.Indexes {
+				if valuesEqual(candidate.Keys, index.Keys) {
+					replacement, ok = candidate, true
+					break
+				}
+			}
+		}
+		if ok && replacement.Name == index.Name && sameDocument(indexStatementOptions(index), indexStatementOptions(replacement)) {
+			kept[index.Name] = true
+			continue
+		}
+		description := fmt.Sprintf("Index %s on %s is no longer declared", index.Name, current.Name)
+		if ok {
+			description = fmt.Sprintf("Index %s on %s changed", index.Name, current.Name)
+		}
+		drops = append(drops, SchemaChange{
+			Collection:  current.Name,
+			Description: description,
+			Statement:   fmt.Sprintf("db.%s.dropIndex(%s);", name, jsLiteral(index.Name)),
+		})
+	}
+
+	for _, index := range current.Indexes {
+		if kept[index.Name] {
+			continue
+		}
+		statement := fmt.Sprintf("db.%s.createIndex(%s", name, jsLiteral(index.Keys))
+		if opts := indexStatementOptions(index); len(opts) > 0 {
+			statement += ", " + jsLiteral(opts)
+		}
+		creates = append(creates, SchemaChange{
+			Collection:  current.Name,
+			Description: fmt.Sprintf("Index %s on %s", index.Name, current.Name),
+			Statement:   statement + ");",
+		})
+	}
+	return append(drops, creates...)
+}
+
+// Returns the options of an index as createIndex takes them, leaving out a
+// name that is the default one
+func indexStatementOptions(index IndexDefinition) bson.D {
+	doc := indexDocument(mongo.IndexModel{Keys: index.Keys, Options: index.Options})
+	var opts bson.D
+	for _, elem := range doc {
+		if elem.Key == "key" || (elem.Key == "name" && elem.Value == defaultIndexName(index.Keys)) {
+			continue
+		}
+		opts = append(opts, elem)
+	}
+	return opts
+}
+
+// Keys that can be written without quotes
+var jsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Writes a value as a script literal. Values without a literal, such as
+// ObjectIDs, are written as Extended JSON.
+func jsLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		quoted, _ := json.Marshal(v)
+		return string(quoted)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bson.D:
+		if len(v) == 0 {
+			return "{}"
+		}
+		parts := make([]string, len(v))
+		for i, elem := range v {
+			key := elem.Key
+			if !jsIdentifier.MatchString(key) {
+				key = jsLiteral(key)
+			}
+			parts[i] = key + ": " + jsLiteral(elem.Value)
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case bson.A:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = jsLiteral(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, true, false)
+		if err != nil {
+			return fmt.Sprintf("%q", fmt.Sprint(v))
+		}
+		// Strip the {"v": ...} wrapper
+		text := strings.TrimSpace(string(data))
+		text = strings.TrimPrefix(text, `{"v":`)
+		return strings.TrimSuffix(text, "}")
+	}
+}
+
+// Writes changes as a formatted migration script, each statement below a
+// comment describing it. Changes without a statement become comments only.
+func FormatMigration(changes []SchemaChange) (string, error) {
+	var b strings.Builder
+	for i, change := range changes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		prefix := ""
+		if change.Destructive {
+			prefix = "DESTRUCTIVE: "
+		}
+		fmt.Fprintf(&b, "// %s%s\n", prefix, change.Description)
+		if change.Statement != "" {
+			fmt.Fprintf(&b, "%s\n", strings.TrimSuffix(strings.TrimSpace(change.Statement), ";")+";")
+		}
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return Format(b.String())
+}
+
+// Parses the old and new versions of a schema script and returns a migration
+// script moving a database from the old schema to the new one. Dropped
+// collections are marked DESTRUCTIVE and, like any drop, are refused when the
+// migration runs unless allowed or confirmed.
+func (p *Parser) GenerateMigration(oldScript, newScript string) (string, error) {
+	from, err := p.SchemaRegistry([]ScriptInfo{{Name: "old", Content: oldScript}})
+	if err != nil {
+		return "", err
+	}
+	to, err := p.SchemaRegistry([]ScriptInfo{{Name: "new", Content: newScript}})
+	if err != nil {
+		return "", err
+	}
+	return FormatMigration(DiffSchemas(from, to))
+}
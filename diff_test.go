@@ -0,0 +1,93 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+)
+
+const oldSchemaScript = `db.createCollection("users", { validator: { $jsonSchema: { required: ["email"] } } });
+db.users.createIndex({ email: 1 }, { unique: true });
+db.users.createIndex({ name: 1 });
+db.users.createIndex({ createdAt: 1 }, { expireAfterSeconds: 3600 });
+db.createCollection("sessions");
+db.createCollection("orders");`
+
+const newSchemaScript = `db.createCollection("users", {
+  validator: { $jsonSchema: { required: ["email", "name"] } },
+  validationLevel: "moderate"
+});
+db.users.createIndex({ email: 1 }, { unique: true });
+db.users.createIndex({ createdAt: 1 }, { expireAfterSeconds: 86400 });
+db.createCollection("orders");
+db.orders.createIndex({ customerId: 1, createdAt: -1 }, { name: "customer_recent" });
+db.createCollection("invoices", { validator: { total: { $gte: 0 } } });`
+
+func TestGenerateMigration(t *testing.T) {
+	parser := NewParser()
+	migration, err := parser.GenerateMigration(oldSchemaScript, newSchemaScript)
+	if err != nil {
+		t.Fatalf("GenerateMigration() returned error: %v", err)
+	}
+
+	expected := `// New collection invoices
+db.createCollection("invoices", { validator: { total: { $gte: 0 } } });
+
+// Index customer_recent on orders
+db.orders.createIndex({ customerId: 1, createdAt: -1 }, {
+  name: "customer_recent"
+});
+
+// DESTRUCTIVE: sessions is no longer declared; dropping it deletes its documents and must be confirmed
+db.sessions.drop();
+
+// Validator settings of users changed (validator differs from the script; validationLevel strict, script has moderate); run with WithValidatorReconciliation to apply it with collMod
+db.createCollection("users", {
+  validator: { $jsonSchema: { required: ["email", "name"] } },
+  validationLevel: "moderate"
+});
+
+// Index name_1 on users is no longer declared
+db.users.dropIndex("name_1");
+
+// Index createdAt_1 on users changed
+db.users.dropIndex("createdAt_1");
+
+// Index createdAt_1 on users
+db.users.createIndex({ createdAt: 1 }, { expireAfterSeconds: 86400 });
+`
+	if migration != expected {
+		t.Errorf("Unexpected migration:\n%s", migration)
+	}
+
+	// Running the migration after the old script declares the new schema
+	applied, err := parser.SchemaRegistry([]ScriptInfo{{Name: "old", Content: oldSchemaScript}, {Name: "migration", Content: migration}})
+	if err != nil {
+		t.Fatalf("Failed to parse the migration: %v", err)
+	}
+	target, _ := parser.SchemaRegistry([]ScriptInfo{{Name: "new", Content: newSchemaScript}})
+	if changes := DiffSchemas(applied, target); len(changes) != 0 {
+		t.Errorf("Expected the migrated schema to match the new one, got %+v", changes)
+	}
+}
+
+func TestGenerateMigrationUnchanged(t *testing.T) {
+	migration, err := NewParser().GenerateMigration(oldSchemaScript, oldSchemaScript)
+	if err != nil || migration != "" {
+		t.Errorf("Expected no migration for identical scripts, got %q, %v", migration, err)
+	}
+
+	changes := DiffSchemas(mustRegistry(t, `db.createCollection("logs", { capped: true, size: 1024 });`), mustRegistry(t, `db.createCollection("logs", { capped: true, size: 4096 });`))
+	if len(changes) != 1 || changes[0].Statement != "" || !strings.Contains(changes[0].Description, "only apply when the collection is recreated") {
+		t.Errorf("Expected fixed options to be reported only, got %+v", changes)
+	}
+}
+
+// Builds a registry from a single script
+func mustRegistry(t *testing.T, script string) *SchemaRegistry {
+	t.Helper()
+	registry, err := NewParser().SchemaRegistry([]ScriptInfo{{Name: "script", Content: script}})
+	if err != nil {
+		t.Fatalf("SchemaRegistry() returned error: %v", err)
+	}
+	return registry
+}
@@ -0,0 +1,127 @@
+package mongoparser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Reads the scripts of fsys matching the patterns and parses their metadata.
+// Patterns are slash-separated globs where "**" matches any number of
+// directories, such as "migrations/**/*.js". Matches of each pattern are
+// ordered by path, patterns in the given order, and a file matched by several
+// patterns is only returned once. Scripts are named by their path below the
+// pattern's first directory containing a wildcard.
+func (r *Runner) Discover(fsys fs.FS, patterns ...string) ([]ScriptInfo, error) {
+	scripts, err := LoadScripts(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scripts {
+		scripts[i].Metadata = r.parser.ParseMetadata(scripts[i].Content)
+		if scripts[i].Metadata != nil {
+			scripts[i].Dependencies = scripts[i].Metadata.Dependencies
+		}
+	}
+	return scripts, nil
+}
+
+// Reads the scripts of fsys matching the patterns, such as "migrations/*.js",
+// in the order described by Runner.Discover. Embed migrations with //go:embed
+// and pass the embed.FS here.
+func LoadScripts(fsys fs.FS, patterns ...string) ([]ScriptInfo, error) {
+	var scripts []ScriptInfo
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := globFS(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		base := globBase(pattern)
+		for _, name := range matches {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			content, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read script %s: %w", name, err)
+			}
+			scripts = append(scripts, ScriptInfo{
+				Name:    strings.TrimPrefix(name, base+"/"),
+				Path:    name,
+				Content: string(content),
+			})
+		}
+	}
+	return scripts, nil
+}
+
+// Lists the regular files of fsys matching pattern, sorted by path
+func globFS(fsys fs.FS, pattern string) ([]string, error) {
+	pattern = path.Clean(pattern)
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, fmt.Errorf("invalid script pattern %s: %w", pattern, err)
+	}
+
+	root := globBase(pattern)
+	if root == "" {
+		root = "."
+	}
+	var matches []string
+	err := fs.WalkDir(fsys, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if name == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.Type().IsRegular() && matchGlob(strings.Split(pattern, "/"), strings.Split(name, "/")) {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts for %s: %w", pattern, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Returns the directories of pattern before its first wildcard
+func globBase(pattern string) string {
+	segments := strings.Split(path.Clean(pattern), "/")
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, "*?[") {
+			return strings.Join(segments[:i], "/")
+		}
+	}
+	return path.Dir(pattern)
+}
+
+// Matches path segments against pattern segments, "**" matching any number of them
+func matchGlob(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
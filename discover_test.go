@@ -0,0 +1,103 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"schema/*.js", "schema/001.js", true},
+		{"schema/*.js", "schema/users/001.js", false},
+		{"schema/**/*.js", "schema/001.js", true},
+		{"schema/**/*.js", "schema/users/v2/001.js", true},
+		{"schema/**", "schema/users/001.js", true},
+		{"**/seed_*.js", "fixtures/seed_users.js", true},
+		{"schema/**/*.js", "seed/001.js", false},
+	}
+	for _, c := range cases {
+		if got := matchGlob(strings.Split(c.pattern, "/"), strings.Split(c.name, "/")); got != c.match {
+			t.Errorf("matchGlob(%q, %q) = %v, expected %v", c.pattern, c.name, got, c.match)
+		}
+	}
+}
+
+func TestLoadScripts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/002_orders.js": {Data: []byte(`db.createCollection("orders");`)},
+		"schema/001_users.js":  {Data: []byte(`db.createCollection("users");`)},
+		"schema/README.md":     {Data: []byte(`# Schema`)},
+	}
+
+	scripts, err := LoadScripts(fsys, "schema/*.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 2 || scripts[0].Name != "001_users.js" || scripts[1].Name != "002_orders.js" {
+		t.Fatalf("Expected scripts ordered by file name, got %+v", scripts)
+	}
+	if scripts[0].Path != "schema/001_users.js" || scripts[0].Content != `db.createCollection("users");` {
+		t.Errorf("Unexpected script %+v", scripts[0])
+	}
+
+	if scripts, err := LoadScripts(fsys, "missing/*.js"); err != nil || len(scripts) != 0 {
+		t.Errorf("Expected no scripts for a missing directory, got %v (%v)", scripts, err)
+	}
+	if _, err := LoadScripts(fsys, "schema/[.js"); err == nil {
+		t.Error("Expected an invalid pattern to fail")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/orders/001_create.js": {Data: []byte("// METADATA:\n// {\"name\": \"orders\", \"dependencies\": [\"users\"]}\ndb.createCollection(\"orders\");")},
+		"db/users/001_create.js":  {Data: []byte("// METADATA:\n// {\"name\": \"users\"}\ndb.createCollection(\"users\");")},
+		"db/001_init.js":          {Data: []byte(`db.createCollection("settings");`)},
+		"seed/users.js":           {Data: []byte(`db.users.insertOne({ name: "Ana" });`)},
+	}
+	runner := NewRunner(NewParser(), nil, "")
+
+	scripts, err := runner.Discover(fsys, "db/**/*.js", "seed/*.js", "db/users/*.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(scripts))
+	for i, script := range scripts {
+		names[i] = script.Name
+	}
+	expected := []string{"001_init.js", "orders/001_create.js", "users/001_create.js", "users.js"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+	}
+
+	if scripts[1].Metadata == nil || scripts[1].Metadata.Name != "orders" {
+		t.Errorf("Expected metadata to be parsed, got %+v", scripts[1].Metadata)
+	}
+	if len(scripts[1].Dependencies) != 1 || scripts[1].Dependencies[0] != "users" {
+		t.Errorf("Expected dependencies from metadata, got %v", scripts[1].Dependencies)
+	}
+	if scripts[0].Metadata != nil {
+		t.Errorf("Expected no metadata, got %+v", scripts[0].Metadata)
+	}
+}
+
+func TestScriptName(t *testing.T) {
+	if got := scriptName(ScriptInfo{Name: "001_users.js"}); got != "001_users.js" {
+		t.Errorf("Expected file name fallback, got %s", got)
+	}
+
+	script := ScriptInfo{Name: "001_users.js", Metadata: &ScriptMetadata{Name: "users_setup"}}
+	if got := scriptName(script); got != "users_setup" {
+		t.Errorf("Expected metadata name, got %s", got)
+	}
+}
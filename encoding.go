@@ -0,0 +1,90 @@
+package mongoparser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order mark of UTF-8 text
+const utf8BOM = "\ufeff"
+
+// Returns a reader yielding the script read from r as UTF-8 without a byte
+// order mark. UTF-16 scripts are recognized by their byte order mark or, when
+// they have none, by the zero bytes ASCII characters have in UTF-16.
+func normalizeEncoding(r io.Reader) io.Reader {
+	reader := bufio.NewReader(r)
+	head, _ := reader.Peek(4)
+
+	var order binary.ByteOrder
+	switch {
+	case strings.HasPrefix(string(head), utf8BOM):
+		reader.Discard(len(utf8BOM))
+		return reader
+	case len(head) >= 2 && head[0] == 0xFF && head[1] == 0xFE:
+		reader.Discard(2)
+		order = binary.LittleEndian
+	case len(head) >= 2 && head[0] == 0xFE && head[1] == 0xFF:
+		reader.Discard(2)
+		order = binary.BigEndian
+	case len(head) == 4 && head[0] != 0 && head[1] == 0 && head[2] != 0 && head[3] == 0:
+		order = binary.LittleEndian
+	case len(head) == 4 && head[0] == 0 && head[1] != 0 && head[2] == 0 && head[3] != 0:
+		order = binary.BigEndian
+	default:
+		return reader
+	}
+	return &utf16Reader{reader: reader, order: order}
+}
+
+// Normalizes script content held in memory like normalizeEncoding
+func normalizeContent(content string) string {
+	head := content[:min(len(content), 4)]
+	if !strings.HasPrefix(head, utf8BOM) && !strings.HasPrefix(head, "\xff\xfe") &&
+		!strings.HasPrefix(head, "\xfe\xff") && !strings.Contains(head, "\x00") {
+		return content
+	}
+	normalized, err := io.ReadAll(normalizeEncoding(strings.NewReader(content)))
+	if err != nil {
+		return content
+	}
+	return string(normalized)
+}
+
+// Decodes UTF-16 text into UTF-8
+type utf16Reader struct {
+	reader  *bufio.Reader
+	order   binary.ByteOrder
+	pending []byte
+	err     error
+}
+
+// Fills p with decoded UTF-8 bytes
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.pending) < len(p) && u.err == nil {
+		var unit [2]byte
+		if _, u.err = io.ReadFull(u.reader, unit[:]); u.err != nil {
+			break
+		}
+		char := rune(u.order.Uint16(unit[:]))
+		if utf16.IsSurrogate(char) {
+			var low [2]byte
+			if _, u.err = io.ReadFull(u.reader, low[:]); u.err != nil {
+				char = utf8.RuneError
+			} else {
+				char = utf16.DecodeRune(char, rune(u.order.Uint16(low[:])))
+			}
+		}
+		u.pending = utf8.AppendRune(u.pending, char)
+	}
+
+	if len(u.pending) == 0 {
+		return 0, u.err
+	}
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package mongoparser
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// Encodes text as UTF-16 in the given byte order, optionally with a byte order mark
+func encodeUTF16(text string, order binary.AppendByteOrder, bom bool) string {
+	units := utf16.Encode([]rune(text))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	encoded := make([]byte, 0, len(units)*2)
+	for _, unit := range units {
+		encoded = order.AppendUint16(encoded, unit)
+	}
+	return string(encoded)
+}
+
+func TestScriptEncodings(t *testing.T) {
+	script := "// METADATA:\r\n// { \"name\": \"windows\" }\r\n\r\ndb.users.insertOne({ name: \"Zoë 😀\" });\r\ndb.users.createIndex({ name: 1 });\r\n"
+
+	tests := map[string]string{
+		"utf-8 with crlf":        script,
+		"utf-8 bom":              utf8BOM + script,
+		"utf-16le bom":           encodeUTF16(script, binary.LittleEndian, true),
+		"utf-16be bom":           encodeUTF16(script, binary.BigEndian, true),
+		"utf-16le without a bom": encodeUTF16(script, binary.LittleEndian, false),
+		"utf-16be without a bom": encodeUTF16(script, binary.BigEndian, false),
+	}
+	for name, content := range tests {
+		parser := NewParser()
+		if metadata := parser.ParseMetadata(content); metadata == nil || metadata.Name != "windows" {
+			t.Errorf("%s: expected metadata, got %+v", name, metadata)
+		}
+
+		ops, err := parser.parseJavaScriptOperations(content)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(ops) != 2 || ops[1].Type != "createIndex" {
+			t.Fatalf("%s: expected 2 operations, got %+v", name, ops)
+		}
+		if value, _ := lookupKey(ops[0].Arguments[0], "name"); value != "Zoë 😀" {
+			t.Errorf("%s: expected name to survive decoding, got %q", name, value)
+		}
+	}
+}
+
+func TestNormalizeContentLeavesUTF8Alone(t *testing.T) {
+	content := strings.Repeat("db.a.insertOne({});\n", 3)
+	if normalized := normalizeContent(content); normalized != content {
+		t.Errorf("Expected content to be unchanged, got %q", normalized)
+	}
+}
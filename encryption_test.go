@@ -0,0 +1,77 @@
+package mongoparser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseEncryptDirective(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`// ENCRYPT: users: ssn, address.zip
+db.users.insertOne({ name: "Ada", ssn: "123-45-6789" });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 2 || ops[0].Type != "encrypt" || ops[0].Collection != "users" {
+		t.Fatalf("Expected encrypt directive followed by insert, got %+v", ops)
+	}
+	if fields := ops[0].EncryptedFields; len(fields) != 2 || fields[1] != "address.zip" {
+		t.Errorf("Unexpected encrypted fields %v", fields)
+	}
+
+	if _, err := parser.parseEncrypt("// ENCRYPT: users"); err == nil {
+		t.Error("Expected directive without fields to fail")
+	}
+}
+
+func TestEncryptRequiresConfiguration(t *testing.T) {
+	result := NewParser().ExecuteScript(context.Background(), nil, `// ENCRYPT: users: ssn`)
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "WithFieldEncryption") {
+		t.Errorf("Expected ENCRYPT to require configuration, got %v", result.Error)
+	}
+}
+
+func TestTransformPath(t *testing.T) {
+	doc := bson.D{
+		{Key: "name", Value: "Ada"},
+		{Key: "address", Value: bson.D{{Key: "zip", Value: "12345"}, {Key: "city", Value: "London"}}},
+	}
+	upper := func(value interface{}) (interface{}, error) {
+		return strings.ToUpper(value.(string)), nil
+	}
+
+	out, err := transformPath(doc, []string{"address", "city"}, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if city := out[1].Value.(bson.D)[1].Value; city != "LONDON" {
+		t.Errorf("Expected nested field to be transformed, got %v", city)
+	}
+	if city := doc[1].Value.(bson.D)[1].Value; city != "London" {
+		t.Errorf("Expected the original document to be untouched, got %v", city)
+	}
+
+	if out, _ := transformPath(doc, []string{"phone"}, upper); len(out) != 2 || out[0].Value != "Ada" {
+		t.Errorf("Expected documents without the path to be unchanged, got %v", out)
+	}
+}
+
+func TestAuditRedactsEncryptedFields(t *testing.T) {
+	parser := NewParser()
+	run := *parser
+	run.encryptedFields = map[string][]string{"users": {"ssn"}}
+
+	args := run.auditArguments(MongoOperation{
+		Type:       "insert",
+		Collection: "users",
+		Arguments:  []bson.D{{{Key: "name", Value: "Ada"}, {Key: "ssn", Value: "123-45-6789"}}},
+	})
+	docs := args[0].Value.(bson.A)
+	if ssn := docs[0].(bson.D)[1].Value; ssn != redactedValue {
+		t.Errorf("Expected encrypted field to be redacted, got %v", ssn)
+	}
+}
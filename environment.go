@@ -0,0 +1,43 @@
+package mongoparser
+
+import "strings"
+
+// Marker starting a section of statements that only run in the listed
+// environments, e.g. "// ENV: development, staging". "// ENV: *" ends the
+// section so the statements after it run everywhere again.
+const environmentMarker = "// ENV:"
+
+// Reports whether a comment line starts an environment section
+func isEnvironmentMarker(line string) bool {
+	return strings.HasPrefix(line, environmentMarker)
+}
+
+// Returns the environments an ENV marker lists, or nil when it applies to all
+func parseEnvironments(line string) []string {
+	var environments []string
+	for _, name := range strings.Split(strings.TrimPrefix(line, environmentMarker), ",") {
+		name = strings.TrimSpace(name)
+		if name == "*" || strings.EqualFold(name, "all") {
+			return nil
+		}
+		if name != "" {
+			environments = append(environments, name)
+		}
+	}
+	return environments
+}
+
+// Reports whether statements of a section scoped to environments run in the
+// configured environment. Unscoped statements always run; scoped ones never
+// run when no environment is configured.
+func (p *Parser) inEnvironment(environments []string) bool {
+	if environments == nil {
+		return true
+	}
+	for _, name := range environments {
+		if strings.EqualFold(name, p.environment) {
+			return true
+		}
+	}
+	return false
+}
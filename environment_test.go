@@ -0,0 +1,49 @@
+package mongoparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvironmentSections(t *testing.T) {
+	script := `// ENV: development
+db.users.insertOne({ name: "dev-admin" });
+// ENV: production, staging
+db.users.insertOne({ name: "ops" });
+// ENV: *
+db.users.createIndex({ name: 1 });`
+
+	tests := []struct {
+		environment string
+		expected    []string
+	}{
+		{"", []string{"createIndex"}},
+		{"development", []string{"insertOne", "createIndex"}},
+		{"Staging", []string{"insertOne", "createIndex"}},
+		{"test", []string{"createIndex"}},
+	}
+	for _, tt := range tests {
+		ops, err := NewParser(WithEnvironment(tt.environment)).parseJavaScriptOperations(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, op := range ops {
+			names = append(names, op.Operation)
+		}
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.environment, tt.expected, names)
+		}
+	}
+}
+
+func TestParseEnvironments(t *testing.T) {
+	if envs := parseEnvironments("// ENV: production, staging"); !reflect.DeepEqual(envs, []string{"production", "staging"}) {
+		t.Errorf("Unexpected environments %v", envs)
+	}
+	for _, marker := range []string{"// ENV: *", "// ENV: all", "// ENV:"} {
+		if envs := parseEnvironments(marker); envs != nil {
+			t.Errorf("%s: expected section to apply to all environments, got %v", marker, envs)
+		}
+	}
+}
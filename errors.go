@@ -0,0 +1,90 @@
+package mongoparser
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Server error codes the parser reacts to
+const (
+	CodeIndexNotFound         int32 = 27
+	CodeNamespaceExists       int32 = 48
+	CodeIndexAlreadyExists    int32 = 68
+	CodeIndexOptionsConflict  int32 = 85
+	CodeIndexKeySpecsConflict int32 = 86
+	CodeDuplicateKey          int32 = 11000
+)
+
+// Describes an operation that failed during script execution
+type OperationError struct {
+	Operation  string
+	Collection string
+	// Server error code, or 0 when the failure did not come from the server
+	Code int32
+	Err  error
+	// Statement the operation was parsed from, when known
+	Location *SourceLocation
+}
+
+// Formats the error with the operation and collection it belongs to and the
+// lines of its statement
+func (e *OperationError) Error() string {
+	if e.Location != nil {
+		return fmt.Sprintf("failed to execute operation %s on %s (%s): %v", e.Operation, e.Collection, e.Location, e.Err)
+	}
+	return fmt.Sprintf("failed to execute operation %s on %s: %v", e.Operation, e.Collection, e.Err)
+}
+
+// Returns the underlying error
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Wraps an execution error with the operation details and server error code
+func newOperationError(op MongoOperation, err error) *OperationError {
+	return &OperationError{
+		Operation:  op.Operation,
+		Collection: op.Collection,
+		Code:       serverErrorCode(err),
+		Err:        err,
+		Location:   op.Location,
+	}
+}
+
+// Extracts the server error code from a driver error
+func serverErrorCode(err error) int32 {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code
+	}
+
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) {
+		if len(writeErr.WriteErrors) > 0 {
+			return int32(writeErr.WriteErrors[0].Code)
+		}
+		if writeErr.WriteConcernError != nil {
+			return int32(writeErr.WriteConcernError.Code)
+		}
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+		return int32(bulkErr.WriteErrors[0].Code)
+	}
+
+	return 0
+}
+
+// Reports whether err is a server error with one of the given codes
+func hasErrorCode(err error, codes ...int32) bool {
+	code := serverErrorCode(err)
+	for _, c := range codes {
+		if code != 0 && code == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package mongoparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestOperationErrorCode(t *testing.T) {
+	op := MongoOperation{Operation: "createCollection", Collection: "users"}
+	serverErr := mongo.CommandError{Code: CodeNamespaceExists, Message: "Collection already exists. NS: app.users"}
+
+	err := newOperationError(op, fmt.Errorf("create failed: %w", serverErr))
+	if err.Code != CodeNamespaceExists {
+		t.Errorf("Expected code %d, got %d", CodeNamespaceExists, err.Code)
+	}
+
+	var target *OperationError
+	if !errors.As(error(err), &target) || target.Collection != "users" {
+		t.Error("Expected errors.As to find the OperationError")
+	}
+
+	var commandErr mongo.CommandError
+	if !errors.As(error(err), &commandErr) {
+		t.Error("Expected the server error to be unwrappable")
+	}
+
+	if hasErrorCode(errors.New("already exists"), CodeNamespaceExists) {
+		t.Error("Expected plain errors to carry no server code")
+	}
+}
+
+func TestOperationErrorLocation(t *testing.T) {
+	op := MongoOperation{
+		Operation:  "insertOne",
+		Collection: "users",
+		Location:   &SourceLocation{StartLine: 4, EndLine: 6, Text: "db.users.insertOne({\n  name: 1\n})"},
+	}
+
+	err := newOperationError(op, errors.New("duplicate key"))
+	if err.Location == nil || !strings.Contains(err.Error(), "lines 4-6") {
+		t.Errorf("Expected the error to quote the statement's lines, got %q", err.Error())
+	}
+}
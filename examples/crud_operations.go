@@ -109,7 +109,7 @@ func crudExample() {
 			}
 		);
 
-		// Update with upsert behavior (would create if not exists)
+		// Update with upsert (creates the document if it does not exist)
 		db.users.updateOne(
 			{ email: "eve.davis@example.com" },
 			{
@@ -123,7 +123,8 @@ func crudExample() {
 					active: true,
 					created_at: new Date()
 				}
-			}
+			},
+			{ upsert: true }
 		);
 
 		// Delete operations
@@ -6,51 +6,246 @@ import (
 	"log"
 	"strings"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Executes a parsed MongoDB operation
-func (p *Parser) executeMongoOperation(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+func (p *Parser) executeMongoOperation(ctx context.Context, db *mongo.Database, op MongoOperation) (output interface{}, err error) {
+	if err := p.confirmDestructive(ctx, op); err != nil {
+		return nil, err
+	}
+	op, err = p.evaluateExpressions(op)
+	if err != nil {
+		return nil, err
+	}
+	if p.existence != nil {
+		defer func() { p.existence.observe(op, output, err) }()
+	}
+
 	switch op.Type {
 	case "createCollection":
 		return p.executeCreateCollection(ctx, db, op)
 	case "createIndex":
 		return p.executeCreateIndex(ctx, db, op)
+	case "createIndexes":
+		return p.executeCreateIndexes(ctx, db, op)
 	case "insert":
 		return p.executeInsert(ctx, db, op)
 	case "update":
 		return p.executeUpdate(ctx, db, op)
 	case "delete":
 		return p.executeDelete(ctx, db, op)
+	case "findAndModify":
+		return p.executeFindAndModify(ctx, db, op)
+	case "print":
+		return p.executePrint(op)
+	case "assert":
+		return p.executeAssert(ctx, db, op)
+	case "sleep":
+		return p.executeSleep(ctx, op)
+	case "transaction":
+		return p.executeTransaction(ctx, db, op)
+	case "watch":
+		return p.executeWatch(ctx, db, op)
+	case "upload":
+		return p.executeUpload(ctx, db, op)
+	case "encrypt":
+		return p.executeEncrypt(op)
+	case "seed":
+		return p.executeSeed(ctx, db, op)
+	case "import":
+		return p.executeImport(ctx, db, op)
+	case "createSearchIndex":
+		return p.executeCreateSearchIndex(ctx, db, op)
+	case "dropSearchIndex":
+		return p.executeDropSearchIndex(ctx, db, op)
+	case "dropCollection", "dropIndex", "dropIndexes", "dropDatabase":
+		return p.executeDrop(ctx, db, op)
+	case "count":
+		if len(op.Arguments) == 0 {
+			return nil, fmt.Errorf("count operation requires a filter document")
+		}
+		countOpts := options.Count()
+		if op.Comment != "" {
+			countOpts.SetComment(op.Comment)
+		}
+		return p.collection(db, op).CountDocuments(ctx, op.Arguments[0], countOpts)
+	case customOperationType:
+		return p.executeCustomOperation(ctx, db, op)
 	default:
 		return nil, fmt.Errorf("unsupported operation type: %s", op.Type)
 	}
 }
 
+// Result of an operation that left existing state untouched
+type skipped string
+
+// Returns the name a script collection maps to under the configured renaming
+func (p *Parser) collectionName(name string) string {
+	if name == "" {
+		return name
+	}
+	if mapped, ok := p.collectionMap[name]; ok {
+		return mapped
+	}
+	return p.collectionPrefix + name + p.collectionSuffix
+}
+
+// Returns the database handle with the operation's concerns applied
+func (p *Parser) database(db *mongo.Database, op MongoOperation) *mongo.Database {
+	if op.WriteConcern == nil && op.ReadConcern == nil && op.ReadPreference == nil {
+		return db
+	}
+
+	opts := options.Database()
+	if op.WriteConcern != nil {
+		opts.SetWriteConcern(op.WriteConcern)
+	}
+	if op.ReadConcern != nil {
+		opts.SetReadConcern(op.ReadConcern)
+	}
+	if op.ReadPreference != nil {
+		opts.SetReadPreference(op.ReadPreference)
+	}
+	return db.Client().Database(db.Name(), opts)
+}
+
+// Returns the collection handle with the operation's concerns applied
+func (p *Parser) collection(db *mongo.Database, op MongoOperation) *mongo.Collection {
+	if op.WriteConcern == nil && op.ReadConcern == nil && op.ReadPreference == nil {
+		return db.Collection(op.Collection)
+	}
+
+	opts := options.Collection()
+	if op.WriteConcern != nil {
+		opts.SetWriteConcern(op.WriteConcern)
+	}
+	if op.ReadConcern != nil {
+		opts.SetReadConcern(op.ReadConcern)
+	}
+	if op.ReadPreference != nil {
+		opts.SetReadPreference(op.ReadPreference)
+	}
+	return db.Collection(op.Collection, opts)
+}
+
 // Executes createCollection operation
 func (p *Parser) executeCreateCollection(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
-	opts := options.CreateCollection()
-	if op.Validator != nil {
-		opts.SetValidator(op.Validator)
+	// The server's errors for bad validators rarely say what is wrong
+	if err := validateValidator(op.Validator); err != nil {
+		return nil, fmt.Errorf("validator for %s: %w", op.Collection, err)
 	}
 
-	err := db.CreateCollection(ctx, op.Collection, opts)
+	if p.existence != nil {
+		exists, _, err := p.existence.collectionExists(ctx, db, op.Collection)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return p.resolveCollectionConflict(ctx, db, op)
+		}
+	}
+
+	err := p.createCollection(ctx, db, op)
 	if err != nil {
 		// Check if collection already exists
-		if mongo.IsDuplicateKeyError(err) || strings.Contains(err.Error(), "already exists") {
-			log.Printf("Collection %s already exists, skipping", op.Collection)
-			return "Collection already exists", nil
+		if hasErrorCode(err, CodeNamespaceExists) {
+			return p.resolveCollectionConflict(ctx, db, op)
 		}
 		return nil, err
 	}
 
+	p.compensate(MongoOperation{Type: "dropCollection", Collection: op.Collection, Operation: "drop"})
 	return fmt.Sprintf("Collection %s created successfully", op.Collection), nil
 }
 
+// Issues the create command for a collection
+func (p *Parser) createCollection(ctx context.Context, db *mongo.Database, op MongoOperation) error {
+	if op.RawCollOptions != nil {
+		return p.runCreateCommand(ctx, db, op)
+	}
+
+	opts := options.CreateCollection()
+	if op.CollOptions != nil {
+		opts = op.CollOptions
+	}
+	if op.Validator != nil {
+		opts.SetValidator(op.Validator)
+	}
+	return p.database(db, op).CreateCollection(ctx, op.Collection, opts)
+}
+
+// Applies the collection conflict policy to a collection that already exists
+func (p *Parser) resolveCollectionConflict(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	switch p.collectionPolicy {
+	case ConflictFail:
+		return nil, fmt.Errorf("collection %s already exists", op.Collection)
+	case ConflictRecreate:
+		log.Printf("Collection %s already exists, dropping and recreating", op.Collection)
+		if err := db.Collection(op.Collection).Drop(ctx); err != nil {
+			return nil, fmt.Errorf("failed to drop collection %s: %w", op.Collection, err)
+		}
+		if err := p.createCollection(ctx, db, op); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Collection %s recreated successfully", op.Collection), nil
+	case ConflictUpdate:
+		updated, err := p.updateCollection(ctx, db, op)
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			return skipped("Collection already matches the script"), nil
+		}
+		return fmt.Sprintf("Collection %s updated successfully", op.Collection), nil
+	default:
+		log.Printf("Collection %s already exists, skipping", op.Collection)
+		return skipped("Collection already exists"), nil
+	}
+}
+
+// Reconciles the validator settings of an existing collection with collMod,
+// sending only the settings that differ from the collection's current options.
+// Returns false when the collection already matches the script.
+func (p *Parser) updateCollection(ctx context.Context, db *mongo.Database, op MongoOperation) (bool, error) {
+	if op.Validator == nil && op.CollOptions == nil {
+		log.Printf("Collection %s already exists and has nothing to update", op.Collection)
+		return false, nil
+	}
+
+	existing, err := collectionOptions(ctx, db, op.Collection)
+	if err != nil {
+		return false, err
+	}
+	changes, differences := validatorChanges(existing, op)
+	if len(changes) == 0 {
+		log.Printf("Collection %s already matches the script", op.Collection)
+		return false, nil
+	}
+	if err := p.confirmValidatorUpdate(ctx, existing, op, differences); err != nil {
+		return false, err
+	}
+
+	command := append(bson.D{{Key: "collMod", Value: op.Collection}}, changes...)
+	if err := db.RunCommand(ctx, command).Err(); err != nil {
+		return false, fmt.Errorf("failed to update collection %s: %w", op.Collection, err)
+	}
+	return true, nil
+}
+
+// Runs the create command directly so options unknown to the driver reach the server
+func (p *Parser) runCreateCommand(ctx context.Context, db *mongo.Database, op MongoOperation) error {
+	command := bson.D{{Key: "create", Value: op.Collection}}
+	command = append(command, op.RawCollOptions...)
+
+	return db.RunCommand(ctx, command).Err()
+}
+
 // Executes createIndex operation
 func (p *Parser) executeCreateIndex(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
-	collection := db.Collection(op.Collection)
+	collection := p.collection(db, op)
 
 	indexModel := mongo.IndexModel{
 		Keys: op.IndexSpec,
@@ -59,68 +254,354 @@ func (p *Parser) executeCreateIndex(ctx context.Context, db *mongo.Database, op
 		indexModel.Options = op.IndexOptions
 	}
 
-	result, err := collection.Indexes().CreateOne(ctx, indexModel)
+	var createOpts []*options.CreateIndexesOptions
+	if op.CreateIndexesOptions != nil {
+		createOpts = append(createOpts, op.CreateIndexesOptions)
+	}
+
+	if err := checkIndexKeyOrder(op.Collection, indexModel.Keys); err != nil {
+		return nil, err
+	}
+
+	existing, err := p.existingIndexes(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	action, existingName, err := p.planIndex(op.Collection, existing, indexModel)
 	if err != nil {
-		// Check if index already exists
-		if strings.Contains(err.Error(), "already exists") {
-			log.Printf("Index already exists on collection %s, skipping", op.Collection)
-			return "Index already exists", nil
+		return nil, err
+	}
+	switch action {
+	case indexSkip:
+		log.Printf("Index %s already exists on collection %s, skipping", existingName, op.Collection)
+		return skipped("Index already exists"), nil
+	case indexUpdate:
+		if err := p.updateIndex(ctx, db, op.Collection, existingName, indexModel); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Index updated on %s: %s", op.Collection, existingName), nil
+	case indexRecreate:
+		if err := p.confirmIndexRebuild(ctx, op.Collection, existing, existingName, indexModel); err != nil {
+			return nil, err
+		}
+		log.Printf("Index %s on collection %s differs from the script, recreating", existingName, op.Collection)
+		if _, err := collection.Indexes().DropOne(ctx, existingName); err != nil {
+			return nil, fmt.Errorf("failed to drop index %s: %w", existingName, err)
+		}
+	}
+
+	result, err := collection.Indexes().CreateOne(ctx, indexModel, createOpts...)
+	if err != nil {
+		if hasErrorCode(err, CodeIndexOptionsConflict, CodeIndexKeySpecsConflict) {
+			return nil, fmt.Errorf("index conflicts with an existing index on %s: %w", op.Collection, err)
 		}
 		return nil, err
 	}
 
+	if action == indexCreate {
+		p.compensate(MongoOperation{Type: "dropIndex", Collection: op.Collection, Operation: "dropIndex", IndexSpec: result})
+	}
+	if p.verifyIndexKeys {
+		if err := p.verifyIndexKeyOrder(ctx, collection, []mongo.IndexModel{indexModel}, []string{result}); err != nil {
+			return nil, err
+		}
+	}
 	return fmt.Sprintf("Index created on %s: %s", op.Collection, result), nil
 }
 
+// Executes createIndexes operation
+func (p *Parser) executeCreateIndexes(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	collection := p.collection(db, op)
+
+	if len(op.IndexModels) == 0 {
+		return nil, fmt.Errorf("no indexes to create")
+	}
+	for _, model := range op.IndexModels {
+		if err := checkIndexKeyOrder(op.Collection, model.Keys); err != nil {
+			return nil, err
+		}
+	}
+
+	var createOpts []*options.CreateIndexesOptions
+	if op.CreateIndexesOptions != nil {
+		createOpts = append(createOpts, op.CreateIndexesOptions)
+	}
+
+	existing, err := p.existingIndexes(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plan every index first so a refused rebuild leaves all of them untouched
+	actions := make([]indexAction, len(op.IndexModels))
+	existingNames := make([]string, len(op.IndexModels))
+	for i, model := range op.IndexModels {
+		actions[i], existingNames[i], err = p.planIndex(op.Collection, existing, model)
+		if err != nil {
+			return nil, err
+		}
+		if actions[i] == indexRecreate {
+			if err := p.confirmIndexRebuild(ctx, op.Collection, existing, existingNames[i], model); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var models []mongo.IndexModel
+	var recreated []bool
+	for i, model := range op.IndexModels {
+		action, existingName := actions[i], existingNames[i]
+		switch action {
+		case indexSkip:
+			log.Printf("Index %s already exists on collection %s, skipping", existingName, op.Collection)
+			continue
+		case indexUpdate:
+			if err := p.updateIndex(ctx, db, op.Collection, existingName, model); err != nil {
+				return nil, err
+			}
+			continue
+		case indexRecreate:
+			log.Printf("Index %s on collection %s differs from the script, recreating", existingName, op.Collection)
+			if _, err := collection.Indexes().DropOne(ctx, existingName); err != nil {
+				return nil, fmt.Errorf("failed to drop index %s: %w", existingName, err)
+			}
+		}
+		models = append(models, model)
+		recreated = append(recreated, action == indexRecreate)
+	}
+	if len(models) == 0 {
+		return skipped("Indexes already exist"), nil
+	}
+
+	names, err := collection.Indexes().CreateMany(ctx, models, createOpts...)
+	if err != nil {
+		if hasErrorCode(err, CodeIndexOptionsConflict, CodeIndexKeySpecsConflict) {
+			return nil, fmt.Errorf("indexes conflict with existing indexes on %s: %w", op.Collection, err)
+		}
+		return nil, err
+	}
+
+	for i, name := range names {
+		// Recreated indexes replaced an existing definition that can't be restored
+		if !recreated[i] {
+			p.compensate(MongoOperation{Type: "dropIndex", Collection: op.Collection, Operation: "dropIndex", IndexSpec: name})
+		}
+	}
+	if p.verifyIndexKeys {
+		if err := p.verifyIndexKeyOrder(ctx, collection, models, names); err != nil {
+			return nil, err
+		}
+	}
+	return fmt.Sprintf("Indexes created on %s: %s", op.Collection, strings.Join(names, ", ")), nil
+}
+
 // Executes insert operations
 func (p *Parser) executeInsert(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
-	collection := db.Collection(op.Collection)
+	collection := p.collection(db, op)
 
 	if len(op.Arguments) == 0 {
 		return nil, fmt.Errorf("no document to insert")
 	}
 
+	docs, err := p.encryptDocuments(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	op.Arguments = docs
+
+	if len(p.upsertKeys) > 0 {
+		return p.executeUpsertInsert(ctx, collection, op)
+	}
+
 	switch op.Operation {
 	case "insertOne":
-		result, err := collection.InsertOne(ctx, op.Arguments[0])
+		insertOpts := options.InsertOne()
+		if op.Comment != "" {
+			insertOpts.SetComment(op.Comment)
+		}
+		result, err := collection.InsertOne(ctx, op.Arguments[0], insertOpts)
 		if err != nil {
 			return nil, err
 		}
+		p.compensateInsert(op, []interface{}{result.InsertedID})
 		return result.InsertedID, nil
 	case "insertMany":
-		var docs []interface{}
-		for _, doc := range op.Arguments {
-			docs = append(docs, doc)
+		var insertOpts []*options.InsertManyOptions
+		if op.InsertManyOptions != nil {
+			insertOpts = append(insertOpts, op.InsertManyOptions)
 		}
-		result, err := collection.InsertMany(ctx, docs)
-		if err != nil {
-			return nil, err
+		if op.Comment != "" {
+			insertOpts = append(insertOpts, options.InsertMany().SetComment(op.Comment))
+		}
+
+		// Unordered inserts keep going after a failed chunk, like the server does within one call
+		ordered := op.InsertManyOptions == nil || op.InsertManyOptions.Ordered == nil || *op.InsertManyOptions.Ordered
+		var insertedIDs []interface{}
+		var firstErr error
+		written := 0
+		// Chunks inserted before a failure are rolled back too
+		defer func() { p.compensateInsert(op, insertedIDs) }()
+		for i, c := range chunkDocuments(op.Arguments, p.insertChunkSize) {
+			if i > 0 {
+				if err := p.throttle(ctx, false); err != nil {
+					return nil, err
+				}
+			}
+			docs := make([]interface{}, 0, c.end-c.start)
+			for _, doc := range op.Arguments[c.start:c.end] {
+				docs = append(docs, doc)
+			}
+
+			result, err := collection.InsertMany(ctx, docs, insertOpts...)
+			if err != nil {
+				err = fmt.Errorf("failed to insert documents %d-%d of %d: %w", c.start+1, c.end, len(op.Arguments), err)
+				if ordered {
+					return nil, err
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			insertedIDs = append(insertedIDs, result.InsertedIDs...)
+			written += len(result.InsertedIDs)
+			p.reportInsertProgress(op, written, len(op.Arguments))
 		}
-		return result.InsertedIDs, nil
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return insertedIDs, nil
 	default:
 		return nil, fmt.Errorf("unsupported insert operation: %s", op.Operation)
 	}
 }
 
+// Executes inserts as upserts keyed on the configured fields so re-runs don't hit duplicate keys
+func (p *Parser) executeUpsertInsert(ctx context.Context, collection *mongo.Collection, op MongoOperation) (interface{}, error) {
+	var models []mongo.WriteModel
+	for _, doc := range op.Arguments {
+		filter := p.upsertFilter(doc)
+		if filter == nil {
+			p.warnf("document in %s lacks upsert key %s, inserting as is", op.Collection, strings.Join(p.upsertKeys, ", "))
+			models = append(models, mongo.NewInsertOneModel().SetDocument(doc))
+			continue
+		}
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true))
+	}
+
+	bulkOpts := options.BulkWrite()
+	if op.InsertManyOptions != nil && op.InsertManyOptions.Ordered != nil {
+		bulkOpts.SetOrdered(*op.InsertManyOptions.Ordered)
+	}
+	if op.Comment != "" {
+		bulkOpts.SetComment(op.Comment)
+	}
+
+	ordered := bulkOpts.Ordered == nil || *bulkOpts.Ordered
+	var inserted, upserted, replaced int64
+	var firstErr error
+	written := 0
+	for i, c := range chunkDocuments(op.Arguments, p.insertChunkSize) {
+		if i > 0 {
+			if err := p.throttle(ctx, false); err != nil {
+				return nil, err
+			}
+		}
+		result, err := collection.BulkWrite(ctx, models[c.start:c.end], bulkOpts)
+		if err != nil {
+			err = fmt.Errorf("failed to upsert documents %d-%d of %d: %w", c.start+1, c.end, len(op.Arguments), err)
+			if ordered {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		inserted += result.InsertedCount
+		upserted += result.UpsertedCount
+		replaced += result.ModifiedCount
+		written += c.end - c.start
+		p.reportInsertProgress(op, written, len(op.Arguments))
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return fmt.Sprintf("%d inserted, %d upserted, %d replaced", inserted, upserted, replaced), nil
+}
+
+// Builds the upsert filter from the configured key fields, or nil when a key is missing
+func (p *Parser) upsertFilter(doc bson.D) bson.D {
+	filter := make(bson.D, 0, len(p.upsertKeys))
+	for _, key := range p.upsertKeys {
+		value, ok := lookupKey(doc, key)
+		if !ok {
+			return nil
+		}
+		filter = append(filter, bson.E{Key: key, Value: value})
+	}
+	return filter
+}
+
 // Executes update operations
 func (p *Parser) executeUpdate(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
-	if len(op.Arguments) < 2 {
+	if len(op.Arguments) == 0 || (len(op.Arguments) < 2 && op.UpdatePipeline == nil) {
 		return nil, fmt.Errorf("update operation requires filter and update documents")
 	}
 
-	collection := db.Collection(op.Collection)
+	collection := p.collection(db, op)
 	filter := op.Arguments[0]
-	update := op.Arguments[1]
+	var update interface{}
+	if op.UpdatePipeline != nil {
+		update = op.UpdatePipeline
+	} else {
+		update = op.Arguments[1]
+	}
+
+	var updateOpts []*options.UpdateOptions
+	if op.UpdateOptions != nil {
+		updateOpts = append(updateOpts, op.UpdateOptions)
+	}
+	if op.Comment != "" {
+		updateOpts = append(updateOpts, options.Update().SetComment(op.Comment))
+	}
 
 	switch op.Operation {
 	case "updateOne":
-		result, err := collection.UpdateOne(ctx, filter, update)
+		result, err := collection.UpdateOne(ctx, filter, update, updateOpts...)
 		if err != nil {
 			return nil, err
 		}
 		return result.ModifiedCount, nil
+	case "replaceOne":
+		replaceOpts := options.Replace()
+		if opts := op.UpdateOptions; opts != nil {
+			if opts.Upsert != nil {
+				replaceOpts.SetUpsert(*opts.Upsert)
+			}
+			if opts.Hint != nil {
+				replaceOpts.SetHint(opts.Hint)
+			}
+			if opts.Collation != nil {
+				replaceOpts.SetCollation(opts.Collation)
+			}
+		}
+		if op.Comment != "" {
+			replaceOpts.SetComment(op.Comment)
+		}
+		result, err := collection.ReplaceOne(ctx, filter, update, replaceOpts)
+		if err != nil {
+			return nil, err
+		}
+		return result.ModifiedCount + result.UpsertedCount, nil
 	case "updateMany":
-		result, err := collection.UpdateMany(ctx, filter, update)
+		if p.updateLimit > 0 {
+			if err := p.checkUpdateLimit(ctx, collection, op); err != nil {
+				return nil, err
+			}
+		}
+		result, err := collection.UpdateMany(ctx, filter, update, updateOpts...)
 		if err != nil {
 			return nil, err
 		}
@@ -136,18 +617,31 @@ func (p *Parser) executeDelete(ctx context.Context, db *mongo.Database, op Mongo
 		return nil, fmt.Errorf("delete operation requires filter document")
 	}
 
-	collection := db.Collection(op.Collection)
+	collection := p.collection(db, op)
 	filter := op.Arguments[0]
 
+	var deleteOpts []*options.DeleteOptions
+	if op.DeleteOptions != nil {
+		deleteOpts = append(deleteOpts, op.DeleteOptions)
+	}
+	if op.Comment != "" {
+		deleteOpts = append(deleteOpts, options.Delete().SetComment(op.Comment))
+	}
+
 	switch op.Operation {
 	case "deleteOne":
-		result, err := collection.DeleteOne(ctx, filter)
+		result, err := collection.DeleteOne(ctx, filter, deleteOpts...)
 		if err != nil {
 			return nil, err
 		}
 		return result.DeletedCount, nil
 	case "deleteMany":
-		result, err := collection.DeleteMany(ctx, filter)
+		if p.deleteLimit > 0 {
+			if err := p.checkDeleteLimit(ctx, collection, op); err != nil {
+				return nil, err
+			}
+		}
+		result, err := collection.DeleteMany(ctx, filter, deleteOpts...)
 		if err != nil {
 			return nil, err
 		}
@@ -156,3 +650,102 @@ func (p *Parser) executeDelete(ctx context.Context, db *mongo.Database, op Mongo
 		return nil, fmt.Errorf("unsupported delete operation: %s", op.Operation)
 	}
 }
+
+// Counts the documents a deleteMany would remove and enforces the configured limit
+func (p *Parser) checkDeleteLimit(ctx context.Context, collection *mongo.Collection, op MongoOperation) error {
+	countOpts := options.Count()
+	if op.DeleteOptions != nil {
+		if op.DeleteOptions.Collation != nil {
+			countOpts.SetCollation(op.DeleteOptions.Collation)
+		}
+		if op.DeleteOptions.Hint != nil {
+			countOpts.SetHint(op.DeleteOptions.Hint)
+		}
+	}
+	return checkAffectedLimit(ctx, collection, op, countOpts, "remove", p.deleteLimit, p.confirmDeleteLimit)
+}
+
+// Counts the documents an updateMany would match and enforces the configured limit
+func (p *Parser) checkUpdateLimit(ctx context.Context, collection *mongo.Collection, op MongoOperation) error {
+	countOpts := options.Count()
+	if op.UpdateOptions != nil {
+		if op.UpdateOptions.Collation != nil {
+			countOpts.SetCollation(op.UpdateOptions.Collation)
+		}
+		if op.UpdateOptions.Hint != nil {
+			countOpts.SetHint(op.UpdateOptions.Hint)
+		}
+	}
+	return checkAffectedLimit(ctx, collection, op, countOpts, "modify", p.updateLimit, p.confirmUpdateLimit)
+}
+
+// Fails when the operation's filter matches more than limit documents, unless confirm allows it
+func checkAffectedLimit(ctx context.Context, collection *mongo.Collection, op MongoOperation, countOpts *options.CountOptions, verb string, limit int64, confirm ConfirmLimitFunc) error {
+	if op.Comment != "" {
+		countOpts.SetComment(op.Comment)
+	}
+	count, err := collection.CountDocuments(ctx, op.Arguments[0], countOpts)
+	if err != nil {
+		return fmt.Errorf("failed to count documents matched by %s filter: %w", op.Operation, err)
+	}
+	if count <= limit {
+		return nil
+	}
+
+	if confirm != nil && confirm(ctx, op, count) {
+		log.Printf("%s on %s matches %d documents, exceeding the limit of %d; confirmed", op.Operation, op.Collection, count, limit)
+		return nil
+	}
+	return fmt.Errorf("%s would %s %d documents, exceeding the limit of %d", op.Operation, verb, count, limit)
+}
+
+// Executes drop, dropIndex, dropIndexes and dropDatabase operations
+func (p *Parser) executeDrop(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	switch op.Type {
+	case "dropDatabase":
+		if err := p.database(db, op).Drop(ctx); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Database %s dropped", db.Name()), nil
+	case "dropCollection":
+		if err := p.collection(db, op).Drop(ctx); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Collection %s dropped", op.Collection), nil
+	case "dropIndexes":
+		if _, err := p.collection(db, op).Indexes().DropAll(ctx); err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("Indexes dropped on %s", op.Collection), nil
+	}
+
+	collection := p.collection(db, op)
+	name, ok := op.IndexSpec.(string)
+	if !ok {
+		keys, _ := op.IndexSpec.(bson.D)
+		existing, err := p.existingIndexes(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range existing {
+			if existingKeys, _ := lookupKey(index, "key"); valuesEqual(existingKeys, keys) {
+				value, _ := lookupKey(index, "name")
+				name, _ = value.(string)
+				break
+			}
+		}
+		if name == "" {
+			log.Printf("No index with keys %s on collection %s, skipping", formatKeys(keys), op.Collection)
+			return skipped("Index does not exist"), nil
+		}
+	}
+
+	if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+		if hasErrorCode(err, CodeIndexNotFound) {
+			log.Printf("Index %s does not exist on collection %s, skipping", name, op.Collection)
+			return skipped("Index does not exist"), nil
+		}
+		return nil, err
+	}
+	return fmt.Sprintf("Index %s dropped on %s", name, op.Collection), nil
+}
@@ -0,0 +1,241 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Lists the database's collections once, the first time a script creates a
+// collection or index, and lists each collection's indexes at most once, then
+// answers from what the script itself did. Collections known to exist skip
+// the create command that would fail, and collections known to be missing
+// skip listIndexes altogether. Other writers changing collections while the
+// script runs can make the cache stale; the server's own conflict checks
+// still apply to whatever is created.
+func WithExistenceCache() Option {
+	return func(p *Parser) {
+		p.cacheExistence = true
+	}
+}
+
+// What an existence cache knows about a collection
+type collectionState int
+
+const (
+	// Not listed when the cache was loaded, and not touched since
+	collectionMissing collectionState = iota
+	collectionExists
+	// Touched by an operation that may or may not have created it
+	collectionUnknown
+)
+
+// Collections and indexes of the database a script runs on, as far as the
+// script has seen them
+type existenceCache struct {
+	loaded      bool
+	collections map[string]collectionState
+	// Index documents by collection, as listIndexes returns them
+	indexes map[string][]bson.D
+}
+
+// Creates an empty cache that loads the collection list on first use
+func newExistenceCache() *existenceCache {
+	return &existenceCache{
+		collections: make(map[string]collectionState),
+		indexes:     make(map[string][]bson.D),
+	}
+}
+
+// Returns whether the collection exists, listing the database's collections
+// the first time; known is false when the cache can't tell
+func (c *existenceCache) collectionExists(ctx context.Context, db *mongo.Database, name string) (exists, known bool, err error) {
+	if !c.loaded {
+		names, err := db.ListCollectionNames(ctx, bson.D{})
+		if err != nil {
+			return false, false, fmt.Errorf("failed to list collections: %w", err)
+		}
+		for _, listed := range names {
+			if _, ok := c.collections[listed]; !ok {
+				c.collections[listed] = collectionExists
+			}
+		}
+		c.loaded = true
+	}
+
+	switch c.collections[name] {
+	case collectionExists:
+		return true, true, nil
+	case collectionMissing:
+		return false, true, nil
+	default:
+		return false, false, nil
+	}
+}
+
+// Returns the collection's index documents, listing them only when the cache
+// doesn't know them yet
+func (p *Parser) existingIndexes(ctx context.Context, collection *mongo.Collection) ([]bson.D, error) {
+	c := p.existence
+	if c == nil {
+		return p.listIndexes(ctx, collection)
+	}
+	if indexes, ok := c.indexes[collection.Name()]; ok {
+		return indexes, nil
+	}
+
+	exists, known, err := c.collectionExists(ctx, collection.Database(), collection.Name())
+	if err != nil {
+		return nil, err
+	}
+	var indexes []bson.D
+	if exists || !known {
+		if indexes, err = p.listIndexes(ctx, collection); err != nil {
+			return nil, err
+		}
+	}
+	c.indexes[collection.Name()] = indexes
+	return indexes, nil
+}
+
+// Records what an executed operation did to the database's collections and indexes
+func (c *existenceCache) observe(op MongoOperation, output interface{}, err error) {
+	if _, ok := output.(skipped); ok {
+		return
+	}
+	name := op.Collection
+
+	if err != nil {
+		switch op.Type {
+		case "transaction", "dropDatabase", customOperationType:
+			c.reset()
+		default:
+			c.forget(name)
+		}
+		return
+	}
+
+	switch op.Type {
+	case "createCollection":
+		if c.collections[name] == collectionMissing {
+			c.indexes[name] = nil
+		} else {
+			// May have been recreated or updated, changing its indexes
+			delete(c.indexes, name)
+		}
+		c.collections[name] = collectionExists
+	case "insert", "seed", "import":
+		if c.collections[name] != collectionExists {
+			c.collections[name] = collectionExists
+			c.indexes[name] = nil
+		}
+	case "createIndex":
+		c.collections[name] = collectionExists
+		c.addIndexes(name, mongo.IndexModel{Keys: op.IndexSpec, Options: op.IndexOptions})
+	case "createIndexes":
+		c.collections[name] = collectionExists
+		c.addIndexes(name, op.IndexModels...)
+	case "dropCollection":
+		c.collections[name] = collectionMissing
+		c.indexes[name] = nil
+	case "dropIndex", "dropIndexes":
+		delete(c.indexes, name)
+	case "dropDatabase", customOperationType:
+		c.reset()
+	default:
+		if c.collections[name] == collectionMissing {
+			c.forget(name)
+		}
+	}
+}
+
+// Records indexes the script created, or forgets the collection's indexes
+// when they can't be described
+func (c *existenceCache) addIndexes(collection string, models ...mongo.IndexModel) {
+	indexes, ok := c.indexes[collection]
+	if !ok {
+		// Not listed yet; the next createIndex lists them with the new ones
+		return
+	}
+
+	for _, model := range models {
+		doc := indexDocument(model)
+		if doc == nil {
+			delete(c.indexes, collection)
+			return
+		}
+		// Recreated and updated indexes replace the previous definition
+		name, _ := lookupKey(doc, "name")
+		keys, _ := lookupKey(doc, "key")
+		kept := indexes[:0:0]
+		for _, index := range indexes {
+			existingName, _ := lookupKey(index, "name")
+			existingKeys, _ := lookupKey(index, "key")
+			if existingName != name && !valuesEqual(existingKeys, keys) {
+				kept = append(kept, index)
+			}
+		}
+		indexes = append(kept, doc)
+	}
+	c.indexes[collection] = indexes
+}
+
+// Forgets what is known about a collection so it is asked about again
+func (c *existenceCache) forget(name string) {
+	if name == "" {
+		return
+	}
+	c.collections[name] = collectionUnknown
+	delete(c.indexes, name)
+}
+
+// Forgets everything, so the collection list is loaded again
+func (c *existenceCache) reset() {
+	c.loaded = false
+	c.collections = make(map[string]collectionState)
+	c.indexes = make(map[string][]bson.D)
+}
+
+// Describes an index the way listIndexes does, as far as planIndex compares
+// it, or returns nil when its keys aren't an ordered document
+func indexDocument(model mongo.IndexModel) bson.D {
+	keys, ok := model.Keys.(bson.D)
+	if !ok {
+		return nil
+	}
+
+	name := defaultIndexName(keys)
+	opts := model.Options
+	if opts != nil && opts.Name != nil {
+		name = *opts.Name
+	}
+	doc := bson.D{{Key: "key", Value: keys}, {Key: "name", Value: name}}
+	if opts == nil {
+		return doc
+	}
+
+	flags := []struct {
+		field string
+		value *bool
+	}{{"unique", opts.Unique}, {"sparse", opts.Sparse}, {"hidden", opts.Hidden}}
+	for _, flag := range flags {
+		if flag.value != nil && *flag.value {
+			doc = append(doc, bson.E{Key: flag.field, Value: true})
+		}
+	}
+	if opts.ExpireAfterSeconds != nil {
+		doc = append(doc, bson.E{Key: "expireAfterSeconds", Value: *opts.ExpireAfterSeconds})
+	}
+	if opts.PartialFilterExpression != nil {
+		doc = append(doc, bson.E{Key: "partialFilterExpression", Value: opts.PartialFilterExpression})
+	}
+	if opts.Collation != nil {
+		doc = append(doc, bson.E{Key: "collation", Value: bson.D{
+			{Key: "locale", Value: opts.Collation.Locale},
+			{Key: "strength", Value: opts.Collation.Strength},
+		}})
+	}
+	return doc
+}
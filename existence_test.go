@@ -0,0 +1,106 @@
+package mongoparser
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestExistenceCacheObserve(t *testing.T) {
+	cache := newExistenceCache()
+	cache.loaded = true
+	cache.collections["users"] = collectionExists
+
+	state := func(name string) collectionState {
+		return cache.collections[name]
+	}
+
+	cache.observe(MongoOperation{Type: "createCollection", Collection: "orders"}, "Collection orders created successfully", nil)
+	if state("orders") != collectionExists {
+		t.Errorf("Expected a created collection to exist")
+	}
+	if indexes, ok := cache.indexes["orders"]; !ok || len(indexes) != 0 {
+		t.Errorf("Expected a created collection to have no indexes to list, got %v", indexes)
+	}
+
+	cache.observe(MongoOperation{Type: "createIndex", Collection: "orders", IndexSpec: bson.D{{Key: "customer", Value: 1}}}, "Index created on orders: customer_1", nil)
+	if indexes := cache.indexes["orders"]; len(indexes) != 1 {
+		t.Fatalf("Expected the created index to be cached, got %v", indexes)
+	}
+
+	cache.observe(MongoOperation{Type: "update", Collection: "invoices"}, int64(1), nil)
+	if state("invoices") != collectionUnknown {
+		t.Errorf("Expected a collection an upsert may have created to be unknown, got %v", state("invoices"))
+	}
+
+	cache.observe(MongoOperation{Type: "insert", Collection: "events"}, []interface{}{1}, nil)
+	if state("events") != collectionExists {
+		t.Errorf("Expected an insert to create its collection")
+	}
+
+	cache.observe(MongoOperation{Type: "dropCollection", Collection: "users"}, "Collection users dropped", nil)
+	if state("users") != collectionMissing {
+		t.Errorf("Expected a dropped collection to be missing")
+	}
+
+	cache.observe(MongoOperation{Type: "createCollection", Collection: "users"}, skipped("Collection already exists"), nil)
+	if state("users") != collectionMissing {
+		t.Errorf("Expected skipped operations to be ignored")
+	}
+
+	cache.observe(MongoOperation{Type: "transaction"}, nil, mongo.ErrClientDisconnected)
+	if cache.loaded || len(cache.collections) != 0 {
+		t.Errorf("Expected a failed transaction to reset the cache")
+	}
+}
+
+func TestExistenceCacheReplacesIndexes(t *testing.T) {
+	cache := newExistenceCache()
+	cache.indexes["users"] = []bson.D{
+		{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "name", Value: "_id_"}},
+		{{Key: "key", Value: bson.D{{Key: "email", Value: 1}}}, {Key: "name", Value: "email_1"}},
+	}
+
+	cache.observe(MongoOperation{
+		Type:         "createIndex",
+		Collection:   "users",
+		IndexSpec:    bson.D{{Key: "email", Value: 1}},
+		IndexOptions: options.Index().SetUnique(true),
+	}, "Index created on users: email_1", nil)
+
+	indexes := cache.indexes["users"]
+	if len(indexes) != 2 {
+		t.Fatalf("Expected the recreated index to replace the old one, got %v", indexes)
+	}
+	if unique, _ := lookupKey(indexes[1], "unique"); unique != true {
+		t.Errorf("Expected the cached index to be unique, got %v", indexes[1])
+	}
+
+	p := NewParser()
+	action, _, err := p.planIndex("users", indexes, mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)})
+	if err != nil || action != indexSkip {
+		t.Errorf("Expected the cached index to match the script, got %v, %v", action, err)
+	}
+}
+
+func TestIndexDocument(t *testing.T) {
+	model := mongo.IndexModel{
+		Keys: bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().
+			SetName("ttl").
+			SetExpireAfterSeconds(3600).
+			SetSparse(true).
+			SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+	}
+
+	doc := indexDocument(model)
+	if differences := NewParser().indexDifferences(doc, bson.D{{Key: "createdAt", Value: 1}}, "ttl", model); len(differences) != 0 {
+		t.Errorf("Expected the index document to match its model, got %v", differences)
+	}
+
+	if indexDocument(mongo.IndexModel{Keys: bson.M{"a": 1}}) != nil {
+		t.Error("Expected no document for unordered keys")
+	}
+}
@@ -0,0 +1,671 @@
+package mongoparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Prefix of the JSON strings that carry expressions through decoding
+const expressionMarker = "\x00expr:"
+
+// Values that decode as JSON without evaluation
+var literalPattern = regexp.MustCompile(`^(true|false|null|-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?)$`)
+
+// Hexadecimal integers such as 0xFF, rewritten as decimal JSON numbers
+var hexPattern = regexp.MustCompile(`^-?0[xX][0-9a-fA-F]+$`)
+
+// A value computed when its operation executes, such as new Date(),
+// Date.now() - 86400000 or a call of a registered function. Other
+// expressions are folded into plain values while parsing.
+type Expression struct {
+	Source string `json:"source"`
+
+	eval expressionFunc
+}
+
+// Computes an expression at the given instant
+type expressionFunc func(now time.Time) (interface{}, error)
+
+// Returns the current time of the parser's clock, truncated to milliseconds
+// like BSON dates
+func (p *Parser) now() time.Time {
+	now := time.Now()
+	if p.clock != nil {
+		now = p.clock()
+	}
+	return now.Truncate(time.Millisecond)
+}
+
+// Replaces values that are not JSON literals, such as new Date() or
+// "user_" + 1, with marked strings the decoder compiles into expressions
+func wrapExpressions(input string) string {
+	var result strings.Builder
+	result.Grow(len(input))
+	// Open brackets, telling object keys after a comma from array values
+	var brackets []byte
+	expectValue := true
+	inQuotes := false
+
+	for i := 0; i < len(input); i++ {
+		char := input[i]
+		if inQuotes {
+			result.WriteByte(char)
+			if char == '\\' && i+1 < len(input) {
+				i++
+				result.WriteByte(input[i])
+			} else if char == '"' {
+				inQuotes = false
+			}
+			continue
+		}
+
+		if expectValue && char != ' ' && char != '\t' && char != '\n' && char != '\r' {
+			expectValue = false
+			if !strings.ContainsRune(`{}[]`, rune(char)) {
+				end := expressionEnd(input, i)
+				text := strings.TrimSpace(input[i:end])
+				if literalPattern.MatchString(text) || isStringLiteral(text) {
+					result.WriteString(input[i:end])
+				} else if decimal, ok := hexToDecimal(text); ok {
+					result.WriteString(decimal)
+				} else {
+					quoted, _ := json.Marshal(expressionMarker + text)
+					result.Write(quoted)
+				}
+				i = end - 1
+				continue
+			}
+		}
+
+		switch char {
+		case '"':
+			inQuotes = true
+		case '{', '[':
+			brackets = append(brackets, char)
+			expectValue = char == '['
+		case '}', ']':
+			if len(brackets) > 0 {
+				brackets = brackets[:len(brackets)-1]
+			}
+		case ':':
+			expectValue = true
+		case ',':
+			expectValue = len(brackets) == 0 || brackets[len(brackets)-1] == '['
+		}
+		result.WriteByte(char)
+	}
+
+	return result.String()
+}
+
+// Reports whether text is exactly one double-quoted string
+func isStringLiteral(text string) bool {
+	if len(text) < 2 || text[0] != '"' {
+		return false
+	}
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i == len(text)-1
+		}
+	}
+	return false
+}
+
+// Converts a hexadecimal integer literal to decimal, keeping its sign
+func hexToDecimal(text string) (string, bool) {
+	if !hexPattern.MatchString(text) {
+		return "", false
+	}
+	digits := strings.TrimPrefix(text, "-")
+	number, err := strconv.ParseUint(digits[2:], 16, 64)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(text, digits) + strconv.FormatUint(number, 10), true
+}
+
+// Returns the index just past the value starting at start: the next comma or
+// closing bracket outside parentheses and quotes
+func expressionEnd(input string, start int) int {
+	depth := 0
+	inQuotes := false
+	for i := start; i < len(input); i++ {
+		char := input[i]
+		switch {
+		case inQuotes:
+			if char == '\\' {
+				i++
+			} else if char == '"' {
+				inQuotes = false
+			}
+		case char == '"':
+			inQuotes = true
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+		case depth <= 0 && (char == ',' || char == '}' || char == ']'):
+			return i
+		}
+	}
+	return len(input)
+}
+
+// Compiles an expression, evaluating it right away unless it reads the clock
+// or calls one of functions
+func compileExpression(source string, functions map[string]ScriptFunc) (interface{}, error) {
+	parser := &expressionParser{source: source, functions: functions}
+	if err := parser.tokenize(); err != nil {
+		return nil, err
+	}
+	eval, err := parser.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unsupported expression %s", source)
+	}
+	if !parser.deferred {
+		return eval(time.Time{})
+	}
+	return Expression{Source: source, eval: eval}, nil
+}
+
+// Token of an expression: a number, string, identifier or operator
+type expressionToken struct {
+	kind byte // 'n', 's', 'i' or the operator itself
+	text string
+}
+
+// Recursive descent parser for the arithmetic subset of JavaScript scripts use
+type expressionParser struct {
+	source    string
+	tokens    []expressionToken
+	pos       int
+	functions map[string]ScriptFunc
+	// Set when the expression reads the clock or calls a registered function,
+	// so it is evaluated each time its operation executes
+	deferred bool
+}
+
+// Splits the source into tokens
+func (e *expressionParser) tokenize() error {
+	source := e.source
+	for i := 0; i < len(source); {
+		char := source[i]
+		switch {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			i++
+		case char == '0' && i+1 < len(source) && (source[i+1] == 'x' || source[i+1] == 'X'):
+			start := i
+			i += 2
+			for i < len(source) && strings.IndexByte("0123456789abcdefABCDEF", source[i]) >= 0 {
+				i++
+			}
+			e.tokens = append(e.tokens, expressionToken{kind: 'n', text: source[start:i]})
+		case char >= '0' && char <= '9' || char == '.':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.' ||
+				source[i] == 'e' || source[i] == 'E' ||
+				(source[i] == '+' || source[i] == '-') && (source[i-1] == 'e' || source[i-1] == 'E')) {
+				i++
+			}
+			e.tokens = append(e.tokens, expressionToken{kind: 'n', text: source[start:i]})
+		case char == '"' || char == '\'':
+			end := closingQuote(source, i)
+			if end < 0 {
+				return fmt.Errorf("unterminated string in expression %s", e.source)
+			}
+			text, err := unquoteJSON5(source[i : end+1])
+			if err != nil {
+				return fmt.Errorf("invalid string in expression %s: %w", e.source, err)
+			}
+			e.tokens = append(e.tokens, expressionToken{kind: 's', text: text})
+			i = end + 1
+		case isAlphaStart(rune(char)):
+			start := i
+			for i < len(source) && (isAlphaNum(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			e.tokens = append(e.tokens, expressionToken{kind: 'i', text: source[start:i]})
+		case strings.IndexByte("+-*/%(),", char) >= 0:
+			e.tokens = append(e.tokens, expressionToken{kind: char})
+			i++
+		default:
+			return fmt.Errorf("unsupported expression %s", e.source)
+		}
+	}
+	return nil
+}
+
+// Returns the next token without consuming it
+func (e *expressionParser) peek() expressionToken {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos]
+	}
+	return expressionToken{}
+}
+
+// Consumes the next token when it is of the given kind
+func (e *expressionParser) accept(kind byte) bool {
+	if e.peek().kind == kind {
+		e.pos++
+		return true
+	}
+	return false
+}
+
+// Parses additions and subtractions
+func (e *expressionParser) parseSum() (expressionFunc, error) {
+	left, err := e.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := e.peek().kind
+		if operator != '+' && operator != '-' {
+			return left, nil
+		}
+		e.pos++
+		right, err := e.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpression(operator, left, right)
+	}
+}
+
+// Parses multiplications, divisions and remainders
+func (e *expressionParser) parseProduct() (expressionFunc, error) {
+	left, err := e.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := e.peek().kind
+		if operator != '*' && operator != '/' && operator != '%' {
+			return left, nil
+		}
+		e.pos++
+		right, err := e.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpression(operator, left, right)
+	}
+}
+
+// Parses unary signs
+func (e *expressionParser) parseUnary() (expressionFunc, error) {
+	if e.accept('-') {
+		operand, err := e.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryExpression('-', constantExpression(0.0), operand), nil
+	}
+	e.accept('+')
+	return e.parsePrimary()
+}
+
+// Parses numbers, strings, parenthesized expressions and date constructors
+func (e *expressionParser) parsePrimary() (expressionFunc, error) {
+	token := e.peek()
+	e.pos++
+	switch token.kind {
+	case 'n':
+		if decimal, ok := hexToDecimal(token.text); ok {
+			token.text = decimal
+		}
+		value, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s in expression %s", token.text, e.source)
+		}
+		return constantExpression(value), nil
+	case 's':
+		return constantExpression(token.text), nil
+	case '(':
+		inner, err := e.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !e.accept(')') {
+			return nil, fmt.Errorf("missing ) in expression %s", e.source)
+		}
+		return inner, nil
+	case 'i':
+		switch token.text {
+		case "Date.now":
+			if !e.accept('(') || !e.accept(')') {
+				return nil, fmt.Errorf("Date.now takes no arguments in expression %s", e.source)
+			}
+			e.deferred = true
+			return func(now time.Time) (interface{}, error) {
+				return float64(now.UnixMilli()), nil
+			}, nil
+		case "new":
+			if next := e.peek(); next.kind != 'i' || next.text != "Date" {
+				break
+			}
+			e.pos++
+			return e.parseDate()
+		case "ISODate":
+			return e.parseDate()
+		case "Infinity":
+			return constantExpression(math.Inf(1)), nil
+		case "NaN":
+			return constantExpression(math.NaN()), nil
+		case "undefined":
+			return constantExpression(undefinedValue{}), nil
+		}
+		if fn, ok := e.functions[token.text]; ok {
+			return e.parseCall(token.text, fn)
+		}
+	}
+	return nil, fmt.Errorf("unsupported expression %s", e.source)
+}
+
+// Parses the arguments of new Date(...) or ISODate(...). Without arguments it
+// is the current time; a number is milliseconds since the epoch, a string an
+// ISO 8601 date and several numbers are UTC date components with 0-based months.
+func (e *expressionParser) parseDate() (expressionFunc, error) {
+	if !e.accept('(') {
+		return nil, fmt.Errorf("missing ( in expression %s", e.source)
+	}
+	var args []expressionFunc
+	for !e.accept(')') {
+		if len(args) > 0 && !e.accept(',') {
+			return nil, fmt.Errorf("missing , in expression %s", e.source)
+		}
+		arg, err := e.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		e.deferred = true
+		return func(now time.Time) (interface{}, error) {
+			return now, nil
+		}, nil
+	}
+	if len(args) > 7 {
+		return nil, fmt.Errorf("too many date arguments in expression %s", e.source)
+	}
+
+	return func(now time.Time) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, err := arg(now)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		if len(values) == 1 {
+			switch v := values[0].(type) {
+			case string:
+				return parseDate(v)
+			case time.Time:
+				return v, nil
+			}
+		}
+
+		components := []int{0, 0, 1, 0, 0, 0, 0}
+		for i, value := range values {
+			number, err := expressionNumber(value)
+			if err != nil {
+				return nil, err
+			}
+			components[i] = int(number)
+		}
+		if len(values) == 1 {
+			return time.UnixMilli(int64(components[0])).UTC(), nil
+		}
+		return time.Date(components[0], time.Month(components[1]+1), components[2],
+			components[3], components[4], components[5], components[6]*int(time.Millisecond), time.UTC), nil
+	}, nil
+}
+
+// Parses the arguments of a call of a registered function, which is made each
+// time the expression is evaluated
+func (e *expressionParser) parseCall(name string, fn ScriptFunc) (expressionFunc, error) {
+	if !e.accept('(') {
+		return nil, fmt.Errorf("missing ( after %s in expression %s", name, e.source)
+	}
+	var args []expressionFunc
+	for !e.accept(')') {
+		if len(args) > 0 && !e.accept(',') {
+			return nil, fmt.Errorf("missing , in expression %s", e.source)
+		}
+		arg, err := e.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	e.deferred = true
+
+	return func(now time.Time) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, err := arg(now)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		result, err := fn(values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return result, nil
+	}, nil
+}
+
+// Returns an expression with a fixed value
+func constantExpression(value interface{}) expressionFunc {
+	return func(time.Time) (interface{}, error) {
+		return value, nil
+	}
+}
+
+// Applies an arithmetic operator. Dates take part as milliseconds since the
+// epoch and + concatenates when either side is a string, as in JavaScript.
+func binaryExpression(operator byte, left, right expressionFunc) expressionFunc {
+	return func(now time.Time) (interface{}, error) {
+		a, err := left(now)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(now)
+		if err != nil {
+			return nil, err
+		}
+
+		if operator == '+' {
+			aText, aString := a.(string)
+			bText, bString := b.(string)
+			if aString || bString {
+				if !aString {
+					aText = formatExpressionValue(a)
+				}
+				if !bString {
+					bText = formatExpressionValue(b)
+				}
+				return aText + bText, nil
+			}
+		}
+
+		x, err := expressionNumber(a)
+		if err != nil {
+			return nil, err
+		}
+		y, err := expressionNumber(b)
+		if err != nil {
+			return nil, err
+		}
+		switch operator {
+		case '+':
+			return x + y, nil
+		case '-':
+			return x - y, nil
+		case '*':
+			return x * y, nil
+		case '/':
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		default:
+			if y == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return math.Mod(x, y), nil
+		}
+	}
+}
+
+// Converts an operand of arithmetic to a number
+func expressionNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case time.Time:
+		return float64(v.UnixMilli()), nil
+	default:
+		return 0, fmt.Errorf("cannot use %v in arithmetic", value)
+	}
+}
+
+// Formats a value concatenated to a string
+func formatExpressionValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Evaluates the expressions in an operation's documents at the parser's
+// current time. The operation is copied where it changes, so cached
+// operations stay untouched.
+func (p *Parser) evaluateExpressions(op MongoOperation) (MongoOperation, error) {
+	now := p.now()
+
+	arguments, err := evaluateDocuments(op.Arguments, now)
+	if err != nil {
+		return op, err
+	}
+	op.Arguments = arguments
+	pipeline, err := evaluateDocuments(op.UpdatePipeline, now)
+	if err != nil {
+		return op, err
+	}
+	op.UpdatePipeline = pipeline
+	if op.Values != nil {
+		values, _, err := evaluateValue(bson.A(op.Values), now)
+		if err != nil {
+			return op, err
+		}
+		op.Values = values.(bson.A)
+	}
+
+	if op.Assertion != nil {
+		assertion := *op.Assertion
+		assertion.Operands = append([]Operand(nil), assertion.Operands...)
+		for i, operand := range assertion.Operands {
+			value, _, err := evaluateValue(operand.Value, now)
+			if err != nil {
+				return op, fmt.Errorf("failed to evaluate %s: %w", operand.Text, err)
+			}
+			assertion.Operands[i].Value = value
+		}
+		op.Assertion = &assertion
+	}
+	return op, nil
+}
+
+// Evaluates the expressions in a list of documents, copying it when any changes
+func evaluateDocuments(docs []bson.D, now time.Time) ([]bson.D, error) {
+	var evaluated []bson.D
+	for i, doc := range docs {
+		value, changed, err := evaluateValue(doc, now)
+		if err != nil {
+			return nil, err
+		}
+		if changed && evaluated == nil {
+			evaluated = append([]bson.D(nil), docs...)
+		}
+		if evaluated != nil {
+			evaluated[i] = value.(bson.D)
+		}
+	}
+	if evaluated == nil {
+		return docs, nil
+	}
+	return evaluated, nil
+}
+
+// Evaluates the expressions within a value and reports whether any was found
+func evaluateValue(value interface{}, now time.Time) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case Expression:
+		result, err := v.eval(now)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to evaluate %s: %w", v.Source, err)
+		}
+		return result, true, nil
+	case bson.D:
+		var doc bson.D
+		for i, elem := range v {
+			evaluated, changed, err := evaluateValue(elem.Value, now)
+			if err != nil {
+				return nil, false, err
+			}
+			if changed && doc == nil {
+				doc = append(bson.D(nil), v...)
+			}
+			if doc != nil {
+				doc[i].Value = evaluated
+			}
+		}
+		if doc == nil {
+			return v, false, nil
+		}
+		return doc, true, nil
+	case bson.A:
+		var arr bson.A
+		for i, item := range v {
+			evaluated, changed, err := evaluateValue(item, now)
+			if err != nil {
+				return nil, false, err
+			}
+			if changed && arr == nil {
+				arr = append(bson.A(nil), v...)
+			}
+			if arr != nil {
+				arr[i] = evaluated
+			}
+		}
+		if arr == nil {
+			return v, false, nil
+		}
+		return arr, true, nil
+	default:
+		return value, false, nil
+	}
+}
@@ -0,0 +1,162 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCompileExpressionConstant(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		{`60 * 60 * 24`, 86400.0},
+		{`-(1 + 2) * 4 % 5`, -2.0},
+		{`"user-" + 7`, "user-7"},
+		{`new Date(0)`, time.Unix(0, 0).UTC()},
+		{`new Date(2024, 0, 31)`, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{`ISODate("2024-01-02T03:04:05Z")`, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{`new Date("2024-01-02")`, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		value, err := compileExpression(tt.source, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.source, err)
+			continue
+		}
+		if value != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.source, tt.expected, value)
+		}
+	}
+}
+
+func TestCompileExpressionInvalid(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`ObjectId("abc")`, "unsupported expression"},
+		{`1 / 0`, "division by zero"},
+		{`Date.now(1)`, "takes no arguments"},
+		{`(1 + 2`, "missing )"},
+		{`new Date("soon")`, "cannot parse"},
+	}
+	for _, tt := range tests {
+		_, err := compileExpression(tt.source, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.source, tt.err, err)
+		}
+	}
+}
+
+func TestExpressionsEvaluatedAtExecution(t *testing.T) {
+	clock := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	parser := NewParser(WithFixedClock(clock))
+	op, err := parser.parseMongoStatement(`db.sessions.insertOne({ createdAt: new Date(), expires: new Date(Date.now() + 3600 * 1000), tags: ["a", Date.now() - 86400000], ttl: 60 * 60 })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := op.Arguments[0]
+	if _, ok := doc[0].Value.(Expression); !ok {
+		t.Fatalf("Expected createdAt to stay an expression until execution, got %T", doc[0].Value)
+	}
+	if doc[3].Value != 3600.0 {
+		t.Errorf("Expected constant arithmetic to be folded, got %v", doc[3].Value)
+	}
+
+	evaluated, err := parser.evaluateExpressions(*op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.D{
+		{Key: "createdAt", Value: clock},
+		{Key: "expires", Value: clock.Add(time.Hour)},
+		{Key: "tags", Value: bson.A{"a", float64(clock.Add(-24 * time.Hour).UnixMilli())}},
+		{Key: "ttl", Value: 3600.0},
+	}
+	if !valuesEqual(evaluated.Arguments[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, evaluated.Arguments[0])
+	}
+	if _, ok := op.Arguments[0][0].Value.(Expression); !ok {
+		t.Error("Evaluation must not modify the parsed operation")
+	}
+}
+
+func TestWrapExpressionsLeavesLiterals(t *testing.T) {
+	input := `{"a": 1.5, "b": "new Date()", "c": [true, null, -2, "x\" + 1"], "d": {}}`
+	if wrapped := wrapExpressions(input); wrapped != input {
+		t.Errorf("Expected literals to be unchanged, got %s", wrapped)
+	}
+}
+
+func TestArithmeticInDocumentValues(t *testing.T) {
+	parser := NewParser()
+
+	capped, err := parser.parseMongoStatement(`db.createCollection("log", { capped: true, size: 1024 * 1024, max: (10 * 1000) })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := capped.CollOptions.SizeInBytes; size == nil || *size != 1048576 {
+		t.Errorf("Expected size of 1MB, got %v", size)
+	}
+
+	ttl, err := parser.parseMongoStatement(`db.sessions.createIndex({ createdAt: 1 }, { expireAfterSeconds: 60 * 60 * 24 })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seconds := ttl.IndexOptions.ExpireAfterSeconds; seconds == nil || *seconds != 86400 {
+		t.Errorf("Expected a TTL of one day, got %v", seconds)
+	}
+
+	insert, err := parser.parseMongoStatement(`db.users.insertOne({ "name": 'user_' + "admin", tags: ["a" + 1, "b, c"], quota: 5 * 1024 })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.D{
+		{Key: "name", Value: "user_admin"},
+		{Key: "tags", Value: bson.A{"a1", "b, c"}},
+		{Key: "quota", Value: 5120.0},
+	}
+	if !valuesEqual(insert.Arguments[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, insert.Arguments[0])
+	}
+}
+
+func TestHexAndScientificLiterals(t *testing.T) {
+	var doc bson.D
+	parser := NewParser()
+	if err := parser.parseJSONLikeString(`{ mask: 0xFF, neg: -0x10, size: 1e6, tiny: 2.5E-3, big: 1E+3, sum: 0x10 * 2 }`, &doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.D{
+		{Key: "mask", Value: 255.0},
+		{Key: "neg", Value: -16.0},
+		{Key: "size", Value: 1e6},
+		{Key: "tiny", Value: 0.0025},
+		{Key: "big", Value: 1000.0},
+		{Key: "sum", Value: 32.0},
+	}
+	if !valuesEqual(doc, expected) {
+		t.Errorf("Expected %v, got %v", expected, doc)
+	}
+
+	capped, err := parser.parseMongoStatement(`db.createCollection("log", { capped: true, size: 1e6, max: 0x400 })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *capped.CollOptions.SizeInBytes != 1000000 || *capped.CollOptions.MaxDocuments != 1024 {
+		t.Errorf("Unexpected capped options size=%d max=%d", *capped.CollOptions.SizeInBytes, *capped.CollOptions.MaxDocuments)
+	}
+
+	large, err := NewParser(WithNumberMode(NumberInt64)).parseMongoStatement(`db.ids.insertOne({ id: 0x20000000000001 })`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := large.Arguments[0][0].Value; id != int64(9007199254740993) {
+		t.Errorf("Expected hexadecimal id to keep int64 precision, got %v (%T)", id, id)
+	}
+}
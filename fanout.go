@@ -0,0 +1,53 @@
+package mongoparser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Applies one script to many databases with at most concurrency executions in
+// flight and returns the result for each database. Databases not yet started
+// when ctx is done report the context error.
+func (p *Parser) ExecuteOnDatabases(ctx context.Context, client *mongo.Client, dbNames []string, js string, concurrency int) map[string]ScriptResult {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make(map[string]ScriptResult, len(dbNames))
+	var mu sync.Mutex
+	names := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(dbNames); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				result := p.ExecuteScript(ctx, client.Database(name), js)
+				mu.Lock()
+				results[name] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for i, name := range dbNames {
+		select {
+		case names <- name:
+		case <-ctx.Done():
+			mu.Lock()
+			for _, skipped := range dbNames[i:] {
+				results[skipped] = ScriptResult{Error: ctx.Err(), StartedAt: time.Now()}
+			}
+			mu.Unlock()
+			break dispatch
+		}
+	}
+	close(names)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,45 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestExecuteOnDatabases(t *testing.T) {
+	// Connect does not dial until an operation runs, and comment-only scripts run none
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	parser := NewParser()
+	script := "// METADATA:\n// { \"name\": \"tenant_setup\" }\n"
+	dbNames := []string{"tenant_a", "tenant_b", "tenant_c", "tenant_d"}
+
+	results := parser.ExecuteOnDatabases(context.Background(), client, dbNames, script, 2)
+	if len(results) != len(dbNames) {
+		t.Fatalf("Expected %d results, got %d", len(dbNames), len(results))
+	}
+	for _, name := range dbNames {
+		if result := results[name]; !result.Success || result.Name != "tenant_setup" {
+			t.Errorf("Unexpected result for %s: %+v", name, result)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = parser.ExecuteOnDatabases(ctx, client, dbNames, script, 1)
+	if len(results) != len(dbNames) {
+		t.Fatalf("Expected a result for every database, got %d", len(results))
+	}
+	for name, result := range results {
+		if !result.Success && !errors.Is(result.Error, context.Canceled) {
+			t.Errorf("Unexpected error for %s: %v", name, result.Error)
+		}
+	}
+}
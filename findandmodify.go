@@ -0,0 +1,265 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Holds the findAndModify settings shared by the findOneAnd* driver calls
+type FindAndModifyOptions struct {
+	Sort         bson.D             `json:"sort,omitempty"`
+	Fields       bson.D             `json:"fields,omitempty"`
+	New          bool               `json:"new,omitempty"`
+	Upsert       bool               `json:"upsert,omitempty"`
+	ArrayFilters []interface{}      `json:"array_filters,omitempty"`
+	Collation    *options.Collation `json:"collation,omitempty"`
+	Hint         interface{}        `json:"hint,omitempty"`
+}
+
+// Parses findAndModify({ query, sort, remove, update, new, fields, upsert, ... })
+// into findOneAndDelete, findOneAndReplace or findOneAndUpdate
+func (p *Parser) parseFindAndModify(collection, argsString string) (*MongoOperation, error) {
+	args := p.splitArguments(argsString)
+	if len(args) != 1 {
+		return nil, fmt.Errorf("findAndModify requires a single command document")
+	}
+
+	var command bson.D
+	if err := p.parseJSONLikeString(args[0], &command); err != nil {
+		return nil, fmt.Errorf("failed to parse findAndModify document: %w", err)
+	}
+	fields, _ := asMap(command)
+
+	op := &MongoOperation{
+		Type:                 "findAndModify",
+		Collection:           collection,
+		FindAndModifyOptions: &FindAndModifyOptions{},
+	}
+	opts := op.FindAndModifyOptions
+
+	query := bson.D{}
+	if value, ok := fields["query"]; ok {
+		if query, ok = value.(bson.D); !ok {
+			return nil, fmt.Errorf("findAndModify query must be a document")
+		}
+	}
+
+	var err error
+	if opts.Sort, err = findAndModifyDocument(fields, "sort"); err != nil {
+		return nil, err
+	}
+	if opts.Fields, err = findAndModifyDocument(fields, "fields"); err != nil {
+		return nil, err
+	}
+	if opts.New, err = findAndModifyBool(fields, "new"); err != nil {
+		return nil, err
+	}
+	if opts.Upsert, err = findAndModifyBool(fields, "upsert"); err != nil {
+		return nil, err
+	}
+	remove, err := findAndModifyBool(fields, "remove")
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := fields["arrayFilters"]; ok {
+		filters, ok := value.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("findAndModify arrayFilters must be an array")
+		}
+		opts.ArrayFilters = []interface{}(filters)
+	}
+	if value, ok := fields["collation"]; ok {
+		if opts.Collation, err = p.parseCollation(value); err != nil {
+			return nil, fmt.Errorf("failed to parse findAndModify collation: %w", err)
+		}
+	}
+	if value, ok := fields["hint"]; ok {
+		if opts.Hint, err = p.parseHint(value); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.parseConcernOptions(fields, op); err != nil {
+		return nil, err
+	}
+
+	update, hasUpdate := fields["update"]
+	switch {
+	case remove && hasUpdate:
+		return nil, fmt.Errorf("findAndModify cannot both remove and update")
+	case remove:
+		if opts.New || opts.Upsert {
+			return nil, fmt.Errorf("findAndModify remove cannot be combined with new or upsert")
+		}
+		op.Operation = "findOneAndDelete"
+		op.Arguments = []bson.D{query}
+	case !hasUpdate:
+		return nil, fmt.Errorf("findAndModify requires either remove or update")
+	default:
+		switch u := update.(type) {
+		case bson.A:
+			pipeline, err := toDocuments(u)
+			if err != nil {
+				return nil, fmt.Errorf("findAndModify update pipeline: %w", err)
+			}
+			op.Operation = "findOneAndUpdate"
+			op.Arguments = []bson.D{query}
+			op.UpdatePipeline = pipeline
+		case bson.D:
+			op.Operation = "findOneAndUpdate"
+			if !hasUpdateOperators(u) {
+				op.Operation = "findOneAndReplace"
+			}
+			op.Arguments = []bson.D{query, u}
+		default:
+			return nil, fmt.Errorf("findAndModify update must be a document or pipeline")
+		}
+	}
+
+	return op, nil
+}
+
+// Returns the optional document stored under key
+func findAndModifyDocument(fields map[string]interface{}, key string) (bson.D, error) {
+	value, ok := fields[key]
+	if !ok {
+		return nil, nil
+	}
+	doc, ok := value.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("findAndModify %s must be a document", key)
+	}
+	return doc, nil
+}
+
+// Returns the optional boolean stored under key
+func findAndModifyBool(fields map[string]interface{}, key string) (bool, error) {
+	value, ok := fields[key]
+	if !ok {
+		return false, nil
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("findAndModify %s must be a boolean", key)
+	}
+	return b, nil
+}
+
+// Converts an array of documents such as an update pipeline
+func toDocuments(values bson.A) ([]bson.D, error) {
+	docs := make([]bson.D, 0, len(values))
+	for i, value := range values {
+		doc, ok := value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("element %d is not a document", i)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// Executes a findAndModify through the matching findOneAnd* call and returns the
+// document it found or produced, or nil when nothing matched
+func (p *Parser) executeFindAndModify(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if len(op.Arguments) == 0 {
+		return nil, fmt.Errorf("findAndModify operation requires a query document")
+	}
+
+	collection := p.collection(db, op)
+	query := op.Arguments[0]
+	opts := op.FindAndModifyOptions
+	if opts == nil {
+		opts = &FindAndModifyOptions{}
+	}
+	returnDocument := options.Before
+	if opts.New {
+		returnDocument = options.After
+	}
+
+	var result *mongo.SingleResult
+	switch op.Operation {
+	case "findOneAndDelete":
+		deleteOpts := options.FindOneAndDelete()
+		if opts.Sort != nil {
+			deleteOpts.SetSort(opts.Sort)
+		}
+		if opts.Fields != nil {
+			deleteOpts.SetProjection(opts.Fields)
+		}
+		if opts.Collation != nil {
+			deleteOpts.SetCollation(opts.Collation)
+		}
+		if opts.Hint != nil {
+			deleteOpts.SetHint(opts.Hint)
+		}
+		if op.Comment != "" {
+			deleteOpts.SetComment(op.Comment)
+		}
+		result = collection.FindOneAndDelete(ctx, query, deleteOpts)
+	case "findOneAndReplace":
+		if len(op.Arguments) < 2 {
+			return nil, fmt.Errorf("findOneAndReplace requires a replacement document")
+		}
+		replaceOpts := options.FindOneAndReplace().SetUpsert(opts.Upsert).SetReturnDocument(returnDocument)
+		if opts.Sort != nil {
+			replaceOpts.SetSort(opts.Sort)
+		}
+		if opts.Fields != nil {
+			replaceOpts.SetProjection(opts.Fields)
+		}
+		if opts.Collation != nil {
+			replaceOpts.SetCollation(opts.Collation)
+		}
+		if opts.Hint != nil {
+			replaceOpts.SetHint(opts.Hint)
+		}
+		if op.Comment != "" {
+			replaceOpts.SetComment(op.Comment)
+		}
+		result = collection.FindOneAndReplace(ctx, query, op.Arguments[1], replaceOpts)
+	case "findOneAndUpdate":
+		var update interface{}
+		if op.UpdatePipeline != nil {
+			update = op.UpdatePipeline
+		} else if len(op.Arguments) > 1 {
+			update = op.Arguments[1]
+		} else {
+			return nil, fmt.Errorf("findOneAndUpdate requires an update document")
+		}
+		updateOpts := options.FindOneAndUpdate().SetUpsert(opts.Upsert).SetReturnDocument(returnDocument)
+		if opts.Sort != nil {
+			updateOpts.SetSort(opts.Sort)
+		}
+		if opts.Fields != nil {
+			updateOpts.SetProjection(opts.Fields)
+		}
+		if opts.ArrayFilters != nil {
+			updateOpts.SetArrayFilters(options.ArrayFilters{Filters: opts.ArrayFilters})
+		}
+		if opts.Collation != nil {
+			updateOpts.SetCollation(opts.Collation)
+		}
+		if opts.Hint != nil {
+			updateOpts.SetHint(opts.Hint)
+		}
+		if op.Comment != "" {
+			updateOpts.SetComment(op.Comment)
+		}
+		result = collection.FindOneAndUpdate(ctx, query, update, updateOpts)
+	default:
+		return nil, fmt.Errorf("unsupported findAndModify operation: %s", op.Operation)
+	}
+
+	var doc bson.D
+	if err := result.Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return doc, nil
+}
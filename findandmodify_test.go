@@ -0,0 +1,66 @@
+package mongoparser
+
+import (
+	"testing"
+)
+
+func TestParseFindAndModify(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		name      string
+		statement string
+		operation string
+	}{
+		{"update", `db.counters.findAndModify({ query: { _id: "orders" }, update: { $inc: { seq: 1 } }, new: true, upsert: true })`, "findOneAndUpdate"},
+		{"replace", `db.users.findAndModify({ query: { name: "Ada" }, update: { name: "Ada", admin: true } })`, "findOneAndReplace"},
+		{"remove", `db.jobs.findAndModify({ query: { state: "queued" }, sort: { priority: -1 }, remove: true })`, "findOneAndDelete"},
+		{"pipeline", `db.users.findAndModify({ query: { name: "Ada" }, update: [{ $set: { seen: true } }] })`, "findOneAndUpdate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, err := parser.parseMongoStatement(tt.statement)
+			if err != nil {
+				t.Fatalf("parseMongoStatement() returned error: %v", err)
+			}
+			if op.Type != "findAndModify" || op.Operation != tt.operation {
+				t.Errorf("Expected findAndModify/%s, got %s/%s", tt.operation, op.Type, op.Operation)
+			}
+		})
+	}
+
+	op, _ := parser.parseMongoStatement(tests[0].statement)
+	if opts := op.FindAndModifyOptions; !opts.New || !opts.Upsert {
+		t.Errorf("Expected new and upsert to be set, got %+v", opts)
+	}
+	if op.Arguments[0][0].Value != "orders" {
+		t.Errorf("Expected query to be parsed, got %v", op.Arguments[0])
+	}
+
+	op, _ = parser.parseMongoStatement(tests[2].statement)
+	if sort := op.FindAndModifyOptions.Sort; len(sort) != 1 || sort[0].Key != "priority" {
+		t.Errorf("Expected sort to be parsed, got %v", sort)
+	}
+
+	op, _ = parser.parseMongoStatement(tests[3].statement)
+	if len(op.UpdatePipeline) != 1 {
+		t.Errorf("Expected update pipeline, got %v", op.UpdatePipeline)
+	}
+}
+
+func TestParseFindAndModifyInvalid(t *testing.T) {
+	parser := NewParser()
+
+	statements := []string{
+		`db.users.findAndModify({ query: { name: "Ada" } })`,
+		`db.users.findAndModify({ query: { name: "Ada" }, remove: true, update: { $set: { a: 1 } } })`,
+		`db.users.findAndModify({ query: { name: "Ada" }, remove: true, new: true })`,
+		`db.users.findAndModify({ query: { name: "Ada" }, update: { $set: { a: 1 } }, upsert: "yes" })`,
+	}
+	for _, statement := range statements {
+		if _, err := parser.parseMongoStatement(statement); err == nil {
+			t.Errorf("Expected error for %s", statement)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Generates count random documents satisfying a $jsonSchema, for fixtures and
+// load tests. Values respect bsonType, enum, pattern, minLength and maxLength,
+// minimum and maximum, and minItems and maxItems; required properties are
+// always set and the others half of the time. String properties named like
+// email, name, city, country or phone get realistic values when the schema
+// doesn't constrain them further. The same randomSeed generates the same
+// documents, apart from objectId and date values; 0 picks one at random.
+func GenerateFixtures(schema bson.D, count int, randomSeed uint64) ([]bson.D, error) {
+	generator := newFixtureGenerator(randomSeed)
+	docs := make([]bson.D, 0, count)
+	for i := 0; i < count; i++ {
+		doc, err := generator.document(schema)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// Generates count documents from the $jsonSchema of the collection's validator
+// as it exists in db, and inserts them in chunks. Returns the number inserted.
+func (p *Parser) InsertFixtures(ctx context.Context, db *mongo.Database, collection string, count int, randomSeed uint64) (int64, error) {
+	inserted, err := p.executeSeed(ctx, db, MongoOperation{
+		Type:       "seed",
+		Collection: collection,
+		Operation:  "seed",
+		Seed:       &SeedSpec{Count: count, FromValidator: true, RandomSeed: randomSeed},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return inserted.(int64), nil
+}
+
+// Returns the $jsonSchema of a collection's validator in db
+func validatorSchema(ctx context.Context, db *mongo.Database, collection string) (bson.D, error) {
+	options, err := collectionOptions(ctx, db, collection)
+	if err != nil {
+		return nil, err
+	}
+	validator, _ := lookupKey(options, "validator")
+	doc, _ := validator.(bson.D)
+	schema, _ := lookupKey(doc, "$jsonSchema")
+	if schemaDoc, ok := schema.(bson.D); ok {
+		return schemaDoc, nil
+	}
+	return nil, fmt.Errorf("collection %s has no $jsonSchema validator to generate documents from", collection)
+}
+
+// Returns a stream of documents generated from schema for insertStream
+func fixtureStream(schema bson.D, count int, randomSeed uint64) func() (bson.D, error) {
+	generator := newFixtureGenerator(randomSeed)
+	generated := 0
+	return func() (bson.D, error) {
+		if generated == count {
+			return nil, io.EOF
+		}
+		generated++
+		return generator.document(schema)
+	}
+}
+
+// Most repetitions of a pattern's unbounded quantifiers, and attempts at a
+// value satisfying the length limits along with the pattern
+const (
+	fixtureMaxRepeat = 8
+	fixtureAttempts  = 100
+)
+
+// Random values following $jsonSchema constraints
+type fixtureGenerator struct {
+	rng *rand.Rand
+	// Position of the document being generated, for the email generator
+	index int
+}
+
+// Creates a generator, picking a seed at random when randomSeed is 0
+func newFixtureGenerator(randomSeed uint64) *fixtureGenerator {
+	if randomSeed == 0 {
+		randomSeed = rand.Uint64()
+	}
+	return &fixtureGenerator{rng: rand.New(rand.NewPCG(randomSeed, randomSeed)), index: -1}
+}
+
+// Generates the next document
+func (g *fixtureGenerator) document(schema bson.D) (bson.D, error) {
+	g.index++
+	return g.object(schema, "")
+}
+
+// Generates a document with the required properties and some of the others
+func (g *fixtureGenerator) object(schema bson.D, path string) (bson.D, error) {
+	doc := bson.D{}
+	for _, property := range schemaProperties(schema) {
+		if !property.required && g.rng.IntN(2) == 0 {
+			continue
+		}
+		value, err := g.value(property.schema, joinPath(path, property.name))
+		if err != nil {
+			return nil, err
+		}
+		doc = append(doc, bson.E{Key: property.name, Value: value})
+	}
+	return doc, nil
+}
+
+// Generates a value for a property schema at path
+func (g *fixtureGenerator) value(schema bson.D, path string) (interface{}, error) {
+	if values, ok := lookupKey(schema, "enum"); ok {
+		list, ok := values.(bson.A)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("enum at %s must be a non-empty array", path)
+		}
+		return list[g.rng.IntN(len(list))], nil
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if branches, ok := lookupKey(schema, keyword); ok {
+			list, _ := branches.(bson.A)
+			if len(list) == 0 {
+				return nil, fmt.Errorf("%s at %s must be a non-empty array", keyword, path)
+			}
+			branch, _ := list[g.rng.IntN(len(list))].(bson.D)
+			return g.value(branch, path)
+		}
+	}
+	for _, keyword := range []string{"allOf", "not"} {
+		if _, ok := lookupKey(schema, keyword); ok {
+			return nil, fmt.Errorf("can't generate a value for %s at %s", keyword, path)
+		}
+	}
+
+	types, nullable := allowedTypes(schema)
+	if len(types) == 0 {
+		switch {
+		case nullable:
+			return nil, nil
+		case len(schemaProperties(schema)) > 0:
+			types = []string{"object"}
+		default:
+			types = []string{"string"}
+		}
+	}
+
+	switch name := types[g.rng.IntN(len(types))]; name {
+	case "string":
+		return g.string(schema, path)
+	case "int", "long":
+		low, high, err := numericRange(schema, path, true)
+		if err != nil {
+			return nil, err
+		}
+		value := int64(low) + g.rng.Int64N(int64(high)-int64(low)+1)
+		if name == "int" {
+			return int32(value), nil
+		}
+		return value, nil
+	case "double", "number", "decimal":
+		low, high, err := numericRange(schema, path, false)
+		if err != nil {
+			return nil, err
+		}
+		value := low + g.rng.Float64()*(high-low)
+		if name == "decimal" {
+			return primitive.ParseDecimal128(strconv.FormatFloat(value, 'f', 2, 64))
+		}
+		return value, nil
+	case "bool":
+		return g.rng.IntN(2) == 1, nil
+	case "date":
+		offset := time.Duration(g.rng.Int64N(int64(365 * 24 * time.Hour)))
+		return primitive.NewDateTimeFromTime(time.Now().Add(-offset)), nil
+	case "objectId":
+		return primitive.NewObjectID(), nil
+	case "timestamp":
+		return primitive.Timestamp{T: uint32(time.Now().Unix()), I: uint32(g.index + 1)}, nil
+	case "binData":
+		data := make([]byte, 16)
+		for i := range data {
+			data[i] = byte(g.rng.IntN(256))
+		}
+		return primitive.Binary{Data: data}, nil
+	case "object":
+		return g.object(schema, path)
+	case "array":
+		return g.array(schema, path)
+	default:
+		return nil, fmt.Errorf("can't generate a value of bsonType %q at %s", name, path)
+	}
+}
+
+// Generates a string matching the pattern and length limits of schema
+func (g *fixtureGenerator) string(schema bson.D, path string) (string, error) {
+	minLength, maxLength := 0, -1
+	if value, ok := lookupKey(schema, "minLength"); ok {
+		number, _ := toFloat64(value)
+		minLength = int(number)
+	}
+	if value, ok := lookupKey(schema, "maxLength"); ok {
+		number, _ := toFloat64(value)
+		maxLength = int(number)
+	}
+	fits := func(s string) bool {
+		length := len([]rune(s))
+		return length >= minLength && (maxLength < 0 || length <= maxLength)
+	}
+
+	if value, ok := lookupKey(schema, "pattern"); ok {
+		pattern, _ := value.(string)
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern at %s: %w", path, err)
+		}
+		re = re.Simplify()
+		for attempt := 0; attempt < fixtureAttempts; attempt++ {
+			var b strings.Builder
+			g.match(&b, re)
+			if fits(b.String()) {
+				return b.String(), nil
+			}
+		}
+		return "", fmt.Errorf("can't generate a string matching %s within the length limits at %s", pattern, path)
+	}
+
+	text := g.namedString(path)
+	for len([]rune(text)) < minLength {
+		text += " " + seedWords[g.rng.IntN(len(seedWords))]
+	}
+	if runes := []rune(text); maxLength >= 0 && len(runes) > maxLength {
+		text = string(runes[:maxLength])
+	}
+	return text, nil
+}
+
+// Generators used for string properties by the last word of their name
+var namedGenerators = map[string]string{
+	"email": "email", "name": "name", "firstname": "firstName", "lastname": "lastName",
+	"city": "city", "country": "country", "phone": "phone", "uuid": "uuid",
+}
+
+// Returns a realistic string for a property named like a known generator, or a word
+func (g *fixtureGenerator) namedString(path string) string {
+	name := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+	name = strings.NewReplacer("_", "", "-", "").Replace(name)
+	generator, matched := "word", ""
+	for suffix, candidate := range namedGenerators {
+		if strings.HasSuffix(name, suffix) && len(suffix) > len(matched) {
+			generator, matched = candidate, suffix
+		}
+	}
+	value, _ := generateValue(g.rng, g.index, generator, nil)
+	return fmt.Sprint(value)
+}
+
+// Writes a random string matching a simplified regular expression
+func (g *fixtureGenerator) match(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && g.rng.IntN(2) == 0 {
+				r = []rune(strings.ToUpper(string(r)))[0]
+			}
+			b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		// Ranges come in pairs; pick one weighted by size, then a rune in it
+		total := 0
+		for i := 0; i < len(re.Rune); i += 2 {
+			total += int(re.Rune[i+1]-re.Rune[i]) + 1
+		}
+		if total == 0 {
+			return
+		}
+		n := g.rng.IntN(total)
+		for i := 0; i < len(re.Rune); i += 2 {
+			size := int(re.Rune[i+1]-re.Rune[i]) + 1
+			if n < size {
+				b.WriteRune(re.Rune[i] + rune(n))
+				return
+			}
+			n -= size
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune('a' + g.rng.IntN(26)))
+	case syntax.OpCapture:
+		g.match(b, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.match(b, sub)
+		}
+	case syntax.OpAlternate:
+		g.match(b, re.Sub[g.rng.IntN(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		low, high := 0, fixtureMaxRepeat
+		switch re.Op {
+		case syntax.OpPlus:
+			low = 1
+		case syntax.OpQuest:
+			high = 1
+		case syntax.OpRepeat:
+			low, high = re.Min, re.Max
+			if high < 0 {
+				high = low + fixtureMaxRepeat
+			}
+		}
+		for n := low + g.rng.IntN(high-low+1); n > 0; n-- {
+			g.match(b, re.Sub[0])
+		}
+	}
+	// Anchors, word boundaries and empty matches produce no text
+}
+
+// Generates an array of items within minItems and maxItems
+func (g *fixtureGenerator) array(schema bson.D, path string) (bson.A, error) {
+	low, high := 0, 3
+	if value, ok := lookupKey(schema, "minItems"); ok {
+		number, _ := toFloat64(value)
+		low = int(number)
+		high = max(high, low)
+	}
+	if value, ok := lookupKey(schema, "maxItems"); ok {
+		number, _ := toFloat64(value)
+		high = min(high, int(number))
+	}
+	if high < low {
+		return nil, fmt.Errorf("minItems is greater than maxItems at %s", path)
+	}
+	unique := false
+	if value, ok := lookupKey(schema, "uniqueItems"); ok {
+		unique, _ = value.(bool)
+	}
+	items, _ := lookupKey(schema, "items")
+	itemSchema, _ := items.(bson.D)
+
+	length := low + g.rng.IntN(high-low+1)
+	array := bson.A{}
+	for attempt := 0; len(array) < length; attempt++ {
+		if attempt == fixtureAttempts*length {
+			return nil, fmt.Errorf("can't generate %d unique items at %s", length, path)
+		}
+		item, err := g.value(itemSchema, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		if unique && containsValue(array, item) {
+			continue
+		}
+		array = append(array, item)
+	}
+	return array, nil
+}
+
+// Reports whether array has an item equal to value
+func containsValue(array bson.A, value interface{}) bool {
+	for _, item := range array {
+		if sameDocument(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the bounds of a number from minimum, maximum and their exclusive
+// flags, defaulting to 0 and 1000 around whichever bound is set
+func numericRange(schema bson.D, path string, integer bool) (float64, float64, error) {
+	low, high := 0.0, 1000.0
+	minimum, hasMinimum := lookupKey(schema, "minimum")
+	maximum, hasMaximum := lookupKey(schema, "maximum")
+	if hasMinimum {
+		low, _ = toFloat64(minimum)
+		if !hasMaximum {
+			high = math.Max(high, low+1000)
+		}
+	}
+	if hasMaximum {
+		high, _ = toFloat64(maximum)
+		if !hasMinimum {
+			low = math.Min(low, high-1000)
+		}
+	}
+
+	step := 0.0
+	if integer {
+		low, high = math.Ceil(low), math.Floor(high)
+		step = 1
+	}
+	if exclusive, _ := lookupKey(schema, "exclusiveMinimum"); exclusive == true {
+		low += step
+	}
+	if exclusive, _ := lookupKey(schema, "exclusiveMaximum"); exclusive == true {
+		high -= step
+	}
+	if high < low {
+		return 0, 0, fmt.Errorf("minimum is greater than maximum at %s", path)
+	}
+	return low, high, nil
+}
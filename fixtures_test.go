@@ -0,0 +1,141 @@
+package mongoparser
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGenerateFixtures(t *testing.T) {
+	op, err := NewParser().parseMongoStatement(`db.createCollection("users", { validator: { $jsonSchema: {
+		bsonType: "object",
+		required: ["email", "sku", "age", "status", "tags", "address", "createdAt", "_id"],
+		properties: {
+			_id: { bsonType: "objectId" },
+			email: { bsonType: "string" },
+			sku: { bsonType: "string", pattern: "^[A-Z]{3}-\\d{4}$" },
+			nickname: { bsonType: "string", minLength: 12, maxLength: 16 },
+			age: { bsonType: "int", minimum: 18, maximum: 21 },
+			score: { bsonType: "double", minimum: 0, maximum: 1, exclusiveMaximum: true },
+			status: { enum: ["active", "disabled"] },
+			tags: { bsonType: "array", minItems: 2, maxItems: 2, uniqueItems: true, items: { enum: ["a", "b"] } },
+			address: { bsonType: "object", required: ["city"], properties: { city: { bsonType: "string" } } },
+			createdAt: { bsonType: "date" },
+			deletedAt: { bsonType: "null" }
+		}
+	} } })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+	schema, _ := lookupKey(op.Validator.(bson.D), "$jsonSchema")
+
+	docs, err := GenerateFixtures(schema.(bson.D), 200, 42)
+	if err != nil {
+		t.Fatalf("GenerateFixtures() returned error: %v", err)
+	}
+	if len(docs) != 200 {
+		t.Fatalf("Expected 200 documents, got %d", len(docs))
+	}
+
+	sku := regexp.MustCompile(`^[A-Z]{3}-\d{4}$`)
+	for _, doc := range docs {
+		get := func(key string) interface{} {
+			value, ok := lookupKey(doc, key)
+			if !ok {
+				t.Fatalf("Expected required %s in %v", key, doc)
+			}
+			return value
+		}
+		if _, ok := get("_id").(primitive.ObjectID); !ok {
+			t.Errorf("Expected an ObjectID _id, got %v", doc)
+		}
+		if email := get("email").(string); !strings.Contains(email, "@") {
+			t.Errorf("Expected a realistic email, got %q", email)
+		}
+		if value := get("sku").(string); !sku.MatchString(value) {
+			t.Errorf("Expected sku to match the pattern, got %q", value)
+		}
+		if age := get("age").(int32); age < 18 || age > 21 {
+			t.Errorf("Expected age within 18-21, got %d", age)
+		}
+		if status := get("status"); status != "active" && status != "disabled" {
+			t.Errorf("Expected an enum status, got %v", status)
+		}
+		if tags := get("tags").(bson.A); len(tags) != 2 || tags[0] == tags[1] {
+			t.Errorf("Expected two unique tags, got %v", tags)
+		}
+		if _, ok := lookupKey(get("address").(bson.D), "city"); !ok {
+			t.Errorf("Expected the nested required city, got %v", doc)
+		}
+		if nickname, ok := lookupKey(doc, "nickname"); ok {
+			if length := len([]rune(nickname.(string))); length < 12 || length > 16 {
+				t.Errorf("Expected a nickname of 12-16 characters, got %q", nickname)
+			}
+		}
+		if score, ok := lookupKey(doc, "score"); ok && (score.(float64) < 0 || score.(float64) >= 1) {
+			t.Errorf("Expected a score in [0, 1), got %v", score)
+		}
+		if deleted, ok := lookupKey(doc, "deletedAt"); ok && deleted != nil {
+			t.Errorf("Expected a null deletedAt, got %v", deleted)
+		}
+	}
+
+	again, _ := GenerateFixtures(schema.(bson.D), 200, 42)
+	if email, _ := lookupKey(again[7], "email"); email != docs[7][1].Value {
+		t.Errorf("Expected the same seed to generate the same documents")
+	}
+}
+
+func TestGenerateFixturesUniqueBinary(t *testing.T) {
+	schema := bson.D{
+		{Key: "required", Value: bson.A{"keys"}},
+		{Key: "properties", Value: bson.D{{Key: "keys", Value: bson.D{
+			{Key: "bsonType", Value: "array"},
+			{Key: "minItems", Value: 3},
+			{Key: "maxItems", Value: 3},
+			{Key: "uniqueItems", Value: true},
+			{Key: "items", Value: bson.D{{Key: "bsonType", Value: "binData"}}},
+		}}}},
+	}
+
+	docs, err := GenerateFixtures(schema, 20, 7)
+	if err != nil {
+		t.Fatalf("GenerateFixtures() returned error: %v", err)
+	}
+	for _, doc := range docs {
+		keys, _ := lookupKey(doc, "keys")
+		items, ok := keys.(bson.A)
+		if !ok || len(items) != 3 {
+			t.Fatalf("Expected three binary keys, got %v", keys)
+		}
+		for i := range items {
+			if _, ok := items[i].(primitive.Binary); !ok {
+				t.Errorf("Expected binary items, got %T", items[i])
+			}
+			for j := i + 1; j < len(items); j++ {
+				if valuesEqual(items[i], items[j]) {
+					t.Errorf("Expected unique binary keys, got %v", items)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateFixturesErrors(t *testing.T) {
+	tests := []struct {
+		schema   bson.D
+		expected string
+	}{
+		{bson.D{{Key: "required", Value: bson.A{"a"}}, {Key: "properties", Value: bson.D{{Key: "a", Value: bson.D{{Key: "bsonType", Value: "int"}, {Key: "minimum", Value: 5}, {Key: "maximum", Value: 1}}}}}}, "minimum is greater than maximum at a"},
+		{bson.D{{Key: "required", Value: bson.A{"a"}}, {Key: "properties", Value: bson.D{{Key: "a", Value: bson.D{{Key: "pattern", Value: "^x$"}, {Key: "minLength", Value: 3}}}}}}, "can't generate a string matching"},
+		{bson.D{{Key: "required", Value: bson.A{"a"}}, {Key: "properties", Value: bson.D{{Key: "a", Value: bson.D{{Key: "not", Value: bson.D{}}}}}}}, "can't generate a value for not at a"},
+	}
+	for _, tt := range tests {
+		if _, err := GenerateFixtures(tt.schema, 1, 1); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,596 @@
+package mongoparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Line width past which the formatter breaks object and array literals
+const formatWidth = 80
+
+// Kinds of formatter tokens
+const (
+	formatWord = iota
+	formatNumber
+	formatString
+	formatPunct
+	formatComment
+)
+
+// Token of a script being formatted
+type formatToken struct {
+	kind int
+	text string
+	line int
+	// Preceded by a line break, or by at least one blank line
+	newline bool
+	blank   bool
+}
+
+// Leaf token or bracketed group of a statement
+type formatNode struct {
+	token *formatToken
+
+	open     string
+	elements []*formatElement
+	// Comments between the last element and the closing bracket
+	closing []string
+	// Statements of a function body, which is printed one per line
+	block []formatItem
+}
+
+// Comma-separated element of a group, or a whole statement at the top level
+type formatElement struct {
+	leading  []string
+	nodes    []*formatNode
+	trailing []string
+}
+
+// Statement or comment line at the top level of a script
+type formatItem struct {
+	comment    string
+	statement  *formatElement
+	terminated bool
+	blank      bool
+}
+
+// Brackets and the tokens closing them
+var formatClosers = map[string]string{"{": "}", "[": "]", "(": ")"}
+
+// Operators of more than one character, longest first
+var formatOperators = []string{"===", "!==", "...", "==", "!=", "<=", ">=", "=>", "&&", "||", "??", "?.", "++", "--", "**"}
+
+// Keys that can be written without quotes
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Re-emits a script in a canonical style: one statement per line, two-space
+// indentation, double-quoted strings unless they contain quotes, unquoted keys
+// where possible, no trailing commas, and object and array literals kept on one
+// line when they fit in 80 columns. Function bodies such as withTransaction
+// callbacks put each statement on its own line. Comments and single blank
+// lines between statements are kept. Formatting a formatted script returns it
+// unchanged, and a script the parser reads keeps its canonical checksum;
+// formatting fails rather than change it.
+func Format(js string) (string, error) {
+	tokens, err := tokenizeFormat(normalizeContent(js))
+	if err != nil {
+		return "", err
+	}
+	items, err := (&formatParser{tokens: tokens}).parseItems(nil)
+	if err != nil {
+		return "", err
+	}
+
+	printer := &formatPrinter{}
+	printer.items(items)
+	formatted := printer.out.String()
+
+	// Scripts the parser reads must read the same once formatted
+	parser := NewParser()
+	if before, err := parser.CanonicalChecksum(js); err == nil {
+		if after, err := parser.CanonicalChecksum(formatted); err != nil || after != before {
+			return "", fmt.Errorf("formatting would change the script's operations")
+		}
+	}
+	return formatted, nil
+}
+
+// Splits a script into formatter tokens
+func tokenizeFormat(input string) ([]*formatToken, error) {
+	var tokens []*formatToken
+	runes := []rune(input)
+	line, newlines := 1, 1
+
+	for i := 0; i < len(runes); {
+		char := runes[i]
+		if char == '\n' {
+			line++
+			newlines++
+			i++
+			continue
+		}
+		if unicode.IsSpace(char) {
+			i++
+			continue
+		}
+
+		start, startLine := i, line
+		token := &formatToken{line: line, newline: newlines > 0, blank: newlines > 1}
+		switch {
+		case char == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			token.kind = formatComment
+		case char == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/'); i++ {
+			}
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("line %d: unterminated comment", startLine)
+			}
+			i += 2
+			token.kind = formatComment
+		case char == '"' || char == '\'' || char == '`':
+			i++
+			for i < len(runes) && runes[i] != char {
+				if runes[i] == '\\' {
+					i++
+				} else if runes[i] == '\n' && char != '`' {
+					break
+				}
+				i++
+			}
+			if i >= len(runes) || runes[i] != char {
+				return nil, fmt.Errorf("line %d: unterminated string", startLine)
+			}
+			i++
+			token.kind = formatString
+		case unicode.IsDigit(char) || (char == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && !followsValue(tokens)):
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E') && !strings.HasPrefix(strings.ToLower(string(runes[start:i])), "0x"))) {
+				i++
+			}
+			token.kind = formatNumber
+		case char == '_' || char == '$' || unicode.IsLetter(char):
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '$' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			token.kind = formatWord
+		default:
+			i++
+			for _, operator := range formatOperators {
+				if strings.HasPrefix(string(runes[start:min(start+len(operator), len(runes))]), operator) {
+					i = start + len(operator)
+					break
+				}
+			}
+			token.kind = formatPunct
+		}
+
+		token.text = string(runes[start:i])
+		line += strings.Count(token.text, "\n")
+		if token.kind == formatString {
+			token.text = canonicalString(token.text)
+		}
+		tokens = append(tokens, token)
+		newlines = 0
+	}
+	return tokens, nil
+}
+
+// Reports whether the last token ends a value, so a following "." is a member access
+func followsValue(tokens []*formatToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == formatWord || last.kind == formatNumber || last.kind == formatString ||
+		last.text == ")" || last.text == "]" || last.text == "}"
+}
+
+// Rewrites a single-quoted string literal with double quotes. Template
+// literals and strings containing quotes are kept as written, since the
+// parser reads quotes within strings as they are written.
+func canonicalString(literal string) string {
+	if literal[0] == '`' || strings.ContainsAny(literal[1:len(literal)-1], `'"`) {
+		return literal
+	}
+
+	var out strings.Builder
+	out.WriteByte('"')
+	content := literal[1 : len(literal)-1]
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+			if content[i] == '\'' {
+				out.WriteByte('\'')
+			} else {
+				out.WriteByte('\\')
+				out.WriteByte(content[i])
+			}
+		case '"':
+			out.WriteString(`\"`)
+		default:
+			out.WriteByte(content[i])
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// Builds statements and bracket groups from formatter tokens
+type formatParser struct {
+	tokens []*formatToken
+	pos    int
+}
+
+// Parses the top level of a script, or the body of a function up to its
+// closing brace when open is its opening one, into statements and comment lines
+func (f *formatParser) parseItems(open *formatToken) ([]formatItem, error) {
+	var items []formatItem
+	current := &formatElement{}
+	blank := false
+
+	finish := func(terminated bool) {
+		items = append(items, formatItem{statement: current, terminated: terminated, blank: blank})
+		current = &formatElement{}
+	}
+
+	for f.pos < len(f.tokens) {
+		token := f.tokens[f.pos]
+		f.pos++
+		if len(current.nodes) == 0 {
+			blank = token.blank
+		}
+
+		switch {
+		case token.kind == formatComment:
+			if len(current.nodes) > 0 {
+				current.trailing = append(current.trailing, token.text)
+			} else if !token.newline && len(items) > 0 && items[len(items)-1].statement != nil {
+				last := items[len(items)-1].statement
+				last.trailing = append(last.trailing, token.text)
+			} else {
+				items = append(items, formatItem{comment: token.text, blank: token.blank})
+			}
+		case token.text == ";":
+			if len(current.nodes) > 0 {
+				finish(true)
+			}
+		case open != nil && token.text == "}":
+			if len(current.nodes) > 0 {
+				finish(false)
+			}
+			return items, nil
+		case formatClosers[token.text] != "":
+			group, err := f.parseGroup(token, current.nodes)
+			if err != nil {
+				return nil, err
+			}
+			current.nodes = append(current.nodes, group)
+		case token.text == "}" || token.text == "]" || token.text == ")":
+			return nil, fmt.Errorf("line %d: unexpected %s", token.line, token.text)
+		default:
+			current.nodes = append(current.nodes, &formatNode{token: token})
+		}
+	}
+	if open != nil {
+		return nil, fmt.Errorf("line %d: %s is never closed", open.line, open.text)
+	}
+	if len(current.nodes) > 0 {
+		finish(false)
+	}
+	return items, nil
+}
+
+// Reports whether a brace following nodes opens a function body rather than
+// an object literal: arrow functions and function declarations
+func opensBlock(nodes []*formatNode) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	last := nodes[len(nodes)-1]
+	if last.token != nil {
+		return last.token.text == "=>"
+	}
+	return last.open == "(" && len(nodes) >= 2 && nodes[len(nodes)-2].token != nil &&
+		(nodes[len(nodes)-2].token.text == "function" || (len(nodes) >= 3 && nodes[len(nodes)-3].token != nil && nodes[len(nodes)-3].token.text == "function"))
+}
+
+// Parses a bracketed group whose opening token was just read, following the
+// nodes before it in its element
+func (f *formatParser) parseGroup(open *formatToken, before []*formatNode) (*formatNode, error) {
+	node := &formatNode{open: open.text}
+	if open.text == "{" && opensBlock(before) {
+		block, err := f.parseItems(open)
+		if err != nil {
+			return nil, err
+		}
+		node.block = block
+		return node, nil
+	}
+	current := &formatElement{}
+	var pending []string
+	previous := open
+
+	add := func(child *formatNode) {
+		if len(current.nodes) == 0 {
+			current.leading, pending = pending, nil
+		}
+		current.nodes = append(current.nodes, child)
+	}
+	finish := func() {
+		if node.open == "{" {
+			unquoteKey(current)
+		}
+		node.elements = append(node.elements, current)
+		current = &formatElement{}
+	}
+
+	for f.pos < len(f.tokens) {
+		token := f.tokens[f.pos]
+		f.pos++
+
+		switch {
+		case token.kind == formatComment:
+			switch {
+			case len(current.nodes) > 0:
+				current.trailing = append(current.trailing, token.text)
+			case !token.newline && previous.text == "," && len(node.elements) > 0:
+				last := node.elements[len(node.elements)-1]
+				last.trailing = append(last.trailing, token.text)
+			default:
+				pending = append(pending, token.text)
+			}
+		case token.text == formatClosers[node.open]:
+			if len(current.nodes) > 0 {
+				finish()
+			}
+			node.closing = pending
+			return node, nil
+		case token.text == "}" || token.text == "]" || token.text == ")" || token.text == ";":
+			return nil, fmt.Errorf("line %d: unexpected %s, expected %s to close %s from line %d", token.line, token.text, formatClosers[node.open], node.open, open.line)
+		case token.text == ",":
+			// An empty element is an array hole; a trailing comma adds none
+			finish()
+		case formatClosers[token.text] != "":
+			group, err := f.parseGroup(token, current.nodes)
+			if err != nil {
+				return nil, err
+			}
+			add(group)
+		default:
+			add(&formatNode{token: token})
+		}
+		previous = token
+	}
+	return nil, fmt.Errorf("line %d: %s is never closed", open.line, open.text)
+}
+
+// Removes the quotes of an object key that is a valid identifier
+func unquoteKey(element *formatElement) {
+	if len(element.nodes) < 2 || element.nodes[0].token == nil || element.nodes[1].token == nil || element.nodes[1].token.text != ":" {
+		return
+	}
+	key := element.nodes[0].token
+	if key.kind == formatString && key.text[0] == '"' && identifierPattern.MatchString(key.text[1:len(key.text)-1]) {
+		key.text = key.text[1 : len(key.text)-1]
+		key.kind = formatWord
+	}
+}
+
+// Writes formatted output, tracking the column for line breaking decisions
+type formatPrinter struct {
+	out    strings.Builder
+	column int
+	indent int
+}
+
+// Appends text to the output
+func (p *formatPrinter) write(text string) {
+	p.out.WriteString(text)
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		p.column = len(text) - i - 1
+	} else {
+		p.column += len(text)
+	}
+}
+
+// Writes statements and comment lines, one per line
+func (p *formatPrinter) items(items []formatItem) {
+	for i, item := range items {
+		if item.blank && i > 0 {
+			p.write("\n")
+		}
+		if item.statement == nil {
+			p.write(item.comment)
+		} else {
+			p.element(item.statement)
+			if item.terminated {
+				p.write(";")
+			}
+			for _, comment := range item.statement.trailing {
+				p.write(" " + comment)
+			}
+		}
+		p.write("\n")
+	}
+}
+
+// Writes a function body with its statements indented on their own lines
+func (p *formatPrinter) block(node *formatNode) {
+	p.write("{")
+	p.indent += 2
+	for i, item := range node.block {
+		if item.blank && i > 0 {
+			p.write("\n")
+		}
+		p.newline()
+		if item.statement == nil {
+			p.write(item.comment)
+			continue
+		}
+		p.element(item.statement)
+		if item.terminated {
+			p.write(";")
+		}
+		for _, comment := range item.statement.trailing {
+			p.write(" " + comment)
+		}
+	}
+	p.indent -= 2
+	p.newline()
+	p.write("}")
+}
+
+// Starts a new line at the current indentation
+func (p *formatPrinter) newline() {
+	p.write("\n" + strings.Repeat(" ", p.indent))
+}
+
+// Writes the nodes of an element with canonical spacing
+func (p *formatPrinter) element(element *formatElement) {
+	for i, node := range element.nodes {
+		if needsSpace(element.nodes, i) {
+			p.write(" ")
+		}
+		if node.token != nil {
+			p.write(node.token.text)
+		} else {
+			p.group(node)
+		}
+	}
+}
+
+// Writes a group on one line when it fits, and one element per line otherwise.
+// Parentheses stay on the line of their call; only their arguments break.
+func (p *formatPrinter) group(node *formatNode) {
+	if node.block != nil {
+		p.block(node)
+		return
+	}
+	flat, ok := flatGroup(node)
+	if ok && p.column+len(flat) <= formatWidth {
+		p.write(flat)
+		return
+	}
+	if node.open == "(" && !hasOwnComments(node) {
+		p.write("(")
+		for i, element := range node.elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.element(element)
+		}
+		p.write(")")
+		return
+	}
+
+	p.write(node.open)
+	p.indent += 2
+	for i, element := range node.elements {
+		for _, comment := range element.leading {
+			p.newline()
+			p.write(comment)
+		}
+		p.newline()
+		p.element(element)
+		if i < len(node.elements)-1 {
+			p.write(",")
+		}
+		for _, comment := range element.trailing {
+			p.write(" " + comment)
+		}
+	}
+	for _, comment := range node.closing {
+		p.newline()
+		p.write(comment)
+	}
+	p.indent -= 2
+	p.newline()
+	p.write(formatClosers[node.open])
+}
+
+// Renders a group on a single line; false when it contains comments
+func flatGroup(node *formatNode) (string, bool) {
+	if node.block != nil || hasOwnComments(node) {
+		return "", false
+	}
+
+	parts := make([]string, len(node.elements))
+	for i, element := range node.elements {
+		var builder strings.Builder
+		for j, child := range element.nodes {
+			if needsSpace(element.nodes, j) {
+				builder.WriteByte(' ')
+			}
+			if child.token != nil {
+				builder.WriteString(child.token.text)
+				continue
+			}
+			flat, ok := flatGroup(child)
+			if !ok {
+				return "", false
+			}
+			builder.WriteString(flat)
+		}
+		parts[i] = builder.String()
+	}
+
+	inner := strings.Join(parts, ", ")
+	switch {
+	case inner == "":
+		return node.open + formatClosers[node.open], true
+	case node.open == "{":
+		return "{ " + inner + " }", true
+	default:
+		return node.open + inner + formatClosers[node.open], true
+	}
+}
+
+// Reports whether a group's own elements carry comments
+func hasOwnComments(node *formatNode) bool {
+	if len(node.closing) > 0 {
+		return true
+	}
+	for _, element := range node.elements {
+		if len(element.leading) > 0 || len(element.trailing) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Decides whether a space goes before the node at index i
+func needsSpace(nodes []*formatNode, i int) bool {
+	if i == 0 {
+		return false
+	}
+	previous, current := nodes[i-1], nodes[i]
+
+	if current.token != nil {
+		switch current.token.text {
+		case ",", ";", ":", ".", "?.", "++", "--":
+			return false
+		}
+	}
+	if previous.token != nil {
+		switch previous.token.text {
+		case ".", "?.", "!", "~", "...":
+			return false
+		case "+", "-":
+			// Unary when it starts the element or follows another operator
+			if i == 1 || (nodes[i-2].token != nil && nodes[i-2].token.kind == formatPunct) {
+				return false
+			}
+		}
+	}
+	if current.token == nil && (current.open == "(" || current.open == "[") {
+		// Calls and indexing hug what they apply to
+		return previous.token != nil && previous.token.kind == formatPunct
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package mongoparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const unformattedScript = `// METADATA:
+// {"name": "users"}
+
+
+
+db.createCollection( 'users' ,{validator:{$jsonSchema:{bsonType:"object",required:['name'],}}} ) ;
+db.users.createIndex({"email":1,'address.city' : -1},{unique:true,name:'email_city',}); // lookups
+db.users.insertMany([
+    {name:'Ana',tags:["a",'b'],score:-1.5e3},   {name:"O'Brien", note: 'say "hi"', age: 5 - -1, joined: new Date(Date.now() - 1000)},
+  ]);
+db.users.updateOne({ name: "Ana" }, {
+  // raise the score
+  $inc: { score: 10 },
+  $set: { updated: true }, // mark it
+})
+`
+
+const formattedScript = `// METADATA:
+// {"name": "users"}
+
+db.createCollection("users", {
+  validator: { $jsonSchema: { bsonType: "object", required: ["name"] } }
+});
+db.users.createIndex({ email: 1, "address.city": -1 }, {
+  unique: true,
+  name: "email_city"
+}); // lookups
+db.users.insertMany([
+  { name: "Ana", tags: ["a", "b"], score: -1.5e3 },
+  {
+    name: "O'Brien",
+    note: 'say "hi"',
+    age: 5 - -1,
+    joined: new Date(Date.now() - 1000)
+  }
+]);
+db.users.updateOne({ name: "Ana" }, {
+  // raise the score
+  $inc: { score: 10 },
+  $set: { updated: true } // mark it
+})
+`
+
+func TestFormat(t *testing.T) {
+	formatted, err := Format(unformattedScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != formattedScript {
+		t.Errorf("Unexpected formatting:\n%s", formatted)
+	}
+
+	again, err := Format(formatted)
+	if err != nil || again != formatted {
+		t.Errorf("Expected formatting to be idempotent, got:\n%s (%v)", again, err)
+	}
+}
+
+func TestFormatPreservesOperations(t *testing.T) {
+	parser := NewParser(WithJSON5())
+	script := strings.ReplaceAll(unformattedScript, "new Date(Date.now() - 1000)", `"2024-01-01"`)
+	formatted, err := Format(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := parser.parseJavaScriptOperations(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := parser.parseJavaScriptOperations(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 4 || len(after) != len(before) {
+		t.Fatalf("Expected 4 operations before and after formatting, got %d and %d", len(before), len(after))
+	}
+	for i := range before {
+		before[i].Statement, after[i].Statement = "", ""
+		before[i].Location, after[i].Location = nil, nil
+		if !reflect.DeepEqual(before[i], after[i]) {
+			t.Errorf("Operation %d changed:\n%+v\n%+v", i, before[i], after[i])
+		}
+	}
+}
+
+func TestFormatKeepsCanonicalChecksum(t *testing.T) {
+	scripts := map[string]string{
+		"quotes": `db.users.insertOne({ 'name': 'Ana', note: 'it\'s' });
+db.users.insertOne({ quote: "say \"hi\"" });`,
+		"transaction": `const session = db.getMongo().startSession();
+session.withTransaction(() => { db.accounts.updateOne({ _id: 'a' }, { $inc: { balance: -10 } }); db.accounts.updateOne({ _id: "b" }, { $inc: { balance: 10 } }) });
+session.endSession();`,
+		"transaction options": `const session = db.getMongo().startSession();
+session.withTransaction(function () {
+    // move the funds
+    db.accounts.updateOne({ _id: "a" }, { $inc: { balance: -10 } });
+
+    db.audit.insertOne({ event: 'transfer', note: 'it\'s done' });
+}, { writeConcern: { w: "majority" } });`,
+		"schema": `db.createCollection('users', {validator:{$jsonSchema:{bsonType:"object",required:['email','name'],properties:{email:{bsonType:"string",pattern:"^.+@.+$"}}}},validationLevel:'moderate'});
+db.users.createIndex({email:1},{unique:true,partialFilterExpression:{email:{$exists:true}}});`,
+		"updates": `db.users.updateMany({status:'inactive'},[{$set:{archived:true,archivedAt:"$$NOW"}}],{upsert:false});
+db.users.deleteMany({ archived: true, tags: { $in: ["a", 'b', 'c\'s'] } });`,
+	}
+	parser := NewParser()
+	for name, script := range scripts {
+		before, err := parser.CanonicalChecksum(script)
+		if err != nil {
+			t.Fatalf("%s: script does not parse: %v", name, err)
+		}
+		formatted, err := Format(script)
+		if err != nil {
+			t.Errorf("%s: Format() returned error: %v", name, err)
+			continue
+		}
+		after, err := parser.CanonicalChecksum(formatted)
+		if err != nil || after != before {
+			t.Errorf("%s: formatted script reads differently (%v):\n%s", name, err, formatted)
+		}
+		if again, err := Format(formatted); err != nil || again != formatted {
+			t.Errorf("%s: expected formatting to be idempotent, got:\n%s (%v)", name, again, err)
+		}
+	}
+}
+
+func TestFormatTransaction(t *testing.T) {
+	formatted, err := Format(`session.withTransaction(() => { db.a.updateOne({ x: 1 }, { $set: { y: 2 } }); db.b.insertOne({ z: 3 }) });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `session.withTransaction(() => {
+  db.a.updateOne({ x: 1 }, { $set: { y: 2 } });
+  db.b.insertOne({ z: 3 })
+});
+`
+	if formatted != expected {
+		t.Errorf("Unexpected formatting:\n%s", formatted)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	cases := map[string]string{
+		`db.users.insertOne({ name: "Ana );`:  "line 1: unterminated string",
+		"db.users.insertOne({\n  name: 1\n);": "line 3: unexpected ), expected } to close { from line 1",
+		`db.users.insertOne({ name: 1 }`:      "line 1: ( is never closed",
+		"/* note\n db.users.drop();":          "line 1: unterminated comment",
+	}
+	for script, message := range cases {
+		if _, err := Format(script); err == nil || err.Error() != message {
+			t.Errorf("%q: expected error %q, got %v", script, message, err)
+		}
+	}
+}
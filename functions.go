@@ -0,0 +1,46 @@
+package mongoparser
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A Go function scripts can call in values, such as hashPassword("secret") or
+// uuidv7(). args are the evaluated arguments: numbers as float64, strings as
+// string and dates as time.Time. The result is stored as is, so it may be any
+// value the driver can encode.
+type ScriptFunc func(args []interface{}) (interface{}, error)
+
+// Names the expression parser handles itself, which can't be registered
+var builtinFunctions = map[string]bool{
+	"new": true, "Date": true, "Date.now": true, "ISODate": true,
+	"Infinity": true, "NaN": true, "undefined": true,
+	"true": true, "false": true, "null": true,
+}
+
+var functionNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// Exposes fn to scripts under name, which may be dotted like "crypto.hash".
+// Calls are evaluated each time their operation executes, like new Date(), so
+// every document of an insertMany gets its own result. Arguments may be
+// numbers, strings and other expressions but not documents or arrays.
+// Register functions before the parser is used; fn must be safe for
+// concurrent use.
+func (p *Parser) RegisterFunction(name string, fn ScriptFunc) error {
+	switch {
+	case !functionNamePattern.MatchString(name):
+		return fmt.Errorf("invalid function name %q", name)
+	case builtinFunctions[name]:
+		return fmt.Errorf("function %s is built in and can't be replaced", name)
+	case p.functions[name] != nil:
+		return fmt.Errorf("function %s is already registered", name)
+	case fn == nil:
+		return fmt.Errorf("function %s is nil", name)
+	}
+
+	if p.functions == nil {
+		p.functions = make(map[string]ScriptFunc)
+	}
+	p.functions[name] = fn
+	return nil
+}
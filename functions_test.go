@@ -0,0 +1,118 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRegisterFunction(t *testing.T) {
+	for _, json5 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("json5=%v", json5), func(t *testing.T) {
+			var opts []Option
+			if json5 {
+				opts = append(opts, WithJSON5())
+			}
+			parser := NewParser(opts...)
+
+			calls := 0
+			if err := parser.RegisterFunction("uuidv7", func(args []interface{}) (interface{}, error) {
+				calls++
+				return fmt.Sprintf("id-%d", calls), nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := parser.RegisterFunction("auth.hash", func(args []interface{}) (interface{}, error) {
+				if len(args) != 1 {
+					return nil, errors.New("expected one argument")
+				}
+				return "hashed:" + args[0].(string), nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			ops, err := parser.ParseScript(`db.users.insertMany([{ _id: uuidv7(), password: auth.hash("s" + 3) }, { _id: uuidv7() }]);`)
+			if err != nil {
+				t.Fatalf("ParseScript() returned error: %v", err)
+			}
+			if calls != 0 {
+				t.Errorf("Expected functions to run when the operation executes, got %d calls while parsing", calls)
+			}
+
+			op, err := parser.evaluateExpressions(ops[0])
+			if err != nil {
+				t.Fatalf("evaluateExpressions() returned error: %v", err)
+			}
+			first, second := op.Arguments[0], op.Arguments[1]
+			if first[0].Value != "id-1" || second[0].Value != "id-2" {
+				t.Errorf("Expected each document to get its own id, got %v and %v", first, second)
+			}
+			if first[1].Value != "hashed:s3" {
+				t.Errorf("Expected the evaluated argument to be passed, got %v", first[1].Value)
+			}
+
+			// Evaluating again, as the next execution of a cached script would, calls the functions again
+			if op, _ = parser.evaluateExpressions(ops[0]); op.Arguments[0][0].Value != "id-3" {
+				t.Errorf("Expected a new value per execution, got %v", op.Arguments[0][0].Value)
+			}
+		})
+	}
+}
+
+func TestRegisterFunctionErrors(t *testing.T) {
+	parser := NewParser(WithFixedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	fail := func(args []interface{}) (interface{}, error) { return nil, errors.New("no key configured") }
+	if err := parser.RegisterFunction("sign", fail); err != nil {
+		t.Fatal(err)
+	}
+
+	ops, err := parser.ParseScript(`db.users.insertOne({ signature: sign(new Date()) });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.evaluateExpressions(ops[0]); err == nil || !strings.Contains(err.Error(), "sign: no key configured") {
+		t.Errorf("Expected the function error to be reported, got %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"sign":     "already registered",
+		"Date.now": "built in",
+		"9lives":   "invalid function name",
+	} {
+		if err := parser.RegisterFunction(name, fail); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("RegisterFunction(%q) = %v, expected an error containing %q", name, err, expected)
+		}
+	}
+
+	if _, err := NewParser().ParseScript(`db.users.insertOne({ signature: sign("x") });`); err == nil {
+		t.Error("Expected unregistered functions to be rejected")
+	}
+}
+
+func TestRegisterFunctionCustomOperationValues(t *testing.T) {
+	parser := NewParser()
+	if err := parser.RegisterFunction("uuidv7", func(args []interface{}) (interface{}, error) { return "fresh", nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := parser.RegisterOperation("tag", nil, func(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	ops, err := parser.ParseScript(`db.users.tag(uuidv7(), { by: uuidv7() });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err := parser.evaluateExpressions(ops[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Values[0] != "fresh" || op.Values[1].(bson.D)[0].Value != "fresh" {
+		t.Errorf("Expected custom operation arguments to be evaluated, got %v", op.Values)
+	}
+}
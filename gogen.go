@@ -0,0 +1,357 @@
+package mongoparser
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Writes a Go source file declaring a struct with bson tags for each
+// collection whose validator has a $jsonSchema, so application models follow
+// the validators of the scripts. Required properties are plain fields, the
+// others are omitted when empty, and properties that may be null are
+// pointers. Nested objects with properties get their own struct; values
+// without a single known type are interface{}. Collection structs get an _id
+// field when the schema doesn't declare one.
+func (r *SchemaRegistry) WriteGo(w io.Writer, pkg string) error {
+	g := &goGenerator{imports: make(map[string]bool), names: make(map[string]bool)}
+	for _, name := range r.Collections() {
+		collection := r.collections[name]
+		if collection.JSONSchema == nil {
+			continue
+		}
+		typeName := g.reserve(singular(exportedName(name)))
+		g.object(typeName, fmt.Sprintf("%s is a document of the %s collection.", typeName, name), collection.JSONSchema, true)
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by mongoparser; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n", pkg)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for path := range g.imports {
+			imports = append(imports, path)
+		}
+		sort.Strings(imports)
+		b.WriteString("\nimport (\n")
+		for _, path := range imports {
+			fmt.Fprintf(&b, "\t%q\n", path)
+		}
+		b.WriteString(")\n")
+	}
+	for _, s := range g.structs {
+		b.WriteString("\n")
+		writeGoComment(&b, "", s.comment)
+		fmt.Fprintf(&b, "type %s struct {\n", s.name)
+		for _, field := range s.fields {
+			writeGoComment(&b, "\t", field.comment)
+			fmt.Fprintf(&b, "\t%s %s `bson:%q`\n", field.name, field.typ, field.tag)
+		}
+		b.WriteString("}\n")
+	}
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return fmt.Errorf("failed to format generated Go code: %w", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+// Go declarations generated from $jsonSchema validators
+type goGenerator struct {
+	structs []goStruct
+	// Import paths used by the field types
+	imports map[string]bool
+	// Type names already taken
+	names map[string]bool
+}
+
+// Generated struct type
+type goStruct struct {
+	name    string
+	comment string
+	fields  []goField
+}
+
+// Field of a generated struct
+type goField struct {
+	name    string
+	typ     string
+	tag     string
+	comment string
+}
+
+// Returns name, or name with a number appended when it is taken, and takes it
+func (g *goGenerator) reserve(name string) string {
+	return uniqueName(g.names, name)
+}
+
+// Returns name, or name with a number appended when it is in used, and adds it to used
+func uniqueName(used map[string]bool, name string) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// Declares a struct for an object schema. Structs are emitted in the order
+// they are declared, so nested structs follow the struct using them.
+func (g *goGenerator) object(name, comment string, schema bson.D, document bool) {
+	g.structs = append(g.structs, goStruct{name: name, comment: comment})
+	index := len(g.structs) - 1
+
+	var fields []goField
+	// Property names can map to the same field name, such as user_id and userId
+	used := map[string]bool{}
+	properties := schemaProperties(schema)
+	implicitID := document && !hasProperty(properties, "_id")
+	for _, property := range properties {
+		// An id property takes the name of the implicit _id field
+		if exportedName(property.name) == "ID" {
+			implicitID = false
+		}
+	}
+	if implicitID {
+		g.imports["go.mongodb.org/mongo-driver/bson/primitive"] = true
+		fields = append(fields, goField{name: uniqueName(used, "ID"), typ: "primitive.ObjectID", tag: "_id,omitempty"})
+	}
+	for _, property := range properties {
+		fieldName := uniqueName(used, exportedName(property.name))
+		field := goField{
+			name:    fieldName,
+			typ:     g.fieldType(name+fieldName, name, property.name, property.schema),
+			tag:     property.name,
+			comment: schemaDescription(property.schema),
+		}
+		if !property.required {
+			field.tag += ",omitempty"
+		}
+		fields = append(fields, field)
+	}
+	g.structs[index].fields = fields
+}
+
+// Returns the Go type of a property, declaring structs for nested objects
+func (g *goGenerator) fieldType(name, parent, property string, schema bson.D) string {
+	types, nullable := allowedTypes(schema)
+	if len(types) != 1 {
+		return "interface{}"
+	}
+
+	var typ string
+	switch types[0] {
+	case "string":
+		typ = "string"
+	case "int":
+		typ = "int32"
+	case "long":
+		typ = "int64"
+	case "double", "number":
+		typ = "float64"
+	case "bool":
+		typ = "bool"
+	case "date":
+		g.imports["time"] = true
+		typ = "time.Time"
+	case "objectId", "decimal", "binData", "timestamp", "regex":
+		g.imports["go.mongodb.org/mongo-driver/bson/primitive"] = true
+		typ = "primitive." + primitiveTypes[types[0]]
+	case "object":
+		if len(schemaProperties(schema)) == 0 {
+			g.imports["go.mongodb.org/mongo-driver/bson"] = true
+			return "bson.M"
+		}
+		typ = g.reserve(name)
+		comment := schemaDescription(schema)
+		if comment == "" {
+			comment = fmt.Sprintf("%s is the %s field of %s.", typ, property, parent)
+		}
+		g.object(typ, comment, schema, false)
+	case "array":
+		items, ok := lookupKey(schema, "items")
+		itemSchema, _ := items.(bson.D)
+		if !ok || itemSchema == nil {
+			return "[]interface{}"
+		}
+		itemType, itemNullable := allowedTypes(itemSchema)
+		if len(itemType) == 1 && itemType[0] == "object" && !itemNullable && len(schemaProperties(itemSchema)) > 0 {
+			typ := g.reserve(singular(name))
+			comment := schemaDescription(itemSchema)
+			if comment == "" {
+				comment = fmt.Sprintf("%s is an element of the %s field of %s.", typ, property, parent)
+			}
+			g.object(typ, comment, itemSchema, false)
+			return "[]" + typ
+		}
+		return "[]" + g.fieldType(singular(name), parent, property, itemSchema)
+	default:
+		return "interface{}"
+	}
+
+	if nullable {
+		return "*" + typ
+	}
+	return typ
+}
+
+// Types of the primitive package for BSON types without a Go counterpart
+var primitiveTypes = map[string]string{
+	"objectId": "ObjectID", "decimal": "Decimal128", "binData": "Binary",
+	"timestamp": "Timestamp", "regex": "Regex",
+}
+
+// Property of an object $jsonSchema
+type schemaProperty struct {
+	name     string
+	schema   bson.D
+	required bool
+}
+
+// Returns the properties of an object schema in their declared order
+func schemaProperties(schema bson.D) []schemaProperty {
+	value, _ := lookupKey(schema, "properties")
+	properties, _ := value.(bson.D)
+	if len(properties) == 0 {
+		return nil
+	}
+
+	required := make(map[string]bool)
+	if list, ok := lookupKey(schema, "required"); ok {
+		for _, name := range schemaTypeNames(list) {
+			required[name] = true
+		}
+	}
+	result := make([]schemaProperty, 0, len(properties))
+	for _, property := range properties {
+		propertySchema, _ := property.Value.(bson.D)
+		result = append(result, schemaProperty{name: property.Key, schema: propertySchema, required: required[property.Key]})
+	}
+	return result
+}
+
+// Reports whether the properties include name
+func hasProperty(properties []schemaProperty, name string) bool {
+	for _, property := range properties {
+		if property.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the BSON types a schema allows, from bsonType or else type, without
+// null, and whether null is allowed. JSON type names are mapped to BSON ones.
+func allowedTypes(schema bson.D) ([]string, bool) {
+	value, ok := lookupKey(schema, "bsonType")
+	if !ok {
+		value, ok = lookupKey(schema, "type")
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var types []string
+	nullable := false
+	for _, name := range schemaTypeNames(value) {
+		switch name {
+		case "null":
+			nullable = true
+			continue
+		case "boolean":
+			name = "bool"
+		}
+		types = append(types, name)
+	}
+	return types, nullable
+}
+
+// Returns the description of a schema, or its title
+func schemaDescription(schema bson.D) string {
+	for _, key := range []string{"description", "title"} {
+		if value, ok := lookupKey(schema, key); ok {
+			if text, ok := value.(string); ok {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// Writes text as a Go comment, one line per line of text
+func writeGoComment(b *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		fmt.Fprintf(b, "%s// %s\n", indent, strings.TrimSpace(line))
+	}
+}
+
+// Words written in capitals in Go names
+var goInitialisms = map[string]bool{
+	"ID": true, "URL": true, "URI": true, "API": true, "IP": true, "JSON": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "UUID": true, "SQL": true,
+}
+
+// Converts a collection or field name, such as "user_id" or "createdAt", to
+// an exported Go name, such as "UserID" or "CreatedAt"
+func exportedName(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); goInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	if b.Len() == 0 || unicode.IsDigit([]rune(b.String())[0]) {
+		return "X" + b.String()
+	}
+	return b.String()
+}
+
+// Returns the singular of a plural English name for the common suffixes,
+// such as "Users" or "Categories"; other names are returned unchanged
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
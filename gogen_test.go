@@ -0,0 +1,158 @@
+package mongoparser
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestWriteGo(t *testing.T) {
+	registry, err := NewParser().SchemaRegistry([]ScriptInfo{{Name: "001_init.js", Content: `db.createCollection("users", { validator: { $jsonSchema: {
+	bsonType: "object",
+	required: ["email", "createdAt"],
+	properties: {
+		email: { bsonType: "string", description: "Login address" },
+		age: { bsonType: "int" },
+		createdAt: { bsonType: "date" },
+		managerId: { bsonType: ["objectId", "null"] },
+		address: { bsonType: "object", properties: { city: { bsonType: "string" } } },
+		addresses: { bsonType: "array", items: { bsonType: "object", properties: { zip: { bsonType: "string" } } } },
+		tags: { bsonType: "array", items: { bsonType: "string" } },
+		settings: { bsonType: "object" },
+		score: { bsonType: ["int", "double"] }
+	}
+} } });
+db.createCollection("categories", { validator: { $jsonSchema: { properties: { _id: { bsonType: "string" }, active: { type: "boolean" } } } } });
+db.createCollection("logs");`}})
+	if err != nil {
+		t.Fatalf("SchemaRegistry() returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := registry.WriteGo(&out, "models"); err != nil {
+		t.Fatalf("WriteGo() returned error: %v", err)
+	}
+	source := out.String()
+	if _, err := parser.ParseFile(token.NewFileSet(), "models_gen.go", source, 0); err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s", err, source)
+	}
+
+	for _, expected := range []string{
+		"// Code generated by mongoparser; DO NOT EDIT.",
+		"type Category struct {\n\tID     string `bson:\"_id,omitempty\"`\n\tActive bool   `bson:\"active,omitempty\"`\n}",
+		"// User is a document of the users collection.",
+		"ID primitive.ObjectID `bson:\"_id,omitempty\"`",
+		"// Login address\n\tEmail string `bson:\"email\"`",
+		"Age int32 `bson:\"age,omitempty\"`",
+		"CreatedAt time.Time `bson:\"createdAt\"`",
+		"ManagerID *primitive.ObjectID `bson:\"managerId,omitempty\"`",
+		"Address UserAddress `bson:\"address,omitempty\"`",
+		"Addresses []UserAddress2 `bson:\"addresses,omitempty\"`",
+		"Tags []string",
+		"Settings bson.M",
+		"Score interface{}",
+		"// UserAddress is the address field of User.",
+		"// UserAddress2 is an element of the addresses field of User.",
+	} {
+		if !strings.Contains(normalizeSpaces(source), normalizeSpaces(expected)) {
+			t.Errorf("Expected generated code to contain %q, got:\n%s", expected, source)
+		}
+	}
+	if strings.Contains(source, "type Log ") {
+		t.Error("Expected collections without a $jsonSchema to be skipped")
+	}
+}
+
+func TestWriteGoFieldNameCollisions(t *testing.T) {
+	registry, err := NewParser().SchemaRegistry([]ScriptInfo{{Name: "001_init.js", Content: `db.createCollection("accounts", { validator: { $jsonSchema: {
+	properties: {
+		id: { bsonType: "string" },
+		user_id: { bsonType: "objectId" },
+		userId: { bsonType: "string" },
+		profile: { bsonType: "object", properties: { "api-key": { bsonType: "string" }, apiKey: { bsonType: "string" }, _id: { bsonType: "int" }, id: { bsonType: "int" } } }
+	}
+} } });`}})
+	if err != nil {
+		t.Fatalf("SchemaRegistry() returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := registry.WriteGo(&out, "models"); err != nil {
+		t.Fatalf("WriteGo() returned error: %v", err)
+	}
+	source := out.String()
+	file, err := parser.ParseFile(token.NewFileSet(), "models_gen.go", source, 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v\n%s", err, source)
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		structType, ok := node.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		names := map[string]bool{}
+		for _, field := range structType.Fields.List {
+			for _, name := range field.Names {
+				if names[name.Name] {
+					t.Errorf("Field %s is declared twice:\n%s", name.Name, source)
+				}
+				names[name.Name] = true
+			}
+		}
+		return true
+	})
+
+	for _, expected := range []string{
+		"ID string `bson:\"id,omitempty\"`",
+		"UserID primitive.ObjectID `bson:\"user_id,omitempty\"`",
+		"UserID2 string `bson:\"userId,omitempty\"`",
+		"APIKey string `bson:\"api-key,omitempty\"`",
+		"APIKey2 string `bson:\"apiKey,omitempty\"`",
+		"ID2 int32 `bson:\"id,omitempty\"`",
+	} {
+		if !strings.Contains(normalizeSpaces(source), normalizeSpaces(expected)) {
+			t.Errorf("Expected generated code to contain %q, got:\n%s", expected, source)
+		}
+	}
+	if strings.Count(source, `"_id,omitempty"`) != 1 {
+		t.Errorf("Expected the id property to replace the implicit _id field, got:\n%s", source)
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":    "UserID",
+		"createdAt":  "CreatedAt",
+		"_id":        "ID",
+		"api-key":    "APIKey",
+		"2fa":        "X2fa",
+		"profileURL": "ProfileURL",
+	}
+	for name, expected := range tests {
+		if got := exportedName(name); got != expected {
+			t.Errorf("exportedName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+// Collapses runs of spaces and tabs, which gofmt uses to align fields
+func normalizeSpaces(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
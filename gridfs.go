@@ -0,0 +1,175 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Directive uploading local files into a GridFS bucket: // UPLOAD: path -> bucket
+const uploadDirective = "// UPLOAD:"
+
+// Parses an UPLOAD directive. The path may be a glob pattern; the bucket defaults to fs.
+func (p *Parser) parseUpload(directive string) (*MongoOperation, error) {
+	spec := strings.TrimSpace(strings.TrimPrefix(directive, uploadDirective))
+	path, bucket, found := strings.Cut(spec, "->")
+	path = strings.TrimSpace(path)
+	bucket = strings.TrimSpace(bucket)
+	if path == "" {
+		return nil, fmt.Errorf("UPLOAD directive requires a file path")
+	}
+	if !found || bucket == "" {
+		bucket = options.DefaultName
+	}
+
+	return &MongoOperation{
+		Type:       "upload",
+		Collection: bucket,
+		Operation:  "upload",
+		Source:     path,
+	}, nil
+}
+
+// Resolves the files a directive reads against the configured root directory
+func (p *Parser) resolveFiles(source string) ([]string, error) {
+	if p.files != nil {
+		return p.resolveFSFiles(source)
+	}
+	if p.fileRoot != "" {
+		if !filepath.IsLocal(source) {
+			return nil, fmt.Errorf("path %s is outside the file root", source)
+		}
+		source = filepath.Join(p.fileRoot, source)
+	}
+
+	if !strings.ContainsAny(source, "*?[") {
+		return []string{source}, nil
+	}
+	matches, err := filepath.Glob(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %s: %w", source, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("file pattern %s matches no files", source)
+	}
+	return matches, nil
+}
+
+// Resolves the files a directive reads within the configured file system
+func (p *Parser) resolveFSFiles(source string) ([]string, error) {
+	if !fs.ValidPath(path.Clean(filepath.ToSlash(source))) {
+		return nil, fmt.Errorf("path %s is outside the file root", source)
+	}
+	source = path.Join(p.fileRoot, filepath.ToSlash(source))
+
+	if !strings.ContainsAny(source, "*?[") {
+		return []string{source}, nil
+	}
+	matches, err := fs.Glob(p.files, source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file pattern %s: %w", source, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("file pattern %s matches no files", source)
+	}
+	return matches, nil
+}
+
+// Opens a file resolved by resolveFiles
+func (p *Parser) openFile(name string) (fs.File, error) {
+	if p.files != nil {
+		return p.files.Open(name)
+	}
+	return os.Open(name)
+}
+
+// Uploads the files of an UPLOAD directive into its bucket. Files already
+// stored under the same name and size are skipped so seeds can be re-run.
+func (p *Parser) executeUpload(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	files, err := p.resolveFiles(op.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := gridfs.NewBucket(p.database(db, op), options.GridFSBucket().SetName(op.Collection))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bucket %s: %w", op.Collection, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := bucket.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	var uploaded int64
+	for _, path := range files {
+		done, err := p.uploadFile(ctx, bucket, op, path)
+		if err != nil {
+			return uploaded, err
+		}
+		if done {
+			uploaded++
+		}
+	}
+
+	if uploaded == 0 {
+		return skipped(fmt.Sprintf("files already in bucket %s", op.Collection)), nil
+	}
+	return uploaded, nil
+}
+
+// Uploads one file, reporting false when an identical file is already stored
+func (p *Parser) uploadFile(ctx context.Context, bucket *gridfs.Bucket, op MongoOperation, path string) (bool, error) {
+	file, err := p.openFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open upload %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to read upload %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("upload %s is a directory", path)
+	}
+	filename := filepath.Base(path)
+
+	cursor, err := bucket.FindContext(ctx, bson.D{{Key: "filename", Value: filename}, {Key: "length", Value: info.Size()}})
+	if err != nil {
+		return false, err
+	}
+	exists := cursor.Next(ctx)
+	cursor.Close(ctx)
+	if exists {
+		return false, nil
+	}
+
+	id, err := bucket.UploadFromStream(filename, file)
+	if err != nil {
+		return false, fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+
+	p.compensate(MongoOperation{
+		Type:       "delete",
+		Collection: op.Collection + ".chunks",
+		Operation:  "deleteMany",
+		Arguments:  []bson.D{{{Key: "files_id", Value: id}}},
+	})
+	p.compensate(MongoOperation{
+		Type:       "delete",
+		Collection: op.Collection + ".files",
+		Operation:  "deleteMany",
+		Arguments:  []bson.D{{{Key: "_id", Value: id}}},
+	})
+	return true, nil
+}
@@ -0,0 +1,87 @@
+package mongoparser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseUploadDirective(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`// UPLOAD: fixtures/logo.png -> assets
+db.products.insertOne({ name: "widget" });
+// UPLOAD: fixtures/*.pdf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 3 {
+		t.Fatalf("Expected 3 operations, got %d", len(ops))
+	}
+	if ops[0].Type != "upload" || ops[0].Source != "fixtures/logo.png" || ops[0].Collection != "assets" {
+		t.Errorf("Unexpected upload %+v", ops[0])
+	}
+	if ops[2].Collection != "fs" {
+		t.Errorf("Expected default bucket fs, got %q", ops[2].Collection)
+	}
+}
+
+func TestUploadFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.pdf", "b.pdf", "c.png"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parser := NewParser(WithFileRoot(root))
+
+	files, err := parser.resolveFiles("*.pdf")
+	if err != nil || len(files) != 2 {
+		t.Errorf("Expected 2 matching files, got %v (%v)", files, err)
+	}
+	if files, err := parser.resolveFiles("c.png"); err != nil || files[0] != filepath.Join(root, "c.png") {
+		t.Errorf("Expected path under the root, got %v (%v)", files, err)
+	}
+	if _, err := parser.resolveFiles("../secrets.txt"); err == nil {
+		t.Error("Expected paths leaving the root to be refused")
+	}
+	if _, err := parser.resolveFiles("*.gif"); err == nil {
+		t.Error("Expected a pattern without matches to fail")
+	}
+}
+
+func TestUploadFilesFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fixtures/a.pdf":  {Data: []byte("a")},
+		"fixtures/b.pdf":  {Data: []byte("b")},
+		"fixtures/c.png":  {Data: []byte("c")},
+		"other/secret.md": {Data: []byte("d")},
+	}
+	parser := NewParser(WithFS(fsys), WithFileRoot("fixtures"))
+
+	files, err := parser.resolveFiles("*.pdf")
+	if err != nil || len(files) != 2 || files[0] != "fixtures/a.pdf" {
+		t.Errorf("Expected 2 matching files, got %v (%v)", files, err)
+	}
+	if _, err := parser.resolveFiles("../other/secret.md"); err == nil {
+		t.Error("Expected paths leaving the root to be refused")
+	}
+
+	file, err := parser.openFile("fixtures/c.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || info.Size() != 1 {
+		t.Errorf("Expected to open the file from the file system, got %v (%v)", info, err)
+	}
+}
+
+func TestUploadPrivileges(t *testing.T) {
+	parser := NewParser()
+	collections := parser.privilegeCollections(MongoOperation{Type: "upload", Collection: "assets"})
+	if len(collections) != 2 || collections[0] != "assets.files" || collections[1] != "assets.chunks" {
+		t.Errorf("Expected bucket collections, got %v", collections)
+	}
+}
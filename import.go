@@ -0,0 +1,305 @@
+package mongoparser
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Directive streaming a data file into a collection:
+// // IMPORT: fixtures/users.csv -> users schema={ age: "int", joined: "date" }
+const importDirective = "// IMPORT:"
+
+// Data file formats an IMPORT directive reads
+const (
+	ImportCSV    = "csv"
+	ImportNDJSON = "ndjson"
+	// Extended JSON as written by mongoexport, one document per line
+	ImportExtJSON = "extjson"
+)
+
+// Describes how an IMPORT directive reads its file
+type ImportSpec struct {
+	Format string `json:"format"`
+	// Types of CSV columns (string, int, double, bool, date, objectId); other
+	// columns are inferred from their values
+	Schema map[string]string `json:"schema,omitempty"`
+
+	// Stream read instead of the operation's Source files
+	reader io.Reader
+}
+
+// Parses an IMPORT directive. The format follows the file extension unless
+// given as format=csv, format=ndjson or format=extjson.
+func (p *Parser) parseImport(directive string) (*MongoOperation, error) {
+	spec := strings.TrimSpace(strings.TrimPrefix(directive, importDirective))
+	path, target, found := strings.Cut(spec, "->")
+	path = strings.TrimSpace(path)
+	if path == "" || !found {
+		return nil, fmt.Errorf("IMPORT directive must have the form path -> collection")
+	}
+	collection, rest, _ := strings.Cut(strings.TrimSpace(target), " ")
+	if collection == "" {
+		return nil, fmt.Errorf("IMPORT directive requires a collection")
+	}
+
+	params, err := splitDirectiveParams(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	importSpec := &ImportSpec{Format: importFormat(path)}
+	for key, value := range params {
+		switch key {
+		case "format":
+			importSpec.Format = value
+		case "schema":
+			schema, err := p.parseOptionsDocument(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse IMPORT schema: %w", err)
+			}
+			importSpec.Schema = make(map[string]string, len(schema))
+			for field, fieldType := range schema {
+				typeName, ok := fieldType.(string)
+				if !ok || !knownImportType(typeName) {
+					return nil, fmt.Errorf("IMPORT schema type of %s must be one of string, int, double, bool, date or objectId", field)
+				}
+				importSpec.Schema[field] = typeName
+			}
+		default:
+			return nil, fmt.Errorf("unknown IMPORT parameter %q", key)
+		}
+	}
+	switch importSpec.Format {
+	case ImportCSV, ImportNDJSON, ImportExtJSON:
+	default:
+		return nil, fmt.Errorf("IMPORT format must be csv, ndjson or extjson, got %q", importSpec.Format)
+	}
+
+	return &MongoOperation{
+		Type:       "import",
+		Collection: collection,
+		Operation:  "import",
+		Source:     path,
+		Import:     importSpec,
+	}, nil
+}
+
+// Derives the import format from a file extension
+func importFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ImportCSV
+	case ".ndjson", ".jsonl", ".json":
+		return ImportNDJSON
+	default:
+		return ""
+	}
+}
+
+// Reports whether name is a column type the schema accepts
+func knownImportType(name string) bool {
+	switch name {
+	case "string", "int", "double", "bool", "date", "objectId":
+		return true
+	}
+	return false
+}
+
+// Streams the files of an IMPORT directive into its collection in chunks
+func (p *Parser) executeImport(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if op.Import == nil {
+		return nil, fmt.Errorf("import operation requires a format")
+	}
+	if op.Import.reader != nil {
+		return p.importStream(ctx, db, op, op.Import.reader)
+	}
+	files, err := p.resolveFiles(op.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var inserted int64
+	for _, path := range files {
+		count, err := p.importFile(ctx, db, op, path)
+		inserted += count
+		if err != nil {
+			return inserted, fmt.Errorf("failed to import %s: %w", path, err)
+		}
+	}
+	return inserted, nil
+}
+
+// Streams one file into the collection
+func (p *Parser) importFile(ctx context.Context, db *mongo.Database, op MongoOperation, path string) (int64, error) {
+	file, err := p.openFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return p.importStream(ctx, db, op, file)
+}
+
+// Streams documents read from r in the operation's format into the collection
+func (p *Parser) importStream(ctx context.Context, db *mongo.Database, op MongoOperation, r io.Reader) (int64, error) {
+	var next func() (bson.D, error)
+	var err error
+	switch op.Import.Format {
+	case ImportCSV:
+		next, err = p.csvDocuments(r, op.Import.Schema)
+	case ImportExtJSON:
+		next = lineDocuments(r, decodeExtJSON)
+	default:
+		next = lineDocuments(r, p.decodeDocument)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return p.insertStream(ctx, db, op, 0, next)
+}
+
+// Returns a reader of CSV rows as documents. The header row names the fields;
+// dotted names such as address.city build nested documents and empty cells
+// are left out.
+func (p *Parser) csvDocuments(r io.Reader, schema map[string]string) (func() (bson.D, error), error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return func() (bson.D, error) { return nil, io.EOF }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	fields := make([]string, len(header))
+	copy(fields, header)
+	if len(fields) > 0 {
+		fields[0] = strings.TrimPrefix(fields[0], "\ufeff")
+	}
+
+	return func() (bson.D, error) {
+		record, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		doc := bson.D{}
+		for i, cell := range record {
+			if i >= len(fields) || cell == "" {
+				continue
+			}
+			value, err := convertCell(cell, schema[fields[i]])
+			if err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("line %d, column %s: %w", line, fields[i], err)
+			}
+			doc = setPath(doc, strings.Split(fields[i], "."), value)
+		}
+		return doc, nil
+	}, nil
+}
+
+// Returns a reader of documents decoded one per line, skipping blank lines
+func lineDocuments(r io.Reader, decode func(string) (bson.D, error)) func() (bson.D, error) {
+	reader := bufio.NewReader(r)
+	line := 0
+	return func() (bson.D, error) {
+		for {
+			text, err := reader.ReadString('\n')
+			if err != nil && (err != io.EOF || text == "") {
+				return nil, err
+			}
+			line++
+			if text = strings.TrimSpace(text); text == "" {
+				continue
+			}
+			doc, err := decode(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			return doc, nil
+		}
+	}
+}
+
+// Decodes a document written in the script's JSON-like syntax
+func (p *Parser) decodeDocument(text string) (bson.D, error) {
+	var doc bson.D
+	err := p.parseJSONLikeString(text, &doc)
+	return doc, err
+}
+
+// Converts a CSV cell to the schema type, or infers one when the column has none
+func convertCell(cell, fieldType string) (interface{}, error) {
+	switch fieldType {
+	case "string":
+		return cell, nil
+	case "int":
+		return strconv.ParseInt(cell, 10, 64)
+	case "double":
+		return strconv.ParseFloat(cell, 64)
+	case "bool":
+		return strconv.ParseBool(cell)
+	case "date":
+		return parseDate(cell)
+	case "objectId":
+		return primitive.ObjectIDFromHex(cell)
+	}
+
+	if value, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseFloat(cell, 64); err == nil {
+		return value, nil
+	}
+	if cell == "true" || cell == "false" {
+		return cell == "true", nil
+	}
+	if value, err := parseDate(cell); err == nil {
+		return value, nil
+	}
+	return cell, nil
+}
+
+// Layouts of the dates CSV cells and date expressions accept; dates without a
+// zone are UTC
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02T15:04", time.DateOnly}
+
+// Parses an ISO 8601 timestamp or a plain YYYY-MM-DD date
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts[:len(dateLayouts)-1] {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// Sets value at a dotted path, creating nested documents as needed
+func setPath(doc bson.D, path []string, value interface{}) bson.D {
+	for i, elem := range doc {
+		if elem.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			doc[i].Value = value
+			return doc
+		}
+		nested, _ := elem.Value.(bson.D)
+		doc[i].Value = setPath(nested, path[1:], value)
+		return doc
+	}
+	if len(path) == 1 {
+		return append(doc, bson.E{Key: path[0], Value: value})
+	}
+	return append(doc, bson.E{Key: path[0], Value: setPath(nil, path[1:], value)})
+}
@@ -0,0 +1,126 @@
+package mongoparser
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseImportDirective(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`// IMPORT: fixtures/users.csv -> users schema={ zip: "string", joined: "date" }
+// IMPORT: fixtures/events.data -> events format=ndjson`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operations, got %d", len(ops))
+	}
+	if ops[0].Type != "import" || ops[0].Collection != "users" || ops[0].Source != "fixtures/users.csv" {
+		t.Errorf("Unexpected import operation %+v", ops[0])
+	}
+	if ops[0].Import.Format != ImportCSV || ops[0].Import.Schema["zip"] != "string" || ops[0].Import.Schema["joined"] != "date" {
+		t.Errorf("Unexpected import spec %+v", ops[0].Import)
+	}
+	if ops[1].Import.Format != ImportNDJSON {
+		t.Errorf("Expected ndjson format, got %q", ops[1].Import.Format)
+	}
+}
+
+func TestParseImportDirectiveInvalid(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		directive string
+		err       string
+	}{
+		{`// IMPORT: fixtures/users.csv`, "path -> collection"},
+		{`// IMPORT: fixtures/users.csv ->`, "requires a collection"},
+		{`// IMPORT: fixtures/users.txt -> users`, "csv, ndjson or extjson"},
+		{`// IMPORT: fixtures/users.csv -> users schema={ age: "number" }`, "schema type of age"},
+		{`// IMPORT: fixtures/users.csv -> users colour=red`, "unknown IMPORT parameter"},
+	}
+	for _, tt := range tests {
+		_, err := parser.parseImport(tt.directive)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.directive, tt.err, err)
+		}
+	}
+}
+
+func TestCSVDocuments(t *testing.T) {
+	parser := NewParser()
+	input := "\ufeffname,age,score,active,joined,zip,address.city,address.country\n" +
+		"Alice,30,9.5,true,2024-01-02,01234,Paris,FR\n" +
+		"Bob,,7,false,2024-01-02T10:00:00Z,98765,Berlin,\n"
+	next, err := parser.csvDocuments(strings.NewReader(input), map[string]string{"zip": "string"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.D{
+		{Key: "name", Value: "Alice"},
+		{Key: "age", Value: int64(30)},
+		{Key: "score", Value: 9.5},
+		{Key: "active", Value: true},
+		{Key: "joined", Value: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{Key: "zip", Value: "01234"},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Paris"}, {Key: "country", Value: "FR"}}},
+	}
+	if !reflect.DeepEqual(alice, expected) {
+		t.Errorf("Expected %v, got %v", expected, alice)
+	}
+
+	bob, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lookupKey(bob, "age"); ok {
+		t.Errorf("Expected empty cell to be left out, got %v", bob)
+	}
+	if address, _ := lookupKey(bob, "address"); len(address.(bson.D)) != 1 {
+		t.Errorf("Expected a single address field, got %v", address)
+	}
+
+	if _, err := next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestCSVDocumentsSchemaError(t *testing.T) {
+	parser := NewParser()
+	next, err := parser.csvDocuments(strings.NewReader("age\nold\n"), map[string]string{"age": "int"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next(); err == nil || !strings.Contains(err.Error(), "line 2, column age") {
+		t.Errorf("Expected a conversion error, got %v", err)
+	}
+}
+
+func TestNDJSONDocuments(t *testing.T) {
+	parser := NewParser()
+	next := lineDocuments(strings.NewReader("{\"a\": 1}\n\n{\"b\": {\"c\": \"x\"}}\n{broken"), parser.decodeDocument)
+
+	for _, key := range []string{"a", "b"} {
+		doc, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(doc) != 1 || doc[0].Key != key {
+			t.Errorf("Expected document with %s, got %v", key, doc)
+		}
+	}
+	if _, err := next(); err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("Expected error on line 4, got %v", err)
+	}
+}
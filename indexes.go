@@ -0,0 +1,380 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// What to do with a scripted index given the indexes already on the collection
+type indexAction int
+
+const (
+	indexCreate indexAction = iota
+	indexSkip
+	indexRecreate
+	indexUpdate
+)
+
+// A single way an existing index differs from the scripted one
+type indexDifference struct {
+	field  string
+	detail string
+}
+
+// Index fields that collMod can change without rebuilding the index
+var mutableIndexFields = map[string]bool{
+	"hidden":             true,
+	"expireAfterSeconds": true,
+}
+
+// Fetches the index documents currently defined on a collection
+func (p *Parser) listIndexes(ctx context.Context, collection *mongo.Collection) ([]bson.D, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []bson.D
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	return indexes, nil
+}
+
+// Compares a scripted index with the existing ones and decides how to apply it.
+// The returned name is the existing index that matched, if any.
+func (p *Parser) planIndex(collection string, existing []bson.D, model mongo.IndexModel) (indexAction, string, error) {
+	keys, ok := model.Keys.(bson.D)
+	if !ok {
+		return indexCreate, "", nil
+	}
+
+	desiredName := defaultIndexName(keys)
+	if model.Options != nil && model.Options.Name != nil {
+		desiredName = *model.Options.Name
+	}
+
+	// Match by name first, then by keys since the server refuses duplicate key patterns
+	var match bson.D
+	for _, index := range existing {
+		if name, _ := lookupKey(index, "name"); name == desiredName {
+			match = index
+			break
+		}
+	}
+	if match == nil {
+		pattern, _ := indexKeyPattern(keys)
+		for _, index := range existing {
+			if indexKeys, _ := lookupKey(index, "key"); valuesEqual(indexKeys, pattern) {
+				match = index
+				break
+			}
+		}
+	}
+	if match == nil {
+		return indexCreate, "", nil
+	}
+
+	existingName, _ := lookupKey(match, "name")
+	existingNameStr, _ := existingName.(string)
+
+	if p.indexPolicy == ConflictFail {
+		return indexCreate, existingNameStr, fmt.Errorf("index %s already exists on %s", existingNameStr, collection)
+	}
+
+	differences := p.indexDifferences(match, keys, desiredName, model)
+	if len(differences) == 0 {
+		return indexSkip, existingNameStr, nil
+	}
+	// Before indexes were compared, an index created under another name was
+	// skipped, so the default policy still skips it with a warning
+	if p.indexPolicy == ConflictSkip && len(differences) == 1 && differences[0].field == "name" {
+		p.warnf("index %s on %s matches the script's index %s except for its name, skipping", existingNameStr, collection, desiredName)
+		return indexSkip, existingNameStr, nil
+	}
+
+	switch p.indexPolicy {
+	case ConflictRecreate:
+		return indexRecreate, existingNameStr, nil
+	case ConflictUpdate:
+		for _, difference := range differences {
+			if !mutableIndexFields[difference.field] {
+				return indexRecreate, existingNameStr, nil
+			}
+		}
+		return indexUpdate, existingNameStr, nil
+	}
+
+	details := make([]string, 0, len(differences))
+	for _, difference := range differences {
+		details = append(details, difference.detail)
+	}
+	return indexCreate, existingNameStr, fmt.Errorf("index %s on %s differs from the script: %s",
+		existingNameStr, collection, strings.Join(details, "; "))
+}
+
+// An existing index that differs from the script's definition of it
+type IndexDrift struct {
+	Collection string
+	// Name of the existing index
+	Name string
+	// What differs, such as "unique false, script has true"
+	Differences []string
+	// Index document as listIndexes returned it
+	Existing bson.D
+	// Index as the script defines it
+	Script mongo.IndexModel
+}
+
+// Decides whether a drifted index may be dropped and recreated
+type IndexRebuildConfirmFunc func(ctx context.Context, drift IndexDrift) bool
+
+// Asks the configured confirmation before an index that differs from the
+// script is dropped and recreated, failing when it is refused
+func (p *Parser) confirmIndexRebuild(ctx context.Context, collection string, existing []bson.D, name string, model mongo.IndexModel) error {
+	if p.confirmRebuild == nil {
+		return nil
+	}
+
+	drift := IndexDrift{Collection: collection, Name: name, Script: model}
+	for _, index := range existing {
+		if indexName, _ := lookupKey(index, "name"); indexName == name {
+			drift.Existing = index
+			break
+		}
+	}
+	if keys, ok := model.Keys.(bson.D); ok {
+		desiredName := defaultIndexName(keys)
+		if model.Options != nil && model.Options.Name != nil {
+			desiredName = *model.Options.Name
+		}
+		for _, difference := range p.indexDifferences(drift.Existing, keys, desiredName, model) {
+			drift.Differences = append(drift.Differences, difference.detail)
+		}
+	}
+
+	if !p.confirmRebuild(ctx, drift) {
+		return fmt.Errorf("index %s on %s differs from the script (%s) and rebuilding it was not confirmed",
+			name, collection, strings.Join(drift.Differences, "; "))
+	}
+	log.Printf("Index %s on collection %s differs from the script (%s); rebuild confirmed", name, collection, strings.Join(drift.Differences, "; "))
+	return nil
+}
+
+// Applies hidden and expireAfterSeconds changes to an existing index with collMod
+func (p *Parser) updateIndex(ctx context.Context, db *mongo.Database, collection, name string, model mongo.IndexModel) error {
+	index := bson.D{{Key: "name", Value: name}}
+	hidden := false
+	if model.Options != nil {
+		if model.Options.Hidden != nil {
+			hidden = *model.Options.Hidden
+		}
+		if model.Options.ExpireAfterSeconds != nil {
+			index = append(index, bson.E{Key: "expireAfterSeconds", Value: *model.Options.ExpireAfterSeconds})
+		}
+	}
+	index = append(index, bson.E{Key: "hidden", Value: hidden})
+
+	command := bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "index", Value: index},
+	}
+	if err := db.RunCommand(ctx, command).Err(); err != nil {
+		return fmt.Errorf("failed to update index %s: %w", name, err)
+	}
+	return nil
+}
+
+// Lists the ways an existing index document differs from the scripted index
+func (p *Parser) indexDifferences(existing bson.D, keys bson.D, desiredName string, model mongo.IndexModel) []indexDifference {
+	var differences []indexDifference
+	differ := func(field, format string, args ...interface{}) {
+		differences = append(differences, indexDifference{field: field, detail: fmt.Sprintf(format, args...)})
+	}
+
+	pattern, textFields := indexKeyPattern(keys)
+	existingKeys, _ := lookupKey(existing, "key")
+	if !valuesEqual(existingKeys, pattern) {
+		differ("key", "keys %v, script has %v", existingKeys, keys)
+	} else if len(textFields) > 0 {
+		var scripted interface{}
+		if model.Options != nil {
+			scripted = model.Options.Weights
+		}
+		existingWeights, _ := lookupKey(existing, "weights")
+		if !sameTextWeights(existingWeights, textFields, scripted) {
+			differ("weights", "text fields %v, script has %v", existingWeights, textFields)
+		}
+	}
+	if name, _ := lookupKey(existing, "name"); name != desiredName {
+		differ("name", "name %v, script has %s", name, desiredName)
+	}
+
+	opts := model.Options
+	compareFlag := func(field string, desired *bool) {
+		value, _ := lookupKey(existing, field)
+		existingFlag, _ := value.(bool)
+		desiredFlag := desired != nil && *desired
+		if existingFlag != desiredFlag {
+			differ(field, "%s %t, script has %t", field, existingFlag, desiredFlag)
+		}
+	}
+
+	var unique, sparse, hidden *bool
+	var expireAfterSeconds *int32
+	var partialFilter interface{}
+	if opts != nil {
+		unique, sparse, hidden = opts.Unique, opts.Sparse, opts.Hidden
+		expireAfterSeconds = opts.ExpireAfterSeconds
+		partialFilter = opts.PartialFilterExpression
+	}
+	compareFlag("unique", unique)
+	compareFlag("sparse", sparse)
+	compareFlag("hidden", hidden)
+
+	existingExpire, hasExpire := lookupKey(existing, "expireAfterSeconds")
+	if hasExpire != (expireAfterSeconds != nil) || (hasExpire && !valuesEqual(existingExpire, *expireAfterSeconds)) {
+		differ("expireAfterSeconds", "expireAfterSeconds differs")
+	}
+
+	existingFilter, hasFilter := lookupKey(existing, "partialFilterExpression")
+	if hasFilter != (partialFilter != nil) || (hasFilter && !valuesEqual(existingFilter, partialFilter)) {
+		differ("partialFilterExpression", "partialFilterExpression differs")
+	}
+
+	// The server fills in every collation field, so only compare what scripts usually set
+	if opts != nil && opts.Collation != nil {
+		existingCollation, _ := lookupKey(existing, "collation")
+		collation, _ := asMap(existingCollation)
+		if collation == nil || collation["locale"] != opts.Collation.Locale ||
+			(opts.Collation.Strength != 0 && !valuesEqual(collation["strength"], opts.Collation.Strength)) {
+			differ("collation", "collation differs")
+		}
+	}
+
+	return differences
+}
+
+// Returns index keys as listIndexes reports them, along with the fields of
+// a text index. The server replaces the text fields of a key pattern with
+// _fts and _ftsx and lists the fields themselves in the index's weights.
+func indexKeyPattern(keys bson.D) (bson.D, []string) {
+	var pattern bson.D
+	var textFields []string
+	for _, elem := range keys {
+		if elem.Value != "text" {
+			pattern = append(pattern, elem)
+			continue
+		}
+		if textFields == nil {
+			pattern = append(pattern, bson.E{Key: "_fts", Value: "text"}, bson.E{Key: "_ftsx", Value: 1})
+		}
+		textFields = append(textFields, elem.Key)
+	}
+	return pattern, textFields
+}
+
+// Reports whether the weights of an existing text index cover exactly the
+// scripted text fields, with the scripted weights or the default of 1
+func sameTextWeights(existing interface{}, fields []string, scripted interface{}) bool {
+	weights, ok := asMap(existing)
+	if !ok || len(weights) != len(fields) {
+		return false
+	}
+	scriptedWeights, _ := asMap(scripted)
+	for _, field := range fields {
+		var expected interface{} = 1
+		if weight, ok := scriptedWeights[field]; ok {
+			expected = weight
+		}
+		if actual, ok := weights[field]; !ok || !valuesEqual(actual, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fails for compound index keys given as a map, whose field order Go
+// randomizes; scripts parse keys into bson.D, but middleware and custom
+// operations may build them
+func checkIndexKeyOrder(collection string, keys interface{}) error {
+	var fields int
+	switch k := keys.(type) {
+	case bson.M:
+		fields = len(k)
+	case map[string]interface{}:
+		fields = len(k)
+	}
+	if fields > 1 {
+		return fmt.Errorf("compound index keys on %s must be an ordered bson.D, got %T", collection, keys)
+	}
+	return nil
+}
+
+// Reads back the indexes just created under names and fails when the server
+// holds their keys in a different order than models
+func (p *Parser) verifyIndexKeyOrder(ctx context.Context, collection *mongo.Collection, models []mongo.IndexModel, names []string) error {
+	indexes, err := p.listIndexes(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	for i, model := range models {
+		keys, ok := model.Keys.(bson.D)
+		if !ok || i >= len(names) {
+			continue
+		}
+		var created interface{}
+		for _, index := range indexes {
+			if name, _ := lookupKey(index, "name"); name == names[i] {
+				created, _ = lookupKey(index, "key")
+				break
+			}
+		}
+		if created == nil {
+			return fmt.Errorf("index %s on %s was not found after creating it", names[i], collection.Name())
+		}
+		if !valuesEqual(created, keys) {
+			createdKeys, _ := created.(bson.D)
+			return fmt.Errorf("index %s on %s was created with keys %s, script has %s",
+				names[i], collection.Name(), formatKeys(createdKeys), formatKeys(keys))
+		}
+	}
+	return nil
+}
+
+// Names indexes "idx_<collection>_<field>_<value>...", such as
+// idx_users_email_1_status_-1 for { email: 1, status: -1 } on users
+func ConventionalIndexName(collection string, keys bson.D) string {
+	return "idx_" + collection + "_" + defaultIndexName(keys)
+}
+
+// Names an unnamed index as configured by WithIndexNaming. The options are
+// copied, since createIndexes shares one options value between its indexes.
+func (p *Parser) nameIndex(collection string, keys bson.D, opts *options.IndexOptions) *options.IndexOptions {
+	if p.indexName == nil || (opts != nil && opts.Name != nil) {
+		return opts
+	}
+	named := options.Index()
+	if opts != nil {
+		copied := *opts
+		named = &copied
+	}
+	return named.SetName(p.indexName(collection, keys))
+}
+
+// Generates the index name the server assigns when none is given
+func defaultIndexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, elem := range keys {
+		parts = append(parts, elem.Key, fmt.Sprint(elem.Value))
+	}
+	return strings.Join(parts, "_")
+}
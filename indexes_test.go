@@ -0,0 +1,203 @@
+package mongoparser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPlanIndex(t *testing.T) {
+	parser := NewParser()
+
+	existing := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}, {Key: "unique", Value: true}},
+	}
+
+	identical := mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	action, name, err := parser.planIndex("users", existing, identical)
+	if err != nil || action != indexSkip || name != "email_1" {
+		t.Errorf("Expected identical index to be skipped, got action %v, name %s, err %v", action, name, err)
+	}
+
+	missing := mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}}}
+	if action, _, err := parser.planIndex("users", existing, missing); err != nil || action != indexCreate {
+		t.Errorf("Expected missing index to be created, got action %v, err %v", action, err)
+	}
+
+	changed := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}}
+	if _, _, err := parser.planIndex("users", existing, changed); err == nil {
+		t.Error("Expected drift error for index that lost its unique option")
+	}
+
+	recreating := NewParser(WithIndexConflictPolicy(ConflictRecreate))
+	action, name, err = recreating.planIndex("users", existing, changed)
+	if err != nil || action != indexRecreate || name != "email_1" {
+		t.Errorf("Expected changed index to be recreated, got action %v, name %s, err %v", action, name, err)
+	}
+
+	failing := NewParser(WithIndexConflictPolicy(ConflictFail))
+	if _, _, err := failing.planIndex("users", existing, identical); err == nil {
+		t.Error("Expected fail policy to reject an existing index")
+	}
+
+	updating := NewParser(WithIndexConflictPolicy(ConflictUpdate))
+	hidden := mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true).SetHidden(true),
+	}
+	if action, _, err := updating.planIndex("users", existing, hidden); err != nil || action != indexUpdate {
+		t.Errorf("Expected hidden change to be applied with collMod, got action %v, err %v", action, err)
+	}
+	if action, _, err := updating.planIndex("users", existing, changed); err != nil || action != indexRecreate {
+		t.Errorf("Expected unique change to require recreating, got action %v, err %v", action, err)
+	}
+}
+
+func TestPlanTextIndex(t *testing.T) {
+	existing := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+		{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: "status", Value: int32(1)}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}},
+			{Key: "name", Value: "status_1_title_text_body_text"},
+			{Key: "weights", Value: bson.D{{Key: "body", Value: int32(1)}, {Key: "title", Value: int32(3)}}},
+			{Key: "default_language", Value: "english"},
+			{Key: "language_override", Value: "language"},
+			{Key: "textIndexVersion", Value: int32(3)},
+		},
+	}
+	keys := bson.D{{Key: "status", Value: 1}, {Key: "title", Value: "text"}, {Key: "body", Value: "text"}}
+
+	for _, policy := range []ConflictPolicy{ConflictSkip, ConflictRecreate} {
+		parser := NewParser(WithIndexConflictPolicy(policy))
+		model := mongo.IndexModel{Keys: keys, Options: options.Index().SetWeights(bson.D{{Key: "title", Value: 3}})}
+		if action, name, err := parser.planIndex("posts", existing, model); err != nil || action != indexSkip || name != "status_1_title_text_body_text" {
+			t.Errorf("Expected an unchanged text index to be skipped under %v, got action %v, name %s, err %v", policy, action, name, err)
+		}
+	}
+
+	reweighted := mongo.IndexModel{Keys: keys}
+	if _, _, err := NewParser().planIndex("posts", existing, reweighted); err == nil || !strings.Contains(err.Error(), "text fields") {
+		t.Errorf("Expected changed weights to be reported, got %v", err)
+	}
+	fewer := mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "title", Value: "text"}}, Options: options.Index().SetName("status_1_title_text_body_text")}
+	if _, _, err := NewParser().planIndex("posts", existing, fewer); err == nil {
+		t.Error("Expected a text index over other fields to be reported")
+	}
+}
+
+func TestPlanIndexWithAnotherName(t *testing.T) {
+	existing := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "by_email"}},
+	}
+	renamed := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}}
+
+	parser := NewParser()
+	parser.warnings = &[]string{}
+	action, name, err := parser.planIndex("users", existing, renamed)
+	if err != nil || action != indexSkip || name != "by_email" {
+		t.Errorf("Expected an index named differently to be skipped, got action %v, name %s, err %v", action, name, err)
+	}
+	if len(*parser.warnings) != 1 || !strings.Contains((*parser.warnings)[0], "except for its name") {
+		t.Errorf("Expected a warning about the name, got %v", *parser.warnings)
+	}
+
+	recreating := NewParser(WithIndexConflictPolicy(ConflictRecreate))
+	if action, _, err := recreating.planIndex("users", existing, renamed); err != nil || action != indexRecreate {
+		t.Errorf("Expected the recreate policy to rename the index, got action %v, err %v", action, err)
+	}
+}
+
+func TestCheckIndexKeyOrder(t *testing.T) {
+	if err := checkIndexKeyOrder("users", bson.D{{Key: "lastName", Value: 1}, {Key: "firstName", Value: 1}}); err != nil {
+		t.Errorf("Expected ordered keys to pass, got %v", err)
+	}
+	if err := checkIndexKeyOrder("users", bson.M{"email": 1}); err != nil {
+		t.Errorf("Expected a single-field map to pass, got %v", err)
+	}
+	if err := checkIndexKeyOrder("users", bson.M{"lastName": 1, "firstName": 1}); err == nil {
+		t.Error("Expected compound keys in a map to be rejected")
+	}
+}
+
+func TestParsedIndexKeysKeepScriptOrder(t *testing.T) {
+	ops, err := NewParser().ParseScript(`db.users.createIndex({ lastName: 1, firstName: 1, age: -1, city: 1, zip: 1 });`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+	keys, ok := ops[0].IndexSpec.(bson.D)
+	if !ok {
+		t.Fatalf("Expected ordered index keys, got %T", ops[0].IndexSpec)
+	}
+	if formatKeys(keys) != "{ lastName: 1, firstName: 1, age: -1, city: 1, zip: 1 }" {
+		t.Errorf("Expected the script's key order, got %s", formatKeys(keys))
+	}
+}
+
+func TestIndexNaming(t *testing.T) {
+	parser := NewParser(WithIndexNaming(ConventionalIndexName))
+	ops, err := parser.ParseScript(`db.users.createIndex({ email: 1, status: -1 });
+db.users.createIndex({ name: 1 }, { name: "by_name", unique: true });
+db.users.createIndexes([{ city: 1 }, { bio: "text" }], { sparse: true });`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+
+	if name := ops[0].IndexOptions.Name; name == nil || *name != "idx_users_email_1_status_-1" {
+		t.Errorf("Expected a conventional name, got %v", name)
+	}
+	if name := *ops[1].IndexOptions.Name; name != "by_name" {
+		t.Errorf("Expected an explicit name to be kept, got %s", name)
+	}
+
+	models := ops[2].IndexModels
+	if *models[0].Options.Name != "idx_users_city_1" || *models[1].Options.Name != "idx_users_bio_text" {
+		t.Errorf("Expected each index to get its own name, got %s and %s", *models[0].Options.Name, *models[1].Options.Name)
+	}
+	if models[0].Options.Sparse == nil || !*models[0].Options.Sparse {
+		t.Error("Expected shared options to be kept when naming")
+	}
+
+	unnamed, err := NewParser().ParseScript(`db.users.createIndex({ email: 1 });`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+	if unnamed[0].IndexOptions != nil {
+		t.Errorf("Expected no name without WithIndexNaming, got %v", unnamed[0].IndexOptions)
+	}
+}
+
+func TestConfirmIndexRebuild(t *testing.T) {
+	existing := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}},
+	}
+	model := mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)}
+
+	var asked IndexDrift
+	refusing := NewParser(WithIndexReconciliation(func(ctx context.Context, drift IndexDrift) bool {
+		asked = drift
+		return false
+	}))
+	err := refusing.confirmIndexRebuild(context.Background(), "users", existing, "email_1", model)
+	if err == nil || !strings.Contains(err.Error(), "rebuilding it was not confirmed") {
+		t.Errorf("Expected a refused rebuild to fail, got %v", err)
+	}
+	if asked.Name != "email_1" || len(asked.Differences) != 1 || asked.Differences[0] != "unique false, script has true" || asked.Existing == nil {
+		t.Errorf("Unexpected drift passed to the confirmation: %+v", asked)
+	}
+
+	if err := NewParser(WithIndexReconciliation(nil)).confirmIndexRebuild(context.Background(), "users", existing, "email_1", model); err != nil {
+		t.Errorf("Expected rebuilds to proceed without a confirmation, got %v", err)
+	}
+	if action, _, _ := NewParser(WithIndexReconciliation(nil)).planIndex("users", existing, model); action != indexRecreate {
+		t.Errorf("Expected reconciliation to recreate the drifted index, got %v", action)
+	}
+}
@@ -0,0 +1,380 @@
+package mongoparser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Decimal numbers as JSON5 writes them, e.g. .5, 5. and +1e3
+var json5NumberPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// Parses relaxed JSON (JSON5) object literals directly into ordered values:
+// unquoted keys, single-quoted strings, trailing commas, comments, hexadecimal
+// numbers, Infinity and NaN. Values that are not literals are compiled as
+// expressions.
+type json5Parser struct {
+	input     string
+	pos       int
+	numbers   NumberMode
+	functions map[string]ScriptFunc
+}
+
+// Parses a complete JSON5 value, decoding numbers according to mode and
+// letting expressions call functions
+func parseJSON5(input string, numbers NumberMode, functions map[string]ScriptFunc) (interface{}, error) {
+	parser := &json5Parser{input: input, numbers: numbers, functions: functions}
+	value, err := parser.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.skipSpace(); err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.input) {
+		return nil, parser.errorf("unexpected content after value")
+	}
+	return value, nil
+}
+
+// Returns an error annotated with the current offset
+func (j *json5Parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at offset %d", fmt.Sprintf(format, args...), j.pos)
+}
+
+// Skips whitespace and comments
+func (j *json5Parser) skipSpace() error {
+	for j.pos < len(j.input) {
+		switch {
+		case strings.HasPrefix(j.input[j.pos:], "//"):
+			end := strings.IndexByte(j.input[j.pos:], '\n')
+			if end < 0 {
+				j.pos = len(j.input)
+				return nil
+			}
+			j.pos += end + 1
+		case strings.HasPrefix(j.input[j.pos:], "/*"):
+			end := strings.Index(j.input[j.pos+2:], "*/")
+			if end < 0 {
+				return j.errorf("unterminated comment")
+			}
+			j.pos += end + 4
+		default:
+			char, size := utf8.DecodeRuneInString(j.input[j.pos:])
+			if !unicode.IsSpace(char) && char != '\ufeff' {
+				return nil
+			}
+			j.pos += size
+		}
+	}
+	return nil
+}
+
+// Parses an object, array or primitive value
+func (j *json5Parser) parseValue() (interface{}, error) {
+	if err := j.skipSpace(); err != nil {
+		return nil, err
+	}
+	if j.pos >= len(j.input) {
+		return nil, j.errorf("unexpected end of input")
+	}
+	switch j.input[j.pos] {
+	case '{':
+		return j.parseObject()
+	case '[':
+		return j.parseArray()
+	default:
+		return j.parsePrimitive()
+	}
+}
+
+// Parses an object into a bson.D, keeping key order
+func (j *json5Parser) parseObject() (interface{}, error) {
+	j.pos++
+	doc := bson.D{}
+	for {
+		if err := j.skipSpace(); err != nil {
+			return nil, err
+		}
+		if j.pos < len(j.input) && j.input[j.pos] == '}' {
+			j.pos++
+			return doc, nil
+		}
+
+		key, err := j.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := j.skipSpace(); err != nil {
+			return nil, err
+		}
+		if j.pos >= len(j.input) || j.input[j.pos] != ':' {
+			return nil, j.errorf("expected : after key %q", key)
+		}
+		j.pos++
+		value, err := j.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		doc = append(doc, bson.E{Key: key, Value: value})
+
+		if err := j.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Parses an array into a bson.A
+func (j *json5Parser) parseArray() (interface{}, error) {
+	j.pos++
+	arr := bson.A{}
+	for {
+		if err := j.skipSpace(); err != nil {
+			return nil, err
+		}
+		if j.pos < len(j.input) && j.input[j.pos] == ']' {
+			j.pos++
+			return arr, nil
+		}
+
+		value, err := j.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, value)
+
+		if err := j.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Consumes the comma after a member, or leaves the closing bracket in place
+func (j *json5Parser) separator(closing byte) error {
+	if err := j.skipSpace(); err != nil {
+		return err
+	}
+	if j.pos >= len(j.input) {
+		return j.errorf("expected %c", closing)
+	}
+	switch j.input[j.pos] {
+	case ',':
+		j.pos++
+		return nil
+	case closing:
+		return nil
+	default:
+		return j.errorf("expected , or %c", closing)
+	}
+}
+
+// Parses a quoted or identifier key
+func (j *json5Parser) parseKey() (string, error) {
+	if j.pos >= len(j.input) {
+		return "", j.errorf("unexpected end of input")
+	}
+	if quote := j.input[j.pos]; quote == '"' || quote == '\'' {
+		end := closingQuote(j.input, j.pos)
+		if end < 0 {
+			return "", j.errorf("unterminated string")
+		}
+		key, err := unquoteJSON5(j.input[j.pos : end+1])
+		if err != nil {
+			return "", j.errorf("%v", err)
+		}
+		j.pos = end + 1
+		return key, nil
+	}
+
+	start := j.pos
+	for j.pos < len(j.input) && isAlphaNum(rune(j.input[j.pos])) {
+		j.pos++
+	}
+	if j.pos == start || !isAlphaStart(rune(j.input[start])) {
+		return "", j.errorf("expected object key")
+	}
+	return j.input[start:j.pos], nil
+}
+
+// Parses a string, number or keyword, or compiles the value as an expression
+// when it is anything else, such as new Date() or 60 * 60
+func (j *json5Parser) parsePrimitive() (interface{}, error) {
+	end := j.valueEnd()
+	text := strings.TrimSpace(j.input[j.pos:end])
+	if text == "" {
+		return nil, j.errorf("expected value")
+	}
+	value, ok, err := json5Primitive(text, j.numbers)
+	if err != nil {
+		return nil, j.errorf("%v", err)
+	}
+	if !ok {
+		value, err = compileExpression(text, j.functions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	j.pos = end
+	return value, nil
+}
+
+// Returns the offset just past the value at the current position: the next
+// comma, closing bracket or comment outside parentheses and quotes
+func (j *json5Parser) valueEnd() int {
+	depth := 0
+	for i := j.pos; i < len(j.input); i++ {
+		switch char := j.input[i]; {
+		case char == '"' || char == '\'':
+			end := closingQuote(j.input, i)
+			if end < 0 {
+				return len(j.input)
+			}
+			i = end
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+		case depth > 0:
+		case char == ',' || char == '}' || char == ']':
+			return i
+		case strings.HasPrefix(j.input[i:], "//") || strings.HasPrefix(j.input[i:], "/*"):
+			return i
+		}
+	}
+	return len(j.input)
+}
+
+// Converts a literal string, number or keyword. ok is false when text is not
+// a single literal.
+func json5Primitive(text string, numbers NumberMode) (value interface{}, ok bool, err error) {
+	switch text {
+	case "true":
+		return true, true, nil
+	case "false":
+		return false, true, nil
+	case "null":
+		return nil, true, nil
+	case "Infinity", "+Infinity":
+		return math.Inf(1), true, nil
+	case "-Infinity":
+		return math.Inf(-1), true, nil
+	case "NaN", "+NaN", "-NaN":
+		return math.NaN(), true, nil
+	}
+
+	if quote := text[0]; quote == '"' || quote == '\'' {
+		if closingQuote(text, 0) != len(text)-1 {
+			return nil, false, nil
+		}
+		value, err := unquoteJSON5(text)
+		return value, true, err
+	}
+
+	unsigned := strings.TrimLeft(text, "+-")
+	if len(text)-len(unsigned) <= 1 && (strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X")) {
+		number, err := strconv.ParseUint(unsigned[2:], 16, 64)
+		if err != nil || number > math.MaxInt64 {
+			return nil, false, nil
+		}
+		decimal := strconv.FormatUint(number, 10)
+		if text[0] == '-' {
+			decimal = "-" + decimal
+		}
+		value, err := decodeNumber(decimal, numbers)
+		return value, true, err
+	}
+	if json5NumberPattern.MatchString(text) {
+		value, err := decodeNumber(text, numbers)
+		return value, true, err
+	}
+	return nil, false, nil
+}
+
+// Returns the offset of the quote closing the string that starts at start, or -1
+func closingQuote(input string, start int) int {
+	quote := input[start]
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// Decodes a single- or double-quoted JSON5 string with its escapes
+func unquoteJSON5(quoted string) (string, error) {
+	body := quoted[1 : len(quoted)-1]
+	if !strings.ContainsRune(body, '\\') {
+		return body, nil
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' {
+			result.WriteByte(body[i])
+			continue
+		}
+		i++
+		if i >= len(body) {
+			return "", fmt.Errorf("invalid escape at end of string")
+		}
+		switch escape := body[i]; escape {
+		case 'b':
+			result.WriteByte('\b')
+		case 'f':
+			result.WriteByte('\f')
+		case 'n':
+			result.WriteByte('\n')
+		case 'r':
+			result.WriteByte('\r')
+		case 't':
+			result.WriteByte('\t')
+		case 'v':
+			result.WriteByte('\v')
+		case '0':
+			result.WriteByte(0)
+		case '\n':
+			// Line continuation
+		case '\r':
+			if i+1 < len(body) && body[i+1] == '\n' {
+				i++
+			}
+		case 'x', 'u':
+			digits := 2
+			if escape == 'u' {
+				digits = 4
+			}
+			if i+digits >= len(body) {
+				return "", fmt.Errorf("invalid \\%c escape", escape)
+			}
+			code, err := strconv.ParseUint(body[i+1:i+1+digits], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\%c escape", escape)
+			}
+			i += digits
+			char := rune(code)
+			// Combine UTF-16 surrogate pairs written as two \u escapes
+			if utf16.IsSurrogate(char) && i+6 < len(body) && strings.HasPrefix(body[i+1:], "\\u") {
+				if low, err := strconv.ParseUint(body[i+3:i+7], 16, 32); err == nil {
+					char = utf16.DecodeRune(char, rune(low))
+					i += 6
+				}
+			}
+			result.WriteRune(char)
+		default:
+			// \\, \", \', \/ and any other character stand for themselves
+			result.WriteByte(escape)
+		}
+	}
+	return result.String(), nil
+}
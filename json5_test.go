@@ -0,0 +1,89 @@
+package mongoparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseJSON5(t *testing.T) {
+	input := `{
+		// Strings keep colons, braces and the other quote kind
+		label: "key: value",
+		pattern: 'a{b}[c]',
+		quote: 'say "hi"',
+		escaped: 'it\'s é\x41',
+		/* numbers */
+		mask: 0xFF,
+		ratio: .5,
+		limit: +Infinity,
+		'quoted key': [1, 2,],
+	}`
+	value, err := parseJSON5(input, NumberFloat64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bson.D{
+		{Key: "label", Value: "key: value"},
+		{Key: "pattern", Value: "a{b}[c]"},
+		{Key: "quote", Value: `say "hi"`},
+		{Key: "escaped", Value: "it's éA"},
+		{Key: "mask", Value: 255.0},
+		{Key: "ratio", Value: 0.5},
+		{Key: "limit", Value: math.Inf(1)},
+		{Key: "quoted key", Value: bson.A{1.0, 2.0}},
+	}
+	if !valuesEqual(value, expected) {
+		t.Errorf("Expected %v, got %v", expected, value)
+	}
+}
+
+func TestParseJSON5Expressions(t *testing.T) {
+	value, err := parseJSON5(`{ ttl: 60 * 60, created: new Date(), name: 'svc_' + "a" }`, NumberFloat64, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := value.(bson.D)
+	if doc[0].Value != 3600.0 || doc[2].Value != "svc_a" {
+		t.Errorf("Expected folded expressions, got %v", doc)
+	}
+	if _, ok := doc[1].Value.(Expression); !ok {
+		t.Errorf("Expected new Date() to be an expression, got %T", doc[1].Value)
+	}
+}
+
+func TestParseJSON5Invalid(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`{ a: 1 b: 2 }`, "unsupported expression 1 b"},
+		{`{ a 1 }`, "expected : after key"},
+		{`{ a: 'open }`, "unterminated string"},
+		{`[1, 2`, "expected ]"},
+		{`{ a: 1 } /* open`, "unterminated comment"},
+		{`{ 1a: 1 }`, "expected object key"},
+	}
+	for _, tt := range tests {
+		_, err := parseJSON5(tt.input, NumberFloat64, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.input, tt.err, err)
+		}
+	}
+}
+
+func TestJSON5Mode(t *testing.T) {
+	statement := `db.settings.insertOne({ url: "http://example.com/{id}", note: 'it:s' })`
+
+	op, err := NewParser(WithJSON5()).parseMongoStatement(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.D{{Key: "url", Value: "http://example.com/{id}"}, {Key: "note", Value: "it:s"}}
+	if !valuesEqual(op.Arguments[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, op.Arguments[0])
+	}
+}
@@ -0,0 +1,431 @@
+package mongoparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Describes a problem in a $jsonSchema validator found before it is sent to
+// the server
+type SchemaError struct {
+	// Location of the offending keyword, such as "properties.age.minimum"
+	Path    string
+	Message string
+}
+
+// Formats the error with the keyword path
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid $jsonSchema: %s", e.Message)
+	}
+	return fmt.Sprintf("invalid $jsonSchema at %s: %s", e.Path, e.Message)
+}
+
+// Kinds of value a $jsonSchema keyword takes
+const (
+	schemaString = iota
+	schemaBool
+	schemaNumber
+	schemaCount
+	schemaStringList
+	schemaSchema
+	schemaSchemaList
+	schemaSchemaMap
+	schemaBoolOrSchema
+	schemaSchemaOrList
+	schemaTypes
+	schemaEnum
+	schemaDependencies
+	schemaEncrypt
+	schemaEncryptMetadata
+)
+
+// Keywords MongoDB supports in $jsonSchema, by the kind of value they take.
+// Draft 4 keywords such as $ref, default, definitions and format are not supported.
+var schemaKeywords = map[string]int{
+	"additionalItems":      schemaBoolOrSchema,
+	"additionalProperties": schemaBoolOrSchema,
+	"allOf":                schemaSchemaList,
+	"anyOf":                schemaSchemaList,
+	"bsonType":             schemaTypes,
+	"dependencies":         schemaDependencies,
+	"description":          schemaString,
+	"encrypt":              schemaEncrypt,
+	"encryptMetadata":      schemaEncryptMetadata,
+	"enum":                 schemaEnum,
+	"exclusiveMaximum":     schemaBool,
+	"exclusiveMinimum":     schemaBool,
+	"items":                schemaSchemaOrList,
+	"maximum":              schemaNumber,
+	"maxItems":             schemaCount,
+	"maxLength":            schemaCount,
+	"maxProperties":        schemaCount,
+	"minimum":              schemaNumber,
+	"minItems":             schemaCount,
+	"minLength":            schemaCount,
+	"minProperties":        schemaCount,
+	"multipleOf":           schemaNumber,
+	"not":                  schemaSchema,
+	"oneOf":                schemaSchemaList,
+	"pattern":              schemaString,
+	"patternProperties":    schemaSchemaMap,
+	"properties":           schemaSchemaMap,
+	"required":             schemaStringList,
+	"title":                schemaString,
+	"type":                 schemaTypes,
+	"uniqueItems":          schemaBool,
+}
+
+// BSON type aliases accepted by bsonType
+var bsonTypeAliases = map[string]bool{
+	"double": true, "string": true, "object": true, "array": true, "binData": true,
+	"undefined": true, "objectId": true, "bool": true, "date": true, "null": true,
+	"regex": true, "dbPointer": true, "javascript": true, "symbol": true,
+	"javascriptWithScope": true, "int": true, "timestamp": true, "long": true,
+	"decimal": true, "minKey": true, "maxKey": true, "number": true,
+}
+
+// JSON types accepted by type; MongoDB has no "integer"
+var jsonTypeNames = map[string]bool{
+	"object": true, "array": true, "number": true, "boolean": true, "string": true, "null": true,
+}
+
+// Checks the $jsonSchema of a collection validator, if it has one
+func validateValidator(validator interface{}) error {
+	doc, ok := validator.(bson.D)
+	if !ok {
+		return nil
+	}
+	schema, ok := lookupKey(doc, "$jsonSchema")
+	if !ok {
+		return nil
+	}
+	return validateSchema(schema, "")
+}
+
+// Checks one schema document and its subschemas
+func validateSchema(value interface{}, path string) error {
+	schema, ok := value.(bson.D)
+	if !ok {
+		return &SchemaError{Path: path, Message: fmt.Sprintf("expected a schema document, got %s", describeSchemaValue(value))}
+	}
+
+	for _, elem := range schema {
+		keyPath := joinPath(path, elem.Key)
+		kind, ok := schemaKeywords[elem.Key]
+		if !ok {
+			return &SchemaError{Path: keyPath, Message: fmt.Sprintf("unsupported keyword %q", elem.Key)}
+		}
+		if err := validateKeyword(elem.Key, kind, elem.Value, keyPath); err != nil {
+			return err
+		}
+	}
+
+	// enum values must be of a type the schema allows
+	if enum, ok := lookupKey(schema, "enum"); ok {
+		types, hasTypes := lookupKey(schema, "bsonType")
+		if !hasTypes {
+			return nil
+		}
+		for i, item := range enum.(bson.A) {
+			if !matchesBSONType(item, schemaTypeNames(types)) {
+				return &SchemaError{
+					Path:    fmt.Sprintf("%s[%d]", joinPath(path, "enum"), i),
+					Message: fmt.Sprintf("%s does not match bsonType %s", describeSchemaValue(item), strings.Join(schemaTypeNames(types), ", ")),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Checks the value of a keyword against the kind it takes
+func validateKeyword(keyword string, kind int, value interface{}, path string) error {
+	invalid := func(expected string) error {
+		return &SchemaError{Path: path, Message: fmt.Sprintf("expected %s, got %s", expected, describeSchemaValue(value))}
+	}
+
+	switch kind {
+	case schemaString:
+		if _, ok := value.(string); !ok {
+			return invalid("a string")
+		}
+	case schemaBool:
+		if _, ok := value.(bool); !ok {
+			return invalid("a boolean")
+		}
+	case schemaNumber:
+		number, ok := toFloat64(value)
+		if !ok {
+			return invalid("a number")
+		}
+		if keyword == "multipleOf" && number <= 0 {
+			return &SchemaError{Path: path, Message: "must be greater than 0"}
+		}
+	case schemaCount:
+		number, ok := toFloat64(value)
+		if !ok || number < 0 || number != float64(int64(number)) {
+			return invalid("a non-negative integer")
+		}
+	case schemaStringList:
+		list, ok := value.(bson.A)
+		if !ok || len(list) == 0 {
+			return invalid("a non-empty array of strings")
+		}
+		seen := make(map[string]bool, len(list))
+		for i, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return &SchemaError{Path: fmt.Sprintf("%s[%d]", path, i), Message: fmt.Sprintf("expected a string, got %s", describeSchemaValue(item))}
+			}
+			if seen[name] {
+				return &SchemaError{Path: fmt.Sprintf("%s[%d]", path, i), Message: fmt.Sprintf("%q is listed twice", name)}
+			}
+			seen[name] = true
+		}
+	case schemaSchema:
+		return validateSchema(value, path)
+	case schemaSchemaList:
+		list, ok := value.(bson.A)
+		if !ok || len(list) == 0 {
+			return invalid("a non-empty array of schemas")
+		}
+		for i, item := range list {
+			if err := validateSchema(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case schemaSchemaMap:
+		doc, ok := value.(bson.D)
+		if !ok {
+			return invalid("a document of schemas")
+		}
+		for _, elem := range doc {
+			if err := validateSchema(elem.Value, joinPath(path, elem.Key)); err != nil {
+				return err
+			}
+		}
+	case schemaBoolOrSchema:
+		if _, ok := value.(bool); !ok {
+			return validateSchema(value, path)
+		}
+	case schemaSchemaOrList:
+		if _, ok := value.(bson.A); ok {
+			return validateKeyword(keyword, schemaSchemaList, value, path)
+		}
+		return validateSchema(value, path)
+	case schemaTypes:
+		return validateSchemaTypes(keyword, value, path)
+	case schemaEnum:
+		list, ok := value.(bson.A)
+		if !ok || len(list) == 0 {
+			return invalid("a non-empty array")
+		}
+	case schemaDependencies:
+		doc, ok := value.(bson.D)
+		if !ok {
+			return invalid("a document")
+		}
+		for _, elem := range doc {
+			elemPath := joinPath(path, elem.Key)
+			if _, ok := elem.Value.(bson.A); ok {
+				if err := validateKeyword(keyword, schemaStringList, elem.Value, elemPath); err != nil {
+					return err
+				}
+			} else if err := validateSchema(elem.Value, elemPath); err != nil {
+				return err
+			}
+		}
+	case schemaEncrypt, schemaEncryptMetadata:
+		return validateEncryptKeyword(kind, value, path)
+	}
+	return nil
+}
+
+// Checks the document of an encrypt or encryptMetadata keyword, which enforce
+// client-side field level encryption. bsonType is only allowed in encrypt.
+func validateEncryptKeyword(kind int, value interface{}, path string) error {
+	doc, ok := value.(bson.D)
+	if !ok {
+		return &SchemaError{Path: path, Message: fmt.Sprintf("expected a document, got %s", describeSchemaValue(value))}
+	}
+	for _, elem := range doc {
+		elemPath := joinPath(path, elem.Key)
+		switch {
+		case elem.Key == "keyId":
+			if err := validateKeyID(elem.Value, elemPath); err != nil {
+				return err
+			}
+		case elem.Key == "algorithm":
+			if algorithm, ok := elem.Value.(string); !ok || (algorithm != AlgorithmDeterministic && algorithm != AlgorithmRandom) {
+				return &SchemaError{Path: elemPath, Message: fmt.Sprintf("expected %s or %s, got %s", AlgorithmDeterministic, AlgorithmRandom, describeSchemaValue(elem.Value))}
+			}
+		case elem.Key == "bsonType" && kind == schemaEncrypt:
+			if err := validateSchemaTypes("bsonType", elem.Value, elemPath); err != nil {
+				return err
+			}
+		default:
+			return &SchemaError{Path: elemPath, Message: fmt.Sprintf("unsupported encryption option %q", elem.Key)}
+		}
+	}
+	return nil
+}
+
+// Checks a keyId: a JSON pointer to the field holding the key's alternate
+// name, or an array of data key UUIDs
+func validateKeyID(value interface{}, path string) error {
+	if pointer, ok := value.(string); ok {
+		if !strings.HasPrefix(pointer, "/") {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("expected a JSON pointer starting with /, got %q", pointer)}
+		}
+		return nil
+	}
+	keys, ok := value.(bson.A)
+	if !ok || len(keys) == 0 {
+		return &SchemaError{Path: path, Message: fmt.Sprintf("expected a JSON pointer or a non-empty array of UUIDs, got %s", describeSchemaValue(value))}
+	}
+	for i, key := range keys {
+		if !isUUID(key) {
+			return &SchemaError{Path: fmt.Sprintf("%s[%d]", path, i), Message: fmt.Sprintf("expected a UUID, got %s", describeSchemaValue(key))}
+		}
+	}
+	return nil
+}
+
+// Reports whether a value is a UUID, either decoded or written in a script
+// as an extended JSON $uuid or $binary document
+func isUUID(value interface{}) bool {
+	switch v := value.(type) {
+	case primitive.Binary:
+		return v.Subtype == bson.TypeBinaryUUID
+	case bson.D:
+		if len(v) != 1 {
+			return false
+		}
+		switch v[0].Key {
+		case "$uuid":
+			_, ok := v[0].Value.(string)
+			return ok
+		case "$binary":
+			binary, _ := v[0].Value.(bson.D)
+			subtype, _ := lookupKey(binary, "subType")
+			return subtype == "04" || subtype == "4"
+		}
+	}
+	return false
+}
+
+// Checks the type names of bsonType or type
+func validateSchemaTypes(keyword string, value interface{}, path string) error {
+	known := bsonTypeAliases
+	if keyword == "type" {
+		known = jsonTypeNames
+	}
+
+	names, ok := value.(bson.A)
+	if !ok {
+		names = bson.A{value}
+	} else if len(names) == 0 {
+		return &SchemaError{Path: path, Message: "expected at least one type"}
+	}
+	for _, item := range names {
+		name, ok := item.(string)
+		if !ok {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("expected a type name, got %s", describeSchemaValue(item))}
+		}
+		if !known[name] {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("unknown %s %q; expected one of %s", keyword, name, strings.Join(sortedKeys(known), ", "))}
+		}
+	}
+	return nil
+}
+
+// Returns the type names of a bsonType value
+func schemaTypeNames(value interface{}) []string {
+	list, ok := value.(bson.A)
+	if !ok {
+		list = bson.A{value}
+	}
+	names := make([]string, 0, len(list))
+	for _, item := range list {
+		if name, ok := item.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Reports whether a decoded script value is of one of the BSON types. Types a
+// script literal cannot produce are assumed to match.
+func matchesBSONType(value interface{}, types []string) bool {
+	for _, name := range types {
+		switch name {
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "bool":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "object":
+			if _, ok := value.(bson.D); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.(bson.A); ok {
+				return true
+			}
+		case "date":
+			if _, ok := value.(primitive.DateTime); ok {
+				return true
+			}
+		case "objectId":
+			if _, ok := value.(primitive.ObjectID); ok {
+				return true
+			}
+		case "int", "long", "double", "decimal", "number":
+			// Script numbers decode as float64 whichever numeric type they are stored as
+			if _, ok := toFloat64(value); ok {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Describes a value for error messages
+func describeSchemaValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bson.D:
+		return "a document"
+	case bson.A:
+		return "an array"
+	default:
+		return fmt.Sprintf("%T %v", value, value)
+	}
+}
+
+// Returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package mongoparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func parseValidator(t *testing.T, schema string) bson.D {
+	t.Helper()
+	var validator bson.D
+	if err := NewParser().parseJSONLikeString(`{ $jsonSchema: `+schema+` }`, &validator); err != nil {
+		t.Fatal(err)
+	}
+	return validator
+}
+
+func TestValidateValidator(t *testing.T) {
+	valid := parseValidator(t, `{
+		bsonType: "object",
+		required: ["name", "status"],
+		additionalProperties: false,
+		properties: {
+			_id: { bsonType: "objectId" },
+			name: { bsonType: "string", minLength: 1, description: "must be a string" },
+			age: { bsonType: ["int", "long"], minimum: 0, maximum: 150 },
+			status: { enum: ["active", "inactive"] },
+			tags: { bsonType: "array", items: { bsonType: "string" }, uniqueItems: true },
+			level: { bsonType: "int", enum: [1, 2, 3] },
+			address: { bsonType: "object", properties: { zip: { type: "string", pattern: "^[0-9]{5}$" } } }
+		},
+		anyOf: [{ required: ["email"] }, { required: ["phone"] }]
+	}`)
+	if err := validateValidator(valid); err != nil {
+		t.Errorf("Expected a valid schema, got %v", err)
+	}
+	if err := validateValidator(bson.D{{Key: "status", Value: "active"}}); err != nil {
+		t.Errorf("Expected query validators to be left alone, got %v", err)
+	}
+
+	cases := map[string]struct{ path, message string }{
+		`{ properties: { email: { bsonType: "string", format: "email" } } }`: {"properties.email.format", `unsupported keyword "format"`},
+		`{ properties: { age: { bsonType: "integer" } } }`:                   {"properties.age.bsonType", `unknown bsonType "integer"`},
+		`{ properties: { age: { type: "int" } } }`:                           {"properties.age.type", `unknown type "int"`},
+		`{ properties: { age: { minimum: "18" } } }`:                         {"properties.age.minimum", `expected a number, got string "18"`},
+		`{ properties: { name: { minLength: -1 } } }`:                        {"properties.name.minLength", "expected a non-negative integer"},
+		`{ required: "name" }`:                                               {"required", "expected a non-empty array of strings"},
+		`{ required: ["name", "name"] }`:                                     {"required[1]", `"name" is listed twice`},
+		`{ properties: { level: { bsonType: "string", enum: ["a", 2] } } }`:  {"properties.level.enum[1]", "does not match bsonType string"},
+		`{ properties: { status: { enum: [] } } }`:                           {"properties.status.enum", "expected a non-empty array"},
+		`{ anyOf: [{ required: ["a"] }, "b"] }`:                              {"anyOf[1]", "expected a schema document"},
+		`{ items: { $ref: "#/definitions/item" } }`:                          {"items.$ref", `unsupported keyword "$ref"`},
+	}
+	for schema, expected := range cases {
+		err := validateValidator(parseValidator(t, schema))
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Errorf("%s: expected a SchemaError, got %v", schema, err)
+			continue
+		}
+		if schemaErr.Path != expected.path || !strings.Contains(schemaErr.Message, expected.message) {
+			t.Errorf("%s: expected %s: %s, got %v", schema, expected.path, expected.message, err)
+		}
+	}
+}
+
+func TestValidateEncryptionSchema(t *testing.T) {
+	valid := parseValidator(t, `{
+		bsonType: "object",
+		encryptMetadata: { keyId: [{ $uuid: "3c7f7c6a-9e3b-4c5d-8f1e-2a9b8c7d6e5f" }], algorithm: "AEAD_AES_256_CBC_HMAC_SHA_512-Random" },
+		properties: {
+			ssn: { encrypt: { bsonType: "string", algorithm: "AEAD_AES_256_CBC_HMAC_SHA_512-Deterministic" } },
+			card: { encrypt: { keyId: "/cardKey", bsonType: ["string", "long"] } },
+			notes: { encrypt: { keyId: [{ $binary: { base64: "PH98ap47TF2PHiqbjH1uXw==", subType: "04" } }] } }
+		}
+	}`)
+	if err := validateValidator(valid); err != nil {
+		t.Errorf("Expected a valid encryption schema, got %v", err)
+	}
+	decoded := bson.D{{Key: "$jsonSchema", Value: bson.D{{Key: "properties", Value: bson.D{{Key: "ssn", Value: bson.D{{Key: "encrypt", Value: bson.D{
+		{Key: "keyId", Value: bson.A{primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: make([]byte, 16)}}},
+	}}}}}}}}}
+	if err := validateValidator(decoded); err != nil {
+		t.Errorf("Expected a decoded UUID keyId to be valid, got %v", err)
+	}
+
+	cases := map[string]struct{ path, message string }{
+		`{ properties: { ssn: { encrypt: "yes" } } }`:                                      {"properties.ssn.encrypt", "expected a document"},
+		`{ properties: { ssn: { encrypt: { algorithm: "AES" } } } }`:                       {"properties.ssn.encrypt.algorithm", "expected AEAD_AES_256_CBC_HMAC_SHA_512-Deterministic or"},
+		`{ properties: { ssn: { encrypt: { bsonType: "text" } } } }`:                       {"properties.ssn.encrypt.bsonType", `unknown bsonType "text"`},
+		`{ properties: { ssn: { encrypt: { keyId: "cardKey" } } } }`:                       {"properties.ssn.encrypt.keyId", "expected a JSON pointer starting with /"},
+		`{ properties: { ssn: { encrypt: { keyId: [] } } } }`:                              {"properties.ssn.encrypt.keyId", "expected a JSON pointer or a non-empty array of UUIDs"},
+		`{ properties: { ssn: { encrypt: { keyId: ["3c7f7c6a"] } } } }`:                    {"properties.ssn.encrypt.keyId[0]", "expected a UUID"},
+		`{ properties: { ssn: { encrypt: { keyAltName: "pii" } } } }`:                      {"properties.ssn.encrypt.keyAltName", `unsupported encryption option "keyAltName"`},
+		`{ encryptMetadata: { bsonType: "string" } }`:                                      {"encryptMetadata.bsonType", `unsupported encryption option "bsonType"`},
+		`{ encryptMetadata: { keyId: [{ $binary: { base64: "AA==", subType: "00" } }] } }`: {"encryptMetadata.keyId[0]", "expected a UUID"},
+	}
+	for schema, expected := range cases {
+		err := validateValidator(parseValidator(t, schema))
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Errorf("%s: expected a SchemaError, got %v", schema, err)
+			continue
+		}
+		if schemaErr.Path != expected.path || !strings.Contains(schemaErr.Message, expected.message) {
+			t.Errorf("%s: expected %s: %s, got %v", schema, expected.path, expected.message, err)
+		}
+	}
+}
+
+func TestLintInvalidValidator(t *testing.T) {
+	script := "// METADATA:\n// {\"name\": \"users\"}\ndb.createCollection(\"users\", {\n  validator: { $jsonSchema: { bsonType: \"objekt\" } }\n});\n"
+	issues := NewParser().Lint(script)
+	if len(issues) != 1 || issues[0].Rule != LintInvalidValidator || issues[0].Line != 3 {
+		t.Fatalf("Expected an invalid validator on line 3, got %v", issues)
+	}
+	if !strings.Contains(issues[0].Message, `unknown bsonType "objekt"`) {
+		t.Errorf("Unexpected message %q", issues[0].Message)
+	}
+}
@@ -0,0 +1,178 @@
+package mongoparser
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Maps legacy shell helpers (ensureIndex, insert, update, remove, save, count)
+// to the operations that replaced them
+func (p *Parser) parseLegacyOperation(collection, operation, argsString string) (*MongoOperation, error) {
+	switch operation {
+	case "ensureIndex":
+		p.warnf("ensureIndex on %s is deprecated, executing as createIndex", collection)
+		return p.parseCreateIndex(collection, argsString)
+	case "insert":
+		args := p.splitArguments(argsString)
+		if len(args) > 0 && strings.HasPrefix(strings.TrimSpace(args[0]), "[") {
+			return p.parseInsert(collection, "insertMany", argsString)
+		}
+		return p.parseInsert(collection, "insertOne", argsString)
+	case "update":
+		return p.parseLegacyUpdate(collection, argsString)
+	case "remove":
+		return p.parseLegacyRemove(collection, argsString)
+	case "save":
+		return p.parseLegacySave(collection, argsString)
+	case "count":
+		return p.parseCount(collection, argsString)
+	default:
+		return nil, fmt.Errorf("unsupported legacy operation: %s", operation)
+	}
+}
+
+// Parses update(filter, update, options) and the older update(filter, update,
+// upsert, multi). Updates without operators replace the matched document.
+func (p *Parser) parseLegacyUpdate(collection, argsString string) (*MongoOperation, error) {
+	args := p.splitArguments(argsString)
+	if len(args) < 2 {
+		return nil, fmt.Errorf("update operation requires at least 2 arguments")
+	}
+
+	var upsert, multi bool
+	modernArgs := args[:2]
+	if len(args) > 2 {
+		switch strings.TrimSpace(args[2]) {
+		case "true":
+			upsert = true
+		case "false":
+		default:
+			legacyOptions, err := p.parseOptionsDocument(args[2])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse update options: %w", err)
+			}
+			if value, ok := legacyOptions["multi"]; ok {
+				if multi, ok = value.(bool); !ok {
+					return nil, fmt.Errorf("update multi option must be a boolean")
+				}
+			}
+			modernArgs = args[:3]
+		}
+		if len(args) > 3 && strings.TrimSpace(args[3]) == "true" {
+			multi = true
+		}
+	}
+
+	operation := "updateOne"
+	if multi {
+		operation = "updateMany"
+	}
+	op, err := p.parseUpdate(collection, operation, strings.Join(modernArgs, ", "))
+	if err != nil {
+		return nil, err
+	}
+	if upsert {
+		if op.UpdateOptions == nil {
+			op.UpdateOptions = options.Update()
+		}
+		op.UpdateOptions.SetUpsert(true)
+	}
+
+	if op.UpdatePipeline == nil && !hasUpdateOperators(op.Arguments[1]) {
+		if multi {
+			return nil, fmt.Errorf("multi update requires update operators")
+		}
+		op.Operation = "replaceOne"
+	}
+	return op, nil
+}
+
+// Parses remove(filter, justOne) where justOne is a boolean or { justOne: true }
+func (p *Parser) parseLegacyRemove(collection, argsString string) (*MongoOperation, error) {
+	args := p.splitArguments(argsString)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("remove requires a filter document")
+	}
+
+	justOne := false
+	if len(args) > 1 {
+		switch arg := strings.TrimSpace(args[1]); arg {
+		case "true", "1":
+			justOne = true
+		case "false", "0":
+		default:
+			removeOptions, err := p.parseOptionsDocument(arg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse remove options: %w", err)
+			}
+			if value, ok := removeOptions["justOne"]; ok {
+				if justOne, ok = value.(bool); !ok {
+					return nil, fmt.Errorf("remove justOne option must be a boolean")
+				}
+			}
+		}
+	}
+
+	operation := "deleteMany"
+	if justOne {
+		operation = "deleteOne"
+	}
+	return p.parseDelete(collection, operation, args[0])
+}
+
+// Parses save(doc): documents with an _id replace (or create) that document,
+// documents without one are inserted
+func (p *Parser) parseLegacySave(collection, argsString string) (*MongoOperation, error) {
+	op, err := p.parseInsert(collection, "insertOne", argsString)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := op.Arguments[0]
+	id, ok := lookupKey(doc, "_id")
+	if !ok {
+		return op, nil
+	}
+
+	return &MongoOperation{
+		Type:          "update",
+		Collection:    collection,
+		Operation:     "replaceOne",
+		Arguments:     []bson.D{{{Key: "_id", Value: id}}, doc},
+		UpdateOptions: options.Update().SetUpsert(true),
+		WriteConcern:  op.WriteConcern,
+	}, nil
+}
+
+// Parses count(filter) and countDocuments(filter)
+func (p *Parser) parseCount(collection, argsString string) (*MongoOperation, error) {
+	op := &MongoOperation{
+		Type:       "count",
+		Collection: collection,
+		Operation:  "countDocuments",
+		Arguments:  []bson.D{{}},
+	}
+
+	args := p.splitArguments(argsString)
+	if len(args) > 0 && strings.TrimSpace(args[0]) != "" {
+		var filter bson.D
+		if err := p.parseJSONLikeString(args[0], &filter); err != nil {
+			return nil, fmt.Errorf("failed to parse count filter: %w", err)
+		}
+		op.Arguments[0] = filter
+	}
+	return op, nil
+}
+
+// Reports whether an update document consists of update operators
+func hasUpdateOperators(update bson.D) bool {
+	for _, elem := range update {
+		if strings.HasPrefix(elem.Key, "$") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package mongoparser
+
+import (
+	"testing"
+)
+
+func TestParseLegacyOperations(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+db.users.ensureIndex({ email: 1 }, { unique: true });
+db.users.insert({ name: "Ada" });
+db.users.insert([{ name: "Grace" }, { name: "Linus" }]);
+db.users.update({ name: "Ada" }, { $set: { admin: true } });
+db.users.update({ active: false }, { $set: { archived: true } }, { multi: true });
+db.users.update({ name: "Ken" }, { $set: { admin: false } }, true, true);
+db.users.update({ name: "Ada" }, { name: "Ada", admin: true });
+db.users.remove({ archived: true });
+db.users.remove({ name: "Ada" }, true);
+db.users.remove({ name: "Grace" }, { justOne: true });
+db.users.save({ _id: 1, name: "Ada" });
+db.users.save({ name: "Barbara" });
+db.users.count({ admin: true });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"createIndex", "insertOne", "insertMany", "updateOne", "updateMany", "updateMany",
+		"replaceOne", "deleteMany", "deleteOne", "deleteOne", "replaceOne", "insertOne", "countDocuments",
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected %d operations, got %d", len(expected), len(ops))
+	}
+	for i, op := range ops {
+		if op.Operation != expected[i] {
+			t.Errorf("Operation %d: expected %s, got %s", i, expected[i], op.Operation)
+		}
+	}
+
+	if opts := ops[5].UpdateOptions; opts == nil || opts.Upsert == nil || !*opts.Upsert {
+		t.Error("Expected positional upsert to be applied")
+	}
+
+	save := ops[10]
+	if save.Arguments[0][0].Key != "_id" || save.Arguments[0][0].Value != float64(1) {
+		t.Errorf("Expected save to filter on _id, got %v", save.Arguments[0])
+	}
+	if opts := save.UpdateOptions; opts == nil || opts.Upsert == nil || !*opts.Upsert {
+		t.Error("Expected save to upsert")
+	}
+
+	if ops[12].Type != "count" || ops[12].Arguments[0][0].Key != "admin" {
+		t.Errorf("Expected count with filter, got %+v", ops[12])
+	}
+}
+
+func TestParseLegacyUpdateMultiReplacement(t *testing.T) {
+	parser := NewParser()
+	if _, err := parser.parseMongoStatement(`db.users.update({}, { name: "x" }, { multi: true })`); err == nil {
+		t.Error("Expected multi update without operators to fail")
+	}
+	if _, err := parser.parseMongoStatement(`db.users.replaceOne({ _id: 1 }, { $set: { name: "x" } })`); err == nil {
+		t.Error("Expected replaceOne with update operators to fail")
+	}
+}
@@ -0,0 +1,268 @@
+package mongoparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How serious a lint issue is
+type LintSeverity int
+
+const (
+	// Disables a rule
+	LintOff LintSeverity = iota
+	LintInfo
+	LintWarning
+	LintError
+)
+
+// Returns the severity name
+func (s LintSeverity) String() string {
+	switch s {
+	case LintOff:
+		return "off"
+	case LintInfo:
+		return "info"
+	case LintWarning:
+		return "warning"
+	case LintError:
+		return "error"
+	default:
+		return fmt.Sprintf("LintSeverity(%d)", int(s))
+	}
+}
+
+// Lint rules
+const (
+	// createIndex or createIndexes without an explicit index name
+	LintUnnamedIndex = "unnamed-index"
+	// Unique index on fields the collection's $jsonSchema validator doesn't require
+	LintUniqueNotRequired = "unique-not-required"
+	// deleteMany with an empty filter
+	LintDeleteAll = "delete-all"
+	// Operation the parser doesn't support and skips
+	LintUnsupportedOperation = "unsupported-operation"
+	// Statement or directive that fails to parse
+	LintInvalidStatement = "invalid-statement"
+	// Other warnings raised while parsing, such as deprecated options
+	LintParserWarning = "parser-warning"
+	// createCollection with a $jsonSchema validator the server would reject
+	LintInvalidValidator = "invalid-validator"
+	// Script without a METADATA block
+	LintMissingMetadata = "missing-metadata"
+)
+
+// Severities of the lint rules unless overridden with WithLintRules
+var defaultLintSeverities = map[string]LintSeverity{
+	LintUnnamedIndex:         LintWarning,
+	LintUniqueNotRequired:    LintWarning,
+	LintDeleteAll:            LintError,
+	LintUnsupportedOperation: LintError,
+	LintInvalidStatement:     LintError,
+	LintParserWarning:        LintWarning,
+	LintInvalidValidator:     LintError,
+	LintMissingMetadata:      LintWarning,
+
+	LintUnboundedArray:        LintInfo,
+	LintRedundantIndex:        LintInfo,
+	LintRegexWithoutCollation: LintInfo,
+	LintOpenSchema:            LintInfo,
+}
+
+// Describes a problem found in a script
+type LintIssue struct {
+	// Line the statement starts on, 1-based
+	Line     int
+	Rule     string
+	Severity LintSeverity
+	Message  string
+}
+
+// Formats the issue as "line: severity: message (rule)"
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%d: %s: %s (%s)", i.Line, i.Severity, i.Message, i.Rule)
+}
+
+// Operation of a linted script with the line its statement starts on
+type lintOperation struct {
+	op   MongoOperation
+	line int
+}
+
+// Collects the issues of one Lint call
+type linter struct {
+	severities map[string]LintSeverity
+	issues     []LintIssue
+}
+
+// Checks a script without executing it and returns its issues ordered by line.
+// Statements of every ENV section are checked. Rule severities can be changed
+// or rules disabled with WithLintRules.
+func (p *Parser) Lint(js string) []LintIssue {
+	l := &linter{severities: p.lintSeverities}
+	scanner := newStatementScanner(strings.NewReader(js))
+	if p.ParseMetadata(scanner.header()) == nil && !scanner.empty() {
+		l.report(1, LintMissingMetadata, "script has no METADATA block")
+	}
+
+	var operations []lintOperation
+	for {
+		statement, ok := scanner.next()
+		if !ok {
+			break
+		}
+		line := scanner.location.StartLine
+
+		warnings := []string{}
+		run := *p
+		run.warnings = &warnings
+		// Statements annotated with ignore never run, so their problems are not reported
+		var annotated MongoOperation
+		run.annotate(&annotated, scanner.takeAnnotations())
+		var op *MongoOperation
+		if !annotated.Ignored {
+			op = run.parseStatement(statement)
+		}
+		for _, warning := range warnings {
+			l.report(line, lintWarningRule(warning), "%s", warning)
+		}
+
+		if op == nil {
+			continue
+		}
+		operations = append(operations, lintOperation{op: *op, line: line})
+		for _, nested := range op.Transaction {
+			operations = append(operations, lintOperation{op: nested, line: line})
+		}
+	}
+	if err := scanner.err; err != nil {
+		l.report(scanner.line, LintInvalidStatement, "failed to read script: %v", err)
+	}
+
+	l.checkOperations(operations)
+	l.advise(operations)
+	sort.SliceStable(l.issues, func(i, j int) bool { return l.issues[i].Line < l.issues[j].Line })
+	return l.issues
+}
+
+// Maps a parser warning to the rule reporting it
+func lintWarningRule(warning string) string {
+	switch {
+	case strings.HasPrefix(warning, "unsupported operation"):
+		return LintUnsupportedOperation
+	case strings.HasPrefix(warning, "failed to parse"):
+		return LintInvalidStatement
+	default:
+		return LintParserWarning
+	}
+}
+
+// Runs the rules that look at parsed operations
+func (l *linter) checkOperations(operations []lintOperation) {
+	// Validators may be defined after the indexes, so collect them first
+	required := make(map[string]map[string]bool)
+	for _, lo := range operations {
+		if lo.op.Type == "createCollection" {
+			if fields, ok := requiredFields(lo.op.Validator); ok {
+				required[lo.op.Collection] = fields
+			}
+		}
+	}
+
+	for _, lo := range operations {
+		op := lo.op
+		switch op.Type {
+		case "createCollection":
+			if err := validateValidator(op.Validator); err != nil {
+				l.report(lo.line, LintInvalidValidator, "validator for %s: %v", op.Collection, err)
+			}
+		case "createIndex":
+			if keys, ok := op.IndexSpec.(bson.D); ok {
+				l.checkIndex(lo.line, op.Collection, keys, op.IndexOptions, required)
+			}
+		case "createIndexes":
+			for _, model := range op.IndexModels {
+				if keys, ok := model.Keys.(bson.D); ok {
+					l.checkIndex(lo.line, op.Collection, keys, model.Options, required)
+				}
+			}
+		case "delete":
+			if op.Operation == "deleteMany" && (len(op.Arguments) == 0 || len(op.Arguments[0]) == 0) {
+				l.report(lo.line, LintDeleteAll, "deleteMany on %s has an empty filter and deletes every document", op.Collection)
+			}
+		}
+	}
+}
+
+// Checks one index definition
+func (l *linter) checkIndex(line int, collection string, keys bson.D, opts *options.IndexOptions, required map[string]map[string]bool) {
+	if opts == nil || opts.Name == nil || *opts.Name == "" {
+		l.report(line, LintUnnamedIndex, "index %s on %s has no name", formatKeys(keys), collection)
+	}
+
+	if opts == nil || opts.Unique == nil || !*opts.Unique {
+		return
+	}
+	// Sparse and partial indexes skip documents missing the fields
+	if (opts.Sparse != nil && *opts.Sparse) || opts.PartialFilterExpression != nil {
+		return
+	}
+	fields, ok := required[collection]
+	if !ok {
+		return
+	}
+	var missing []string
+	for _, key := range keys {
+		if !fields[key.Key] {
+			missing = append(missing, key.Key)
+		}
+	}
+	if len(missing) > 0 {
+		l.report(line, LintUniqueNotRequired, "unique index %s on %s covers %s, which the validator does not require; only one document may omit it",
+			formatKeys(keys), collection, strings.Join(missing, ", "))
+	}
+}
+
+// Returns the required fields of a $jsonSchema validator
+func requiredFields(validator interface{}) (map[string]bool, bool) {
+	doc, ok := validator.(bson.D)
+	if !ok {
+		return nil, false
+	}
+	value, ok := lookupKey(doc, "$jsonSchema")
+	if !ok {
+		return nil, false
+	}
+	schema, ok := value.(bson.D)
+	if !ok {
+		return nil, false
+	}
+
+	fields := make(map[string]bool)
+	if value, ok := lookupKey(schema, "required"); ok {
+		if list, ok := value.(bson.A); ok {
+			for _, field := range list {
+				if name, ok := field.(string); ok {
+					fields[name] = true
+				}
+			}
+		}
+	}
+	return fields, true
+}
+
+// Records an issue unless its rule is disabled
+func (l *linter) report(line int, rule, format string, args ...interface{}) {
+	severity, ok := l.severities[rule]
+	if !ok {
+		severity = defaultLintSeverities[rule]
+	}
+	if severity == LintOff {
+		return
+	}
+	l.issues = append(l.issues, LintIssue{Line: line, Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,80 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+)
+
+const lintScript = `// METADATA:
+// {"name": "users"}
+
+db.users.createIndex({ email: 1 }, { unique: true, name: "email_unique" });
+db.users.createIndex({ createdAt: -1 });
+
+db.createCollection("users", {
+  validator: {
+    $jsonSchema: { bsonType: "object", required: ["name"], additionalProperties: true }
+  }
+});
+
+db.users.renameCollection("people");
+db.users.deleteMany({});
+db.users.createIndex({ name: 1 }, { unique: true, name: "name_unique" });
+`
+
+func TestLint(t *testing.T) {
+	issues := NewParser().Lint(lintScript)
+
+	expected := []struct {
+		line     int
+		rule     string
+		severity LintSeverity
+	}{
+		{4, LintUniqueNotRequired, LintWarning},
+		{5, LintUnnamedIndex, LintWarning},
+		{13, LintUnsupportedOperation, LintError},
+		{14, LintDeleteAll, LintError},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %v", len(expected), issues)
+	}
+	for i, e := range expected {
+		if issues[i].Line != e.line || issues[i].Rule != e.rule || issues[i].Severity != e.severity {
+			t.Errorf("Issue %d: expected line %d %s %s, got %s", i, e.line, e.severity, e.rule, issues[i])
+		}
+	}
+	if !strings.Contains(issues[0].Message, "email") {
+		t.Errorf("Expected the unrequired field to be named, got %q", issues[0].Message)
+	}
+}
+
+func TestLintRuleConfiguration(t *testing.T) {
+	parser := NewParser(WithLintRules(map[string]LintSeverity{
+		LintUnnamedIndex:    LintOff,
+		LintDeleteAll:       LintWarning,
+		LintMissingMetadata: LintInfo,
+	}))
+	issues := parser.Lint("db.users.createIndex({ email: 1 });\ndb.users.deleteMany({});\n")
+
+	if len(issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %v", issues)
+	}
+	if issues[0].Rule != LintMissingMetadata || issues[0].Severity != LintInfo || issues[0].Line != 1 {
+		t.Errorf("Unexpected issue %s", issues[0])
+	}
+	if issues[1].Rule != LintDeleteAll || issues[1].Severity != LintWarning || issues[1].Line != 2 {
+		t.Errorf("Unexpected issue %s", issues[1])
+	}
+}
+
+func TestLintInvalidStatements(t *testing.T) {
+	script := "// METADATA:\n// {\"name\": \"broken\"}\n// mongoparser: ignore\ndb.users.renameCollection(\"people\");\ndb.users.insertOne({ name: \"Ana\"\n  , age: [1, 2 });\n"
+	issues := NewParser().Lint(script)
+	if len(issues) != 1 || issues[0].Rule != LintInvalidStatement || issues[0].Line != 5 {
+		t.Fatalf("Expected one invalid statement on line 5, got %v", issues)
+	}
+
+	if issues := NewParser().Lint("  \n"); len(issues) != 0 {
+		t.Errorf("Expected no issues for an empty script, got %v", issues)
+	}
+}
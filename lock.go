@@ -0,0 +1,144 @@
+package mongoparser
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Returned when another runner holds the lock for a script
+var ErrScriptLocked = errors.New("script is locked by another runner")
+
+// Lock key used for scripts without a metadata name; it serializes all of them
+const anonymousScriptLock = "*"
+
+// Shortest lock TTL; the heartbeat extends locks every third of their TTL
+const minScriptLockTTL = time.Second
+
+// An advisory lock document held while a script executes
+type scriptLock struct {
+	collection *mongo.Collection
+	key        string
+	owner      string
+	ttl        time.Duration
+}
+
+// Acquires the advisory lock for a script, failing with ErrScriptLocked while
+// another runner holds an unexpired lock. The returned context is cancelled if
+// the lock is lost; release stops the heartbeat and frees the lock.
+func (p *Parser) acquireScriptLock(ctx context.Context, db *mongo.Database, name string) (context.Context, func(), error) {
+	if name == "" {
+		name = anonymousScriptLock
+	}
+
+	lock := &scriptLock{
+		collection: db.Collection(p.lockCollection),
+		key:        name,
+		owner:      lockOwner(),
+		ttl:        p.lockTTL,
+	}
+
+	// Expired locks are removed by the server as a fallback for crashed runners
+	_, err := lock.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare lock collection %s: %w", p.lockCollection, err)
+	}
+
+	if err := lock.acquire(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go lock.heartbeat(lockCtx, cancel, done)
+
+	release := func() {
+		close(done)
+		cancel(nil)
+		// Release even if the script's context was cancelled
+		releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer releaseCancel()
+		if _, err := lock.collection.DeleteOne(releaseCtx, bson.D{{Key: "_id", Value: lock.key}, {Key: "owner", Value: lock.owner}}); err != nil {
+			p.warnf("failed to release script lock %s: %v", lock.key, err)
+		}
+	}
+	return lockCtx, release, nil
+}
+
+// Takes the lock if it is free or expired
+func (l *scriptLock) acquire(ctx context.Context) error {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: l.key},
+		{Key: "expiresAt", Value: bson.D{{Key: "$lt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: l.owner},
+		{Key: "acquiredAt", Value: now},
+		{Key: "expiresAt", Value: now.Add(l.ttl)},
+	}}}
+
+	// A held lock doesn't match the filter, so the upsert collides with its _id
+	_, err := l.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if hasErrorCode(err, CodeDuplicateKey) {
+		var holder struct {
+			Owner     string    `bson:"owner"`
+			ExpiresAt time.Time `bson:"expiresAt"`
+		}
+		if findErr := l.collection.FindOne(ctx, bson.D{{Key: "_id", Value: l.key}}).Decode(&holder); findErr == nil {
+			return fmt.Errorf("%w: %s is held by %s until %s", ErrScriptLocked, l.key, holder.Owner, holder.ExpiresAt.Format(time.RFC3339))
+		}
+		return fmt.Errorf("%w: %s", ErrScriptLocked, l.key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acquire script lock %s: %w", l.key, err)
+	}
+	return nil
+}
+
+// Extends the lock every third of its TTL until done; cancels the script when
+// the lock can no longer be extended
+func (l *scriptLock) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := l.collection.UpdateOne(ctx,
+				bson.D{{Key: "_id", Value: l.key}, {Key: "owner", Value: l.owner}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "expiresAt", Value: time.Now().Add(l.ttl)}}}},
+			)
+			if err == nil && result.MatchedCount == 0 {
+				err = errors.New("lock was taken over")
+			}
+			if err != nil {
+				cancel(fmt.Errorf("lost script lock %s: %w", l.key, err))
+				return
+			}
+		}
+	}
+}
+
+// Identifies this runner in lock documents
+func lockOwner() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,158 @@
+package mongoparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lists the scripts of a repository in the order they are applied, instead of
+// relying on file name conventions
+type Manifest struct {
+	Scripts []ManifestEntry `json:"scripts" yaml:"scripts"`
+
+	// File system and directory script paths are relative to
+	files fs.FS
+	dir   string
+	// Local directory of manifests read from disk, used for ScriptInfo.Path
+	root string
+}
+
+// Describes one script of a manifest
+type ManifestEntry struct {
+	// Migration name; defaults to the file name
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Path of the script, relative to the manifest
+	Path string   `json:"path" yaml:"path"`
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Database the script is applied to; defaults to the Runner's database
+	Database string `json:"database,omitempty" yaml:"database,omitempty"`
+}
+
+// Reads a manifest.json or manifest.yaml file
+func LoadManifest(name string) (*Manifest, error) {
+	manifest, err := LoadManifestFS(os.DirFS(filepath.Dir(name)), filepath.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	manifest.root = filepath.Dir(name)
+	return manifest, nil
+}
+
+// Reads a manifest.json or manifest.yaml file from fsys, such as an embed.FS.
+// Script paths are resolved within fsys relative to the manifest.
+func LoadManifestFS(fsys fs.FS, name string) (*Manifest, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&manifest)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&manifest)
+	default:
+		return nil, fmt.Errorf("manifest %s must be a .json, .yaml or .yml file", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", name, err)
+	}
+
+	manifest.files = fsys
+	manifest.dir = path.Dir(name)
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", name, err)
+	}
+	return &manifest, nil
+}
+
+// Fills in default names and checks that every script has a path and a unique name
+func (m *Manifest) validate() error {
+	names := make(map[string]bool, len(m.Scripts))
+	for i := range m.Scripts {
+		entry := &m.Scripts[i]
+		if entry.Path == "" {
+			return fmt.Errorf("script %d has no path", i+1)
+		}
+		if !fs.ValidPath(path.Join(m.dir, entry.Path)) {
+			return fmt.Errorf("script path %s is outside the manifest directory", entry.Path)
+		}
+		if entry.Name == "" {
+			entry.Name = path.Base(entry.Path)
+		}
+		if names[entry.Name] {
+			return fmt.Errorf("script name %s is listed twice", entry.Name)
+		}
+		names[entry.Name] = true
+	}
+	return nil
+}
+
+// Reads the listed scripts in manifest order
+func (m *Manifest) Load() ([]ScriptInfo, error) {
+	scripts := make([]ScriptInfo, 0, len(m.Scripts))
+	for _, entry := range m.Scripts {
+		name := path.Join(m.dir, entry.Path)
+		content, err := fs.ReadFile(m.files, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read script %s: %w", entry.Name, err)
+		}
+		if m.root != "" {
+			name = filepath.Join(m.root, filepath.FromSlash(name))
+		}
+		scripts = append(scripts, ScriptInfo{
+			Name:     entry.Name,
+			Path:     name,
+			Content:  string(content),
+			Tags:     entry.Tags,
+			Database: entry.Database,
+		})
+	}
+	return scripts, nil
+}
+
+// Applies scripts in order, each to its target database, and stops at the first
+// script that fails. Scripts already applied are skipped.
+func (r *Runner) ApplyAll(ctx context.Context, scripts []ScriptInfo) []ScriptResult {
+	results := make([]ScriptResult, 0, len(scripts))
+	for _, script := range scripts {
+		result := r.forDatabase(script.Database).Apply(ctx, script)
+		results = append(results, result)
+		if !result.Success {
+			break
+		}
+	}
+	return results
+}
+
+// Reads the scripts of a manifest and applies them with ApplyAll
+func (r *Runner) ApplyManifest(ctx context.Context, manifest *Manifest) ([]ScriptResult, error) {
+	scripts, err := manifest.Load()
+	if err != nil {
+		return nil, err
+	}
+	return r.ApplyAll(ctx, scripts), nil
+}
+
+// Returns a runner for the named database on the same client, tracking
+// migrations in the same collection; the runner itself when name is empty
+func (r *Runner) forDatabase(name string) *Runner {
+	if name == "" || name == r.db.Name() {
+		return r
+	}
+	return &Runner{parser: r.parser, db: r.db.Client().Database(name), collection: r.collection}
+}
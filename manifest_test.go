@@ -0,0 +1,125 @@
+package mongoparser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func writeManifestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := writeManifestFiles(t, map[string]string{
+		"manifest.json": `{"scripts": [
+			{"path": "users/001_create.js", "tags": ["schema"]},
+			{"name": "seed_users", "path": "users/002_seed.js", "database": "app"}
+		]}`,
+		"manifest.yaml": "scripts:\n  - path: users/001_create.js\n    tags: [schema]\n  - name: seed_users\n    path: users/002_seed.js\n    database: app\n",
+	})
+
+	expected := []ManifestEntry{
+		{Name: "001_create.js", Path: "users/001_create.js", Tags: []string{"schema"}},
+		{Name: "seed_users", Path: "users/002_seed.js", Database: "app"},
+	}
+	for _, name := range []string{"manifest.json", "manifest.yaml"} {
+		manifest, err := LoadManifest(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(manifest.Scripts, expected) {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, manifest.Scripts)
+		}
+	}
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	dir := writeManifestFiles(t, map[string]string{
+		"missing_path.json": `{"scripts": [{"name": "a"}]}`,
+		"duplicate.yaml":    "scripts:\n  - path: a/001.js\n  - path: b/001.js\n",
+		"unknown.json":      `{"scripts": [{"path": "a.js", "order": 1}]}`,
+		"manifest.toml":     "",
+		"escape.json":       `{"scripts": [{"path": "../other/001.js"}]}`,
+	})
+
+	cases := map[string]string{
+		"missing_path.json": "has no path",
+		"duplicate.yaml":    "listed twice",
+		"unknown.json":      "unknown field",
+		"manifest.toml":     "must be a .json",
+		"escape.json":       "outside the manifest directory",
+	}
+	for name, message := range cases {
+		_, err := LoadManifest(filepath.Join(dir, name))
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: expected error containing %q, got %v", name, message, err)
+		}
+	}
+}
+
+func TestManifestLoad(t *testing.T) {
+	dir := writeManifestFiles(t, map[string]string{
+		"manifest.yaml":     "scripts:\n  - path: scripts/002.js\n    tags: [seed]\n  - path: scripts/001.js\n    database: audit\n",
+		"scripts/001.js":    `db.createCollection("events");`,
+		"scripts/002.js":    `db.users.insertOne({ name: "Ana" });`,
+		"scripts/ignore.js": `db.ignored.drop();`,
+	})
+
+	manifest, err := LoadManifest(filepath.Join(dir, "manifest.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := manifest.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 2 || scripts[0].Name != "002.js" || scripts[1].Name != "001.js" {
+		t.Fatalf("Expected scripts in manifest order, got %+v", scripts)
+	}
+	if scripts[0].Content != `db.users.insertOne({ name: "Ana" });` || !reflect.DeepEqual(scripts[0].Tags, []string{"seed"}) {
+		t.Errorf("Unexpected first script: %+v", scripts[0])
+	}
+	if scripts[1].Database != "audit" {
+		t.Errorf("Expected target database audit, got %q", scripts[1].Database)
+	}
+
+	manifest.Scripts = append(manifest.Scripts, ManifestEntry{Name: "missing", Path: "scripts/missing.js"})
+	if _, err := manifest.Load(); err == nil {
+		t.Error("Expected an error for a missing script file")
+	}
+}
+
+func TestLoadManifestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/manifest.json": {Data: []byte(`{"scripts": [{"path": "002.js"}, {"path": "001.js"}]}`)},
+		"migrations/001.js":        {Data: []byte(`db.createCollection("users");`)},
+		"migrations/002.js":        {Data: []byte(`db.createCollection("orders");`)},
+	}
+
+	manifest, err := LoadManifestFS(fsys, "migrations/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := manifest.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 2 || scripts[0].Path != "migrations/002.js" || scripts[1].Content != `db.createCollection("users");` {
+		t.Errorf("Unexpected scripts %+v", scripts)
+	}
+}
@@ -0,0 +1,181 @@
+package mongoparser
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Writes a Mermaid entity-relationship diagram of the declared collections
+// for architecture reviews. Attributes are the top-level properties of each
+// $jsonSchema and the fields of its indexes; _id is the primary key, fields
+// with a single-field unique index are unique keys and other indexed fields
+// are marked "indexed". An objectId property, or array of them, named after a
+// collection, such as customerId or tag_ids, is drawn as a reference to it.
+func (r *SchemaRegistry) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+
+	var relationships []string
+	for _, name := range r.Collections() {
+		collection := r.collections[name]
+		entity := mermaidName(name)
+		fmt.Fprintf(&b, "    %s {\n", entity)
+		for _, attribute := range r.attributes(collection) {
+			fmt.Fprintf(&b, "        %s %s", attribute.typ, mermaidName(attribute.name))
+			if len(attribute.keys) > 0 {
+				fmt.Fprintf(&b, " %s", strings.Join(attribute.keys, ", "))
+			}
+			if attribute.comment != "" {
+				fmt.Fprintf(&b, " %q", attribute.comment)
+			}
+			b.WriteString("\n")
+			if attribute.references != "" {
+				relationships = append(relationships, fmt.Sprintf("    %s %s %s : %q\n",
+					entity, attribute.cardinality, mermaidName(attribute.references), attribute.name))
+			}
+		}
+		b.WriteString("    }\n")
+	}
+	for _, relationship := range relationships {
+		b.WriteString(relationship)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Attribute of an entity in a diagram
+type mermaidAttribute struct {
+	name string
+	typ  string
+	// PK, FK or UK
+	keys    []string
+	comment string
+	// Collection an objectId attribute refers to, and the relationship's
+	// cardinality from the referring collection
+	references  string
+	cardinality string
+}
+
+// Returns the attributes of a collection: _id, its schema's properties and
+// the fields of its indexes the schema doesn't declare
+func (r *SchemaRegistry) attributes(collection *CollectionSchema) []mermaidAttribute {
+	unique := make(map[string]bool)
+	indexed := make(map[string]bool)
+	for _, index := range collection.Indexes {
+		for _, key := range index.Keys {
+			indexed[strings.Split(key.Key, ".")[0]] = true
+		}
+		if len(index.Keys) == 1 && index.Options != nil && index.Options.Unique != nil && *index.Options.Unique {
+			unique[index.Keys[0].Key] = true
+		}
+	}
+
+	properties := schemaProperties(collection.JSONSchema)
+	attributes := []mermaidAttribute{{name: "_id", typ: "objectId", keys: []string{"PK"}}}
+	seen := map[string]bool{"_id": true}
+	for _, property := range properties {
+		if property.name == "_id" {
+			attributes[0].typ = mermaidType(property.schema)
+			continue
+		}
+		seen[property.name] = true
+		attribute := mermaidAttribute{name: property.name, typ: mermaidType(property.schema)}
+		r.reference(&attribute, property)
+		attributes = append(attributes, attribute)
+	}
+	for _, index := range collection.Indexes {
+		for _, key := range index.Keys {
+			field := strings.Split(key.Key, ".")[0]
+			if !seen[field] {
+				seen[field] = true
+				attributes = append(attributes, mermaidAttribute{name: field, typ: "any"})
+			}
+		}
+	}
+
+	for i := range attributes {
+		attribute := &attributes[i]
+		if unique[attribute.name] && attribute.name != "_id" {
+			attribute.keys = append(attribute.keys, "UK")
+		} else if indexed[attribute.name] && attribute.name != "_id" {
+			attribute.comment = "indexed"
+		}
+	}
+	return attributes
+}
+
+// Resolves the collection an objectId property refers to by its name
+func (r *SchemaRegistry) reference(attribute *mermaidAttribute, property schemaProperty) {
+	types, nullable := allowedTypes(property.schema)
+	if len(types) != 1 {
+		return
+	}
+	many := false
+	if types[0] == "array" {
+		items, _ := lookupKey(property.schema, "items")
+		itemSchema, _ := items.(bson.D)
+		if types, _ = allowedTypes(itemSchema); len(types) != 1 {
+			return
+		}
+		many = true
+	}
+	if types[0] != "objectId" {
+		return
+	}
+
+	match := referenceSuffix.FindStringSubmatch(property.name)
+	if match == nil {
+		return
+	}
+	target := strings.ToLower(strings.ReplaceAll(match[1], "_", ""))
+	for _, name := range r.Collections() {
+		candidate := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+		if candidate != target && singular(candidate) != target {
+			continue
+		}
+		attribute.references = name
+		attribute.keys = append(attribute.keys, "FK")
+		switch {
+		case many:
+			attribute.cardinality = "}o--o{"
+		case property.required && !nullable:
+			attribute.cardinality = "}o--||"
+		default:
+			attribute.cardinality = "}o--o|"
+		}
+		return
+	}
+}
+
+// Matches the name of a reference field, capturing the name of the collection
+var referenceSuffix = regexp.MustCompile(`^(.+?)_?(?:Id|ID|id|Ids|IDs|ids)$`)
+
+// Returns the type of a property as a diagram shows it
+func mermaidType(schema bson.D) string {
+	types, _ := allowedTypes(schema)
+	if len(types) != 1 {
+		return "mixed"
+	}
+	if types[0] == "array" {
+		items, _ := lookupKey(schema, "items")
+		if itemSchema, ok := items.(bson.D); ok {
+			if itemTypes, _ := allowedTypes(itemSchema); len(itemTypes) == 1 {
+				return itemTypes[0] + "[]"
+			}
+		}
+	}
+	return types[0]
+}
+
+// Characters Mermaid doesn't accept in entity and attribute names
+var mermaidInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Replaces the characters of a name Mermaid doesn't accept with underscores
+func mermaidName(name string) string {
+	return mermaidInvalid.ReplaceAllString(name, "_")
+}
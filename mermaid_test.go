@@ -0,0 +1,60 @@
+package mongoparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMermaid(t *testing.T) {
+	registry, err := NewParser().SchemaRegistry([]ScriptInfo{{Name: "001_init.js", Content: `db.createCollection("customers", { validator: { $jsonSchema: {
+	required: ["email"],
+	properties: { email: { bsonType: "string" }, "loyalty.tier": { bsonType: "int" } }
+} } });
+db.customers.createIndex({ email: 1 }, { unique: true });
+db.createCollection("orders", { validator: { $jsonSchema: {
+	required: ["customerId"],
+	properties: {
+		customerId: { bsonType: "objectId" },
+		couponId: { bsonType: ["objectId", "null"] },
+		product_ids: { bsonType: "array", items: { bsonType: "objectId" } },
+		total: { bsonType: "decimal" },
+		lines: { bsonType: "array", items: { bsonType: "object" } }
+	}
+} } });
+db.orders.createIndex({ customerId: 1, createdAt: -1 });
+db.products.createIndex({ sku: 1 });`}})
+	if err != nil {
+		t.Fatalf("SchemaRegistry() returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := registry.WriteMermaid(&out); err != nil {
+		t.Fatalf("WriteMermaid() returned error: %v", err)
+	}
+
+	expected := `erDiagram
+    customers {
+        objectId _id PK
+        string email UK
+        int loyalty_tier
+    }
+    orders {
+        objectId _id PK
+        objectId customerId FK "indexed"
+        objectId couponId
+        objectId[] product_ids FK
+        decimal total
+        object[] lines
+        any createdAt "indexed"
+    }
+    products {
+        objectId _id PK
+        any sku "indexed"
+    }
+    orders }o--|| customers : "customerId"
+    orders }o--o{ products : "product_ids"
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected diagram:\n%s", out.String())
+	}
+}
@@ -0,0 +1,33 @@
+package mongoparser
+
+import (
+	"time"
+)
+
+// Receives metrics about executed operations, e.g. to export them to a monitoring system.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Called after every executed operation; err is nil when it succeeded
+	OperationExecuted(opType, collection string, duration time.Duration, err error)
+	// Called after a successful insert with the number of documents written
+	DocumentsInserted(collection string, count int64)
+}
+
+// Reports executed operations to the given metrics sink
+func WithMetrics(metrics Metrics) Option {
+	return func(p *Parser) {
+		p.metrics = metrics
+	}
+}
+
+// Forwards an operation result to the configured metrics sink
+func (p *Parser) recordMetrics(result OperationResult) {
+	if p.metrics == nil {
+		return
+	}
+
+	p.metrics.OperationExecuted(result.Type, result.Collection, result.Duration, result.Error)
+	if result.Error == nil && (result.Type == "insert" || result.Type == "seed" || result.Type == "import") && result.Documents > 0 {
+		p.metrics.DocumentsInserted(result.Collection, result.Documents)
+	}
+}
@@ -0,0 +1,33 @@
+package mongoparser
+
+import "fmt"
+
+// Rewrites an operation before it executes, or refuses it with an error.
+// Operations share their documents with the parse cache, so middleware must
+// replace documents it changes rather than modify them in place.
+type Middleware func(op MongoOperation) (MongoOperation, error)
+
+// Passes every operation through middleware before it executes, such as to
+// add a tenant field to insert filters or a $comment for profiling. Repeated
+// options chain in the order given. Middleware sees collection names after
+// prefixes, suffixes and WithCollectionMap are applied, runs for each
+// operation inside a transaction and for operations assertions evaluate, and
+// must be safe for concurrent use.
+func WithMiddleware(middleware Middleware) Option {
+	return func(p *Parser) {
+		p.middleware = append(p.middleware, middleware)
+	}
+}
+
+// Runs an operation through the middleware chain, returning it unchanged
+// along with the error when a middleware refuses it
+func (p *Parser) applyMiddleware(op MongoOperation) (MongoOperation, error) {
+	transformed := op
+	for _, middleware := range p.middleware {
+		var err error
+		if transformed, err = middleware(transformed); err != nil {
+			return op, fmt.Errorf("middleware rejected %s: %w", op.Operation, err)
+		}
+	}
+	return transformed, nil
+}
@@ -0,0 +1,66 @@
+package mongoparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestApplyMiddleware(t *testing.T) {
+	tenant := func(op MongoOperation) (MongoOperation, error) {
+		if op.Type != "insert" {
+			return op, nil
+		}
+		documents := make([]bson.D, len(op.Arguments))
+		for i, doc := range op.Arguments {
+			documents[i] = append(append(bson.D{}, doc...), bson.E{Key: "tenant", Value: "acme"})
+		}
+		op.Arguments = documents
+		return op, nil
+	}
+	prefix := func(op MongoOperation) (MongoOperation, error) {
+		op.Collection = "acme_" + op.Collection
+		return op, nil
+	}
+	parser := NewParser(WithMiddleware(tenant), WithMiddleware(prefix))
+
+	original := MongoOperation{Type: "insert", Operation: "insertOne", Collection: "users", Arguments: []bson.D{{{Key: "name", Value: "Ana"}}}}
+	op, err := parser.applyMiddleware(original)
+	if err != nil {
+		t.Fatalf("applyMiddleware() returned error: %v", err)
+	}
+	if op.Collection != "acme_users" || len(op.Arguments[0]) != 2 || op.Arguments[0][1].Value != "acme" {
+		t.Errorf("Expected both middleware to apply in order, got %+v", op)
+	}
+	if len(original.Arguments[0]) != 1 {
+		t.Errorf("Expected the original documents to be left alone, got %v", original.Arguments[0])
+	}
+}
+
+func TestMiddlewareRejectsOperation(t *testing.T) {
+	parser := NewParser(
+		WithMiddleware(func(op MongoOperation) (MongoOperation, error) {
+			op.Message = strings.ToUpper(op.Message)
+			return op, nil
+		}),
+		WithMiddleware(func(op MongoOperation) (MongoOperation, error) {
+			if op.Message == "STOP" {
+				return op, errors.New("stop requested")
+			}
+			return op, nil
+		}),
+	)
+
+	result := parser.ExecuteScript(t.Context(), nil, "print('hello');\nprint('stop');\nprint('never');")
+	if result.Success || !strings.Contains(result.Error.Error(), "middleware rejected print: stop requested") {
+		t.Fatalf("Expected the middleware error to fail the script, got %v", result.Error)
+	}
+	if result.Operations[0].Result != "HELLO" {
+		t.Errorf("Expected middleware to rewrite the first statement, got %v", result.Operations[0].Result)
+	}
+	if result.Operations[1].Status != StatusFailed || result.Operations[2].Status != StatusNotRun {
+		t.Errorf("Unexpected operation statuses: %+v", result.Operations)
+	}
+}
@@ -0,0 +1,38 @@
+package mongoparser
+
+import (
+	"context"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Executes mongoexport output read from r as a script inserting every document
+// into collection. Documents may be in relaxed or canonical Extended JSON, one
+// per line, and are inserted in chunks like an IMPORT directive, so locks,
+// rollback, audit and metrics apply as for any other script.
+func (p *Parser) ExecuteExport(ctx context.Context, db *mongo.Database, collection string, r io.Reader) ScriptResult {
+	op := MongoOperation{
+		Type:       "import",
+		Collection: collection,
+		Operation:  "import",
+		Import:     &ImportSpec{Format: ImportExtJSON, reader: r},
+	}
+
+	run := *p
+	run.warnings = &[]string{}
+	script := &parsedScript{operations: []MongoOperation{op}}
+	result := run.executeScript(ctx, db, script.source())
+	result.Warnings = *run.warnings
+	return result
+}
+
+// Decodes a relaxed or canonical Extended JSON document
+func decodeExtJSON(text string) (bson.D, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(text), false, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,62 @@
+package mongoparser
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDecodeExtJSON(t *testing.T) {
+	export := `{"_id":{"$oid":"5f1d7f3e9d1e4b2a3c4d5e6f"},"name":"Alice","age":30,"joined":{"$date":"2024-01-02T00:00:00Z"}}
+
+{"_id":{"$oid":"5f1d7f3e9d1e4b2a3c4d5e70"},"age":{"$numberLong":"31"},"score":{"$numberDouble":"9.5"},"joined":{"$date":{"$numberLong":"1704153600000"}}}
+`
+	next := lineDocuments(strings.NewReader(export), decodeExtJSON)
+
+	relaxed, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := lookupKey(relaxed, "_id"); id.(primitive.ObjectID).Hex() != "5f1d7f3e9d1e4b2a3c4d5e6f" {
+		t.Errorf("Expected ObjectId, got %v", id)
+	}
+	if age, _ := lookupKey(relaxed, "age"); age != int32(30) {
+		t.Errorf("Expected int32 age, got %T", age)
+	}
+
+	canonical, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age, _ := lookupKey(canonical, "age"); age != int64(31) {
+		t.Errorf("Expected int64 age, got %T", age)
+	}
+	joined, _ := lookupKey(canonical, "joined")
+	if joined.(primitive.DateTime).Time().UTC() != time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Unexpected date %v", joined)
+	}
+
+	if _, err := next(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestDecodeExtJSONInvalid(t *testing.T) {
+	next := lineDocuments(strings.NewReader(`{name: "unquoted"}`), decodeExtJSON)
+	if _, err := next(); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected error on line 1, got %v", err)
+	}
+}
+
+func TestParseImportExtJSON(t *testing.T) {
+	op, err := NewParser().parseImport(`// IMPORT: dump/users.json -> users format=extjson`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Import.Format != ImportExtJSON {
+		t.Errorf("Expected extjson format, got %q", op.Import.Format)
+	}
+}
@@ -0,0 +1,71 @@
+package mongoparsertest
+
+import (
+	"testing"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Executes script on db with parser, failing t when it doesn't succeed
+func Execute(t testing.TB, parser *mongoparser.Parser, db *mongo.Database, script string) mongoparser.ScriptResult {
+	t.Helper()
+
+	result := parser.ExecuteScript(t.Context(), db, script)
+	if !result.Success {
+		t.Fatalf("script failed: %v", result.Error)
+	}
+	return result
+}
+
+// Fails t unless db has a collection or view with the given name
+func CollectionExists(t testing.TB, db *mongo.Database, name string) {
+	t.Helper()
+
+	names, err := db.ListCollectionNames(t.Context(), bson.D{{Key: "name", Value: name}})
+	if err != nil {
+		t.Fatalf("failed to list collections: %v", err)
+	}
+	if len(names) == 0 {
+		t.Errorf("expected collection %s to exist in %s", name, db.Name())
+	}
+}
+
+// Fails t unless the collection has an index with the given name
+func IndexExists(t testing.TB, db *mongo.Database, collection, index string) {
+	t.Helper()
+
+	specs, err := db.Collection(collection).Indexes().ListSpecifications(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list indexes of %s: %v", collection, err)
+	}
+	for _, spec := range specs {
+		if spec.Name == index {
+			return
+		}
+	}
+
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+	t.Errorf("expected index %s on %s, found %v", index, collection, names)
+}
+
+// Fails t unless exactly expected documents of the collection match filter;
+// a nil filter matches every document
+func DocCount(t testing.TB, db *mongo.Database, collection string, filter interface{}, expected int64) {
+	t.Helper()
+
+	if filter == nil {
+		filter = bson.D{}
+	}
+	count, err := db.Collection(collection).CountDocuments(t.Context(), filter)
+	if err != nil {
+		t.Fatalf("failed to count documents of %s: %v", collection, err)
+	}
+	if count != expected {
+		t.Errorf("expected %d documents in %s, found %d", expected, collection, count)
+	}
+}
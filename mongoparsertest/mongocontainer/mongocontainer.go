@@ -0,0 +1,115 @@
+// Package mongocontainer starts throwaway MongoDB servers in Docker for
+// integration tests. It is a separate module so projects using
+// mongoparsertest don't depend on Testcontainers and the Docker client.
+package mongocontainer
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Image Start runs
+const DefaultImage = "mongo:7"
+
+// How long Start waits for the container to accept connections
+const startTimeout = 2 * time.Minute
+
+// Counts databases created by Server.Database so their names are unique
+var databases atomic.Int64
+
+// A throwaway MongoDB server started for a test
+type Server struct {
+	// Connection string of the server
+	URI string
+	// Client connected to the server, disconnected when the test ends
+	Client *mongo.Client
+}
+
+// Starts a single-node replica set in a Docker container, so scripts using
+// transactions and change streams work, and terminates it when the test ends.
+// The test is skipped when Docker is not available.
+func Start(t testing.TB) *Server {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	if err := dockerHealth(ctx); err != nil {
+		t.Skipf("Docker is not available: %v", err)
+	}
+
+	container, err := mongodb.Run(ctx, DefaultImage, mongodb.WithReplicaSet("rs0"))
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("failed to start MongoDB container: %v", err)
+	}
+
+	endpoint, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("failed to read MongoDB connection string: %v", err)
+	}
+	// The replica set member advertises the container's address, not the mapped port
+	uri := endpoint + "/?directConnection=true"
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+	})
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("failed to reach MongoDB: %v", err)
+	}
+
+	return &Server{URI: uri, Client: client}
+}
+
+// Reports why Docker can't run containers, or nil when it can
+func dockerHealth(ctx context.Context) (err error) {
+	// testcontainers panics when it finds no Docker host at all
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%v", v)
+		}
+	}()
+
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		return err
+	}
+	return provider.Health(ctx)
+}
+
+// Returns an empty database named after the test, dropped when the test ends
+func (s *Server) Database(t testing.TB) *mongo.Database {
+	t.Helper()
+
+	db := s.Client.Database(databaseName(t.Name(), databases.Add(1)))
+	t.Cleanup(func() {
+		_ = db.Drop(context.Background())
+	})
+	return db
+}
+
+// Characters MongoDB doesn't allow in database names, and a few it discourages
+var invalidDatabaseChars = regexp.MustCompile(`[/\\. "$*<>:|?]+`)
+
+// Turns a test name into a database name within MongoDB's 63 byte limit
+func databaseName(test string, n int64) string {
+	name := invalidDatabaseChars.ReplaceAllString(test, "_")
+	suffix := fmt.Sprintf("_%d", n)
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+	return name + suffix
+}
@@ -0,0 +1,38 @@
+package mongocontainer
+
+import (
+	"strings"
+	"testing"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+	"github.com/artumont/MongoDBParser/mongoparsertest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDatabaseName(t *testing.T) {
+	if got := databaseName("TestUsers/with space.and$dots", 3); got != "TestUsers_with_space_and_dots_3" {
+		t.Errorf("Unexpected database name %s", got)
+	}
+	if got := databaseName(strings.Repeat("a", 100), 12); len(got) != 63 || !strings.HasSuffix(got, "_12") {
+		t.Errorf("Expected a 63 byte name keeping the suffix, got %s", got)
+	}
+}
+
+func TestStart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a MongoDB container")
+	}
+	server := Start(t)
+	db := server.Database(t)
+
+	mongoparsertest.Execute(t, mongoparser.NewParser(), db, `db.createCollection("users", {
+  validator: { $jsonSchema: { bsonType: "object", required: ["email"] } }
+});
+db.users.createIndex({ email: 1 }, { unique: true, name: "email_unique" });
+db.users.insertMany([{ email: "a@example.com" }, { email: "b@example.com" }]);`)
+
+	mongoparsertest.CollectionExists(t, db, "users")
+	mongoparsertest.IndexExists(t, db, "users", "email_unique")
+	mongoparsertest.DocCount(t, db, "users", nil, 2)
+	mongoparsertest.DocCount(t, db, "users", bson.D{{Key: "email", Value: "a@example.com"}}, 1)
+}
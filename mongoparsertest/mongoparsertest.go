@@ -0,0 +1,154 @@
+// Package mongoparsertest compares the operations scripts parse into against
+// golden files, so changes to what a schema script does show up in review.
+package mongoparsertest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+)
+
+// Environment variable that makes the assertions rewrite golden files instead
+// of comparing against them
+const UpdateEnv = "MONGOPARSER_UPDATE_SNAPSHOTS"
+
+// Serializes operations deterministically: the canonical form of each
+// operation, indented one field per line, with operations separated by a blank
+// line. Formatting, comments and statement positions don't affect the result.
+func Snapshot(ops []mongoparser.MongoOperation) []byte {
+	var b bytes.Buffer
+	for i, op := range ops {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		writeIndented(&b, mongoparser.CanonicalOperation(op))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// Compares the snapshot of ops with the golden file, failing t with the first
+// differing line. When UpdateEnv is set the golden file is written instead.
+func AssertSnapshot(t testing.TB, golden string, ops []mongoparser.MongoOperation) {
+	t.Helper()
+
+	actual := Snapshot(ops)
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(golden, actual, 0o644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(golden)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist; run the test with %s=1 to create it", golden, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	// Golden files checked out on Windows may have CRLF line endings
+	expected = bytes.ReplaceAll(expected, []byte("\r\n"), []byte("\n"))
+
+	if diff := firstDifference(string(expected), string(actual)); diff != "" {
+		t.Errorf("operations differ from %s (run with %s=1 to update):\n%s", golden, UpdateEnv, diff)
+	}
+}
+
+// Parses script with parser and compares its operations with the golden file.
+// Statements that fail to parse fail t.
+func AssertScript(t testing.TB, parser *mongoparser.Parser, script, golden string) {
+	t.Helper()
+
+	ops, err := parser.ParseScript(script)
+	if err != nil {
+		t.Fatalf("failed to parse script: %v", err)
+	}
+	AssertSnapshot(t, golden, ops)
+}
+
+// Describes the first line where two snapshots differ, or returns "" when they match
+func firstDifference(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	want := strings.Split(expected, "\n")
+	got := strings.Split(actual, "\n")
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if i >= len(want) || i >= len(got) || w != g {
+			return fmt.Sprintf("line %d:\n  expected: %s\n  actual:   %s", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+// Writes a canonical form with every field and element on its own line,
+// leaving the content of strings alone
+func writeIndented(b *bytes.Buffer, canonical string) {
+	depth := 0
+	newline := func() {
+		b.WriteByte('\n')
+		b.WriteString(strings.Repeat("  ", depth))
+	}
+
+	inString, escaped := false, false
+	for i := 0; i < len(canonical); i++ {
+		c := canonical[i]
+		if inString {
+			b.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+			b.WriteByte(c)
+		case '{', '[':
+			b.WriteByte(c)
+			// Keep empty documents and arrays on one line
+			if i+1 < len(canonical) && (canonical[i+1] == '}' || canonical[i+1] == ']') {
+				i++
+				b.WriteByte(canonical[i])
+				continue
+			}
+			depth++
+			newline()
+		case '}', ']':
+			depth--
+			newline()
+			b.WriteByte(c)
+		case ',':
+			b.WriteByte(c)
+			newline()
+		case ':':
+			b.WriteString(": ")
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
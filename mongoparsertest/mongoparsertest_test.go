@@ -0,0 +1,126 @@
+package mongoparsertest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+)
+
+const usersScript = `db.createCollection("users", {
+  validator: { $jsonSchema: { bsonType: "object", required: ["email"] } }
+});
+db.users.createIndex({ email: 1 }, { unique: true, name: "email_unique" });
+`
+
+// Records failures instead of reporting them, so assertions can be tested failing
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	panic(r)
+}
+
+// Runs an assertion against a recorder, stopping at its first fatal failure
+func record(t *testing.T, assert func(tb testing.TB)) []string {
+	r := &recorder{TB: t}
+	func() {
+		defer func() {
+			if v := recover(); v != nil && v != r {
+				panic(v)
+			}
+		}()
+		assert(r)
+	}()
+	return r.failures
+}
+
+func TestAssertScript(t *testing.T) {
+	AssertScript(t, mongoparser.NewParser(), usersScript, filepath.Join("testdata", "users.golden"))
+}
+
+func TestSnapshotIgnoresFormatting(t *testing.T) {
+	parser := mongoparser.NewParser()
+	reformatted := "// users\ndb.createCollection('users', { validator: { \"$jsonSchema\": { bsonType: 'object', required: [ 'email' ] } } });\n\n" +
+		"db.users.createIndex({email: 1}, {unique: true, name: 'email_unique'});\n"
+
+	a, err := parser.ParseScript(usersScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parser.ParseScript(reformatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(Snapshot(a)) != string(Snapshot(b)) {
+		t.Errorf("Expected formatting not to change the snapshot:\n%s\n---\n%s", Snapshot(a), Snapshot(b))
+	}
+
+	snapshot := string(Snapshot(a))
+	for _, expected := range []string{"  Collection: \"users\",\n", "      \"required\": [\n        \"email\"\n      ]", "    Unique: true\n"} {
+		if !strings.Contains(snapshot, expected) {
+			t.Errorf("Expected snapshot to contain %q, got:\n%s", expected, snapshot)
+		}
+	}
+}
+
+func TestAssertSnapshotReportsChanges(t *testing.T) {
+	ops, err := mongoparser.NewParser().ParseScript(strings.Replace(usersScript, "unique: true", "unique: false", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures := record(t, func(tb testing.TB) {
+		AssertSnapshot(tb, filepath.Join("testdata", "users.golden"), ops)
+	})
+	if len(failures) != 1 || !strings.Contains(failures[0], "Unique: true") {
+		t.Errorf("Expected the changed index option to be reported, got %q", failures)
+	}
+
+	failures = record(t, func(tb testing.TB) {
+		AssertSnapshot(tb, filepath.Join(t.TempDir(), "missing.golden"), ops)
+	})
+	if len(failures) != 1 || !strings.Contains(failures[0], UpdateEnv) {
+		t.Errorf("Expected a missing golden file to explain how to create it, got %q", failures)
+	}
+}
+
+func TestAssertSnapshotUpdates(t *testing.T) {
+	t.Setenv(UpdateEnv, "1")
+	golden := filepath.Join(t.TempDir(), "nested", "users.golden")
+
+	ops, err := mongoparser.NewParser().ParseScript(usersScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertSnapshot(t, golden, ops)
+
+	content, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Expected the golden file to be written: %v", err)
+	}
+	if string(content) != string(Snapshot(ops)) {
+		t.Errorf("Expected the golden file to hold the snapshot, got:\n%s", content)
+	}
+}
+
+func TestAssertScriptRejectsUnsupportedStatements(t *testing.T) {
+	failures := record(t, func(tb testing.TB) {
+		AssertScript(tb, mongoparser.NewParser(), "db.users.frobnicate({});", filepath.Join("testdata", "users.golden"))
+	})
+	if len(failures) != 1 || !strings.Contains(failures[0], "unsupported operation") {
+		t.Errorf("Expected the unsupported statement to fail the test, got %q", failures)
+	}
+}
@@ -0,0 +1,426 @@
+package mongoparsertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Wire protocol opcodes the recorder speaks
+const (
+	opReply int32 = 1
+	opQuery int32 = 2004
+	opMsg   int32 = 2013
+)
+
+// OP_MSG flags
+const (
+	msgChecksumPresent uint32 = 1 << 0
+	msgMoreToCome      uint32 = 1 << 1
+)
+
+// Commands the driver sends on its own to discover and monitor servers
+var driverCommands = map[string]bool{
+	"hello":       true,
+	"isMaster":    true,
+	"ismaster":    true,
+	"endSessions": true,
+}
+
+// Command fields describing the driver's session and connection rather than
+// what a script asked for
+var driverFields = map[string]bool{
+	"$db":          true,
+	"lsid":         true,
+	"$clusterTime": true,
+}
+
+// A command a script sent to the recorder
+type Command struct {
+	// Database the command ran on
+	Database string
+	// Command name, such as "insert" or "createIndexes"
+	Name string
+	// Collection the command targets; empty for database commands
+	Collection string
+	// Command document with batched documents inlined, without session fields
+	Body bson.Raw
+	// Hex ID of the logical session the command ran in
+	Session string
+}
+
+// Returns the command's field, such as "documents" or "indexes", or an empty
+// value when the command doesn't have it
+func (c Command) Lookup(key ...string) bson.RawValue {
+	value, err := c.Body.LookupErr(key...)
+	if err != nil {
+		return bson.RawValue{}
+	}
+	return value
+}
+
+// Builds the reply to a recorded command; returning nil sends the default reply
+type ReplyFunc func(cmd Command) bson.D
+
+// Records the commands a script sends instead of running them against a
+// server. The driver connects to an in-memory endpoint that acknowledges
+// every command: writes report their documents as written, and queries find
+// nothing, so existing collections and indexes are never reported.
+type Recorder struct {
+	client *mongo.Client
+
+	mu       sync.Mutex
+	commands []Command
+	reply    ReplyFunc
+}
+
+// Creates a recorder whose client is disconnected when the test ends
+func NewRecorder(t testing.TB) *Recorder {
+	t.Helper()
+
+	r := &Recorder{}
+	// The recorder poses as a mongos so the driver neither adds read
+	// preferences to primary reads nor refuses transactions
+	client, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://recorder:27017/?directConnection=true").
+		SetDialer(recorderDialer{recorder: r}).
+		SetRetryWrites(false).
+		SetRetryReads(false).
+		SetServerSelectionTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("failed to connect to the recorder: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+	})
+
+	r.client = client
+	return r
+}
+
+// Returns the client sending commands to the recorder
+func (r *Recorder) Client() *mongo.Client {
+	return r.client
+}
+
+// Returns a database whose commands are recorded
+func (r *Recorder) Database(name string) *mongo.Database {
+	return r.client.Database(name)
+}
+
+// Replaces the default replies, for example to report a collection as existing
+func (r *Recorder) Reply(reply ReplyFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reply = reply
+}
+
+// Returns the commands recorded so far in the order they were sent
+func (r *Recorder) Commands() []Command {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Command(nil), r.commands...)
+}
+
+// Forgets the commands recorded so far
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = nil
+}
+
+// Records a command and returns its reply
+func (r *Recorder) handle(body bson.Raw, sequences map[string]bson.A) bson.D {
+	elements, err := body.Elements()
+	if err != nil || len(elements) == 0 {
+		return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "invalid command document"}}
+	}
+
+	name := elements[0].Key()
+	if driverCommands[name] {
+		return helloReply()
+	}
+
+	cmd := Command{Name: name}
+	cmd.Collection, _ = elements[0].Value().StringValueOK()
+	cmd.Database, _ = body.Lookup("$db").StringValueOK()
+	if _, id, ok := body.Lookup("lsid", "id").BinaryOK(); ok {
+		cmd.Session = hex.EncodeToString(id)
+	}
+
+	var doc bson.D
+	for _, element := range elements {
+		if !driverFields[element.Key()] {
+			doc = append(doc, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+	}
+	for _, identifier := range sortedIdentifiers(sequences) {
+		doc = append(doc, bson.E{Key: identifier, Value: sequences[identifier]})
+	}
+	if cmd.Body, err = bson.Marshal(doc); err != nil {
+		return bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: err.Error()}}
+	}
+
+	r.mu.Lock()
+	r.commands = append(r.commands, cmd)
+	reply := r.reply
+	r.mu.Unlock()
+
+	if reply != nil {
+		if doc := reply(cmd); doc != nil {
+			return doc
+		}
+	}
+	return defaultReply(cmd)
+}
+
+// Returns the identifiers of OP_MSG document sequences in a stable order
+func sortedIdentifiers(sequences map[string]bson.A) []string {
+	identifiers := make([]string, 0, len(sequences))
+	for identifier := range sequences {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+	return identifiers
+}
+
+// Describes the recorder as a mongos running MongoDB 7.0
+func helloReply() bson.D {
+	return bson.D{
+		{Key: "ismaster", Value: true},
+		{Key: "isWritablePrimary", Value: true},
+		{Key: "helloOk", Value: true},
+		{Key: "msg", Value: "isdbgrid"},
+		{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+		{Key: "maxMessageSizeBytes", Value: int32(48000000)},
+		{Key: "maxWriteBatchSize", Value: int32(100000)},
+		{Key: "localTime", Value: time.Now()},
+		{Key: "logicalSessionTimeoutMinutes", Value: int32(30)},
+		{Key: "minWireVersion", Value: int32(0)},
+		{Key: "maxWireVersion", Value: int32(21)},
+		{Key: "ok", Value: 1.0},
+	}
+}
+
+// Acknowledges a command the way a server with no data would
+func defaultReply(cmd Command) bson.D {
+	ok := bson.E{Key: "ok", Value: 1.0}
+	switch cmd.Name {
+	case "find", "aggregate", "listCollections", "listIndexes", "listSearchIndexes":
+		return bson.D{
+			{Key: "cursor", Value: bson.D{
+				{Key: "id", Value: int64(0)},
+				{Key: "ns", Value: cmd.Database + "." + cmd.Collection},
+				{Key: "firstBatch", Value: bson.A{}},
+			}},
+			ok,
+		}
+	case "insert", "update", "delete":
+		n := int32(0)
+		if cmd.Name == "insert" {
+			if values, err := cmd.Lookup("documents").Array().Values(); err == nil {
+				n = int32(len(values))
+			}
+		}
+		return bson.D{{Key: "n", Value: n}, {Key: "nModified", Value: int32(0)}, ok}
+	case "findAndModify":
+		return bson.D{{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: int32(0)}}}, {Key: "value", Value: nil}, ok}
+	case "createSearchIndexes":
+		var created bson.A
+		if values, err := cmd.Lookup("indexes").Array().Values(); err == nil {
+			for _, value := range values {
+				name, ok := value.Document().Lookup("name").StringValueOK()
+				if !ok {
+					name = "default"
+				}
+				created = append(created, bson.D{{Key: "id", Value: name}, {Key: "name", Value: name}})
+			}
+		}
+		return bson.D{{Key: "indexesCreated", Value: created}, ok}
+	default:
+		return bson.D{ok}
+	}
+}
+
+// Connects the driver to the recorder over in-memory pipes
+type recorderDialer struct {
+	recorder *Recorder
+}
+
+func (d recorderDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go d.recorder.serve(server)
+	return client, nil
+}
+
+// Answers the wire protocol messages of one connection until it closes
+func (r *Recorder) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		requestID, opCode, payload, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+
+		var reply []byte
+		switch opCode {
+		case opQuery:
+			// Only the driver's initial handshake uses OP_QUERY
+			reply, err = replyMessage(requestID, opReply, helloReply())
+		case opMsg:
+			var flags uint32
+			var body bson.Raw
+			var sequences map[string]bson.A
+			if flags, body, sequences, err = parseMsg(payload); err != nil {
+				return
+			}
+			doc := r.handle(body, sequences)
+			if flags&msgMoreToCome != 0 {
+				// Unacknowledged writes expect no reply
+				continue
+			}
+			reply, err = replyMessage(requestID, opMsg, doc)
+		default:
+			return
+		}
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// Reads one wire protocol message, returning its request ID, opcode and the
+// bytes following the header
+func readMessage(conn io.Reader) (int32, int32, []byte, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, 0, nil, err
+	}
+	length := int32(binary.LittleEndian.Uint32(header[0:4]))
+	if length < 16 {
+		return 0, 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+	payload := make([]byte, length-16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	requestID := int32(binary.LittleEndian.Uint32(header[4:8]))
+	opCode := int32(binary.LittleEndian.Uint32(header[12:16]))
+	return requestID, opCode, payload, nil
+}
+
+// Splits an OP_MSG into its flags, command document and document sequences
+func parseMsg(payload []byte) (uint32, bson.Raw, map[string]bson.A, error) {
+	if len(payload) < 4 {
+		return 0, nil, nil, errors.New("truncated OP_MSG")
+	}
+	flags := binary.LittleEndian.Uint32(payload)
+	payload = payload[4:]
+	if flags&msgChecksumPresent != 0 {
+		if len(payload) < 4 {
+			return 0, nil, nil, errors.New("truncated OP_MSG checksum")
+		}
+		payload = payload[:len(payload)-4]
+	}
+
+	var body bson.Raw
+	sequences := map[string]bson.A{}
+	for len(payload) > 0 {
+		kind := payload[0]
+		payload = payload[1:]
+		switch kind {
+		case 0:
+			doc, rest, err := readDocument(payload)
+			if err != nil {
+				return 0, nil, nil, err
+			}
+			body, payload = doc, rest
+		case 1:
+			if len(payload) < 4 {
+				return 0, nil, nil, errors.New("truncated document sequence")
+			}
+			size := int(binary.LittleEndian.Uint32(payload))
+			if size < 4 || size > len(payload) {
+				return 0, nil, nil, fmt.Errorf("invalid document sequence size %d", size)
+			}
+			section := payload[4:size]
+			payload = payload[size:]
+
+			end := bytes.IndexByte(section, 0)
+			if end < 0 {
+				return 0, nil, nil, errors.New("unterminated document sequence identifier")
+			}
+			identifier := string(section[:end])
+			section = section[end+1:]
+			docs := bson.A{}
+			for len(section) > 0 {
+				doc, rest, err := readDocument(section)
+				if err != nil {
+					return 0, nil, nil, err
+				}
+				docs = append(docs, doc)
+				section = rest
+			}
+			sequences[identifier] = docs
+		default:
+			return 0, nil, nil, fmt.Errorf("unknown OP_MSG section kind %d", kind)
+		}
+	}
+	if body == nil {
+		return 0, nil, nil, errors.New("OP_MSG without a command document")
+	}
+	return flags, body, sequences, nil
+}
+
+// Reads a BSON document from the start of data, returning it and the bytes after it
+func readDocument(data []byte) (bson.Raw, []byte, error) {
+	if len(data) < 5 {
+		return nil, nil, errors.New("truncated BSON document")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < 5 || size > len(data) {
+		return nil, nil, fmt.Errorf("invalid BSON document size %d", size)
+	}
+	return bson.Raw(data[:size]), data[size:], nil
+}
+
+// Encodes a reply to the request with the given ID as an OP_MSG or OP_REPLY
+func replyMessage(requestID, opCode int32, doc bson.D) ([]byte, error) {
+	body, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	if opCode == opReply {
+		// responseFlags, cursorID, startingFrom, numberReturned
+		payload = make([]byte, 20)
+		binary.LittleEndian.PutUint32(payload[16:20], 1)
+	} else {
+		// flagBits followed by a single body section
+		payload = []byte{0, 0, 0, 0, 0}
+	}
+	payload = append(payload, body...)
+
+	message := make([]byte, 16, 16+len(payload))
+	binary.LittleEndian.PutUint32(message[0:4], uint32(16+len(payload)))
+	binary.LittleEndian.PutUint32(message[8:12], uint32(requestID))
+	binary.LittleEndian.PutUint32(message[12:16], uint32(opCode))
+	return append(message, payload...), nil
+}
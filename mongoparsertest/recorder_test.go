@@ -0,0 +1,783 @@
+package mongoparsertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(t)
+	db := recorder.Database("app")
+
+	result := mongoparser.NewParser().ExecuteScript(t.Context(), db, usersScript+`db.users.insertMany([{ email: "a@example.com" }, { email: "b@example.com" }]);
+db.users.updateOne({ email: "a@example.com" }, { $set: { admin: true } });`)
+	if !result.Success {
+		t.Fatalf("Expected the script to run against the recorder, got %v", result.Error)
+	}
+
+	var names []string
+	for _, cmd := range recorder.Commands() {
+		names = append(names, cmd.Name)
+		if cmd.Database != "app" {
+			t.Errorf("Expected %s to run on app, got %s", cmd.Name, cmd.Database)
+		}
+		if _, err := cmd.Body.LookupErr("lsid"); err == nil {
+			t.Errorf("Expected session fields to be removed from %s", cmd.Body)
+		}
+	}
+
+	commands := recorder.Commands()
+	find := func(name string) Command {
+		t.Helper()
+		for _, cmd := range commands {
+			if cmd.Name == name {
+				return cmd
+			}
+		}
+		t.Fatalf("Expected a %s command, got %v", name, names)
+		return Command{}
+	}
+
+	create := find("create")
+	if create.Collection != "users" || create.Lookup("validator", "$jsonSchema", "bsonType").StringValue() != "object" {
+		t.Errorf("Unexpected create command: %s", create.Body)
+	}
+
+	index := find("createIndexes")
+	spec := index.Lookup("indexes").Array().Index(0).Value().Document()
+	if spec.Lookup("name").StringValue() != "email_unique" || !spec.Lookup("unique").Boolean() {
+		t.Errorf("Unexpected createIndexes command: %s", index.Body)
+	}
+
+	insert := find("insert")
+	documents, err := insert.Lookup("documents").Array().Values()
+	if err != nil || len(documents) != 2 {
+		t.Errorf("Expected both documents to be recorded, got %s", insert.Body)
+	}
+	if result.Operations[2].Documents != 2 {
+		t.Errorf("Expected the recorder to acknowledge 2 inserted documents, got %d", result.Operations[2].Documents)
+	}
+
+	update := find("update")
+	set := update.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set")
+	if !set.Document().Lookup("admin").Boolean() {
+		t.Errorf("Unexpected update command: %s", update.Body)
+	}
+
+	recorder.Reset()
+	if len(recorder.Commands()) != 0 {
+		t.Error("Expected Reset to forget recorded commands")
+	}
+}
+
+func TestRecorderReply(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		if cmd.Name != "create" {
+			return nil
+		}
+		return bson.D{
+			{Key: "ok", Value: 0.0},
+			{Key: "code", Value: int32(mongoparser.CodeNamespaceExists)},
+			{Key: "codeName", Value: "NamespaceExists"},
+			{Key: "errmsg", Value: "Collection app.users already exists."},
+		}
+	})
+
+	result := mongoparser.NewParser().ExecuteScript(t.Context(), recorder.Database("app"), `db.createCollection("users");`)
+	if !result.Success || result.Operations[0].Status != mongoparser.StatusSkipped {
+		t.Errorf("Expected the existing collection to be skipped, got %+v", result)
+	}
+}
+
+func TestRecorderOperationComments(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		switch {
+		case cmd.Name == "profile" && cmd.Lookup("profile").AsInt64() == -1:
+			return bson.D{{Key: "was", Value: int32(0)}, {Key: "slowms", Value: int32(100)}, {Key: "ok", Value: 1.0}}
+		case cmd.Name == "find" && cmd.Collection == "system.profile":
+			entry := bson.D{
+				{Key: "op", Value: "update"},
+				{Key: "ns", Value: "app.users"},
+				{Key: "millis", Value: int32(75)},
+				{Key: "command", Value: bson.D{{Key: "comment", Value: "mongoparser add-users line 4"}}},
+				{Key: "planSummary", Value: "COLLSCAN"},
+				{Key: "docsExamined", Value: int32(5000)},
+			}
+			return bson.D{
+				{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.system.profile"}, {Key: "firstBatch", Value: bson.A{entry}}}},
+				{Key: "ok", Value: 1.0},
+			}
+		}
+		return nil
+	})
+
+	parser := mongoparser.NewParser(mongoparser.WithOperationComments(), mongoparser.WithProfiler(50*time.Millisecond))
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), `// METADATA:
+// {"name": "add-users"}
+
+db.users.insertOne({ email: "a@example.com" });
+db.users.updateMany({}, { $set: { active: true } });`)
+	if !result.Success {
+		t.Fatalf("Expected the script to succeed, got %v", result.Error)
+	}
+
+	var profiles []int64
+	comments := map[string]string{}
+	for _, cmd := range recorder.Commands() {
+		switch cmd.Name {
+		case "profile":
+			profiles = append(profiles, cmd.Lookup("profile").AsInt64())
+			if cmd.Lookup("profile").AsInt64() == 1 && cmd.Lookup("slowms").AsInt64() != 50 {
+				t.Errorf("Expected the threshold to be set to 50ms, got %s", cmd.Body)
+			}
+		case "insert", "update":
+			comments[cmd.Name], _ = cmd.Lookup("comment").StringValueOK()
+		}
+	}
+	if len(profiles) != 3 || profiles[0] != -1 || profiles[1] != 1 || profiles[2] != 0 {
+		t.Errorf("Expected the profiler to be read, enabled and restored, got levels %v", profiles)
+	}
+	if comments["insert"] != "mongoparser add-users line 4" || comments["update"] != "mongoparser add-users line 5" {
+		t.Errorf("Unexpected comments: %v", comments)
+	}
+
+	if len(result.SlowOperations) != 1 {
+		t.Fatalf("Expected one slow operation, got %+v", result.SlowOperations)
+	}
+	slow := result.SlowOperations[0]
+	if slow.Namespace != "app.users" || slow.Duration != 75*time.Millisecond || slow.PlanSummary != "COLLSCAN" || slow.DocsExamined != 5000 || slow.Comment != "mongoparser add-users line 4" {
+		t.Errorf("Unexpected slow operation: %+v", slow)
+	}
+}
+
+func TestRecorderExistenceCache(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		var batch bson.A
+		switch {
+		case cmd.Name == "listCollections":
+			batch = bson.A{bson.D{{Key: "name", Value: "users"}, {Key: "type", Value: "collection"}}}
+		case cmd.Name == "listIndexes" && cmd.Collection == "users":
+			batch = bson.A{
+				bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+				bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_unique"}, {Key: "unique", Value: true}},
+			}
+		default:
+			return nil
+		}
+		return bson.D{
+			{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: cmd.Database + "." + cmd.Collection}, {Key: "firstBatch", Value: batch}}},
+			{Key: "ok", Value: 1.0},
+		}
+	})
+
+	parser := mongoparser.NewParser(mongoparser.WithExistenceCache())
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), usersScript+`db.users.createIndex({ name: 1 });
+db.createCollection("orders");
+db.orders.createIndex({ customer: 1 });
+db.orders.createIndex({ createdAt: -1 });`)
+	if !result.Success {
+		t.Fatalf("Expected the script to succeed, got %v", result.Error)
+	}
+
+	var names []string
+	for _, cmd := range recorder.Commands() {
+		names = append(names, cmd.Name+" "+cmd.Collection)
+	}
+	expected := []string{
+		"listCollections ",
+		"listIndexes users",
+		"createIndexes users",
+		"create orders",
+		"createIndexes orders",
+		"createIndexes orders",
+	}
+	if strings.Join(names, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected commands %v, got %v", expected, names)
+	}
+	if result.Operations[0].Status != mongoparser.StatusSkipped || result.Operations[1].Status != mongoparser.StatusSkipped {
+		t.Errorf("Expected the existing collection and index to be skipped, got %+v", result.Operations[:2])
+	}
+}
+
+func TestRecorderDependencyCheck(t *testing.T) {
+	recorder := NewRecorder(t)
+	db := recorder.Database("app")
+
+	script := `db.users.insertOne({ email: "a@example.com" });
+db.createCollection("users", { validator: { $jsonSchema: { required: ["email"] } } });
+db.usres.createIndex({ email: 1 });`
+	result := mongoparser.NewParser(mongoparser.WithDependencyCheck(true)).ExecuteScript(t.Context(), db, script)
+	var dependencyErr *mongoparser.DependencyError
+	if !errors.As(result.Error, &dependencyErr) || len(dependencyErr.Issues) != 1 || dependencyErr.Issues[0].Collection != "usres" {
+		t.Fatalf("Expected the index on a missing collection to be reported, got %v", result.Error)
+	}
+	for _, cmd := range recorder.Commands() {
+		if cmd.Name != "listCollections" {
+			t.Errorf("Expected nothing to run before the check failed, got %s", cmd.Name)
+		}
+	}
+
+	recorder.Reset()
+	result = mongoparser.NewParser(mongoparser.WithDependencyCheck(true)).ExecuteScript(t.Context(), db, strings.Replace(script, "usres", "users", 1))
+	if !result.Success {
+		t.Fatalf("Expected the reordered script to succeed, got %v", result.Error)
+	}
+	var names []string
+	for _, cmd := range recorder.Commands() {
+		names = append(names, cmd.Name)
+	}
+	if strings.Join(names, ", ") != "create, insert, listIndexes, createIndexes" {
+		t.Errorf("Expected the collection to be created first, got %v", names)
+	}
+}
+
+func TestRecorderIndexKeyVerification(t *testing.T) {
+	script := `db.users.createIndex({ lastName: 1, firstName: 1 }, { name: "name_1" });`
+	for _, reverse := range []bool{false, true} {
+		recorder := NewRecorder(t)
+		var stored bson.D
+		// Stores the created index's keys, reversed when the server is to misbehave
+		recorder.Reply(func(cmd Command) bson.D {
+			switch cmd.Name {
+			case "createIndexes":
+				elements, _ := cmd.Lookup("indexes").Array().Index(0).Value().Document().Lookup("key").Document().Elements()
+				stored = nil
+				for _, element := range elements {
+					stored = append(stored, bson.E{Key: element.Key(), Value: element.Value()})
+				}
+				if reverse {
+					slices.Reverse(stored)
+				}
+			case "listIndexes":
+				batch := bson.A{}
+				if stored != nil {
+					batch = bson.A{bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: stored}, {Key: "name", Value: "name_1"}}}
+				}
+				return bson.D{
+					{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.users"}, {Key: "firstBatch", Value: batch}}},
+					{Key: "ok", Value: 1.0},
+				}
+			}
+			return nil
+		})
+
+		parser := mongoparser.NewParser(mongoparser.WithIndexKeyVerification())
+		result := parser.ExecuteScript(t.Context(), recorder.Database("app"), script)
+		if !reverse && !result.Success {
+			t.Errorf("Expected matching keys to pass verification, got %v", result.Error)
+		}
+		if reverse && (result.Success || !strings.Contains(result.Error.Error(), "was created with keys { firstName: 1, lastName: 1 }, script has { lastName: 1, firstName: 1 }")) {
+			t.Errorf("Expected the reversed key order to fail the script, got %v", result.Error)
+		}
+	}
+}
+
+func TestRecorderIndexReconciliation(t *testing.T) {
+	script := `db.users.createIndexes([{ status: 1 }, { email: 1 }], { unique: true });`
+	for _, confirmed := range []bool{false, true} {
+		recorder := NewRecorder(t)
+		recorder.Reply(func(cmd Command) bson.D {
+			if cmd.Name != "listIndexes" {
+				return nil
+			}
+			batch := bson.A{
+				bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "status", Value: int32(1)}}}, {Key: "name", Value: "status_1"}},
+				bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}},
+			}
+			return bson.D{
+				{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.users"}, {Key: "firstBatch", Value: batch}}},
+				{Key: "ok", Value: 1.0},
+			}
+		})
+
+		var asked []string
+		parser := mongoparser.NewParser(mongoparser.WithIndexReconciliation(func(ctx context.Context, drift mongoparser.IndexDrift) bool {
+			asked = append(asked, drift.Name)
+			return confirmed || drift.Name == "status_1"
+		}))
+		result := parser.ExecuteScript(t.Context(), recorder.Database("app"), script)
+
+		var names []string
+		for _, cmd := range recorder.Commands() {
+			names = append(names, cmd.Name)
+		}
+		if strings.Join(asked, ", ") != "status_1, email_1" {
+			t.Errorf("Expected both drifted indexes to be confirmed, got %v", asked)
+		}
+		if !confirmed && (result.Success || strings.Join(names, ", ") != "listIndexes") {
+			t.Errorf("Expected a refused rebuild to leave every index alone, got %v after %v", result.Error, names)
+		}
+		if confirmed && (!result.Success || strings.Join(names, ", ") != "listIndexes, dropIndexes, dropIndexes, createIndexes") {
+			t.Errorf("Expected both indexes to be rebuilt, got %v after %v", result.Error, names)
+		}
+	}
+}
+
+func TestRecorderValidatorReconciliation(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		switch cmd.Name {
+		case "create":
+			return bson.D{
+				{Key: "ok", Value: 0.0},
+				{Key: "code", Value: int32(mongoparser.CodeNamespaceExists)},
+				{Key: "codeName", Value: "NamespaceExists"},
+				{Key: "errmsg", Value: "Collection app.users already exists."},
+			}
+		case "listCollections":
+			options := bson.D{
+				{Key: "validator", Value: bson.D{{Key: "email", Value: bson.D{{Key: "$exists", Value: true}}}}},
+				{Key: "validationLevel", Value: "moderate"},
+				{Key: "validationAction", Value: "error"},
+			}
+			batch := bson.A{bson.D{{Key: "name", Value: "users"}, {Key: "type", Value: "collection"}, {Key: "options", Value: options}}}
+			return bson.D{
+				{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.$cmd.listCollections"}, {Key: "firstBatch", Value: batch}}},
+				{Key: "ok", Value: 1.0},
+			}
+		}
+		return nil
+	})
+
+	var drifts []mongoparser.ValidatorDrift
+	parser := mongoparser.NewParser(mongoparser.WithValidatorReconciliation(func(ctx context.Context, drift mongoparser.ValidatorDrift) bool {
+		drifts = append(drifts, drift)
+		return true
+	}))
+
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), `db.createCollection("users", { validator: { email: { $exists: true } } });`)
+	if !result.Success || result.Operations[0].Status != mongoparser.StatusSkipped || len(drifts) != 0 {
+		t.Errorf("Expected a matching validator to be left alone, got %+v after %v", result, drifts)
+	}
+
+	recorder.Reset()
+	result = parser.ExecuteScript(t.Context(), recorder.Database("app"), `db.createCollection("users", { validator: { email: { $type: "string" } } });`)
+	if !result.Success {
+		t.Fatalf("Expected the validator to be updated, got %v", result.Error)
+	}
+	if len(drifts) != 1 || drifts[0].Differences[0] != "validator differs from the script" {
+		t.Errorf("Expected the drift to be confirmed, got %+v", drifts)
+	}
+
+	commands := recorder.Commands()
+	collMod := commands[len(commands)-1]
+	if collMod.Name != "collMod" {
+		t.Fatalf("Expected collMod last, got %s", collMod.Name)
+	}
+	if _, err := collMod.Body.LookupErr("validator"); err != nil {
+		t.Errorf("Expected collMod to set the validator, got %v", collMod.Body)
+	}
+	if _, err := collMod.Body.LookupErr("validationLevel"); err == nil {
+		t.Errorf("Expected collMod to keep the collection's validationLevel, got %v", collMod.Body)
+	}
+}
+
+func TestRecorderSeedFromValidator(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		if cmd.Name != "listCollections" {
+			return nil
+		}
+		schema := bson.D{
+			{Key: "required", Value: bson.A{"email", "plan"}},
+			{Key: "properties", Value: bson.D{
+				{Key: "email", Value: bson.D{{Key: "bsonType", Value: "string"}}},
+				{Key: "plan", Value: bson.D{{Key: "enum", Value: bson.A{"free", "pro"}}}},
+			}},
+		}
+		options := bson.D{{Key: "validator", Value: bson.D{{Key: "$jsonSchema", Value: schema}}}}
+		batch := bson.A{bson.D{{Key: "name", Value: "users"}, {Key: "type", Value: "collection"}, {Key: "options", Value: options}}}
+		return bson.D{
+			{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.$cmd.listCollections"}, {Key: "firstBatch", Value: batch}}},
+			{Key: "ok", Value: 1.0},
+		}
+	})
+
+	result := mongoparser.NewParser().ExecuteScript(t.Context(), recorder.Database("app"), `// SEED: users count=25 from=validator seed=7`)
+	if !result.Success {
+		t.Fatalf("Expected the seed to succeed, got %v", result.Error)
+	}
+
+	var inserted []bson.Raw
+	for _, cmd := range recorder.Commands() {
+		if cmd.Name == "insert" && cmd.Collection == "users" {
+			values, _ := cmd.Lookup("documents").Array().Values()
+			for _, value := range values {
+				inserted = append(inserted, value.Document())
+			}
+		}
+	}
+	if len(inserted) != 25 {
+		t.Fatalf("Expected 25 documents, got %d", len(inserted))
+	}
+	for _, doc := range inserted {
+		plan := doc.Lookup("plan").StringValue()
+		if !strings.Contains(doc.Lookup("email").StringValue(), "@") || (plan != "free" && plan != "pro") {
+			t.Errorf("Expected a document valid for the schema, got %v", doc)
+		}
+	}
+}
+
+func TestRecorderExecutePlan(t *testing.T) {
+	recorder := NewRecorder(t)
+	db := recorder.Database("app")
+
+	parser := mongoparser.NewParser()
+	script := `// METADATA:
+// {"name": "seed-users", "version": "2.0.0"}
+db.users.insertOne({ email: "a@example.com", joined: new Date() });`
+	plan, err := parser.PlanScript(mongoparser.ScriptInfo{Name: "users.js", Content: script})
+	if err != nil {
+		t.Fatalf("PlanScript() returned error: %v", err)
+	}
+	var file bytes.Buffer
+	if err := mongoparser.SavePlan(&file, plan, mongoparser.PlanBSON); err != nil {
+		t.Fatalf("SavePlan() returned error: %v", err)
+	}
+	loaded, err := parser.LoadPlan(&file)
+	if err != nil {
+		t.Fatalf("LoadPlan() returned error: %v", err)
+	}
+
+	result := parser.ExecutePlan(t.Context(), db, loaded)
+	if !result.Success || result.Name != "seed-users" || result.Version != "2.0.0" {
+		t.Fatalf("Expected the plan to run as its script, got %+v", result)
+	}
+	commands := recorder.Commands()
+	if len(commands) != 1 || commands[0].Name != "insert" {
+		t.Fatalf("Expected a single insert, got %v", commands)
+	}
+	documents, err := commands[0].Lookup("documents").Array().Values()
+	if err != nil || len(documents) != 1 {
+		t.Fatalf("Unexpected insert command: %s", commands[0].Body)
+	}
+	if joined := documents[0].Document().Lookup("joined"); joined.Type != bson.TypeDateTime {
+		t.Errorf("Expected new Date() to be evaluated at execution, got %s", joined)
+	}
+}
+
+func TestRecorderExistingTextIndex(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		if cmd.Name != "listIndexes" {
+			return nil
+		}
+		batch := bson.A{
+			bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+			bson.D{
+				{Key: "v", Value: int32(2)},
+				{Key: "key", Value: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}},
+				{Key: "name", Value: "title_text"},
+				{Key: "weights", Value: bson.D{{Key: "title", Value: int32(1)}}},
+				{Key: "default_language", Value: "english"},
+				{Key: "language_override", Value: "language"},
+				{Key: "textIndexVersion", Value: int32(3)},
+			},
+		}
+		return bson.D{
+			{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.posts"}, {Key: "firstBatch", Value: batch}}},
+			{Key: "ok", Value: 1.0},
+		}
+	})
+
+	result := mongoparser.NewParser().ExecuteScript(t.Context(), recorder.Database("app"), `db.posts.createIndex({ title: "text" });`)
+	if !result.Success {
+		t.Fatalf("Expected the existing text index to be skipped, got %v", result.Error)
+	}
+	for _, cmd := range recorder.Commands() {
+		if cmd.Name != "listIndexes" {
+			t.Errorf("Expected nothing but listIndexes, got %s", cmd.Name)
+		}
+	}
+}
+
+const lockedScript = `// METADATA:
+// {"name": "add-users"}
+db.users.insertOne({ email: "a@example.com" });
+db.users.insertOne({ email: "b@example.com" });`
+
+// Returns the first statement of a recorded update or delete command
+func firstStatement(t *testing.T, cmd Command, field string) bson.Raw {
+	t.Helper()
+	statement, ok := cmd.Lookup(field).Array().Index(0).Value().DocumentOK()
+	if !ok {
+		t.Fatalf("Expected %s in %s", field, cmd.Body)
+	}
+	return statement
+}
+
+func TestRecorderScriptLock(t *testing.T) {
+	recorder := NewRecorder(t)
+	parser := mongoparser.NewParser(mongoparser.WithScriptLock("migration_locks", time.Minute))
+
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), lockedScript)
+	if !result.Success {
+		t.Fatalf("Expected the script to run under its lock, got %v", result.Error)
+	}
+
+	commands := recorder.Commands()
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name+" "+cmd.Collection)
+	}
+	expected := []string{"createIndexes migration_locks", "update migration_locks", "insert users", "insert users", "delete migration_locks"}
+	if !slices.Equal(names, expected) {
+		t.Fatalf("Expected the lock to be taken before and released after the script, got %v", names)
+	}
+
+	ttl := commands[0].Lookup("indexes").Array().Index(0).Value().Document()
+	if ttl.Lookup("key", "expiresAt").AsInt64() != 1 || ttl.Lookup("expireAfterSeconds").AsInt64() != 0 {
+		t.Errorf("Expected a TTL index on expiresAt, got %s", commands[0].Body)
+	}
+
+	acquire := firstStatement(t, commands[1], "updates")
+	owner := acquire.Lookup("u", "$set", "owner").StringValue()
+	if acquire.Lookup("q", "_id").StringValue() != "add-users" || !acquire.Lookup("upsert").Boolean() || owner == "" {
+		t.Errorf("Unexpected lock acquisition: %s", acquire)
+	}
+	if _, err := acquire.LookupErr("q", "expiresAt", "$lt"); err != nil {
+		t.Errorf("Expected only an expired lock to be taken over, got %s", acquire)
+	}
+
+	release := firstStatement(t, commands[4], "deletes")
+	if release.Lookup("q", "_id").StringValue() != "add-users" || release.Lookup("q", "owner").StringValue() != owner {
+		t.Errorf("Expected the lock to be released by its owner %s, got %s", owner, release)
+	}
+}
+
+func TestRecorderScriptLockContention(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		if cmd.Collection != "migration_locks" {
+			return nil
+		}
+		switch cmd.Name {
+		case "update":
+			return bson.D{
+				{Key: "n", Value: int32(0)},
+				{Key: "writeErrors", Value: bson.A{bson.D{
+					{Key: "index", Value: int32(0)},
+					{Key: "code", Value: int32(11000)},
+					{Key: "errmsg", Value: "E11000 duplicate key error collection: app.migration_locks index: _id_"},
+				}}},
+				{Key: "ok", Value: 1.0},
+			}
+		case "find":
+			holder := bson.D{{Key: "_id", Value: "add-users"}, {Key: "owner", Value: "deploy-2"}, {Key: "expiresAt", Value: time.Now().Add(time.Minute)}}
+			return bson.D{
+				{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.migration_locks"}, {Key: "firstBatch", Value: bson.A{holder}}}},
+				{Key: "ok", Value: 1.0},
+			}
+		}
+		return nil
+	})
+
+	parser := mongoparser.NewParser(mongoparser.WithScriptLock("migration_locks", time.Minute))
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), lockedScript)
+	if !errors.Is(result.Error, mongoparser.ErrScriptLocked) || !strings.Contains(result.Error.Error(), "held by deploy-2") {
+		t.Fatalf("Expected the script to be refused while deploy-2 holds its lock, got %v", result.Error)
+	}
+	for _, cmd := range recorder.Commands() {
+		if cmd.Collection != "migration_locks" {
+			t.Errorf("Expected nothing to run without the lock, got %s on %s", cmd.Name, cmd.Collection)
+		}
+		if cmd.Name == "delete" {
+			t.Error("Expected a lock held by another runner not to be released")
+		}
+	}
+}
+
+func TestRecorderScriptLockTakeover(t *testing.T) {
+	recorder := NewRecorder(t)
+	released := make(chan struct{})
+	var once sync.Once
+	recorder.Reply(func(cmd Command) bson.D {
+		switch {
+		case cmd.Name == "delete" && cmd.Collection == "migration_locks":
+			once.Do(func() { close(released) })
+		case cmd.Name == "insert":
+			// Hold the script until it gives up its lock
+			select {
+			case <-released:
+			case <-time.After(10 * time.Second):
+			}
+		}
+		// The default reply matches no document, as if another runner owned the lock
+		return nil
+	})
+
+	// The TTL is raised to a second, so the heartbeat runs every third of one
+	parser := mongoparser.NewParser(mongoparser.WithScriptLock("migration_locks", time.Nanosecond))
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), lockedScript)
+	if result.Success || !strings.Contains(fmt.Sprint(result.Error), "lost script lock add-users: lock was taken over") {
+		t.Fatalf("Expected the script to stop when its lock was taken over, got %v", result.Error)
+	}
+
+	var heartbeats, deletes int
+	for _, cmd := range recorder.Commands() {
+		if cmd.Collection != "migration_locks" {
+			continue
+		}
+		switch cmd.Name {
+		case "update":
+			statement := firstStatement(t, cmd, "updates")
+			if _, err := statement.LookupErr("upsert"); err == nil {
+				continue
+			}
+			heartbeats++
+			if statement.Lookup("q", "owner").StringValue() == "" {
+				t.Errorf("Expected the heartbeat to extend only its own lock, got %s", statement)
+			}
+			if _, err := statement.LookupErr("u", "$set", "expiresAt"); err != nil {
+				t.Errorf("Expected the heartbeat to extend the lock, got %s", statement)
+			}
+		case "delete":
+			deletes++
+		}
+	}
+	if heartbeats != 1 || deletes != 1 {
+		t.Errorf("Expected one heartbeat and the lock to be released, got %d heartbeats and %d deletes", heartbeats, deletes)
+	}
+}
+
+const transferScript = `db.accounts.insertOne({ _id: "c", balance: 0 });
+const session = db.getMongo().startSession();
+session.withTransaction(() => {
+    db.accounts.updateOne({ _id: "a" }, { $inc: { balance: -10 } });
+    db.accounts.updateOne({ _id: "b" }, { $inc: { balance: 10 } });
+});
+session.endSession();
+db.accounts.countDocuments({});`
+
+// Returns the sessions of the commands with the given names, in order
+func commandSessions(t *testing.T, commands []Command, names ...string) []string {
+	t.Helper()
+	var sessions []string
+	for _, cmd := range commands {
+		if slices.Contains(names, cmd.Name) {
+			if cmd.Session == "" {
+				t.Errorf("Expected %s to run in a session", cmd.Name)
+			}
+			sessions = append(sessions, cmd.Session)
+		}
+	}
+	return sessions
+}
+
+func TestRecorderCausalConsistency(t *testing.T) {
+	recorder := NewRecorder(t)
+	parser := mongoparser.NewParser(mongoparser.WithCausalConsistency())
+
+	result := parser.ExecuteScript(t.Context(), recorder.Database("app"), transferScript)
+	if !result.Success {
+		t.Fatalf("Expected the script to run in a causally consistent session, got %v", result.Error)
+	}
+
+	sessions := commandSessions(t, recorder.Commands(), "insert", "update", "commitTransaction", "aggregate")
+	if len(sessions) != 5 {
+		t.Fatalf("Expected an insert, two updates, a commit and a count, got %d commands", len(sessions))
+	}
+	for _, session := range sessions {
+		if session != sessions[0] {
+			t.Errorf("Expected the script and its transaction to share one session, got %v", sessions)
+			break
+		}
+	}
+}
+
+func TestRecorderTransactionSession(t *testing.T) {
+	recorder := NewRecorder(t)
+
+	result := mongoparser.NewParser().ExecuteScript(t.Context(), recorder.Database("app"), transferScript)
+	if !result.Success {
+		t.Fatalf("Expected the transaction to run, got %v", result.Error)
+	}
+
+	commands := recorder.Commands()
+	sessions := commandSessions(t, commands, "update", "commitTransaction")
+	if len(sessions) != 3 || sessions[1] != sessions[0] || sessions[2] != sessions[0] {
+		t.Errorf("Expected the transaction to run in its own session, got %v", sessions)
+	}
+	for _, cmd := range commands {
+		if cmd.Name == "update" {
+			if cmd.Lookup("txnNumber").IsZero() || cmd.Lookup("autocommit").Type != bson.TypeBoolean {
+				t.Errorf("Expected the update to run in a transaction, got %s", cmd.Body)
+			}
+		}
+	}
+}
+
+func TestRecorderTransactionSessionDatabase(t *testing.T) {
+	recorder := NewRecorder(t)
+
+	result := mongoparser.NewParser().ExecuteScript(t.Context(), recorder.Database("bank"), `const session = db.getMongo().startSession();
+session.withTransaction(() => {
+    session.getDatabase("bank").accounts.updateOne({ _id: "a" }, { $inc: { balance: -10 } });
+    db.accounts.updateOne({ _id: "b" }, { $inc: { balance: 10 } });
+});`)
+	if !result.Success {
+		t.Fatalf("Expected session.getDatabase() naming the target database to run, got %v", result.Error)
+	}
+	updates := 0
+	for _, cmd := range recorder.Commands() {
+		if cmd.Name == "update" {
+			updates++
+			if cmd.Database != "bank" {
+				t.Errorf("Expected the update to run on bank, got %s", cmd.Database)
+			}
+		}
+	}
+	if updates != 2 {
+		t.Errorf("Expected both updates to run, got %d", updates)
+	}
+}
+
+func TestRecorderDependencyCheckPrefix(t *testing.T) {
+	recorder := NewRecorder(t)
+	recorder.Reply(func(cmd Command) bson.D {
+		if cmd.Name != "listCollections" {
+			return nil
+		}
+		batch := bson.A{bson.D{{Key: "name", Value: "dev_users"}, {Key: "type", Value: "collection"}}}
+		return bson.D{
+			{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: "app.$cmd.listCollections"}, {Key: "firstBatch", Value: batch}}},
+			{Key: "ok", Value: 1.0},
+		}
+	})
+	db := recorder.Database("app")
+
+	parser := mongoparser.NewParser(mongoparser.WithDependencyCheck(false), mongoparser.WithCollectionPrefix("dev_"))
+	result := parser.ExecuteScript(t.Context(), db, `db.users.createIndex({ email: 1 });`)
+	if !result.Success {
+		t.Fatalf("Expected the index on the existing prefixed collection to be allowed, got %v", result.Error)
+	}
+	var indexed []string
+	for _, cmd := range recorder.Commands() {
+		if cmd.Name == "createIndexes" {
+			indexed = append(indexed, cmd.Collection)
+		}
+	}
+	if !slices.Equal(indexed, []string{"dev_users"}) {
+		t.Errorf("Expected the index to be created on dev_users, got %v", indexed)
+	}
+
+	// Only dev_users exists, so the unprefixed name is missing
+	result = mongoparser.NewParser(mongoparser.WithDependencyCheck(false)).ExecuteScript(t.Context(), db, `db.users.createIndex({ email: 1 });`)
+	var dependencyErr *mongoparser.DependencyError
+	if !errors.As(result.Error, &dependencyErr) || len(dependencyErr.Issues) != 1 || dependencyErr.Issues[0].Collection != "users" {
+		t.Errorf("Expected the index on the missing collection to be reported, got %v", result.Error)
+	}
+}
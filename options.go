@@ -0,0 +1,414 @@
+package mongoparser
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Configures optional Parser behavior
+type Option func(*Parser)
+
+// Decides whether an operation that exceeds a configured document limit may proceed
+type ConfirmLimitFunc func(ctx context.Context, op MongoOperation, count int64) bool
+
+// Caps how many documents a deleteMany may remove. When the filter matches more
+// documents, confirm is asked whether to proceed; a nil confirm aborts the script.
+func WithDeleteLimit(limit int64, confirm ConfirmLimitFunc) Option {
+	return func(p *Parser) {
+		p.deleteLimit = limit
+		p.confirmDeleteLimit = confirm
+	}
+}
+
+// Caps how many documents an updateMany may match. When the filter matches more
+// documents, confirm is asked whether to proceed; a nil confirm aborts the script.
+func WithUpdateLimit(limit int64, confirm ConfirmLimitFunc) Option {
+	return func(p *Parser) {
+		p.updateLimit = limit
+		p.confirmUpdateLimit = confirm
+	}
+}
+
+// Decides what happens when a scripted collection or index already exists
+type ConflictPolicy int
+
+const (
+	// Leaves existing objects untouched; indexes that differ from the script are reported as drift
+	ConflictSkip ConflictPolicy = iota
+	// Fails the script as soon as a collection or index already exists
+	ConflictFail
+	// Drops and recreates objects that differ from the script. For collections this drops all data.
+	ConflictRecreate
+	// Reconciles existing objects in place with collMod, recreating indexes when collMod cannot apply the change
+	ConflictUpdate
+)
+
+// Returns the policy name
+func (c ConflictPolicy) String() string {
+	switch c {
+	case ConflictSkip:
+		return "skip"
+	case ConflictFail:
+		return "fail"
+	case ConflictRecreate:
+		return "recreate"
+	case ConflictUpdate:
+		return "update"
+	default:
+		return fmt.Sprintf("ConflictPolicy(%d)", int(c))
+	}
+}
+
+// Sets how createCollection handles collections that already exist
+func WithCollectionConflictPolicy(policy ConflictPolicy) Option {
+	return func(p *Parser) {
+		p.collectionPolicy = policy
+	}
+}
+
+// Sets how createIndex and createIndexes handle indexes that already exist
+func WithIndexConflictPolicy(policy ConflictPolicy) Option {
+	return func(p *Parser) {
+		p.indexPolicy = policy
+	}
+}
+
+// Reconciles indexes that differ from the script in keys or options by
+// dropping and recreating them, like WithIndexConflictPolicy(ConflictRecreate).
+// When confirm is not nil it is asked before each rebuild, with the
+// differences found, and a refusal fails the script leaving the index as it
+// is. The confirmation also applies when ConflictUpdate has to rebuild an index.
+func WithIndexReconciliation(confirm IndexRebuildConfirmFunc) Option {
+	return func(p *Parser) {
+		p.indexPolicy = ConflictRecreate
+		p.confirmRebuild = confirm
+	}
+}
+
+// Keeps the validators of existing collections in sync with the script by
+// applying the script's validator, validationLevel and validationAction with
+// collMod, like WithCollectionConflictPolicy(ConflictUpdate). Settings the
+// script leaves out keep the collection's current values. When confirm is not
+// nil it is asked before each update, with the differences found, and a
+// refusal fails the script leaving the validator as it is.
+func WithValidatorReconciliation(confirm ValidatorUpdateConfirmFunc) Option {
+	return func(p *Parser) {
+		p.collectionPolicy = ConflictUpdate
+		p.confirmValidator = confirm
+	}
+}
+
+// Names an index from the script's collection name and the index's key pattern
+type IndexNameFunc func(collection string, keys bson.D) string
+
+// Names the indexes a script leaves unnamed with name, so the same script
+// yields the same index names in every environment and drift detection
+// matches indexes by name. The collection is the name used in the script,
+// before WithCollectionPrefix and similar options apply. ConventionalIndexName
+// is a ready-made convention.
+func WithIndexNaming(name IndexNameFunc) Option {
+	return func(p *Parser) {
+		p.indexName = name
+	}
+}
+
+// Reads every index back after creating it and fails the script when the
+// server's key order differs from the script's. Compound index keys are
+// ordered, so { a: 1, b: 1 } and { b: 1, a: 1 } serve different queries.
+func WithIndexKeyVerification() Option {
+	return func(p *Parser) {
+		p.verifyIndexKeys = true
+	}
+}
+
+// Executes insertOne and insertMany as upserts keyed on the given top-level fields
+// (default _id), so re-running a seed script replaces its documents instead of
+// failing on duplicate keys. Documents missing a key field are inserted normally.
+func WithUpsertInserts(keys ...string) Option {
+	return func(p *Parser) {
+		if len(keys) == 0 {
+			keys = []string{"_id"}
+		}
+		p.upsertKeys = keys
+	}
+}
+
+// Receives progress of a chunked insert: done of total documents have been
+// written. total is 0 when an imported file's size in documents is unknown.
+type ProgressFunc func(op MongoOperation, done, total int)
+
+// Sends insertMany documents in chunks of at most size documents (default 1000),
+// never exceeding the 16MB message limit, and reports progress after each chunk.
+// progress may be nil.
+func WithInsertChunkSize(size int, progress ProgressFunc) Option {
+	return func(p *Parser) {
+		p.insertChunkSize = size
+		p.insertProgress = progress
+	}
+}
+
+// Caches up to size parsed scripts by content checksum so ExecuteScript can skip
+// re-parsing scripts it has already seen. Statistics are available from CacheStats.
+func WithParseCache(size int) Option {
+	return func(p *Parser) {
+		if size > 0 {
+			p.cache = newParseCache(size)
+		}
+	}
+}
+
+// Limits how many operations, counting each insert chunk separately, are sent per
+// second across all executions of the Parser, so backfills don't saturate the primary
+func WithRateLimit(opsPerSecond float64) Option {
+	return func(p *Parser) {
+		if opsPerSecond > 0 {
+			p.rateLimiter = newRateLimiter(opsPerSecond)
+		}
+	}
+}
+
+// Pauses for delay between consecutive operations and insert chunks of a script
+func WithOperationDelay(delay time.Duration) Option {
+	return func(p *Parser) {
+		p.operationDelay = delay
+	}
+}
+
+// Prepends prefix to every collection name the script touches, e.g. "tenantX_"
+func WithCollectionPrefix(prefix string) Option {
+	return func(p *Parser) {
+		p.collectionPrefix = prefix
+	}
+}
+
+// Appends suffix to every collection name the script touches
+func WithCollectionSuffix(suffix string) Option {
+	return func(p *Parser) {
+		p.collectionSuffix = suffix
+	}
+}
+
+// Renames the listed collections at execution time. Mapped names are used as is;
+// prefix and suffix only apply to collections without an entry.
+func WithCollectionMap(names map[string]string) Option {
+	return func(p *Parser) {
+		p.collectionMap = make(map[string]string, len(names))
+		for from, to := range names {
+			p.collectionMap[from] = to
+		}
+	}
+}
+
+// Holds an advisory lock in the given collection while a script executes, so two
+// runners can't apply the same script at once. Locks are keyed by the metadata
+// name (scripts without one share a single lock) and expire after ttl unless
+// the running script keeps extending them. A ttl under a second is raised to
+// one second.
+func WithScriptLock(collection string, ttl time.Duration) Option {
+	return func(p *Parser) {
+		if ttl <= 0 {
+			ttl = time.Minute
+		} else if ttl < minScriptLockTTL {
+			ttl = minScriptLockTTL
+		}
+		p.lockCollection = collection
+		p.lockTTL = ttl
+	}
+}
+
+// Records every executed operation with its sanitized arguments, outcome,
+// duration and the given actor in an audit collection of the target database.
+// Values of fields that look like secrets (password, token, ...) are redacted.
+func WithAuditLog(collection, actor string) Option {
+	return func(p *Parser) {
+		p.auditCollection = collection
+		p.auditActor = actor
+	}
+}
+
+// Persists a checkpoint in the given collection when a named script fails, so
+// the next execution of the same script skips the operations that already
+// completed instead of replaying them. The checkpoint is removed once the
+// script succeeds.
+func WithCheckpoints(collection string) Option {
+	return func(p *Parser) {
+		p.checkpointCollection = collection
+	}
+}
+
+// Collects compensating operations (drop created collections and indexes, delete
+// inserted documents) into ScriptResult.Rollback when a script fails part way.
+// With execute set they are run immediately to restore the pre-script state;
+// otherwise Parser.Rollback runs them on demand.
+func WithRollback(execute bool) Option {
+	return func(p *Parser) {
+		p.rollback = true
+		p.autoRollback = execute
+	}
+}
+
+// Refuses to execute scripts that are not signed with the private key matching
+// key, either through an embedded "// SIGNATURE:" line or a detached signature
+// passed to ExecuteSignedScript
+func WithSignatureKey(key ed25519.PublicKey) Option {
+	return func(p *Parser) {
+		p.signatureKey = key
+	}
+}
+
+// Checks the connected user's privileges against the whole script before running
+// it and fails with a *PermissionError listing every missing privilege
+func WithPermissionPreflight() Option {
+	return func(p *Parser) {
+		p.preflight = true
+	}
+}
+
+// Lets destructive operations (drop, dropIndexes, dropDatabase and deleteMany
+// with an empty filter) run without confirmation
+func WithAllowDestructive() Option {
+	return func(p *Parser) {
+		p.allowDestructive = true
+	}
+}
+
+// Asks confirm before running each destructive operation; it is refused when
+// confirm returns false
+func WithDestructiveConfirm(confirm ConfirmFunc) Option {
+	return func(p *Parser) {
+		p.confirmDestructiveOp = confirm
+	}
+}
+
+// Writes the output of print, printjson and console.log statements to w, one
+// line per statement. The rendered text is also part of ScriptResult.Output.
+// Concurrent executions share w.
+func WithPrintOutput(w io.Writer) Option {
+	return func(p *Parser) {
+		p.printOutput = w
+	}
+}
+
+// Executes watch statements by consuming their change stream for duration (or
+// until the context ends when duration is zero) and passing every event to
+// handler. Without this option watch statements are treated as
+// interactive-only and skipped with a warning.
+func WithChangeStream(duration time.Duration, handler ChangeEventFunc) Option {
+	return func(p *Parser) {
+		p.changeStreamDuration = duration
+		p.changeStreamHandler = handler
+	}
+}
+
+// Resolves the paths of file directives such as UPLOAD against dir and refuses
+// paths that leave it. Without a root, paths are relative to the working directory.
+func WithFileRoot(dir string) Option {
+	return func(p *Parser) {
+		p.fileRoot = dir
+	}
+}
+
+// Reads the files of directives such as UPLOAD and IMPORT from fsys, for example
+// an embed.FS, instead of the local disk. Paths are slash-separated and, with
+// WithFileRoot, relative to that directory of fsys.
+func WithFS(fsys fs.FS) Option {
+	return func(p *Parser) {
+		p.files = fsys
+	}
+}
+
+// Encrypts the configured fields, and fields marked with ENCRYPT directives,
+// of inserted documents client-side before they are sent
+func WithFieldEncryption(config FieldEncryption) Option {
+	return func(p *Parser) {
+		fields := make(map[string][]string, len(config.Fields))
+		for collection, paths := range config.Fields {
+			fields[collection] = append([]string(nil), paths...)
+		}
+		config.Fields = fields
+		p.encryption = &config
+	}
+}
+
+// Parses object literals with a JSON5 parser instead of normalizing them to
+// JSON, so strings containing colons, braces or quotes of the other kind are
+// kept intact. Comments, hexadecimal numbers, Infinity and NaN are accepted too.
+func WithJSON5() Option {
+	return func(p *Parser) {
+		p.json5 = true
+	}
+}
+
+// Decides how number literals in scripts are decoded
+type NumberMode int
+
+const (
+	// Decodes every number as float64, like the mongo shell's JavaScript numbers
+	NumberFloat64 NumberMode = iota
+	// Keeps integers of magnitude 2^53 or more, which float64 cannot represent
+	// exactly, as int64; other numbers stay float64
+	NumberInt64
+	// Like NumberInt64, and decodes numbers with a fraction or exponent as
+	// Decimal128 so they keep every digit
+	NumberDecimal128
+)
+
+// Sets how number literals in scripts are decoded. The default, NumberFloat64,
+// rounds large IDs such as 9007199254740993.
+func WithNumberMode(mode NumberMode) Option {
+	return func(p *Parser) {
+		p.numbers = mode
+	}
+}
+
+// Sets how Infinity, NaN and undefined values in scripts are handled. null
+// always stays null since filters rely on it.
+func WithSpecialValues(policy SpecialValuePolicy) Option {
+	return func(p *Parser) {
+		p.specialValues = policy
+	}
+}
+
+// Names the environment scripts run in, such as "production". Statements in
+// "// ENV:" sections run only when the section lists this environment; without
+// one, only statements outside sections run.
+func WithEnvironment(name string) Option {
+	return func(p *Parser) {
+		p.environment = name
+	}
+}
+
+// Overrides the severity of Lint rules by name; LintOff disables a rule
+func WithLintRules(severities map[string]LintSeverity) Option {
+	return func(p *Parser) {
+		p.lintSeverities = make(map[string]LintSeverity, len(severities))
+		for rule, severity := range severities {
+			p.lintSeverities[rule] = severity
+		}
+	}
+}
+
+// Pins the time new Date() and Date.now() evaluate to, so fixtures built from
+// them are reproducible. Without it expressions read the wall clock when their
+// operation executes.
+func WithFixedClock(t time.Time) Option {
+	return func(p *Parser) {
+		p.clock = func() time.Time { return t }
+	}
+}
+
+// Executes every operation of a script in one causally consistent session, so
+// reads such as counts and assertions observe the script's own earlier writes
+// even when they are served by a secondary. Pair it with majority read and
+// write concerns for the guarantee to hold across failovers.
+func WithCausalConsistency() Option {
+	return func(p *Parser) {
+		p.causalConsistency = true
+	}
+}
@@ -2,28 +2,103 @@ package mongoparser
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Handles parsing and execution of MongoDB JavaScript operations
-type Parser struct{}
+// Handles parsing and execution of MongoDB JavaScript operations. A Parser is
+// immutable once created and safe for concurrent use by multiple goroutines;
+// each execution keeps its own state and shared caches and limiters lock.
+type Parser struct {
+	deleteLimit          int64
+	updateLimit          int64
+	confirmUpdateLimit   ConfirmLimitFunc
+	confirmDeleteLimit   ConfirmLimitFunc
+	collectionPolicy     ConflictPolicy
+	indexPolicy          ConflictPolicy
+	upsertKeys           []string
+	metrics              Metrics
+	insertChunkSize      int
+	insertProgress       ProgressFunc
+	cache                *parseCache
+	rateLimiter          *rateLimiter
+	operationDelay       time.Duration
+	collectionPrefix     string
+	collectionSuffix     string
+	collectionMap        map[string]string
+	lockCollection       string
+	lockTTL              time.Duration
+	auditCollection      string
+	auditActor           string
+	checkpointCollection string
+	rollback             bool
+	autoRollback         bool
+	signatureKey         ed25519.PublicKey
+	preflight            bool
+	allowDestructive     bool
+	confirmDestructiveOp ConfirmFunc
+	printOutput          io.Writer
+	fileRoot             string
+	files                fs.FS
+	encryption           *FieldEncryption
+	causalConsistency    bool
+	changeStreamHandler  ChangeEventFunc
+	changeStreamDuration time.Duration
+	clock                func() time.Time
+	json5                bool
+	numbers              NumberMode
+	specialValues        SpecialValuePolicy
+	environment          string
+	lintSeverities       map[string]LintSeverity
+	middleware           []Middleware
+	customOperations     map[string]*customOperation
+	functions            map[string]ScriptFunc
+	operationComments    bool
+	cacheExistence       bool
+	dependencyCheck      bool
+	verifyIndexKeys      bool
+	indexName            IndexNameFunc
+	confirmRebuild       IndexRebuildConfirmFunc
+	confirmValidator     ValidatorUpdateConfirmFunc
+	reorderDependencies  bool
+	profiler             bool
+	profileThreshold     time.Duration
+
+	// Set only on the per-execution copy made by ExecuteScript
+	warnings  *[]string
+	undo      *[]MongoOperation
+	variables map[string]interface{}
+	existence *existenceCache
+	// Encrypted field paths per collection, including ENCRYPT directives seen so far
+	encryptedFields map[string][]string
+}
 
 // Creates a new MongoDB JavaScript parser
-func NewParser() *Parser {
-	return &Parser{}
+func NewParser(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Extracts metadata from script comments
 func (p *Parser) ParseMetadata(content string) *ScriptMetadata {
-	lines := strings.Split(content, "\n")
+	lines := strings.Split(normalizeContent(content), "\n")
 	var metadataLines []string
 
 	// Look for JSON metadata in comments at the start of the file
@@ -31,6 +106,14 @@ func (p *Parser) ParseMetadata(content string) *ScriptMetadata {
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 
+		// The signature line is never part of the metadata block
+		if strings.HasPrefix(line, signaturePrefix) {
+			if inMetadata {
+				break
+			}
+			continue
+		}
+
 		if strings.HasPrefix(line, "// METADATA:") {
 			inMetadata = true
 			continue
@@ -59,45 +142,345 @@ func (p *Parser) ParseMetadata(content string) *ScriptMetadata {
 	jsonStr := strings.Join(metadataLines, "")
 	var metadata ScriptMetadata
 	if err := json.Unmarshal([]byte(jsonStr), &metadata); err != nil {
-		log.Printf("Warning: failed to parse script metadata: %v", err)
+		p.warnf("failed to parse script metadata: %v", err)
 		return nil
 	}
 
 	return &metadata
 }
 
+// Parses a script into its operations without executing them. Statements that
+// are not supported or cannot be parsed are an error rather than a warning.
+func (p *Parser) ParseScript(js string) ([]MongoOperation, error) {
+	run := *p
+	run.warnings = &[]string{}
+	ops, err := run.parseJavaScriptOperations(js)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	for _, warning := range *run.warnings {
+		if lintWarningRule(warning) != LintParserWarning {
+			return nil, errors.New(warning)
+		}
+	}
+	return ops, nil
+}
+
 // Executes JavaScript content by parsing and converting to Go MongoDB operations
 func (p *Parser) ExecuteScript(ctx context.Context, db *mongo.Database, jsContent string) ScriptResult {
-	if len(strings.TrimSpace(jsContent)) == 0 {
+	if p.signatureKey != nil {
+		if err := p.VerifyScript(jsContent, nil); err != nil {
+			return ScriptResult{Error: err, StartedAt: time.Now()}
+		}
+	}
+	return p.executeContent(ctx, db, jsContent)
+}
+
+// Executes a script after verifying it against a detached signature
+func (p *Parser) ExecuteSignedScript(ctx context.Context, db *mongo.Database, jsContent string, signature []byte) ScriptResult {
+	if err := p.VerifyScript(jsContent, signature); err != nil {
+		return ScriptResult{Error: err, StartedAt: time.Now()}
+	}
+	return p.executeContent(ctx, db, jsContent)
+}
+
+// Executes a script read from r statement by statement, so memory use stays
+// bounded by the largest statement rather than the size of the script. When
+// signatures, a permission preflight or a dependency check are required the
+// script is read in full and checked first.
+func (p *Parser) ExecuteReader(ctx context.Context, db *mongo.Database, r io.Reader) ScriptResult {
+	if p.signatureKey != nil || p.preflight || p.dependencyCheck {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return ScriptResult{Error: fmt.Errorf("failed to read script: %w", err), StartedAt: time.Now()}
+		}
+		return p.ExecuteScript(ctx, db, string(content))
+	}
+	return p.executeReader(ctx, db, r)
+}
+
+// Executes verified script content, from the parse cache when enabled
+func (p *Parser) executeContent(ctx context.Context, db *mongo.Database, jsContent string) ScriptResult {
+	if p.preflight {
+		if err := p.Preflight(ctx, db, jsContent); err != nil {
+			return ScriptResult{Error: err, StartedAt: time.Now()}
+		}
+	}
+	if p.cache == nil && !p.dependencyCheck {
+		return p.executeReader(ctx, db, strings.NewReader(jsContent))
+	}
+
+	run := *p
+	run.warnings = &[]string{}
+	var script *parsedScript
+	if p.cache != nil {
+		script = run.cachedScript(jsContent)
+	} else {
+		script = run.parseContent(jsContent)
+	}
+	if p.dependencyCheck {
+		ordered, err := run.checkDependencies(ctx, db, script.operations)
+		if err != nil {
+			return ScriptResult{Error: err, StartedAt: time.Now(), Warnings: *run.warnings}
+		}
+		// Cached scripts are shared, so the reordered operations go into a copy
+		reordered := *script
+		reordered.operations = ordered
+		script = &reordered
+	}
+	result := run.profileScript(ctx, db, script.source())
+	result.Warnings = *run.warnings
+	return result
+}
+
+// Streams a verified script from r
+func (p *Parser) executeReader(ctx context.Context, db *mongo.Database, r io.Reader) ScriptResult {
+	// Per-execution state lives on a copy so one Parser can serve many executions
+	run := *p
+	run.warnings = &[]string{}
+	result := run.profileScript(ctx, db, &statementSource{parser: &run, scanner: newStatementScanner(r)})
+	result.Warnings = *run.warnings
+	return result
+}
+
+// Runs a script on a per-execution copy of the parser
+func (p *Parser) executeScript(ctx context.Context, db *mongo.Database, script operationSource) ScriptResult {
+	startedAt := time.Now()
+	if script.empty() {
+		if err := script.err(); err != nil {
+			return ScriptResult{
+				Error:     fmt.Errorf("failed to read script: %w", err),
+				StartedAt: startedAt,
+			}
+		}
 		return ScriptResult{
-			Success: true,
-			Output:  "Script is empty, skipped",
+			Success:   true,
+			Output:    "Script is empty, skipped",
+			StartedAt: startedAt,
 		}
 	}
 
-	operations, err := p.parseJavaScriptOperations(jsContent)
+	metadata := p.ParseMetadata(script.header())
+	result := ScriptResult{StartedAt: startedAt}
+	if metadata != nil {
+		result.Name = metadata.Name
+		result.Version = metadata.Version
+	}
+
+	defaults, err := p.parseConcernDefaults(metadata)
 	if err != nil {
-		return ScriptResult{
-			Success: false,
-			Error:   fmt.Errorf("failed to parse JavaScript operations: %w", err),
+		result.Error = err
+		result.Duration = time.Since(startedAt)
+		return result
+	}
+
+	if p.lockCollection != "" {
+		lockCtx, release, err := p.acquireScriptLock(ctx, db, result.Name)
+		if err != nil {
+			result.Error = err
+			result.Duration = time.Since(startedAt)
+			return result
 		}
+		defer release()
+		ctx = lockCtx
+	}
+
+	if p.causalConsistency {
+		session, err := db.Client().StartSession(options.Session().SetCausalConsistency(true))
+		if err != nil {
+			result.Error = fmt.Errorf("failed to start causally consistent session: %w", err)
+			result.Duration = time.Since(startedAt)
+			return result
+		}
+		defer session.EndSession(context.WithoutCancel(ctx))
+		ctx = mongo.NewSessionContext(ctx, session)
+	}
+
+	if p.rollback {
+		p.undo = &[]MongoOperation{}
+		if len(p.upsertKeys) > 0 {
+			p.warnf("inserts run as upserts and are not rolled back")
+		}
+	}
+
+	p.variables = map[string]interface{}{}
+	if p.cacheExistence {
+		p.existence = newExistenceCache()
+	}
+	p.encryptedFields = p.configuredEncryptedFields()
+
+	checkpoints, err := p.openCheckpoints(ctx, db, result.Name)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(startedAt)
+		return result
 	}
 
 	var results []interface{}
-	for _, op := range operations {
-		result, err := p.executeMongoOperation(ctx, db, op)
+	for {
+		op := script.next()
+		if op == nil {
+			break
+		}
+
+		skip, err := checkpoints.skip(op)
 		if err != nil {
-			return ScriptResult{
-				Success: false,
-				Error:   fmt.Errorf("failed to execute operation %s on %s: %w", op.Operation, op.Collection, err),
+			result.Error = err
+			result.Duration = time.Since(startedAt)
+			return result
+		}
+		if skip {
+			result.Operations = append(result.Operations, OperationResult{
+				Type:       op.Type,
+				Collection: p.collectionName(op.Collection),
+				Operation:  op.Operation,
+				Location:   op.Location,
+				Status:     StatusSkipped,
+				Result:     "completed in a previous run",
+			})
+			continue
+		}
+		if op.Ignored {
+			result.Operations = append(result.Operations, OperationResult{
+				Type:       op.Type,
+				Collection: p.collectionName(op.Collection),
+				Operation:  op.Operation,
+				Location:   op.Location,
+				Status:     StatusSkipped,
+				Result:     "ignored by annotation",
+			})
+			checkpoints.advance(op)
+			continue
+		}
+
+		defaults.apply(op)
+		op.Collection = p.collectionName(op.Collection)
+		opStart := time.Now()
+		*op, err = p.applyMiddleware(*op)
+		if p.operationComments && op.Comment == "" {
+			op.Comment = operationComment(result.Name, op.Location)
+		}
+		if err == nil && !isShellOperation(*op) {
+			err = p.throttle(ctx, len(result.Operations) == 0)
+		}
+		var output interface{}
+		if err == nil {
+			output, err = p.executeWithTimeout(ctx, db, *op)
+		}
+		opResult := OperationResult{
+			Type:       op.Type,
+			Collection: op.Collection,
+			Operation:  op.Operation,
+			Location:   op.Location,
+			Status:     StatusSucceeded,
+			Duration:   time.Since(opStart),
+		}
+		if skip, ok := output.(skipped); ok {
+			output = string(skip)
+			opResult.Status = StatusSkipped
+		}
+		opResult.Result = output
+		if err == nil {
+			opResult.Documents = documentCount(*op, output)
+			opResult.IndexKeys = indexKeys(*op)
+		}
+		if err != nil {
+			// Surface why the context was cancelled, e.g. a lost script lock
+			if cause := context.Cause(ctx); cause != nil && !errors.Is(err, cause) {
+				err = fmt.Errorf("%w: %v", err, cause)
 			}
+			opResult.Status = StatusFailed
+			opResult.Error = err
+			if !isShellOperation(*op) {
+				p.recordMetrics(opResult)
+				p.auditOperation(ctx, db, result, *op, opResult)
+			}
+			if op.ContinueOnError && ctx.Err() == nil {
+				p.warnf("%s on %s failed, continuing as annotated: %v", op.Operation, op.Collection, err)
+				checkpoints.advance(op)
+				result.Operations = append(result.Operations, opResult)
+				continue
+			}
+			result.Rollback = p.rollbackPlan()
+			if p.autoRollback && len(result.Rollback) > 0 {
+				// Roll back even when the failure was a cancelled context
+				result.RollbackError = p.Rollback(context.WithoutCancel(ctx), db, result)
+				result.RolledBack = result.RollbackError == nil
+			}
+			if !result.RolledBack {
+				checkpoints.fail(ctx, err)
+			}
+			result.Operations = append(result.Operations, opResult)
+			result.Operations = append(result.Operations, p.remainingOperations(script)...)
+			result.Error = newOperationError(*op, err)
+			result.Duration = time.Since(startedAt)
+			return result
+		}
+		if !isShellOperation(*op) {
+			p.recordMetrics(opResult)
+			p.auditOperation(ctx, db, result, *op, opResult)
 		}
-		results = append(results, result)
+		if op.Variable != "" {
+			p.variables[op.Variable] = output
+		}
+		checkpoints.advance(op)
+		result.Operations = append(result.Operations, opResult)
+		results = append(results, output)
+	}
+
+	if err := script.err(); err != nil {
+		result.Error = fmt.Errorf("failed to read script: %w", err)
+		result.Duration = time.Since(startedAt)
+		return result
+	}
+
+	if err := checkpoints.finish(ctx); err != nil {
+		result.Error = err
+		result.Duration = time.Since(startedAt)
+		return result
+	}
+
+	result.Success = true
+	result.Output = results
+	result.Duration = time.Since(startedAt)
+	return result
+}
+
+// Executes an operation, bounded by its annotated timeout when it has one
+func (p *Parser) executeWithTimeout(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if op.Timeout <= 0 {
+		return p.executeMongoOperation(ctx, db, op)
+	}
+	opCtx, cancel := context.WithTimeout(ctx, op.Timeout)
+	defer cancel()
+	output, err := p.executeMongoOperation(opCtx, db, op)
+	if err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", op.Timeout, err)
+	}
+	return output, err
+}
+
+// Collects the operations left after a failure so they can be reported as not run
+func (p *Parser) remainingOperations(script operationSource) []OperationResult {
+	var remaining []OperationResult
+	for op := script.next(); op != nil; op = script.next() {
+		remaining = append(remaining, OperationResult{
+			Type:       op.Type,
+			Collection: p.collectionName(op.Collection),
+			Operation:  op.Operation,
+			Location:   op.Location,
+			Status:     StatusNotRun,
+		})
 	}
+	return remaining
+}
 
-	return ScriptResult{
-		Success: true,
-		Output:  results,
+// Logs a warning and records it on the current execution, if any
+func (p *Parser) warnf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("Warning: %s", message)
+	if p.warnings != nil {
+		*p.warnings = append(*p.warnings, message)
 	}
 }
 
@@ -105,29 +488,68 @@ func (p *Parser) ExecuteScript(ctx context.Context, db *mongo.Database, jsConten
 func (p *Parser) parseJavaScriptOperations(jsContent string) ([]MongoOperation, error) {
 	var operations []MongoOperation
 
-	// First, split the content into complete statements that may span multiple lines
-	statements := p.splitIntoStatements(jsContent)
+	// Statements may span multiple lines; the scanner joins them before parsing
+	script := &statementSource{parser: p, scanner: newStatementScanner(strings.NewReader(jsContent))}
+	for op := script.next(); op != nil; op = script.next() {
+		operations = append(operations, *op)
+	}
+
+	return operations, script.err()
+}
 
-	for _, statement := range statements {
-		statement = strings.TrimSpace(statement)
-		if statement == "" || strings.HasPrefix(statement, "//") {
-			continue
+// Parses a single script statement, warning about and skipping ones that are
+// not MongoDB operations or cannot be parsed
+func (p *Parser) parseStatement(statement string) *MongoOperation {
+	statement = strings.TrimSpace(statement)
+	if isDirective(statement) {
+		op, err := p.parseDirective(statement)
+		if err != nil {
+			p.warnf("failed to parse directive '%s': %v", statement, err)
+			return nil
 		}
+		op.Statement = statement
+		return op
+	}
+	if statement == "" || strings.HasPrefix(statement, "//") {
+		return nil
+	}
 
-		// Parse db.collection.operation() patterns
-		if strings.HasPrefix(statement, "db.") && strings.Contains(statement, "(") {
-			op, err := p.parseMongoStatement(statement)
-			if err != nil {
-				log.Printf("Warning: failed to parse statement '%s': %v", statement, err)
-				continue
-			}
-			if op != nil {
-				operations = append(operations, *op)
-			}
+	// var/let/const bind the result of an operation for later assertions
+	if match := assignmentPattern.FindStringSubmatch(statement); match != nil {
+		if startSessionPattern.MatchString(strings.TrimSuffix(match[2], ";")) {
+			return nil
+		}
+		op := p.parseStatement(match[2])
+		if op != nil {
+			op.Variable = match[1]
+			op.Statement = statement
+		}
+		return op
+	}
+
+	if op, handled, err := p.parseShellStatement(statement); handled {
+		if err != nil {
+			p.warnf("failed to parse statement '%s': %v", statement, err)
+			return nil
 		}
+		op.Statement = statement
+		return op
 	}
 
-	return operations, nil
+	// Parse db.collection.operation() patterns
+	if !strings.HasPrefix(statement, "db.") || !strings.Contains(statement, "(") {
+		return nil
+	}
+
+	op, err := p.parseMongoStatement(statement)
+	if err != nil {
+		p.warnf("failed to parse statement '%s': %v", statement, err)
+		return nil
+	}
+	if op != nil {
+		op.Statement = statement
+	}
+	return op
 }
 
 // Parses createIndex operation
@@ -143,49 +565,221 @@ func (p *Parser) parseCreateIndex(collection, argsString string) (*MongoOperatio
 	if len(args) > 0 {
 		indexSpecStr := strings.TrimSpace(args[0])
 
-		// Convert to bson.D for proper index specification
-		var indexSpecMap map[string]interface{}
-		if err := p.parseJSONLikeString(indexSpecStr, &indexSpecMap); err != nil {
+		// Parse into bson.D so compound index field order is preserved
+		var rawSpec bson.D
+		if err := p.parseJSONLikeString(indexSpecStr, &rawSpec); err != nil {
 			return nil, fmt.Errorf("failed to parse index specification: %w", err)
 		}
 
-		// Convert map to bson.D to preserve field order for indexes
-		var indexSpec bson.D
-		for key, value := range indexSpecMap {
-			// Ensure numeric values are properly typed
-			if numValue, err := p.convertToNumber(value); err == nil {
-				indexSpec = append(indexSpec, bson.E{Key: key, Value: numValue})
-			} else {
-				indexSpec = append(indexSpec, bson.E{Key: key, Value: value})
-			}
+		indexSpec, err := p.parseIndexSpec(rawSpec)
+		if err != nil {
+			return nil, err
 		}
 		op.IndexSpec = indexSpec
 
 		// Parse index options if provided
 		if len(args) > 1 {
-			var indexOptions map[string]interface{}
-			if err := p.parseJSONLikeString(strings.TrimSpace(args[1]), &indexOptions); err != nil {
-				log.Printf("Warning: failed to parse index options: %v", err)
+			indexOptions, err := p.parseOptionsDocument(strings.TrimSpace(args[1]))
+			if err != nil {
+				p.warnf("failed to parse index options: %v", err)
 			} else {
-				opts := options.Index()
-				if unique, ok := indexOptions["unique"]; ok {
-					if uniqueBool, ok := unique.(bool); ok {
-						opts.SetUnique(uniqueBool)
-					}
-				}
-				if name, ok := indexOptions["name"]; ok {
-					if nameStr, ok := name.(string); ok {
-						opts.SetName(nameStr)
-					}
+				opts, err := p.parseIndexOptions(indexOptions, op)
+				if err != nil {
+					return nil, err
 				}
 				op.IndexOptions = opts
 			}
 		}
+		op.IndexOptions = p.nameIndex(collection, indexSpec, op.IndexOptions)
+
+		if err := p.parseCommitQuorumArgument(args, op); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
+
+// Parses createIndexes operation with an array of index specifications
+func (p *Parser) parseCreateIndexes(collection, argsString string) (*MongoOperation, error) {
+	op := &MongoOperation{
+		Type:       "createIndexes",
+		Collection: collection,
+		Operation:  "createIndexes",
+	}
+
+	args := p.splitArguments(argsString)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("createIndexes requires an array of index specifications")
+	}
+
+	var rawSpecs []bson.D
+	if err := p.parseJSONLikeString(strings.TrimSpace(args[0]), &rawSpecs); err != nil {
+		return nil, fmt.Errorf("failed to parse index specifications: %w", err)
+	}
+	if len(rawSpecs) == 0 {
+		return nil, fmt.Errorf("createIndexes requires at least one index specification")
+	}
+
+	// Options passed to createIndexes apply to every index in the array
+	var opts *options.IndexOptions
+	if len(args) > 1 {
+		indexOptions, err := p.parseOptionsDocument(strings.TrimSpace(args[1]))
+		if err != nil {
+			p.warnf("failed to parse index options: %v", err)
+		} else {
+			parsed, err := p.parseIndexOptions(indexOptions, op)
+			if err != nil {
+				return nil, err
+			}
+			opts = parsed
+		}
+	}
+
+	for _, rawSpec := range rawSpecs {
+		indexSpec, err := p.parseIndexSpec(rawSpec)
+		if err != nil {
+			return nil, err
+		}
+		op.IndexModels = append(op.IndexModels, mongo.IndexModel{
+			Keys:    indexSpec,
+			Options: p.nameIndex(collection, indexSpec, opts),
+		})
+	}
+
+	if err := p.parseCommitQuorumArgument(args, op); err != nil {
+		return nil, err
 	}
 
 	return op, nil
 }
 
+// Converts a parsed index specification into bson.D with properly typed values
+func (p *Parser) parseIndexSpec(rawSpec bson.D) (bson.D, error) {
+	indexSpec := make(bson.D, 0, len(rawSpec))
+	hashedFields := 0
+	for _, elem := range rawSpec {
+		// Ensure numeric values are properly typed and index types are kept as strings
+		keyValue, err := p.convertIndexKeyValue(elem.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index key '%s': %w", elem.Key, err)
+		}
+		if keyValue == "hashed" {
+			hashedFields++
+		}
+		indexSpec = append(indexSpec, bson.E{Key: elem.Key, Value: keyValue})
+	}
+	if hashedFields > 1 {
+		return nil, fmt.Errorf("index specification may contain only one hashed field")
+	}
+	return indexSpec, nil
+}
+
+// Converts a parsed index options document into driver index options
+func (p *Parser) parseIndexOptions(indexOptions map[string]interface{}, op *MongoOperation) (*options.IndexOptions, error) {
+	opts := options.Index()
+	if unique, ok := indexOptions["unique"]; ok {
+		if uniqueBool, ok := unique.(bool); ok {
+			opts.SetUnique(uniqueBool)
+		}
+	}
+	if name, ok := indexOptions["name"]; ok {
+		if nameStr, ok := name.(string); ok {
+			opts.SetName(nameStr)
+		}
+	}
+	if sparse, ok := indexOptions["sparse"]; ok {
+		if sparseBool, ok := sparse.(bool); ok {
+			opts.SetSparse(sparseBool)
+		}
+	}
+	if filter, ok := indexOptions["partialFilterExpression"]; ok {
+		if filterDoc, ok := filter.(bson.D); ok {
+			opts.SetPartialFilterExpression(filterDoc)
+		} else {
+			p.warnf("ignoring partialFilterExpression, expected a document")
+		}
+	}
+	if expire, ok := indexOptions["expireAfterSeconds"]; ok {
+		seconds, err := p.convertToInt64(expire)
+		if err != nil || seconds < 0 || seconds > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid expireAfterSeconds %v", expire)
+		}
+		opts.SetExpireAfterSeconds(int32(seconds))
+	}
+	if collationValue, ok := indexOptions["collation"]; ok {
+		collation, err := p.parseCollation(collationValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse index collation: %w", err)
+		}
+		opts.SetCollation(collation)
+	}
+	if hidden, ok := indexOptions["hidden"]; ok {
+		if hiddenBool, ok := hidden.(bool); ok {
+			opts.SetHidden(hiddenBool)
+		}
+	}
+	if background, ok := indexOptions["background"]; ok {
+		if backgroundBool, ok := background.(bool); ok {
+			// Ignored by MongoDB 4.2+, but kept so older servers behave as scripted
+			p.warnf("index option 'background' is deprecated since MongoDB 4.2")
+			opts.SetBackground(backgroundBool)
+		}
+	}
+	if err := p.parseConcernOptions(indexOptions, op); err != nil {
+		return nil, err
+	}
+	if quorum, ok := indexOptions["commitQuorum"]; ok {
+		createOpts, err := p.parseCommitQuorum(quorum)
+		if err != nil {
+			return nil, err
+		}
+		op.CreateIndexesOptions = createOpts
+	}
+	return opts, nil
+}
+
+// Parses the optional third commitQuorum argument accepted by mongosh
+func (p *Parser) parseCommitQuorumArgument(args []string, op *MongoOperation) error {
+	if len(args) < 3 {
+		return nil
+	}
+
+	var quorum interface{}
+	if err := p.parseJSONLikeString(strings.TrimSpace(args[2]), &quorum); err != nil {
+		return fmt.Errorf("failed to parse commitQuorum: %w", err)
+	}
+	createOpts, err := p.parseCommitQuorum(quorum)
+	if err != nil {
+		return err
+	}
+	op.CreateIndexesOptions = createOpts
+	return nil
+}
+
+// Converts a commitQuorum value into createIndexes options
+func (p *Parser) parseCommitQuorum(value interface{}) (*options.CreateIndexesOptions, error) {
+	opts := options.CreateIndexes()
+	switch v := value.(type) {
+	case string:
+		switch v {
+		case "majority":
+			opts.SetCommitQuorumMajority()
+		case "votingMembers":
+			opts.SetCommitQuorumVotingMembers()
+		default:
+			opts.SetCommitQuorumString(v)
+		}
+	default:
+		quorum, err := p.convertToInt64(v)
+		if err != nil || quorum < 0 || quorum > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid commitQuorum %v, expected a string or a non-negative integer", value)
+		}
+		opts.SetCommitQuorumInt(int32(quorum))
+	}
+	return opts, nil
+}
+
 // Attempts to convert a value to the appropriate numeric type
 func (p *Parser) convertToNumber(value interface{}) (interface{}, error) {
 	switch v := value.(type) {
@@ -207,6 +801,12 @@ func (p *Parser) convertToNumber(value interface{}) (interface{}, error) {
 			return int(v), nil
 		}
 		return v, nil
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a number")
+		}
+		return p.convertToNumber(f)
 	case int, int32, int64:
 		return v, nil
 	default:
@@ -214,6 +814,51 @@ func (p *Parser) convertToNumber(value interface{}) (interface{}, error) {
 	}
 }
 
+// Converts a numeric value to int64, rejecting fractional values
+func (p *Parser) convertToInt64(value interface{}) (int64, error) {
+	num, err := p.convertToNumber(value)
+	if err != nil {
+		return 0, err
+	}
+	switch v := num.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %v", value)
+	}
+}
+
+// Index types that are specified by name instead of a sort direction
+var indexTypeNames = map[string]bool{
+	"hashed":      true,
+	"text":        true,
+	"2d":          true,
+	"2dsphere":    true,
+	"geoHaystack": true,
+}
+
+// Converts an index key value to a sort direction or a named index type
+func (p *Parser) convertIndexKeyValue(value interface{}) (interface{}, error) {
+	if str, ok := value.(string); ok && indexTypeNames[str] {
+		return str, nil
+	}
+
+	numValue, err := p.convertToNumber(value)
+	if err != nil {
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported index value %v", value)
+		}
+		p.warnf("unknown index type '%s', passing it through unchanged", str)
+		return str, nil
+	}
+	return numValue, nil
+}
+
 // Parses insert operations
 func (p *Parser) parseInsert(collection, operation, argsString string) (*MongoOperation, error) {
 	op := &MongoOperation{
@@ -222,12 +867,51 @@ func (p *Parser) parseInsert(collection, operation, argsString string) (*MongoOp
 		Operation:  operation,
 	}
 
-	var document bson.M
-	if err := p.parseJSONLikeString(argsString, &document); err != nil {
-		return nil, fmt.Errorf("failed to parse insert document: %w", err)
+	args := p.splitArguments(argsString)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s requires a document", operation)
+	}
+
+	documentsStr := strings.TrimSpace(args[0])
+	if operation == "insertMany" && strings.HasPrefix(documentsStr, "[") {
+		var documents []bson.D
+		if err := p.parseJSONLikeString(documentsStr, &documents); err != nil {
+			return nil, fmt.Errorf("failed to parse insert documents: %w", err)
+		}
+		if len(documents) == 0 {
+			return nil, fmt.Errorf("insertMany requires at least one document")
+		}
+		op.Arguments = documents
+	} else {
+		var document bson.D
+		if err := p.parseJSONLikeString(documentsStr, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse insert document: %w", err)
+		}
+		op.Arguments = []bson.D{document}
+	}
+
+	// Parse insert options if provided
+	if len(args) > 1 {
+		insertOptions, err := p.parseOptionsDocument(strings.TrimSpace(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse insert options: %w", err)
+		}
+		if err := p.parseConcernOptions(insertOptions, op); err != nil {
+			return nil, err
+		}
+		if operation == "insertMany" {
+			opts := options.InsertMany()
+			if ordered, ok := insertOptions["ordered"]; ok {
+				orderedBool, ok := ordered.(bool)
+				if !ok {
+					return nil, fmt.Errorf("insertMany ordered option must be a boolean")
+				}
+				opts.SetOrdered(orderedBool)
+			}
+			op.InsertManyOptions = opts
+		}
 	}
 
-	op.Arguments = []bson.M{document}
 	return op, nil
 }
 
@@ -245,18 +929,96 @@ func (p *Parser) parseUpdate(collection, operation, argsString string) (*MongoOp
 		return nil, fmt.Errorf("update operation requires at least 2 arguments")
 	}
 
-	var filter, update bson.M
+	var filter bson.D
 	if err := p.parseJSONLikeString(args[0], &filter); err != nil {
 		return nil, fmt.Errorf("failed to parse update filter: %w", err)
 	}
-	if err := p.parseJSONLikeString(args[1], &update); err != nil {
-		return nil, fmt.Errorf("failed to parse update document: %w", err)
+
+	// The update is either a document of operators or an aggregation pipeline
+	updateStr := strings.TrimSpace(args[1])
+	if strings.HasPrefix(updateStr, "[") {
+		var pipeline []bson.D
+		if err := p.parseJSONLikeString(updateStr, &pipeline); err != nil {
+			return nil, fmt.Errorf("failed to parse update pipeline: %w", err)
+		}
+		if len(pipeline) == 0 {
+			return nil, fmt.Errorf("update pipeline requires at least one stage")
+		}
+		op.Arguments = []bson.D{filter}
+		op.UpdatePipeline = pipeline
+	} else {
+		var update bson.D
+		if err := p.parseJSONLikeString(updateStr, &update); err != nil {
+			return nil, fmt.Errorf("failed to parse update document: %w", err)
+		}
+		op.Arguments = []bson.D{filter, update}
+	}
+
+	// Parse update options if provided
+	if len(args) > 2 {
+		updateOptions, err := p.parseOptionsDocument(args[2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse update options: %w", err)
+		}
+		opts, err := p.parseUpdateOptions(updateOptions)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.parseConcernOptions(updateOptions, op); err != nil {
+			return nil, err
+		}
+		op.UpdateOptions = opts
 	}
 
-	op.Arguments = []bson.M{filter, update}
 	return op, nil
 }
 
+// Converts a parsed update options document into driver update options
+func (p *Parser) parseUpdateOptions(updateOptions map[string]interface{}) (*options.UpdateOptions, error) {
+	opts := options.Update()
+	if upsert, ok := updateOptions["upsert"]; ok {
+		upsertBool, ok := upsert.(bool)
+		if !ok {
+			return nil, fmt.Errorf("update upsert option must be a boolean")
+		}
+		opts.SetUpsert(upsertBool)
+	}
+	if arrayFilters, ok := updateOptions["arrayFilters"]; ok {
+		filters, ok := arrayFilters.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("update arrayFilters option must be an array")
+		}
+		opts.SetArrayFilters(options.ArrayFilters{Filters: []interface{}(filters)})
+	}
+	if hint, ok := updateOptions["hint"]; ok {
+		parsedHint, err := p.parseHint(hint)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetHint(parsedHint)
+	}
+	if collationValue, ok := updateOptions["collation"]; ok {
+		collation, err := p.parseCollation(collationValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse update collation: %w", err)
+		}
+		opts.SetCollation(collation)
+	}
+	return opts, nil
+}
+
+// Converts a hint given as an index name or key pattern
+func (p *Parser) parseHint(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bson.D:
+		return p.parseIndexSpec(v)
+	default:
+		return nil, fmt.Errorf("hint must be an index name or key pattern")
+	}
+}
+
 // Parses delete operations
 func (p *Parser) parseDelete(collection, operation, argsString string) (*MongoOperation, error) {
 	op := &MongoOperation{
@@ -265,70 +1027,46 @@ func (p *Parser) parseDelete(collection, operation, argsString string) (*MongoOp
 		Operation:  operation,
 	}
 
-	var filter bson.M
-	if err := p.parseJSONLikeString(argsString, &filter); err != nil {
-		return nil, fmt.Errorf("failed to parse delete filter: %w", err)
+	args := p.splitArguments(argsString)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s requires a filter document", operation)
 	}
 
-	op.Arguments = []bson.M{filter}
-	return op, nil
-}
+	var filter bson.D
+	if err := p.parseJSONLikeString(args[0], &filter); err != nil {
+		return nil, fmt.Errorf("failed to parse delete filter: %w", err)
+	}
 
-// Splits JavaScript content into complete statements
-func (p *Parser) splitIntoStatements(jsContent string) []string {
-	var statements []string
-	var current strings.Builder
-	braceLevel := 0
-	inQuotes := false
-	var quoteChar rune
+	op.Arguments = []bson.D{filter}
 
-	lines := strings.Split(jsContent, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "//") {
-			continue
+	// Parse delete options if provided
+	if len(args) > 1 {
+		deleteOptions, err := p.parseOptionsDocument(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse delete options: %w", err)
 		}
-
-		// Add this line to current statement
-		if current.Len() > 0 {
-			current.WriteRune(' ')
+		opts := options.Delete()
+		if hint, ok := deleteOptions["hint"]; ok {
+			parsedHint, err := p.parseHint(hint)
+			if err != nil {
+				return nil, err
+			}
+			opts.SetHint(parsedHint)
 		}
-		current.WriteString(line)
-
-		// Count braces and quotes to determine when statement ends
-		for _, char := range line {
-			switch char {
-			case '"', '\'':
-				if !inQuotes {
-					inQuotes = true
-					quoteChar = char
-				} else if char == quoteChar {
-					inQuotes = false
-				}
-			case '{':
-				if !inQuotes {
-					braceLevel++
-				}
-			case '}':
-				if !inQuotes {
-					braceLevel--
-				}
+		if collationValue, ok := deleteOptions["collation"]; ok {
+			collation, err := p.parseCollation(collationValue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse delete collation: %w", err)
 			}
+			opts.SetCollation(collation)
 		}
-
-		// If statement ends with semicolon and braces are balanced, it's complete
-		if strings.HasSuffix(line, ";") && braceLevel == 0 && !inQuotes {
-			statements = append(statements, current.String())
-			current.Reset()
+		if err := p.parseConcernOptions(deleteOptions, op); err != nil {
+			return nil, err
 		}
+		op.DeleteOptions = opts
 	}
 
-	// Add any remaining content as a statement
-	if current.Len() > 0 {
-		statements = append(statements, current.String())
-	}
-
-	return statements
+	return op, nil
 }
 
 // Parses a complete MongoDB JavaScript statement
@@ -341,6 +1079,18 @@ func (p *Parser) parseMongoStatement(statement string) (*MongoOperation, error)
 		return p.parseDbCreateCollection(statement)
 	}
 
+	if strings.HasPrefix(statement, "db.dropDatabase(") {
+		return &MongoOperation{Type: "dropDatabase", Operation: "dropDatabase"}, nil
+	}
+
+	if strings.HasPrefix(statement, "db.watch(") {
+		_, args, ok := splitCall(statement)
+		if !ok {
+			return nil, fmt.Errorf("no matching closing parenthesis found")
+		}
+		return p.parseWatch("", args)
+	}
+
 	// Handle db.collection.operation() patterns
 	if !strings.HasPrefix(statement, "db.") {
 		return nil, fmt.Errorf("invalid MongoDB operation format")
@@ -394,18 +1144,86 @@ func (p *Parser) parseMongoStatement(statement string) (*MongoOperation, error)
 	switch operation {
 	case "createIndex":
 		return p.parseCreateIndex(collection, argsString)
+	case "createIndexes":
+		return p.parseCreateIndexes(collection, argsString)
 	case "insertOne", "insertMany":
 		return p.parseInsert(collection, operation, argsString)
 	case "updateOne", "updateMany":
 		return p.parseUpdate(collection, operation, argsString)
 	case "deleteOne", "deleteMany":
 		return p.parseDelete(collection, operation, argsString)
+	case "replaceOne":
+		op, err := p.parseUpdate(collection, operation, argsString)
+		if err != nil {
+			return nil, err
+		}
+		if op.UpdatePipeline != nil || hasUpdateOperators(op.Arguments[1]) {
+			return nil, fmt.Errorf("replaceOne requires a replacement document without update operators")
+		}
+		return op, nil
+	case "countDocuments":
+		return p.parseCount(collection, argsString)
+	case "findAndModify":
+		return p.parseFindAndModify(collection, argsString)
+	case "watch":
+		return p.parseWatch(collection, argsString)
+	case "createSearchIndex":
+		return p.parseCreateSearchIndex(collection, argsString)
+	case "createSearchIndexes":
+		return p.parseCreateSearchIndexes(collection, argsString)
+	case "dropSearchIndex":
+		var name string
+		if err := p.parseJSONLikeString(argsString, &name); err != nil {
+			return nil, fmt.Errorf("dropSearchIndex requires an index name: %w", err)
+		}
+		return &MongoOperation{Type: "dropSearchIndex", Collection: collection, Operation: operation, IndexSpec: name}, nil
+	case "ensureIndex", "insert", "update", "remove", "save", "count":
+		return p.parseLegacyOperation(collection, operation, argsString)
+	case "drop":
+		return &MongoOperation{Type: "dropCollection", Collection: collection, Operation: "drop"}, nil
+	case "dropIndex", "dropIndexes":
+		return p.parseDropIndex(collection, operation, argsString)
 	default:
-		log.Printf("Warning: unsupported operation '%s' for collection '%s'", operation, collection)
+		if op, err := p.parseCustomOperation(collection, operation, argsString); op != nil || err != nil {
+			return op, err
+		}
+		p.warnf("unsupported operation '%s' for collection '%s'", operation, collection)
 		return nil, nil
 	}
 }
 
+// Parses dropIndex and dropIndexes. dropIndex takes an index name or key
+// pattern; dropIndexes drops every index but _id unless given a name.
+func (p *Parser) parseDropIndex(collection, operation, argsString string) (*MongoOperation, error) {
+	op := &MongoOperation{
+		Type:       operation,
+		Collection: collection,
+		Operation:  operation,
+	}
+
+	arg := strings.TrimSpace(argsString)
+	switch {
+	case arg == "":
+		if operation == "dropIndex" {
+			return nil, fmt.Errorf("dropIndex requires an index name or key pattern")
+		}
+	case strings.HasPrefix(arg, "{"):
+		var keys bson.D
+		if err := p.parseJSONLikeString(arg, &keys); err != nil {
+			return nil, fmt.Errorf("failed to parse index key pattern: %w", err)
+		}
+		op.IndexSpec = keys
+	default:
+		op.IndexSpec = strings.Trim(arg, `"'`)
+	}
+
+	// A named dropIndexes is a single-index drop
+	if op.IndexSpec != nil {
+		op.Type = "dropIndex"
+	}
+	return op, nil
+}
+
 // Handles db.createCollection() operations
 func (p *Parser) parseDbCreateCollection(statement string) (*MongoOperation, error) {
 	// Extract arguments from db.createCollection(collectionName, options)
@@ -432,17 +1250,225 @@ func (p *Parser) parseDbCreateCollection(statement string) (*MongoOperation, err
 
 	// Parse options if provided
 	if len(args) > 1 {
-		var options map[string]interface{}
-		if err := p.parseJSONLikeString(args[1], &options); err != nil {
-			log.Printf("Warning: failed to parse createCollection options: %v", err)
-		} else {
-			if validator, ok := options["validator"]; ok {
-				if validatorMap, ok := validator.(map[string]interface{}); ok {
-					op.Validator = validatorMap
-				}
-			}
+		var collOptions bson.D
+		if err := p.parseJSONLikeString(args[1], &collOptions); err != nil {
+			p.warnf("failed to parse createCollection options: %v", err)
+		} else if err := p.parseCollectionOptions(collOptions, op); err != nil {
+			return nil, err
 		}
 	}
 
 	return op, nil
 }
+
+// Converts a parsed createCollection options document into driver options
+func (p *Parser) parseCollectionOptions(rawOptions bson.D, op *MongoOperation) error {
+	opts := options.CreateCollection()
+	collOptions, _ := asMap(rawOptions)
+
+	if validator, ok := collOptions["validator"]; ok {
+		if validatorDoc, ok := validator.(bson.D); ok {
+			op.Validator = validatorDoc
+		}
+	}
+
+	if err := p.parseConcernOptions(collOptions, op); err != nil {
+		return err
+	}
+
+	if level, ok := collOptions["validationLevel"]; ok {
+		levelStr, ok := level.(string)
+		if !ok {
+			return fmt.Errorf("validationLevel must be a string")
+		}
+		switch levelStr {
+		case "off", "strict", "moderate":
+			opts.SetValidationLevel(levelStr)
+		default:
+			return fmt.Errorf("unsupported validationLevel '%s'", levelStr)
+		}
+	}
+	if action, ok := collOptions["validationAction"]; ok {
+		actionStr, ok := action.(string)
+		if !ok {
+			return fmt.Errorf("validationAction must be a string")
+		}
+		switch actionStr {
+		case "error", "warn":
+			opts.SetValidationAction(actionStr)
+		default:
+			return fmt.Errorf("unsupported validationAction '%s'", actionStr)
+		}
+	}
+
+	if capped, ok := collOptions["capped"]; ok {
+		if cappedBool, ok := capped.(bool); ok {
+			opts.SetCapped(cappedBool)
+		}
+	}
+	if size, ok := collOptions["size"]; ok {
+		sizeInt, err := p.convertToInt64(size)
+		if err != nil {
+			return fmt.Errorf("invalid capped collection size: %w", err)
+		}
+		opts.SetSizeInBytes(sizeInt)
+	}
+	if max, ok := collOptions["max"]; ok {
+		maxInt, err := p.convertToInt64(max)
+		if err != nil {
+			return fmt.Errorf("invalid capped collection max: %w", err)
+		}
+		opts.SetMaxDocuments(maxInt)
+	}
+	if timeseries, ok := collOptions["timeseries"]; ok {
+		timeSeriesOpts, err := p.parseTimeSeriesOptions(timeseries)
+		if err != nil {
+			return fmt.Errorf("invalid timeseries options: %w", err)
+		}
+		opts.SetTimeSeriesOptions(timeSeriesOpts)
+	}
+	if expire, ok := collOptions["expireAfterSeconds"]; ok {
+		expireInt, err := p.convertToInt64(expire)
+		if err != nil {
+			return fmt.Errorf("invalid expireAfterSeconds: %w", err)
+		}
+		opts.SetExpireAfterSeconds(expireInt)
+	}
+	if collationValue, ok := collOptions["collation"]; ok {
+		collation, err := p.parseCollation(collationValue)
+		if err != nil {
+			return fmt.Errorf("failed to parse collection collation: %w", err)
+		}
+		opts.SetCollation(collation)
+	}
+	if clusteredIndex, ok := collOptions["clusteredIndex"]; ok {
+		clusteredSpec, err := p.parseClusteredIndex(clusteredIndex)
+		if err != nil {
+			return fmt.Errorf("invalid clusteredIndex: %w", err)
+		}
+		opts.SetClusteredIndex(clusteredSpec)
+	}
+	if opts.Capped != nil && *opts.Capped && opts.SizeInBytes == nil {
+		return fmt.Errorf("capped collection %s requires a size", op.Collection)
+	}
+
+	// Options the driver does not model are sent verbatim with the create command
+	var passthrough []string
+	for _, elem := range rawOptions {
+		if !knownCollectionOptions[elem.Key] {
+			passthrough = append(passthrough, elem.Key)
+		}
+	}
+	if len(passthrough) > 0 {
+		log.Printf("Passing createCollection options %s through to the server for %s", strings.Join(passthrough, ", "), op.Collection)
+		op.RawCollOptions = rawOptions
+	}
+
+	op.CollOptions = opts
+	return nil
+}
+
+// createCollection options that are mapped onto CreateCollectionOptions
+var knownCollectionOptions = map[string]bool{
+	"validator":          true,
+	"validationLevel":    true,
+	"validationAction":   true,
+	"capped":             true,
+	"size":               true,
+	"max":                true,
+	"timeseries":         true,
+	"expireAfterSeconds": true,
+	"collation":          true,
+	"clusteredIndex":     true,
+	"writeConcern":       true,
+}
+
+// Converts a parsed clusteredIndex document into the command representation
+func (p *Parser) parseClusteredIndex(value interface{}) (bson.D, error) {
+	clustered, ok := asMap(value)
+	if !ok {
+		return nil, fmt.Errorf("clusteredIndex must be a document")
+	}
+
+	rawKey, ok := clustered["key"].(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("clusteredIndex requires a key document")
+	}
+	key, err := p.parseIndexSpec(rawKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 1 || key[0].Key != "_id" || key[0].Value != 1 {
+		return nil, fmt.Errorf("clusteredIndex key must be { _id: 1 }")
+	}
+
+	// The server rejects clustered indexes that are not unique
+	unique, ok := clustered["unique"].(bool)
+	if !ok || !unique {
+		return nil, fmt.Errorf("clusteredIndex requires unique: true")
+	}
+
+	spec := bson.D{
+		{Key: "key", Value: key},
+		{Key: "unique", Value: true},
+	}
+	if name, ok := clustered["name"]; ok {
+		nameStr, ok := name.(string)
+		if !ok {
+			return nil, fmt.Errorf("clusteredIndex name must be a string")
+		}
+		spec = append(spec, bson.E{Key: "name", Value: nameStr})
+	}
+
+	return spec, nil
+}
+
+// Converts a parsed timeseries document into driver time-series options
+func (p *Parser) parseTimeSeriesOptions(value interface{}) (*options.TimeSeriesOptions, error) {
+	timeseries, ok := asMap(value)
+	if !ok {
+		return nil, fmt.Errorf("timeseries must be a document")
+	}
+
+	timeField, ok := timeseries["timeField"].(string)
+	if !ok || timeField == "" {
+		return nil, fmt.Errorf("timeseries requires a timeField")
+	}
+	opts := options.TimeSeries().SetTimeField(timeField)
+
+	if metaField, ok := timeseries["metaField"]; ok {
+		metaFieldStr, ok := metaField.(string)
+		if !ok {
+			return nil, fmt.Errorf("timeseries metaField must be a string")
+		}
+		opts.SetMetaField(metaFieldStr)
+	}
+	if granularity, ok := timeseries["granularity"]; ok {
+		granularityStr, ok := granularity.(string)
+		if !ok {
+			return nil, fmt.Errorf("timeseries granularity must be a string")
+		}
+		switch granularityStr {
+		case "seconds", "minutes", "hours":
+			opts.SetGranularity(granularityStr)
+		default:
+			return nil, fmt.Errorf("unsupported timeseries granularity '%s'", granularityStr)
+		}
+	}
+	if maxSpan, ok := timeseries["bucketMaxSpanSeconds"]; ok {
+		seconds, err := p.convertToInt64(maxSpan)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucketMaxSpanSeconds: %w", err)
+		}
+		opts.SetBucketMaxSpan(time.Duration(seconds) * time.Second)
+	}
+	if rounding, ok := timeseries["bucketRoundingSeconds"]; ok {
+		seconds, err := p.convertToInt64(rounding)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucketRoundingSeconds: %w", err)
+		}
+		opts.SetBucketRounding(time.Duration(seconds) * time.Second)
+	}
+
+	return opts, nil
+}
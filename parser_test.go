@@ -1,7 +1,11 @@
 package mongoparser
 
 import (
+	"strings"
 	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestNewParser(t *testing.T) {
@@ -57,3 +61,616 @@ func TestParseMetadataNoMetadata(t *testing.T) {
 		t.Error("ParseMetadata() should return nil for script without metadata")
 	}
 }
+
+func TestParseCreateIndexOptions(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.users.createIndex({ email: 1 }, {
+		unique: true,
+		sparse: true,
+		partialFilterExpression: { deleted_at: { $exists: false } },
+		collation: { locale: "en", strength: 2 }
+	})`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	opts := op.IndexOptions
+	if opts == nil {
+		t.Fatal("Expected index options to be set")
+	}
+
+	if opts.Sparse == nil || !*opts.Sparse {
+		t.Error("Expected sparse option to be true")
+	}
+
+	if opts.PartialFilterExpression == nil {
+		t.Error("Expected partialFilterExpression to be set")
+	}
+
+	if opts.Collation == nil || opts.Collation.Locale != "en" || opts.Collation.Strength != 2 {
+		t.Errorf("Expected collation {en, 2}, got %+v", opts.Collation)
+	}
+}
+
+func TestParseCreateIndexHashed(t *testing.T) {
+	parser := NewParser()
+
+	op, err := parser.parseMongoStatement(`db.users.createIndex({ tenant_id: "hashed" })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	spec, ok := op.IndexSpec.(bson.D)
+	if !ok || len(spec) != 1 {
+		t.Fatalf("Expected single-field bson.D index spec, got %#v", op.IndexSpec)
+	}
+
+	if spec[0].Value != "hashed" {
+		t.Errorf("Expected hashed index value to be preserved, got %#v", spec[0].Value)
+	}
+
+	if _, err := parser.parseMongoStatement(`db.users.createIndex({ a: "hashed", b: "hashed" })`); err == nil {
+		t.Error("Expected error for index with multiple hashed fields")
+	}
+}
+
+func TestParseCreateIndexes(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.orders.createIndexes([{ customer_id: 1 }, { created_at: -1 }], { sparse: true }, "majority")`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if op.Type != "createIndexes" {
+		t.Errorf("Expected type 'createIndexes', got '%s'", op.Type)
+	}
+
+	if len(op.IndexModels) != 2 {
+		t.Fatalf("Expected 2 index models, got %d", len(op.IndexModels))
+	}
+
+	for _, model := range op.IndexModels {
+		if model.Options == nil || model.Options.Sparse == nil || !*model.Options.Sparse {
+			t.Error("Expected shared options to apply to every index model")
+		}
+	}
+
+	if op.CreateIndexesOptions == nil {
+		t.Error("Expected commitQuorum to be parsed from the third argument")
+	}
+}
+
+func TestParseCreateIndexHiddenAndBackground(t *testing.T) {
+	parser := NewParser()
+	parser.warnings = &[]string{}
+
+	op, err := parser.parseMongoStatement(`db.users.createIndex({ email: 1 }, { hidden: true, background: true })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	opts := op.IndexOptions
+	if opts == nil || opts.Hidden == nil || !*opts.Hidden {
+		t.Error("Expected hidden option to be true")
+	}
+	if opts == nil || opts.Background == nil || !*opts.Background {
+		t.Error("Expected background option to be kept for older servers")
+	}
+	if len(*parser.warnings) != 1 || !strings.Contains((*parser.warnings)[0], "'background' is deprecated") {
+		t.Errorf("Expected a deprecation warning for background, got %v", *parser.warnings)
+	}
+}
+
+func TestParseCommitQuorum(t *testing.T) {
+	parser := NewParser()
+
+	cases := map[string]interface{}{
+		`db.users.createIndex({ email: 1 }, { commitQuorum: 2 })`:               int32(2),
+		`db.users.createIndex({ email: 1 }, { commitQuorum: 0 })`:               int32(0),
+		`db.users.createIndex({ email: 1 }, { commitQuorum: "majority" })`:      "majority",
+		`db.users.createIndex({ email: 1 }, { commitQuorum: "dataCenters" })`:   "dataCenters",
+		`db.users.createIndex({ email: 1 }, {}, 3)`:                             int32(3),
+		`db.users.createIndex({ email: 1 }, { unique: true }, "votingMembers")`: "votingMembers",
+		`db.users.createIndexes([{ a: 1 }, { b: 1 }], {}, 1)`:                   int32(1),
+	}
+	for statement, expected := range cases {
+		op, err := parser.parseMongoStatement(statement)
+		if err != nil {
+			t.Errorf("%s: parseMongoStatement() returned error: %v", statement, err)
+			continue
+		}
+		if op.CreateIndexesOptions == nil || op.CreateIndexesOptions.CommitQuorum != expected {
+			t.Errorf("%s: expected commitQuorum %#v, got %+v", statement, expected, op.CreateIndexesOptions)
+		}
+	}
+
+	invalid := []string{
+		`db.users.createIndex({ email: 1 }, { commitQuorum: -1 })`,
+		`db.users.createIndex({ email: 1 }, { commitQuorum: 1.5 })`,
+		`db.users.createIndex({ email: 1 }, { commitQuorum: 4294967297 })`,
+		`db.users.createIndex({ email: 1 }, { commitQuorum: true })`,
+		`db.users.createIndex({ email: 1 }, {}, -2)`,
+		`db.users.createIndexes([{ a: 1 }], {}, 2147483648)`,
+	}
+	for _, statement := range invalid {
+		if _, err := parser.parseMongoStatement(statement); err == nil || !strings.Contains(err.Error(), "invalid commitQuorum") {
+			t.Errorf("%s: expected commitQuorum to be refused, got %v", statement, err)
+		}
+	}
+}
+
+func TestParseCreateCollectionCapped(t *testing.T) {
+	parser := NewParser()
+
+	op, err := parser.parseMongoStatement(`db.createCollection("logs", { capped: true, size: 1048576, max: 5000 })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	opts := op.CollOptions
+	if opts == nil {
+		t.Fatal("Expected collection options to be set")
+	}
+
+	if opts.Capped == nil || !*opts.Capped {
+		t.Error("Expected capped option to be true")
+	}
+
+	if opts.SizeInBytes == nil || *opts.SizeInBytes != 1048576 {
+		t.Errorf("Expected size 1048576, got %v", opts.SizeInBytes)
+	}
+
+	if opts.MaxDocuments == nil || *opts.MaxDocuments != 5000 {
+		t.Errorf("Expected max 5000, got %v", opts.MaxDocuments)
+	}
+
+	if _, err := parser.parseMongoStatement(`db.createCollection("logs", { capped: true })`); err == nil {
+		t.Error("Expected error for capped collection without size")
+	}
+}
+
+func TestParseCreateCollectionTimeSeries(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.createCollection("metrics", {
+		timeseries: { timeField: "ts", metaField: "host", granularity: "minutes" },
+		expireAfterSeconds: 86400
+	})`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	timeseries := op.CollOptions.TimeSeriesOptions
+	if timeseries == nil {
+		t.Fatal("Expected timeseries options to be set")
+	}
+
+	if timeseries.TimeField != "ts" {
+		t.Errorf("Expected timeField 'ts', got '%s'", timeseries.TimeField)
+	}
+
+	if timeseries.MetaField == nil || *timeseries.MetaField != "host" {
+		t.Errorf("Expected metaField 'host', got %v", timeseries.MetaField)
+	}
+
+	if op.CollOptions.ExpireAfterSeconds == nil || *op.CollOptions.ExpireAfterSeconds != 86400 {
+		t.Errorf("Expected expireAfterSeconds 86400, got %v", op.CollOptions.ExpireAfterSeconds)
+	}
+}
+
+func TestParseCreateCollectionClusteredIndex(t *testing.T) {
+	parser := NewParser()
+
+	op, err := parser.parseMongoStatement(`db.createCollection("orders", { clusteredIndex: { key: { _id: 1 }, unique: true, name: "orders_clustered" } })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+	if op.CollOptions == nil {
+		t.Fatal("Expected collection options to be set")
+	}
+
+	spec, ok := op.CollOptions.ClusteredIndex.(bson.D)
+	if !ok || len(spec) != 3 {
+		t.Fatalf("Expected a clustered index spec, got %#v", op.CollOptions.ClusteredIndex)
+	}
+	key, ok := spec[0].Value.(bson.D)
+	if spec[0].Key != "key" || !ok || len(key) != 1 || key[0].Key != "_id" || key[0].Value != 1 {
+		t.Errorf("Expected clustered key { _id: 1 }, got %v", spec[0])
+	}
+	if spec[1].Key != "unique" || spec[1].Value != true || spec[2].Key != "name" || spec[2].Value != "orders_clustered" {
+		t.Errorf("Unexpected clustered index spec %v", spec)
+	}
+
+	invalid := map[string]string{
+		`db.createCollection("orders", { clusteredIndex: { key: { email: 1 }, unique: true } })`:        "key must be { _id: 1 }",
+		`db.createCollection("orders", { clusteredIndex: { key: { _id: -1 }, unique: true } })`:         "key must be { _id: 1 }",
+		`db.createCollection("orders", { clusteredIndex: { key: { _id: 1, a: 1 }, unique: true } })`:    "key must be { _id: 1 }",
+		`db.createCollection("orders", { clusteredIndex: { key: { _id: 1 }, unique: false } })`:         "requires unique: true",
+		`db.createCollection("orders", { clusteredIndex: { key: { _id: 1 } } })`:                        "requires unique: true",
+		`db.createCollection("orders", { clusteredIndex: { unique: true } })`:                           "requires a key document",
+		`db.createCollection("orders", { clusteredIndex: true })`:                                       "must be a document",
+		`db.createCollection("orders", { clusteredIndex: { key: { _id: 1 }, unique: true, name: 1 } })`: "name must be a string",
+	}
+	for statement, message := range invalid {
+		if _, err := parser.parseMongoStatement(statement); err == nil || !strings.Contains(err.Error(), "invalid clusteredIndex: clusteredIndex "+message) {
+			t.Errorf("%s: expected error %q, got %v", statement, message, err)
+		}
+	}
+}
+
+func TestParseCreateCollectionCollation(t *testing.T) {
+	parser := NewParser()
+
+	op, err := parser.parseMongoStatement(`db.createCollection("people", { collation: { locale: "fr", strength: 2, caseLevel: true, caseFirst: "upper", numericOrdering: true } })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+	if op.CollOptions == nil || op.CollOptions.Collation == nil {
+		t.Fatal("Expected the collation to be set on the collection options")
+	}
+
+	collation := op.CollOptions.Collation
+	if collation.Locale != "fr" || collation.Strength != 2 || !collation.CaseLevel || collation.CaseFirst != "upper" || !collation.NumericOrdering {
+		t.Errorf("Unexpected collation %+v", collation)
+	}
+	if _, ok := lookupKey(op.RawCollOptions, "collation"); ok {
+		t.Error("Expected the collation not to be passed through verbatim as well")
+	}
+
+	invalid := map[string]string{
+		`db.createCollection("people", { collation: "fr" })`:                               "collation must be a document",
+		`db.createCollection("people", { collation: { strength: 2 } })`:                    "collation requires a locale",
+		`db.createCollection("people", { collation: { locale: "fr", strength: 1.5 } })`:    "collation strength must be an integer",
+		`db.createCollection("people", { collation: { locale: "fr", caseLevel: "yes" } })`: "collation caseLevel must be a boolean",
+		`db.createCollection("people", { collation: { locale: "fr", accent: true } })`:     "unknown collation field 'accent'",
+	}
+	for statement, message := range invalid {
+		if _, err := parser.parseMongoStatement(statement); err == nil || !strings.Contains(err.Error(), "failed to parse collection collation: "+message) {
+			t.Errorf("%s: expected error %q, got %v", statement, message, err)
+		}
+	}
+}
+
+func TestParseCreateCollectionValidation(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.createCollection("users", {
+		validator: { $jsonSchema: { bsonType: "object", required: ["email"] } },
+		validationLevel: "moderate",
+		validationAction: "warn"
+	})`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if op.Validator == nil {
+		t.Error("Expected validator to be set")
+	}
+
+	if op.CollOptions.ValidationLevel == nil || *op.CollOptions.ValidationLevel != "moderate" {
+		t.Errorf("Expected validationLevel 'moderate', got %v", op.CollOptions.ValidationLevel)
+	}
+
+	if op.CollOptions.ValidationAction == nil || *op.CollOptions.ValidationAction != "warn" {
+		t.Errorf("Expected validationAction 'warn', got %v", op.CollOptions.ValidationAction)
+	}
+
+	if _, err := parser.parseMongoStatement(`db.createCollection("users", { validationAction: "ignore" })`); err == nil {
+		t.Error("Expected error for unsupported validationAction")
+	}
+}
+
+func TestParseCreateCollectionPassthrough(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.createCollection("events", {
+		capped: true,
+		size: 4096,
+		changeStreamPreAndPostImages: { enabled: true }
+	})`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if op.RawCollOptions == nil {
+		t.Fatal("Expected unrecognized options to be passed through")
+	}
+
+	if len(op.RawCollOptions) != 3 || op.RawCollOptions[2].Key != "changeStreamPreAndPostImages" {
+		t.Errorf("Expected all options passed through in order, got %v", op.RawCollOptions)
+	}
+
+	op, err = parser.parseMongoStatement(`db.createCollection("plain", { capped: true, size: 4096 })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if op.RawCollOptions != nil {
+		t.Error("Expected no passthrough options when all options are recognized")
+	}
+}
+
+func TestParseInsertManyArray(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.users.insertMany([
+		{ name: "Ada", role: "admin" },
+		{ name: "Linus", role: "user" },
+	], { ordered: false })`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if len(op.Arguments) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(op.Arguments))
+	}
+
+	if op.Arguments[1][0].Value != "Linus" {
+		t.Errorf("Expected second document name 'Linus', got %v", op.Arguments[1][0].Value)
+	}
+
+	if op.InsertManyOptions == nil || op.InsertManyOptions.Ordered == nil || *op.InsertManyOptions.Ordered {
+		t.Error("Expected ordered option to be false")
+	}
+}
+
+func TestParseUpdateOptions(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.users.updateOne(
+		{ email: "eve@example.com" },
+		{ $set: { "grades.$[g].passed": true } },
+		{ upsert: true, arrayFilters: [{ "g.score": { $gte: 60 } }], hint: { email: 1 } }
+	)`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	opts := op.UpdateOptions
+	if opts == nil {
+		t.Fatal("Expected update options to be set")
+	}
+
+	if opts.Upsert == nil || !*opts.Upsert {
+		t.Error("Expected upsert option to be true")
+	}
+
+	if opts.ArrayFilters == nil || len(opts.ArrayFilters.Filters) != 1 {
+		t.Errorf("Expected one array filter, got %+v", opts.ArrayFilters)
+	}
+
+	if _, ok := opts.Hint.(bson.D); !ok {
+		t.Errorf("Expected key pattern hint as bson.D, got %#v", opts.Hint)
+	}
+}
+
+func TestParseUpdatePipeline(t *testing.T) {
+	parser := NewParser()
+
+	statement := `db.users.updateMany(
+		{ status: "inactive" },
+		[{ $set: { archived: true } }, { $unset: ["session_token"] }]
+	)`
+
+	op, err := parser.parseMongoStatement(statement)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if len(op.Arguments) != 1 {
+		t.Errorf("Expected only the filter in arguments, got %d", len(op.Arguments))
+	}
+
+	if len(op.UpdatePipeline) != 2 {
+		t.Fatalf("Expected 2 pipeline stages, got %d", len(op.UpdatePipeline))
+	}
+
+	if op.UpdatePipeline[1][0].Key != "$unset" {
+		t.Error("Expected second stage to be $unset")
+	}
+}
+
+func TestParseDeleteOptions(t *testing.T) {
+	parser := NewParser(WithDeleteLimit(100, nil))
+
+	if parser.deleteLimit != 100 {
+		t.Errorf("Expected delete limit 100, got %d", parser.deleteLimit)
+	}
+
+	op, err := parser.parseMongoStatement(`db.users.deleteMany({ name: "ada" }, { collation: { locale: "en", strength: 1 }, hint: "name_1" })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	opts := op.DeleteOptions
+	if opts == nil {
+		t.Fatal("Expected delete options to be set")
+	}
+
+	if opts.Collation == nil || opts.Collation.Strength != 1 {
+		t.Errorf("Expected collation with strength 1, got %+v", opts.Collation)
+	}
+
+	if opts.Hint != "name_1" {
+		t.Errorf("Expected hint 'name_1', got %v", opts.Hint)
+	}
+}
+
+func TestParseWriteConcern(t *testing.T) {
+	parser := NewParser()
+
+	op, err := parser.parseMongoStatement(`db.payments.insertOne({ amount: 10 }, { writeConcern: { w: "majority", j: true, wtimeout: 5000 } })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	if op.WriteConcern == nil || op.WriteConcern.W != "majority" {
+		t.Fatalf("Expected majority write concern, got %+v", op.WriteConcern)
+	}
+
+	if op.WriteConcern.Journal == nil || !*op.WriteConcern.Journal {
+		t.Error("Expected journaled write concern")
+	}
+
+	script := `
+		// METADATA:
+		// { "name": "payments", "write_concern": { "w": 2 }, "read_concern": "majority" }
+	`
+	defaults, err := parser.parseConcernDefaults(parser.ParseMetadata(script))
+	if err != nil {
+		t.Fatalf("parseConcernDefaults() returned error: %v", err)
+	}
+
+	defaults.apply(op)
+	if op.WriteConcern.W != "majority" {
+		t.Error("Expected statement write concern to take precedence over metadata defaults")
+	}
+
+	if op.ReadConcern == nil || op.ReadConcern.Level != "majority" {
+		t.Errorf("Expected metadata read concern to be applied, got %+v", op.ReadConcern)
+	}
+}
+
+func TestParsePreservesKeyOrder(t *testing.T) {
+	parser := NewParser()
+
+	// Enough fields that map iteration would almost certainly shuffle them
+	op, err := parser.parseMongoStatement(`db.events.createIndex({ tenant: 1, kind: 1, status: -1, created_at: -1, owner: 1, region: 1 })`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	expected := []string{"tenant", "kind", "status", "created_at", "owner", "region"}
+	spec := op.IndexSpec.(bson.D)
+	for i, key := range expected {
+		if spec[i].Key != key {
+			t.Fatalf("Expected index key %d to be '%s', got '%s'", i, key, spec[i].Key)
+		}
+	}
+
+	op, err = parser.parseMongoStatement(`db.createCollection("users", {
+		validator: { $jsonSchema: { bsonType: "object", required: ["name", "email"], properties: { name: {}, email: {} } } }
+	})`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	validator, ok := op.Validator.(bson.D)
+	if !ok {
+		t.Fatalf("Expected validator as bson.D, got %T", op.Validator)
+	}
+
+	schema := validator[0].Value.(bson.D)
+	if schema[0].Key != "bsonType" || schema[1].Key != "required" || schema[2].Key != "properties" {
+		t.Errorf("Expected $jsonSchema keys in script order, got %v", schema)
+	}
+}
+
+func TestUpsertFilter(t *testing.T) {
+	parser := NewParser(WithUpsertInserts())
+
+	doc := bson.D{{Key: "_id", Value: "admin"}, {Key: "role", Value: "owner"}}
+	filter := parser.upsertFilter(doc)
+	if len(filter) != 1 || filter[0].Key != "_id" || filter[0].Value != "admin" {
+		t.Errorf("Expected filter on _id, got %v", filter)
+	}
+
+	keyed := NewParser(WithUpsertInserts("tenant", "email"))
+	if filter := keyed.upsertFilter(bson.D{{Key: "email", Value: "a@example.com"}}); filter != nil {
+		t.Errorf("Expected nil filter when a key field is missing, got %v", filter)
+	}
+}
+
+func TestCollectionName(t *testing.T) {
+	parser := NewParser(
+		WithCollectionPrefix("tenantX_"),
+		WithCollectionSuffix("_v2"),
+		WithCollectionMap(map[string]string{"audit": "shared_audit"}),
+	)
+
+	if got := parser.collectionName("users"); got != "tenantX_users_v2" {
+		t.Errorf("Expected prefixed and suffixed name, got %s", got)
+	}
+	if got := parser.collectionName("audit"); got != "shared_audit" {
+		t.Errorf("Expected mapped name, got %s", got)
+	}
+	if got := NewParser().collectionName("users"); got != "users" {
+		t.Errorf("Expected name to be unchanged by default, got %s", got)
+	}
+}
+
+func TestNumberModes(t *testing.T) {
+	statement := `db.accounts.insertOne({ id: 9007199254740993, small: 42, price: 19.99, json5: 0x20000000000001 })`
+	decimal, _ := primitive.ParseDecimal128("19.99")
+
+	tests := []struct {
+		mode  NumberMode
+		id    interface{}
+		small interface{}
+		price interface{}
+	}{
+		{NumberFloat64, 9007199254740992.0, 42.0, 19.99},
+		{NumberInt64, int64(9007199254740993), 42.0, 19.99},
+		{NumberDecimal128, int64(9007199254740993), 42.0, decimal},
+	}
+	for _, tt := range tests {
+		for _, json5 := range []bool{false, true} {
+			opts := []Option{WithNumberMode(tt.mode)}
+			input := statement
+			if json5 {
+				opts = append(opts, WithJSON5())
+			} else {
+				// Hexadecimal numbers are JSON5 only
+				input = strings.Replace(statement, ", json5: 0x20000000000001", "", 1)
+			}
+			op, err := NewParser(opts...).parseMongoStatement(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := op.Arguments[0]
+			if id, _ := lookupKey(doc, "id"); id != tt.id {
+				t.Errorf("mode %d: expected id %v (%T), got %v (%T)", tt.mode, tt.id, tt.id, id, id)
+			}
+			if small, _ := lookupKey(doc, "small"); small != tt.small {
+				t.Errorf("mode %d: expected small %v, got %v (%T)", tt.mode, tt.small, small, small)
+			}
+			if price, _ := lookupKey(doc, "price"); price != tt.price {
+				t.Errorf("mode %d: expected price %v, got %v (%T)", tt.mode, tt.price, price, price)
+			}
+			if hex, ok := lookupKey(doc, "json5"); ok && hex != tt.id {
+				t.Errorf("mode %d: expected hexadecimal %v, got %v (%T)", tt.mode, tt.id, hex, hex)
+			}
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.ParseScript("db.createCollection('users');\ndb.users.createIndex({ email: 1 });")
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+	if len(ops) != 2 || ops[1].Type != "createIndex" || ops[1].Location.StartLine != 2 {
+		t.Errorf("Unexpected operations: %+v", ops)
+	}
+
+	if _, err := parser.ParseScript("db.users.frobnicate({});"); err == nil || !strings.Contains(err.Error(), "unsupported operation") {
+		t.Errorf("Expected unsupported statements to be an error, got %v", err)
+	}
+}
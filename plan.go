@@ -0,0 +1,492 @@
+package mongoparser
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Version of the plan file format SavePlan writes; LoadPlan refuses newer ones
+const PlanVersion = 1
+
+// Encoding of a plan file
+type PlanFormat int
+
+const (
+	// Canonical Extended JSON, indented so plans can be reviewed and diffed
+	PlanExtendedJSON PlanFormat = iota
+	// BSON
+	PlanBSON
+)
+
+// Operations parsed from a script, saved so the plan reviewed is exactly what
+// runs later instead of a new parse of a script that may have been edited
+type Plan struct {
+	Version int
+	// Name of the script, from its metadata when it has one
+	Script string
+	// Checksum of the script content the plan was parsed from
+	Checksum  string
+	CreatedAt time.Time
+	// Comment block preceding the first statement, holding script metadata
+	Header     string
+	Metadata   *ScriptMetadata
+	Operations []MongoOperation
+	// Ed25519 signature of the plan made by SignPlan, required to load and
+	// execute plans when WithSignatureKey is set
+	Signature []byte
+}
+
+// Plan as stored in a file
+type planFile struct {
+	Version    int                `json:"version"`
+	Script     string             `json:"script"`
+	Checksum   string             `json:"checksum"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Header     string             `json:"header,omitempty"`
+	Metadata   *ScriptMetadata    `json:"metadata,omitempty"`
+	Operations []plannedOperation `json:"operations"`
+	Signature  []byte             `json:"signature,omitempty"`
+}
+
+// Operation of a plan file with its hash, which catches corrupted files and
+// operations that don't survive encoding. The hash is not keyed, so only a
+// signature protects a plan against edits.
+type plannedOperation struct {
+	Hash      string         `json:"hash"`
+	Operation MongoOperation `json:"operation"`
+}
+
+// Parses a script into a plan. The script must parse without warnings, and
+// its signature is verified when signatures are required; the plan itself
+// then needs signing with SignPlan before it can be loaded or executed.
+func (p *Parser) PlanScript(script ScriptInfo) (*Plan, error) {
+	if p.signatureKey != nil {
+		if err := p.VerifyScript(script.Content, nil); err != nil {
+			return nil, err
+		}
+	}
+	ops, err := p.ParseScript(script.Content)
+	if err != nil {
+		return nil, err
+	}
+	if script.Metadata == nil {
+		script.Metadata = p.ParseMetadata(script.Content)
+	}
+	return &Plan{
+		Version:    PlanVersion,
+		Script:     scriptName(script),
+		Checksum:   Checksum(script.Content),
+		CreatedAt:  p.now(),
+		Header:     newStatementScanner(strings.NewReader(script.Content)).header(),
+		Metadata:   script.Metadata,
+		Operations: ops,
+	}, nil
+}
+
+// Writes a plan to w in the given format
+func SavePlan(w io.Writer, plan *Plan, format PlanFormat) error {
+	file := planFile{
+		Version:   plan.Version,
+		Script:    plan.Script,
+		Checksum:  plan.Checksum,
+		CreatedAt: plan.CreatedAt,
+		Header:    plan.Header,
+		Metadata:  plan.Metadata,
+		Signature: plan.Signature,
+	}
+	if file.Version == 0 {
+		file.Version = PlanVersion
+	}
+	for _, op := range plan.Operations {
+		file.Operations = append(file.Operations, plannedOperation{Hash: OperationHash(op), Operation: op})
+	}
+
+	var buf bytes.Buffer
+	var vw bsonrw.ValueWriter
+	var err error
+	switch format {
+	case PlanExtendedJSON:
+		vw, err = bsonrw.NewExtJSONValueWriter(&buf, true, false)
+	case PlanBSON:
+		vw, err = bsonrw.NewBSONValueWriter(&buf)
+	default:
+		return fmt.Errorf("unsupported plan format %d", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	encoder, err := bson.NewEncoder(vw)
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := encoder.SetRegistry(planRegistry); err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	encoder.UseJSONStructTags()
+	if err := encoder.Encode(file); err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+
+	data := buf.Bytes()
+	if format == PlanExtendedJSON {
+		data = bytes.TrimSpace(data)
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode plan: %w", err)
+		}
+		indented.WriteByte('\n')
+		data = indented.Bytes()
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Reads a plan written by SavePlan in either format. Expressions evaluated at
+// execution, such as new Date(), are compiled with the parser's functions,
+// and every operation is checked against the hash it was saved with to catch
+// corrupted files. When WithSignatureKey is set the plan must carry a valid
+// signature, which is what refuses plans edited after they were signed.
+func (p *Parser) LoadPlan(r io.Reader) (*Plan, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var vr bsonrw.ValueReader
+	if bson.Raw(data).Validate() == nil {
+		vr = bsonrw.NewBSONDocumentReader(data)
+	} else if vr, err = bsonrw.NewExtJSONValueReader(bytes.NewReader(data), true); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	decoder, err := bson.NewDecoder(vr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	if err := decoder.SetRegistry(planRegistry); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	decoder.UseJSONStructTags()
+	decoder.DefaultDocumentD()
+	var file planFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	if file.Version < 1 || file.Version > PlanVersion {
+		return nil, fmt.Errorf("unsupported plan version %d, expected at most %d", file.Version, PlanVersion)
+	}
+
+	plan := &Plan{
+		Version:   file.Version,
+		Script:    file.Script,
+		Checksum:  file.Checksum,
+		CreatedAt: file.CreatedAt,
+		Header:    file.Header,
+		Metadata:  file.Metadata,
+		Signature: file.Signature,
+	}
+	for i, planned := range file.Operations {
+		op := planned.Operation
+		if err := p.restoreExpressions(&op); err != nil {
+			return nil, fmt.Errorf("operation %d of plan: %w", i+1, err)
+		}
+		if hash := OperationHash(op); hash != planned.Hash {
+			return nil, fmt.Errorf("operation %d of plan (%s) does not match its hash; the plan file is corrupted", i+1, op.Statement)
+		}
+		plan.Operations = append(plan.Operations, op)
+	}
+	if p.signatureKey != nil {
+		if err := p.VerifyPlan(plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// Signs a plan with key, so parsers holding the matching public key through
+// WithSignatureKey accept it. Any later change to the plan's operations or
+// script details invalidates the signature.
+func SignPlan(plan *Plan, key ed25519.PrivateKey) {
+	plan.Signature = ed25519.Sign(key, planDigest(plan))
+}
+
+// Verifies a plan's signature against the configured public key
+func (p *Parser) VerifyPlan(plan *Plan) error {
+	if p.signatureKey == nil {
+		return errors.New("no signature key configured")
+	}
+	if len(plan.Signature) == 0 {
+		return fmt.Errorf("%w: plan of %s carries no signature", ErrUnsigned, plan.Script)
+	}
+	if !ed25519.Verify(p.signatureKey, planDigest(plan), plan.Signature) {
+		return fmt.Errorf("%w: plan of %s was modified after it was signed", ErrInvalidSignature, plan.Script)
+	}
+	return nil
+}
+
+// Returns the SHA-256 a plan's signature covers: its script details and the
+// canonical form and $comment of each operation
+func planDigest(plan *Plan) []byte {
+	hash := sha256.New()
+	for _, field := range []string{strconv.Itoa(plan.Version), plan.Script, plan.Checksum, plan.CreatedAt.UTC().Format(time.RFC3339Nano), plan.Header} {
+		fmt.Fprintf(hash, "%q\n", field)
+	}
+	for _, op := range plan.Operations {
+		fmt.Fprintf(hash, "%q %q\n", CanonicalOperation(op), op.Comment)
+	}
+	return hash.Sum(nil)
+}
+
+// Executes the operations of a plan like ExecuteScript executes a script's,
+// without parsing anything. When WithSignatureKey is set unsigned plans and
+// plans whose signature doesn't match are refused. Permission preflights and
+// dependency checks run when configured. Directives such as IMPORT still read
+// their files when they run.
+func (p *Parser) ExecutePlan(ctx context.Context, db *mongo.Database, plan *Plan) ScriptResult {
+	if plan.Version > PlanVersion {
+		return ScriptResult{Error: fmt.Errorf("unsupported plan version %d, expected at most %d", plan.Version, PlanVersion), StartedAt: time.Now()}
+	}
+	if p.signatureKey != nil {
+		if err := p.VerifyPlan(plan); err != nil {
+			return ScriptResult{Error: err, StartedAt: time.Now()}
+		}
+	}
+	if p.preflight {
+		if err := p.preflightOperations(ctx, db, plan.Operations); err != nil {
+			return ScriptResult{Error: err, StartedAt: time.Now()}
+		}
+	}
+
+	run := *p
+	run.warnings = &[]string{}
+	script := &parsedScript{
+		leading:    plan.Header,
+		blank:      strings.TrimSpace(plan.Header) == "" && len(plan.Operations) == 0,
+		operations: plan.Operations,
+	}
+	if p.dependencyCheck {
+		ordered, err := run.checkDependencies(ctx, db, script.operations)
+		if err != nil {
+			return ScriptResult{Error: err, StartedAt: time.Now(), Warnings: *run.warnings}
+		}
+		script.operations = ordered
+	}
+	result := run.profileScript(ctx, db, script.source())
+	result.Warnings = *run.warnings
+	return result
+}
+
+// Key of the document an Expression is saved as
+const planExpressionKey = "$expression"
+
+// Replaces the saved forms of expressions in an operation's documents with
+// expressions compiled from their source
+func (p *Parser) restoreExpressions(op *MongoOperation) error {
+	for i := range op.Arguments {
+		value, err := p.restoreExpression(op.Arguments[i])
+		if err != nil {
+			return err
+		}
+		op.Arguments[i] = value.(bson.D)
+	}
+	for i := range op.UpdatePipeline {
+		value, err := p.restoreExpression(op.UpdatePipeline[i])
+		if err != nil {
+			return err
+		}
+		op.UpdatePipeline[i] = value.(bson.D)
+	}
+	for i := range op.Values {
+		value, err := p.restoreExpression(op.Values[i])
+		if err != nil {
+			return err
+		}
+		op.Values[i] = value
+	}
+	if op.Assertion != nil {
+		for i := range op.Assertion.Operands {
+			operand := &op.Assertion.Operands[i]
+			value, err := p.restoreExpression(operand.Value)
+			if err != nil {
+				return err
+			}
+			operand.Value = value
+			if operand.Operation != nil {
+				if err := p.restoreExpressions(operand.Operation); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i := range op.Transaction {
+		if err := p.restoreExpressions(&op.Transaction[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compiles the saved expressions within a value
+func (p *Parser) restoreExpression(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bson.D:
+		if len(v) == 1 && v[0].Key == planExpressionKey {
+			if source, ok := v[0].Value.(string); ok {
+				return compileExpression(source, p.functions)
+			}
+		}
+		for i, elem := range v {
+			restored, err := p.restoreExpression(elem.Value)
+			if err != nil {
+				return nil, err
+			}
+			v[i].Value = restored
+		}
+	case bson.A:
+		for i, item := range v {
+			restored, err := p.restoreExpression(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = restored
+		}
+	}
+	return value, nil
+}
+
+// Registry encoding the values of operations the default one can't: driver
+// concerns, whose own encoding refuses unset values, and expressions, which
+// are saved as their source
+var planRegistry = newPlanRegistry()
+
+// Builds planRegistry
+func newPlanRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	// Dates are parsed as time.Time rather than the driver's DateTime
+	registry.RegisterTypeMapEntry(bsontype.DateTime, reflect.TypeOf(time.Time{}))
+
+	registry.RegisterTypeEncoder(reflect.TypeOf(Expression{}), bsoncodec.ValueEncoderFunc(
+		func(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, v reflect.Value) error {
+			return encodePlanDocument(ec, vw, bson.D{{Key: planExpressionKey, Value: v.Interface().(Expression).Source}})
+		}))
+
+	registry.RegisterTypeEncoder(reflect.TypeOf(&writeconcern.WriteConcern{}), planDocumentEncoder(func(v reflect.Value) bson.D {
+		wc := v.Interface().(*writeconcern.WriteConcern)
+		doc := bson.D{}
+		if wc.W != nil {
+			doc = append(doc, bson.E{Key: "w", Value: wc.W})
+		}
+		if wc.Journal != nil {
+			doc = append(doc, bson.E{Key: "j", Value: *wc.Journal})
+		}
+		if wc.WTimeout != 0 {
+			doc = append(doc, bson.E{Key: "wtimeout", Value: wc.WTimeout.Milliseconds()})
+		}
+		return doc
+	}))
+	registry.RegisterTypeDecoder(reflect.TypeOf(&writeconcern.WriteConcern{}), planDocumentDecoder(func(doc bson.D) (interface{}, error) {
+		wc := &writeconcern.WriteConcern{}
+		for _, elem := range doc {
+			switch elem.Key {
+			case "w":
+				wc.W = elem.Value
+				if w, ok := toFloat64(elem.Value); ok {
+					wc.W = int(w)
+				}
+			case "j":
+				journal, _ := elem.Value.(bool)
+				wc.Journal = &journal
+			case "wtimeout":
+				ms, _ := toFloat64(elem.Value)
+				wc.WTimeout = time.Duration(ms) * time.Millisecond
+			}
+		}
+		return wc, nil
+	}))
+
+	registry.RegisterTypeEncoder(reflect.TypeOf(&readconcern.ReadConcern{}), planDocumentEncoder(func(v reflect.Value) bson.D {
+		return bson.D{{Key: "level", Value: v.Interface().(*readconcern.ReadConcern).Level}}
+	}))
+	registry.RegisterTypeDecoder(reflect.TypeOf(&readconcern.ReadConcern{}), planDocumentDecoder(func(doc bson.D) (interface{}, error) {
+		level, _ := lookupKey(doc, "level")
+		text, _ := level.(string)
+		return &readconcern.ReadConcern{Level: text}, nil
+	}))
+
+	registry.RegisterTypeEncoder(reflect.TypeOf(&readpref.ReadPref{}), planDocumentEncoder(func(v reflect.Value) bson.D {
+		return bson.D{{Key: "mode", Value: v.Interface().(*readpref.ReadPref).Mode().String()}}
+	}))
+	registry.RegisterTypeDecoder(reflect.TypeOf(&readpref.ReadPref{}), planDocumentDecoder(func(doc bson.D) (interface{}, error) {
+		value, _ := lookupKey(doc, "mode")
+		text, _ := value.(string)
+		mode, err := readpref.ModeFromString(text)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported readPreference '%s'", text)
+		}
+		return readpref.New(mode)
+	}))
+	return registry
+}
+
+// Returns an encoder writing a pointer as the document toDocument builds, or
+// null when it is nil
+func planDocumentEncoder(toDocument func(reflect.Value) bson.D) bsoncodec.ValueEncoderFunc {
+	return func(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, v reflect.Value) error {
+		if v.IsNil() {
+			return vw.WriteNull()
+		}
+		return encodePlanDocument(ec, vw, toDocument(v))
+	}
+}
+
+// Writes a document with the registry's document encoder
+func encodePlanDocument(ec bsoncodec.EncodeContext, vw bsonrw.ValueWriter, doc bson.D) error {
+	encoder, err := ec.LookupEncoder(reflect.TypeOf(doc))
+	if err != nil {
+		return err
+	}
+	return encoder.EncodeValue(ec, vw, reflect.ValueOf(doc))
+}
+
+// Returns a decoder reading a document into the pointer fromDocument builds,
+// leaving it nil for null
+func planDocumentDecoder(fromDocument func(bson.D) (interface{}, error)) bsoncodec.ValueDecoderFunc {
+	return func(dc bsoncodec.DecodeContext, vr bsonrw.ValueReader, v reflect.Value) error {
+		if vr.Type() == bsontype.Null {
+			v.Set(reflect.Zero(v.Type()))
+			return vr.ReadNull()
+		}
+		var doc bson.D
+		decoder, err := dc.LookupDecoder(reflect.TypeOf(doc))
+		if err != nil {
+			return err
+		}
+		if err := decoder.DecodeValue(dc, vr, reflect.ValueOf(&doc).Elem()); err != nil {
+			return err
+		}
+		value, err := fromDocument(doc)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+}
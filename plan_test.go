@@ -0,0 +1,172 @@
+package mongoparser
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const planScript = `// METADATA:
+// {"name": "plan-users", "version": "1.2.0"}
+
+db.createCollection("users", { validator: { $jsonSchema: { required: ["email"] } }, validationLevel: "moderate", collation: { locale: "en" } });
+db.users.createIndex({ email: 1 }, { unique: true, partialFilterExpression: { email: { $exists: true } } });
+db.users.createIndexes([{ a: 1 }, { b: -1 }], { name: "b" });
+db.users.insertMany([{ at: ISODate("2024-01-01T00:00:00Z"), n: 5, f: 1.5, seen: new Date() }], { ordered: false });
+db.users.updateMany({ a: 1 }, { $set: { b: 2 } }, { upsert: true, arrayFilters: [{ "x.y": 1 }] });
+db.users.deleteMany({ a: 1 }, { hint: { a: 1 } });
+db.users.updateOne({ a: 1 }, { $set: { b: 2 } }, { writeConcern: { w: "majority", j: true, wtimeout: 100 } });
+db.users.updateOne({ a: 1 }, { $set: { b: 2 } }, { writeConcern: { w: 2 }, readPreference: "secondary", readConcern: { level: "majority" } });
+db.counters.findAndModify({ query: { _id: "orders" }, update: { $inc: { seq: 1 } }, new: true, upsert: true });
+db.users.dropIndex("a_1");
+print("done");
+const session = db.getMongo().startSession();
+session.withTransaction(() => {
+    db.users.insertOne({ a: 1, at: new Date() });
+});
+session.endSession();
+`
+
+func TestPlanRoundTrip(t *testing.T) {
+	parser := NewParser(WithFixedClock(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)))
+	plan, err := parser.PlanScript(ScriptInfo{Name: "001_users.js", Content: planScript})
+	if err != nil {
+		t.Fatalf("PlanScript() returned error: %v", err)
+	}
+	if plan.Script != "plan-users" || plan.Checksum != Checksum(planScript) || !strings.Contains(plan.Header, "METADATA") {
+		t.Errorf("Unexpected plan details: %s %s %q", plan.Script, plan.Checksum, plan.Header)
+	}
+
+	for _, format := range []PlanFormat{PlanExtendedJSON, PlanBSON} {
+		var buf bytes.Buffer
+		if err := SavePlan(&buf, plan, format); err != nil {
+			t.Fatalf("SavePlan(%d) returned error: %v", format, err)
+		}
+		if format == PlanExtendedJSON && !strings.Contains(buf.String(), `"$expression": "new Date()"`) {
+			t.Errorf("Expected expressions to be saved as their source, got:\n%s", buf.String())
+		}
+
+		loaded, err := parser.LoadPlan(&buf)
+		if err != nil {
+			t.Fatalf("LoadPlan(%d) returned error: %v", format, err)
+		}
+		if loaded.Version != PlanVersion || loaded.Script != plan.Script || loaded.Header != plan.Header ||
+			!loaded.CreatedAt.Equal(plan.CreatedAt) || loaded.Metadata == nil || loaded.Metadata.Version != "1.2.0" {
+			t.Errorf("Unexpected loaded plan details: %+v", loaded)
+		}
+		if len(loaded.Operations) != len(plan.Operations) {
+			t.Fatalf("Expected %d operations, got %d", len(plan.Operations), len(loaded.Operations))
+		}
+		for i, op := range plan.Operations {
+			if CanonicalOperation(loaded.Operations[i]) != CanonicalOperation(op) {
+				t.Errorf("Operation %d changed:\n%s\n%s", i+1, CanonicalOperation(op), CanonicalOperation(loaded.Operations[i]))
+			}
+		}
+
+		evaluated, err := parser.evaluateExpressions(loaded.Operations[3])
+		if err != nil {
+			t.Fatalf("Failed to evaluate loaded expressions: %v", err)
+		}
+		if seen, _ := lookupKey(evaluated.Arguments[0], "seen"); seen != parser.now() {
+			t.Errorf("Expected new Date() to evaluate at execution, got %v", seen)
+		}
+		if w := loaded.Operations[7].WriteConcern.W; w != 2 {
+			t.Errorf("Expected an integer w to stay an int, got %T %v", w, w)
+		}
+	}
+}
+
+func TestLoadPlanRejectsCorruptedPlans(t *testing.T) {
+	parser := NewParser()
+	plan, err := parser.PlanScript(ScriptInfo{Name: "users.js", Content: `db.users.deleteMany({ status: "inactive" });`})
+	if err != nil {
+		t.Fatalf("PlanScript() returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := SavePlan(&buf, plan, PlanExtendedJSON); err != nil {
+		t.Fatalf("SavePlan() returned error: %v", err)
+	}
+
+	edited := strings.Replace(buf.String(), `"inactive"`, `"active"`, 1)
+	if _, err := parser.LoadPlan(strings.NewReader(edited)); err == nil || !strings.Contains(err.Error(), "does not match its hash") {
+		t.Errorf("Expected a corrupted plan to be refused, got %v", err)
+	}
+
+	newer := strings.Replace(buf.String(), `"version": {`, `"version": {"$numberInt": "2"}, "old": {`, 1)
+	if _, err := parser.LoadPlan(strings.NewReader(newer)); err == nil || !strings.Contains(err.Error(), "unsupported plan version 2") {
+		t.Errorf("Expected a newer plan version to be refused, got %v", err)
+	}
+}
+
+func TestSignedPlans(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	parser := NewParser(WithSignatureKey(public))
+	script := SignScript(`db.users.deleteMany({ status: "inactive" });`, private)
+	plan, err := parser.PlanScript(ScriptInfo{Name: "users.js", Content: script})
+	if err != nil {
+		t.Fatalf("PlanScript() returned error: %v", err)
+	}
+
+	save := func(plan *Plan) *bytes.Buffer {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := SavePlan(&buf, plan, PlanExtendedJSON); err != nil {
+			t.Fatalf("SavePlan() returned error: %v", err)
+		}
+		return &buf
+	}
+
+	if _, err := parser.LoadPlan(save(plan)); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected an unsigned plan to be refused, got %v", err)
+	}
+	if result := parser.ExecutePlan(t.Context(), nil, plan); !errors.Is(result.Error, ErrUnsigned) {
+		t.Errorf("Expected an unsigned plan not to execute, got %v", result.Error)
+	}
+
+	SignPlan(plan, private)
+	loaded, err := parser.LoadPlan(save(plan))
+	if err != nil {
+		t.Fatalf("Expected a signed plan to load, got %v", err)
+	}
+	if err := parser.VerifyPlan(loaded); err != nil {
+		t.Errorf("Expected the loaded plan to verify, got %v", err)
+	}
+
+	// Saving again recomputes the operation hashes, as anyone editing the file could
+	loaded.Operations[0].Arguments[0] = bson.D{{Key: "status", Value: "active"}}
+	if _, err := parser.LoadPlan(save(loaded)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected an edited plan to be refused, got %v", err)
+	}
+	if result := parser.ExecutePlan(t.Context(), nil, loaded); !errors.Is(result.Error, ErrInvalidSignature) {
+		t.Errorf("Expected an edited plan not to execute, got %v", result.Error)
+	}
+}
+
+func TestPlanScriptRequiresCleanParse(t *testing.T) {
+	if _, err := NewParser().PlanScript(ScriptInfo{Name: "bad.js", Content: `db.users.frobnicate({});`}); err == nil {
+		t.Error("Expected a script with unparsed statements to be refused")
+	}
+
+	plan, err := NewParser().PlanScript(ScriptInfo{Name: "users.js", Content: `db.users.insertOne({ tags: ["a", "b"] });`})
+	if err != nil {
+		t.Fatalf("PlanScript() returned error: %v", err)
+	}
+	if plan.Script != "users.js" || plan.Metadata != nil {
+		t.Errorf("Expected a plan named after the script, got %s %+v", plan.Script, plan.Metadata)
+	}
+	var buf bytes.Buffer
+	if err := SavePlan(&buf, plan, PlanBSON); err != nil {
+		t.Fatalf("SavePlan() returned error: %v", err)
+	}
+	if err := bson.Raw(buf.Bytes()).Validate(); err != nil {
+		t.Errorf("Expected a BSON document, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Builds the operation a custom collection method stands for, such as
+// db.users.archiveTo("users_archive"). args are the method's arguments decoded
+// like other script values: documents as bson.D, arrays as bson.A.
+type OperationParseFunc func(collection string, args []interface{}) (MongoOperation, error)
+
+// Executes a custom operation. The collection name has prefixes, suffixes and
+// WithCollectionMap applied.
+type OperationExecFunc func(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error)
+
+// Type of operations parsed by a method registered with RegisterOperation
+const customOperationType = "custom"
+
+// A collection method registered with RegisterOperation
+type customOperation struct {
+	parse OperationParseFunc
+	exec  OperationExecFunc
+}
+
+// Collection methods the parser handles itself, which can't be registered
+var builtinMethods = map[string]bool{
+	"createIndex": true, "createIndexes": true, "ensureIndex": true,
+	"insertOne": true, "insertMany": true, "insert": true, "save": true,
+	"updateOne": true, "updateMany": true, "replaceOne": true, "update": true,
+	"deleteOne": true, "deleteMany": true, "remove": true,
+	"countDocuments": true, "count": true, "findAndModify": true, "watch": true,
+	"createSearchIndex": true, "createSearchIndexes": true, "dropSearchIndex": true,
+	"drop": true, "dropIndex": true, "dropIndexes": true,
+}
+
+var methodNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Teaches the parser a collection method such as db.users.archiveTo(...).
+// parse builds the operation from the method's arguments; when it is nil the
+// decoded arguments are kept in the operation's Values. The operation's Type
+// is "custom" and its Operation the method name, and exec runs it like any
+// other operation, so middleware, audit logs and metrics see it too.
+// Register methods before the parser is used; built-in methods can't be
+// replaced.
+func (p *Parser) RegisterOperation(name string, parse OperationParseFunc, exec OperationExecFunc) error {
+	switch {
+	case !methodNamePattern.MatchString(name):
+		return fmt.Errorf("invalid operation name %q", name)
+	case builtinMethods[name]:
+		return fmt.Errorf("operation %s is built in and can't be replaced", name)
+	case p.customOperations[name] != nil:
+		return fmt.Errorf("operation %s is already registered", name)
+	case exec == nil:
+		return fmt.Errorf("operation %s requires an exec function", name)
+	}
+
+	if p.customOperations == nil {
+		p.customOperations = make(map[string]*customOperation)
+	}
+	p.customOperations[name] = &customOperation{parse: parse, exec: exec}
+	return nil
+}
+
+// Parses a call of a registered collection method, returning nil when no
+// method of that name is registered
+func (p *Parser) parseCustomOperation(collection, name, argsString string) (*MongoOperation, error) {
+	custom := p.customOperations[name]
+	if custom == nil {
+		return nil, nil
+	}
+
+	var args []interface{}
+	for _, arg := range p.splitArguments(argsString) {
+		if arg = strings.TrimSpace(arg); arg == "" {
+			continue
+		}
+		var value interface{}
+		if err := p.parseJSONLikeString(arg, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse %s argument %s: %w", name, arg, err)
+		}
+		args = append(args, value)
+	}
+
+	op := MongoOperation{Values: args}
+	if custom.parse != nil {
+		var err error
+		if op, err = custom.parse(collection, args); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	op.Type = customOperationType
+	op.Operation = name
+	if op.Collection == "" {
+		op.Collection = collection
+	}
+	return &op, nil
+}
+
+// Executes an operation parsed by a registered collection method
+func (p *Parser) executeCustomOperation(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	custom := p.customOperations[op.Operation]
+	if custom == nil {
+		return nil, fmt.Errorf("operation %s is not registered", op.Operation)
+	}
+	return custom.exec(ctx, db, op)
+}
@@ -0,0 +1,88 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRegisterOperation(t *testing.T) {
+	var executed []MongoOperation
+	exec := func(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+		executed = append(executed, op)
+		return "archived", nil
+	}
+
+	parser := NewParser(WithCollectionPrefix("app_"))
+	if err := parser.RegisterOperation("archiveTo", nil, exec); err != nil {
+		t.Fatalf("RegisterOperation() returned error: %v", err)
+	}
+
+	result := parser.ExecuteScript(t.Context(), nil, `db.users.archiveTo("users_archive", { before: { $date: "2024-01-01T00:00:00Z" }, batch: 500 });`)
+	if !result.Success {
+		t.Fatalf("Expected the custom operation to run, got %v", result.Error)
+	}
+	if len(executed) != 1 {
+		t.Fatalf("Expected one execution, got %d", len(executed))
+	}
+
+	op := executed[0]
+	if op.Type != "custom" || op.Operation != "archiveTo" || op.Collection != "app_users" {
+		t.Errorf("Unexpected operation: %+v", op)
+	}
+	if len(op.Values) != 2 || op.Values[0] != "users_archive" {
+		t.Fatalf("Expected the decoded arguments, got %v", op.Values)
+	}
+	if options, ok := op.Values[1].(bson.D); !ok || options[0].Key != "before" {
+		t.Errorf("Expected the options document to keep its order, got %#v", op.Values[1])
+	}
+	if result.Operations[0].Result != "archived" {
+		t.Errorf("Expected the exec result to be reported, got %v", result.Operations[0].Result)
+	}
+}
+
+func TestRegisterOperationParse(t *testing.T) {
+	parser := NewParser()
+	parse := func(collection string, args []interface{}) (MongoOperation, error) {
+		if len(args) != 1 {
+			return MongoOperation{}, errors.New("expected a target collection")
+		}
+		target, _ := args[0].(string)
+		return MongoOperation{Arguments: []bson.D{{{Key: "to", Value: target}}}}, nil
+	}
+	exec := func(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+		return nil, nil
+	}
+	if err := parser.RegisterOperation("archiveTo", parse, exec); err != nil {
+		t.Fatalf("RegisterOperation() returned error: %v", err)
+	}
+
+	ops, err := parser.ParseScript(`db.users.archiveTo("users_archive");`)
+	if err != nil {
+		t.Fatalf("ParseScript() returned error: %v", err)
+	}
+	if ops[0].Collection != "users" || ops[0].Arguments[0][0].Value != "users_archive" {
+		t.Errorf("Expected the parse function's operation, got %+v", ops[0])
+	}
+
+	if _, err := parser.ParseScript(`db.users.archiveTo();`); err == nil || !strings.Contains(err.Error(), "expected a target collection") {
+		t.Errorf("Expected the parse error to be reported, got %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"insertOne":  "built in",
+		"archiveTo":  "already registered",
+		"archive-to": "invalid operation name",
+	} {
+		if err := parser.RegisterOperation(name, nil, exec); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("RegisterOperation(%q) = %v, expected an error containing %q", name, err, expected)
+		}
+	}
+	if err := parser.RegisterOperation("purge", nil, nil); err == nil {
+		t.Error("Expected an exec function to be required")
+	}
+}
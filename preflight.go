@@ -0,0 +1,215 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// An action the connected user lacks for an operation of the script
+type PermissionIssue struct {
+	Operation  string
+	Collection string
+	Action     string
+}
+
+// Lists every operation the connected user is not allowed to perform
+type PermissionError struct {
+	Missing []PermissionIssue
+}
+
+// Formats the missing privileges, one per operation and action
+func (e *PermissionError) Error() string {
+	lines := make([]string, 0, len(e.Missing))
+	for _, issue := range e.Missing {
+		lines = append(lines, fmt.Sprintf("%s on %s requires %s", issue.Operation, issue.Collection, issue.Action))
+	}
+	return fmt.Sprintf("connected user lacks %d required privileges: %s", len(e.Missing), strings.Join(lines, "; "))
+}
+
+// A privilege as reported by connectionStatus
+type privilege struct {
+	Resource struct {
+		DB          *string `bson:"db"`
+		Collection  *string `bson:"collection"`
+		AnyResource bool    `bson:"anyResource"`
+	} `bson:"resource"`
+	Actions []string `bson:"actions"`
+}
+
+// Checks the connected user's privileges against every operation of a script
+// without executing it. It returns a *PermissionError listing what is missing,
+// or nil when the user may run the whole script or access control is disabled.
+func (p *Parser) Preflight(ctx context.Context, db *mongo.Database, jsContent string) error {
+	operations, err := p.parseJavaScriptOperations(jsContent)
+	if err != nil {
+		return fmt.Errorf("failed to parse JavaScript operations: %w", err)
+	}
+	return p.preflightOperations(ctx, db, operations)
+}
+
+// Checks the connected user's privileges against parsed operations
+func (p *Parser) preflightOperations(ctx context.Context, db *mongo.Database, operations []MongoOperation) error {
+	var status struct {
+		AuthInfo struct {
+			AuthenticatedUsers []bson.Raw  `bson:"authenticatedUsers"`
+			Privileges         []privilege `bson:"authenticatedUserPrivileges"`
+		} `bson:"authInfo"`
+	}
+	command := bson.D{{Key: "connectionStatus", Value: 1}, {Key: "showPrivileges", Value: true}}
+	if err := db.RunCommand(ctx, command).Decode(&status); err != nil {
+		return fmt.Errorf("failed to read connection privileges: %w", err)
+	}
+
+	// Without an authenticated user access control is off and everything is allowed
+	if len(status.AuthInfo.AuthenticatedUsers) == 0 {
+		return nil
+	}
+
+	if missing := p.missingPrivileges(db.Name(), operations, status.AuthInfo.Privileges); len(missing) > 0 {
+		return &PermissionError{Missing: missing}
+	}
+	return nil
+}
+
+// Collects the actions operations need that the privileges don't grant
+func (p *Parser) missingPrivileges(dbName string, operations []MongoOperation, privileges []privilege) []PermissionIssue {
+	var missing []PermissionIssue
+	for _, op := range operations {
+		for _, collection := range p.privilegeCollections(op) {
+			for _, action := range p.requiredActions(op) {
+				if !hasPrivilege(privileges, dbName, collection, action) {
+					missing = append(missing, PermissionIssue{Operation: op.Operation, Collection: collection, Action: action})
+				}
+			}
+		}
+		missing = append(missing, p.missingPrivileges(dbName, nestedOperations(op), privileges)...)
+	}
+	return missing
+}
+
+// Returns the collections op needs privileges on; GridFS uploads write to the
+// files and chunks collections of their bucket
+func (p *Parser) privilegeCollections(op MongoOperation) []string {
+	collection := p.collectionName(op.Collection)
+	if op.Type == "upload" {
+		return []string{collection + ".files", collection + ".chunks"}
+	}
+	return []string{collection}
+}
+
+// Returns the privilege actions executing op needs under the parser's configuration
+func (p *Parser) requiredActions(op MongoOperation) []string {
+	switch op.Type {
+	case "createCollection":
+		actions := []string{"createCollection"}
+		switch p.collectionPolicy {
+		case ConflictRecreate:
+			actions = append(actions, "dropCollection")
+		case ConflictUpdate:
+			actions = append(actions, "collMod")
+		}
+		return actions
+	case "createIndex", "createIndexes":
+		actions := []string{"listIndexes", "createIndex"}
+		switch p.indexPolicy {
+		case ConflictRecreate:
+			actions = append(actions, "dropIndex")
+		case ConflictUpdate:
+			actions = append(actions, "collMod", "dropIndex")
+		}
+		return actions
+	case "insert", "seed", "import":
+		if len(p.upsertKeys) > 0 {
+			return []string{"insert", "update"}
+		}
+		return []string{"insert"}
+	case "update":
+		actions := []string{"update"}
+		if op.UpdateOptions != nil && op.UpdateOptions.Upsert != nil && *op.UpdateOptions.Upsert {
+			actions = append(actions, "insert")
+		}
+		if op.Operation == "updateMany" && p.updateLimit > 0 {
+			actions = append(actions, "find")
+		}
+		return actions
+	case "delete":
+		if op.Operation == "deleteMany" && p.deleteLimit > 0 {
+			return []string{"remove", "find"}
+		}
+		return []string{"remove"}
+	case "findAndModify":
+		if op.Operation == "findOneAndDelete" {
+			return []string{"find", "remove"}
+		}
+		actions := []string{"find", "update"}
+		if op.FindAndModifyOptions != nil && op.FindAndModifyOptions.Upsert {
+			actions = append(actions, "insert")
+		}
+		return actions
+	case "createSearchIndex":
+		actions := []string{"listSearchIndexes", "createSearchIndexes"}
+		if p.indexPolicy == ConflictRecreate || p.indexPolicy == ConflictUpdate {
+			actions = append(actions, "updateSearchIndex", "dropSearchIndex")
+		}
+		return actions
+	case "dropSearchIndex":
+		return []string{"dropSearchIndex"}
+	case "upload":
+		return []string{"find", "insert", "listIndexes", "createIndex"}
+	case "count":
+		return []string{"find"}
+	case "watch":
+		// Watch statements are skipped unless change streams are consumed
+		if p.changeStreamHandler == nil {
+			return nil
+		}
+		return []string{"find", "changeStream"}
+	case "dropCollection":
+		return []string{"dropCollection"}
+	case "dropIndex", "dropIndexes":
+		return []string{"dropIndex"}
+	case "dropDatabase":
+		return []string{"dropDatabase"}
+	default:
+		return nil
+	}
+}
+
+// Reports whether any privilege grants action on the collection
+func hasPrivilege(privileges []privilege, dbName, collection, action string) bool {
+	for _, priv := range privileges {
+		if !priv.matches(dbName, collection) {
+			continue
+		}
+		for _, granted := range priv.Actions {
+			if granted == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether the privilege's resource covers the collection. Empty
+// database or collection names in a resource match any name.
+func (priv privilege) matches(dbName, collection string) bool {
+	if priv.Resource.AnyResource {
+		return true
+	}
+	if priv.Resource.DB == nil || priv.Resource.Collection == nil {
+		// Cluster resources don't cover collections
+		return false
+	}
+	if db := *priv.Resource.DB; db != "" && db != dbName {
+		return false
+	}
+	if coll := *priv.Resource.Collection; coll != "" && coll != collection {
+		return false
+	}
+	// Wildcard collections exclude system collections
+	return *priv.Resource.Collection != "" || !strings.HasPrefix(collection, "system.")
+}
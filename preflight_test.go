@@ -0,0 +1,64 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMissingPrivileges(t *testing.T) {
+	parser := NewParser(WithIndexConflictPolicy(ConflictRecreate))
+	ops, err := parser.parseJavaScriptOperations(`
+db.createCollection("users");
+db.users.createIndex({ email: 1 });
+db.users.insertOne({ name: "a" });
+db.audit.updateOne({ _id: 1 }, { $set: { seen: true } }, { upsert: true });`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, all := "app", ""
+	users := "users"
+	privileges := []privilege{
+		{Actions: []string{"createCollection", "insert", "update", "listIndexes", "createIndex"}},
+		{Actions: []string{"dropIndex"}},
+	}
+	privileges[0].Resource.DB, privileges[0].Resource.Collection = &app, &all
+	privileges[1].Resource.DB, privileges[1].Resource.Collection = &app, &users
+
+	if missing := parser.missingPrivileges("app", ops, privileges); len(missing) != 0 {
+		t.Errorf("Expected all privileges to be granted, got %+v", missing)
+	}
+
+	missing := parser.missingPrivileges("app", ops, privileges[:1])
+	if len(missing) != 1 || missing[0].Collection != "users" || missing[0].Action != "dropIndex" {
+		t.Errorf("Expected only dropIndex to be missing, got %+v", missing)
+	}
+
+	missing = parser.missingPrivileges("other", ops, privileges)
+	if len(missing) != 7 {
+		t.Errorf("Expected every action to be missing on another database, got %+v", missing)
+	}
+
+	message := (&PermissionError{Missing: missing[:1]}).Error()
+	if !strings.Contains(message, "createCollection on users requires createCollection") {
+		t.Errorf("Unexpected error message: %s", message)
+	}
+}
+
+func TestRequiredActionsWithLimits(t *testing.T) {
+	parser := NewParser(WithDeleteLimit(100, nil), WithUpdateLimit(100, nil))
+
+	update := MongoOperation{Type: "update", Operation: "updateMany"}
+	if actions := parser.requiredActions(update); len(actions) != 2 || actions[1] != "find" {
+		t.Errorf("Expected guarded updateMany to need find, got %v", actions)
+	}
+
+	del := MongoOperation{Type: "delete", Operation: "deleteMany"}
+	if actions := parser.requiredActions(del); len(actions) != 2 || actions[1] != "find" {
+		t.Errorf("Expected guarded deleteMany to need find, got %v", actions)
+	}
+
+	if actions := NewParser().requiredActions(update); len(actions) != 1 {
+		t.Errorf("Expected unguarded updateMany to need only update, got %v", actions)
+	}
+}
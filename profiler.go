@@ -0,0 +1,170 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Prefix of the $comment WithOperationComments attaches to commands
+const commentPrefix = "mongoparser"
+
+// Tags the commands of every operation with a $comment naming the script and
+// the lines of its statement, such as "mongoparser add-users lines 12-15", so
+// they can be told apart in the profiler, currentOp and the server log.
+// Commands that don't accept a comment, such as create and createIndexes,
+// are sent without one.
+func WithOperationComments() Option {
+	return func(p *Parser) {
+		p.operationComments = true
+	}
+}
+
+// Turns the database profiler on while a script runs, recording operations
+// that take at least threshold, and collects them into the result's
+// SlowOperations and its reports. The previous profiling level and threshold
+// are restored afterwards; the threshold is shared by every database on the
+// server. Combine with WithOperationComments so only the script's own
+// operations are collected rather than everything profiled in the database
+// meanwhile. When profiling can't be enabled, as on mongos, a warning is
+// recorded and the script runs without it.
+func WithProfiler(threshold time.Duration) Option {
+	return func(p *Parser) {
+		p.profiler = true
+		p.profileThreshold = threshold
+	}
+}
+
+// Operation the database profiler recorded as slow while a script ran
+type SlowOperation struct {
+	Namespace string `json:"namespace"`
+	// Profiler operation type, such as "insert", "update" or "command"
+	Operation string        `json:"operation"`
+	Duration  time.Duration `json:"duration_ns"`
+	// $comment of the operation, naming the statement when WithOperationComments is set
+	Comment      string `json:"comment,omitempty"`
+	PlanSummary  string `json:"plan_summary,omitempty"`
+	KeysExamined int64  `json:"keys_examined"`
+	DocsExamined int64  `json:"docs_examined"`
+}
+
+// Builds the $comment of an operation of the named script
+func operationComment(script string, location *SourceLocation) string {
+	comment := commentPrefix
+	if script != "" {
+		comment += " " + script
+	}
+	if location != nil {
+		comment += " " + location.String()
+	}
+	return comment
+}
+
+// Profiling level and slow operation threshold of a database
+type profileSettings struct {
+	Level  int `bson:"was"`
+	SlowMS int `bson:"slowms"`
+}
+
+// Runs a script, with the database profiler on when WithProfiler is set
+func (p *Parser) profileScript(ctx context.Context, db *mongo.Database, script operationSource) ScriptResult {
+	if !p.profiler {
+		return p.executeScript(ctx, db, script)
+	}
+
+	// Settings are restored and slow operations collected even when the script was cancelled
+	bg := context.WithoutCancel(ctx)
+	previous, since, err := p.enableProfiler(bg, db)
+	if err != nil {
+		p.warnf("profiler not enabled: %v", err)
+		return p.executeScript(ctx, db, script)
+	}
+
+	result := p.executeScript(ctx, db, script)
+	restore := bson.D{{Key: "profile", Value: previous.Level}, {Key: "slowms", Value: previous.SlowMS}}
+	if err := db.RunCommand(bg, restore).Err(); err != nil {
+		p.warnf("failed to restore profiling level %d: %v", previous.Level, err)
+	}
+	if result.SlowOperations, err = p.slowOperations(bg, db, since); err != nil {
+		p.warnf("failed to collect slow operations: %v", err)
+	}
+	return result
+}
+
+// Sets profiling level 1 with the configured threshold, returning the previous
+// settings and the server's clock when profiling started
+func (p *Parser) enableProfiler(ctx context.Context, db *mongo.Database) (profileSettings, time.Time, error) {
+	var previous profileSettings
+	if err := db.RunCommand(ctx, bson.D{{Key: "profile", Value: -1}}).Decode(&previous); err != nil {
+		return previous, time.Time{}, err
+	}
+
+	// Profile entries carry the server's timestamps, so the client's clock can't be trusted to select them
+	var hello struct {
+		LocalTime time.Time `bson:"localTime"`
+	}
+	if err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return previous, time.Time{}, err
+	}
+
+	enable := bson.D{{Key: "profile", Value: 1}, {Key: "slowms", Value: p.profileThreshold.Milliseconds()}}
+	if err := db.RunCommand(ctx, enable).Err(); err != nil {
+		return previous, time.Time{}, err
+	}
+	return previous, hello.LocalTime, nil
+}
+
+// Entry of the system.profile collection
+type profileEntry struct {
+	Op           string   `bson:"op"`
+	Namespace    string   `bson:"ns"`
+	Millis       int64    `bson:"millis"`
+	Command      bson.Raw `bson:"command"`
+	PlanSummary  string   `bson:"planSummary"`
+	KeysExamined int64    `bson:"keysExamined"`
+	DocsExamined int64    `bson:"docsExamined"`
+}
+
+// Reads the operations profiled since the script started, oldest first
+func (p *Parser) slowOperations(ctx context.Context, db *mongo.Database, since time.Time) ([]SlowOperation, error) {
+	filter := bson.D{
+		{Key: "ts", Value: bson.D{{Key: "$gte", Value: since}}},
+		{Key: "millis", Value: bson.D{{Key: "$gte", Value: p.profileThreshold.Milliseconds()}}},
+		{Key: "ns", Value: bson.D{{Key: "$ne", Value: db.Name() + ".system.profile"}}},
+	}
+	if p.operationComments {
+		pattern := "^" + regexp.QuoteMeta(commentPrefix) + "( |$)"
+		filter = append(filter, bson.E{Key: "command.comment", Value: bson.D{{Key: "$regex", Value: pattern}}})
+	}
+
+	cursor, err := db.Collection("system.profile").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "ts", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var entries []profileEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to read profiler entries: %w", err)
+	}
+
+	slow := make([]SlowOperation, 0, len(entries))
+	for _, entry := range entries {
+		operation := SlowOperation{
+			Namespace:    entry.Namespace,
+			Operation:    entry.Op,
+			Duration:     time.Duration(entry.Millis) * time.Millisecond,
+			PlanSummary:  entry.PlanSummary,
+			KeysExamined: entry.KeysExamined,
+			DocsExamined: entry.DocsExamined,
+		}
+		if entry.Command != nil {
+			operation.Comment, _ = entry.Command.Lookup("comment").StringValueOK()
+		}
+		slow = append(slow, operation)
+	}
+	return slow, nil
+}
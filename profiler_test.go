@@ -0,0 +1,71 @@
+package mongoparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperationComment(t *testing.T) {
+	tests := []struct {
+		script   string
+		location *SourceLocation
+		expected string
+	}{
+		{"add-users", &SourceLocation{StartLine: 12, EndLine: 15}, "mongoparser add-users lines 12-15"},
+		{"add-users", &SourceLocation{StartLine: 3, EndLine: 3}, "mongoparser add-users line 3"},
+		{"", &SourceLocation{StartLine: 3, EndLine: 3}, "mongoparser line 3"},
+		{"add-users", nil, "mongoparser add-users"},
+	}
+	for _, tt := range tests {
+		if comment := operationComment(tt.script, tt.location); comment != tt.expected {
+			t.Errorf("operationComment(%q, %v) = %q, expected %q", tt.script, tt.location, comment, tt.expected)
+		}
+	}
+}
+
+func TestCanonicalOperationIgnoresComment(t *testing.T) {
+	op := MongoOperation{Type: "insert", Collection: "users", Operation: "insertOne"}
+	commented := op
+	commented.Comment = "mongoparser add-users line 3"
+	if CanonicalOperation(op) != CanonicalOperation(commented) {
+		t.Errorf("Expected the comment not to change the canonical form, got %s", CanonicalOperation(commented))
+	}
+}
+
+func TestReportSlowOperations(t *testing.T) {
+	result := ScriptResult{
+		Success: true,
+		Name:    "add-users",
+		SlowOperations: []SlowOperation{{
+			Namespace:    "app.users",
+			Operation:    "update",
+			Duration:     1500 * time.Millisecond,
+			Comment:      "mongoparser add-users line 7",
+			PlanSummary:  "COLLSCAN",
+			DocsExamined: 120000,
+		}},
+	}
+
+	report := result.Report()
+	if len(report.SlowOperations) != 1 || report.SlowOperations[0].PlanSummary != "COLLSCAN" {
+		t.Errorf("Expected the slow operation in the report, got %+v", report.SlowOperations)
+	}
+
+	var markdown bytes.Buffer
+	if err := result.WriteMarkdown(&markdown); err != nil {
+		t.Fatalf("WriteMarkdown() returned error: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "| `app.users` | update | 1.5s | COLLSCAN | mongoparser add-users line 7 |") {
+		t.Errorf("Expected a slow operations table, got:\n%s", markdown.String())
+	}
+
+	var html bytes.Buffer
+	if err := result.WriteHTML(&html); err != nil {
+		t.Fatalf("WriteHTML() returned error: %v", err)
+	}
+	if !strings.Contains(html.String(), "<h3>Slow operations</h3>") {
+		t.Errorf("Expected a slow operations section, got:\n%s", html.String())
+	}
+}
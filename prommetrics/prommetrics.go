@@ -0,0 +1,71 @@
+// Package prommetrics implements mongoparser.Metrics on top of Prometheus collectors.
+package prommetrics
+
+import (
+	"time"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Exports parser metrics as Prometheus counters and histograms
+type Metrics struct {
+	operations *prometheus.CounterVec
+	failures   *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+	documents  *prometheus.CounterVec
+}
+
+var _ mongoparser.Metrics = (*Metrics)(nil)
+
+// Creates the collectors under the given namespace and registers them with reg
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mongoparser",
+			Name:      "operations_total",
+			Help:      "Number of executed script operations.",
+		}, []string{"type", "collection"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mongoparser",
+			Name:      "operation_failures_total",
+			Help:      "Number of script operations that failed.",
+		}, []string{"type", "collection"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "mongoparser",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of executed script operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"type"}),
+		documents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "mongoparser",
+			Name:      "documents_inserted_total",
+			Help:      "Number of documents written by insert operations.",
+		}, []string{"collection"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.operations, m.failures, m.durations, m.documents} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Counts the operation and observes its duration
+func (m *Metrics) OperationExecuted(opType, collection string, duration time.Duration, err error) {
+	m.operations.WithLabelValues(opType, collection).Inc()
+	if err != nil {
+		m.failures.WithLabelValues(opType, collection).Inc()
+	}
+	m.durations.WithLabelValues(opType).Observe(duration.Seconds())
+}
+
+// Adds inserted documents to the per-collection counter
+func (m *Metrics) DocumentsInserted(collection string, count int64) {
+	m.documents.WithLabelValues(collection).Add(float64(count))
+}
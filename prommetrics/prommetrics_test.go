@@ -0,0 +1,39 @@
+package prommetrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg, "test")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	m.OperationExecuted("insert", "users", 20*time.Millisecond, nil)
+	m.OperationExecuted("insert", "users", 5*time.Millisecond, errors.New("duplicate key"))
+	m.DocumentsInserted("users", 3)
+
+	if got := testutil.ToFloat64(m.operations.WithLabelValues("insert", "users")); got != 2 {
+		t.Errorf("Expected 2 operations, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.failures.WithLabelValues("insert", "users")); got != 1 {
+		t.Errorf("Expected 1 failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.documents.WithLabelValues("users")); got != 3 {
+		t.Errorf("Expected 3 documents, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.durations); got != 1 {
+		t.Errorf("Expected 1 duration series, got %d", got)
+	}
+
+	if _, err := New(reg, "test"); err == nil {
+		t.Error("Expected duplicate registration to fail")
+	}
+}
@@ -0,0 +1,201 @@
+package mongoparser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Prompts written by REPL before a new statement and before its continuation lines
+const (
+	replPrompt             = "> "
+	replContinuationPrompt = "... "
+)
+
+// Reads statements from in one at a time, executes each through ExecuteScript
+// and writes its outcome to out, until in is exhausted, ctx ends or a line reads
+// "exit". Statements spanning several lines are collected until their braces,
+// brackets and quotes balance. Every statement runs as its own script, so options such
+// as WithAuditLog, WithScriptLock and the destructive operation checks apply
+// to it, and variables don't carry over to later statements. Statements
+// can't be signed, so REPL refuses to start when WithSignatureKey is set.
+func (p *Parser) REPL(ctx context.Context, db *mongo.Database, in io.Reader, out io.Writer) error {
+	if p.signatureKey != nil {
+		return errors.New("interactive statements can't be executed when scripts must be signed")
+	}
+
+	reader := bufio.NewReader(in)
+	var buffer strings.Builder
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		prompt := replPrompt
+		if buffer.Len() > 0 {
+			prompt = replContinuationPrompt
+		}
+		if _, err := io.WriteString(out, prompt); err != nil {
+			return fmt.Errorf("failed to write prompt: %w", err)
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read statement: %w", err)
+		}
+		eof := err == io.EOF
+		if buffer.Len() == 0 && strings.TrimSpace(line) == "exit" {
+			return nil
+		}
+		buffer.WriteString(line)
+		if !eof && !strings.HasSuffix(line, "\n") {
+			buffer.WriteByte('\n')
+		}
+
+		statement := buffer.String()
+		switch completeness(statement) {
+		case statementIncomplete:
+			if !eof {
+				continue
+			}
+		case statementPending:
+			if !eof {
+				continue
+			}
+			// Annotations without a statement to apply to
+			buffer.Reset()
+			return nil
+		case statementEmpty:
+			buffer.Reset()
+			if eof {
+				return nil
+			}
+			continue
+		}
+
+		buffer.Reset()
+		if err := p.writeREPLResult(out, p.ExecuteScript(ctx, db, statement)); err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// How far the lines entered at the REPL prompt are from a statement to execute
+type statementCompleteness int
+
+const (
+	// Holds at least one statement with balanced braces and quotes
+	statementComplete statementCompleteness = iota
+	// Ends inside braces, brackets or quotes
+	statementIncomplete
+	// Holds only annotations or an environment section, which apply to the next statement
+	statementPending
+	// Holds nothing but whitespace and comments
+	statementEmpty
+)
+
+// Reports whether content is ready to execute, using the same rules the
+// scanner uses to split scripts into statements
+func completeness(content string) statementCompleteness {
+	scanner := newStatementScanner(strings.NewReader(content))
+	found := false
+	depth := 0
+	for {
+		statement, ok := scanner.next()
+		if !ok {
+			break
+		}
+		found = true
+		depth += bracketDepth(statement)
+	}
+	switch {
+	case scanner.braceLevel > 0 || scanner.inQuotes || depth > 0:
+		return statementIncomplete
+	case found:
+		return statementComplete
+	case len(scanner.annotations) > 0 || scanner.environments != nil:
+		return statementPending
+	default:
+		return statementEmpty
+	}
+}
+
+// Returns how many more parentheses and square brackets a statement opens
+// than it closes, ignoring those inside strings
+func bracketDepth(statement string) int {
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, char := range statement {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if char == '\\' {
+				escaped = true
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'' || char == '`':
+			quote = char
+		case char == '(' || char == '[':
+			depth++
+		case char == ')' || char == ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// Writes the outcome of a statement executed at the REPL prompt: one line per
+// operation, followed by warnings and the error that stopped it
+func (p *Parser) writeREPLResult(out io.Writer, result ScriptResult) error {
+	var b strings.Builder
+	for _, op := range result.Operations {
+		switch {
+		case op.Status == StatusFailed, op.Status == StatusNotRun:
+			continue
+		case op.Status == StatusSkipped:
+			fmt.Fprintf(&b, "skipped: %v\n", op.Result)
+		case op.Type == "print":
+			// Already written when print output goes to a writer
+			if p.printOutput == nil {
+				fmt.Fprintf(&b, "%v\n", op.Result)
+			}
+		default:
+			fmt.Fprintf(&b, "%s\n", formatREPLValue(op.Result))
+		}
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", warning)
+	}
+	if result.Error != nil {
+		fmt.Fprintf(&b, "error: %v\n", result.Error)
+	}
+
+	if _, err := io.WriteString(out, b.String()); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// Renders an operation's output as relaxed Extended JSON, falling back to its
+// Go representation for values that aren't documents
+func formatREPLValue(value interface{}) string {
+	if value == nil {
+		return "ok"
+	}
+	if data, err := bson.MarshalExtJSON(value, false, false); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,71 @@
+package mongoparser
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+func TestCompleteness(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected statementCompleteness
+	}{
+		{"db.users.insertOne({ name: 'Ana' });\n", statementComplete},
+		{"db.users.insertOne({\n", statementIncomplete},
+		{"db.users.insertOne({ name: 'Ana\n", statementIncomplete},
+		{"db.users.insertOne({\n  name: 'Ana'\n})\n", statementComplete},
+		{"db.users.createIndexes([\n", statementIncomplete},
+		{"print('(');\n", statementComplete},
+		{"// mongoparser:ignore\n", statementPending},
+		{"// just a comment\n\n", statementEmpty},
+		{"// UPLOAD: bucket=files path=logo.png\n", statementComplete},
+	}
+
+	for _, test := range tests {
+		if got := completeness(test.content); got != test.expected {
+			t.Errorf("completeness(%q) = %d, expected %d", test.content, got, test.expected)
+		}
+	}
+}
+
+func TestREPL(t *testing.T) {
+	input := strings.Join([]string{
+		"print('hello');",
+		"",
+		"print(",
+		"  'multi'",
+		");",
+		"db.users.frobnicate({});",
+		"exit",
+		"print('never');",
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := NewParser().REPL(t.Context(), nil, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("REPL() returned error: %v", err)
+	}
+
+	output := out.String()
+	for _, expected := range []string{"> hello\n", "> ... ... multi\n", "warning: unsupported operation"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected REPL output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "never") {
+		t.Errorf("Expected REPL to stop at exit, got:\n%s", output)
+	}
+}
+
+func TestREPLRequiresUnsignedScripts(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := NewParser(WithSignatureKey(public)).REPL(t.Context(), nil, strings.NewReader("print('hi');"), &out); err == nil {
+		t.Error("Expected REPL to refuse to run when signatures are required")
+	}
+}
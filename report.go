@@ -0,0 +1,382 @@
+package mongoparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Machine-readable summary of a script execution
+type Report struct {
+	Script     string            `json:"script,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Success    bool              `json:"success"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	DurationMS float64           `json:"duration_ms"`
+	Summary    Summary           `json:"summary"`
+	Operations []OperationReport `json:"operations"`
+	Warnings   []string          `json:"warnings,omitempty"`
+	// Operations the profiler recorded as slow, when WithProfiler is set
+	SlowOperations []SlowOperation `json:"slow_operations,omitempty"`
+}
+
+// Report entry for a single operation
+type OperationReport struct {
+	Index      int     `json:"index"`
+	Type       string  `json:"type"`
+	Collection string  `json:"collection"`
+	Operation  string  `json:"operation"`
+	Status     string  `json:"status"`
+	Result     string  `json:"result,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	// Statement the operation was parsed from
+	Location *SourceLocation `json:"location,omitempty"`
+}
+
+// Aggregated statistics about a script execution
+type Summary struct {
+	Operations         int            `json:"operations"`
+	ByType             map[string]int `json:"by_type"`
+	Succeeded          int            `json:"succeeded"`
+	Skipped            int            `json:"skipped"`
+	Failed             int            `json:"failed"`
+	NotRun             int            `json:"not_run"`
+	CollectionsCreated int            `json:"collections_created"`
+	IndexesCreated     int            `json:"indexes_created"`
+	DocumentsWritten   int64          `json:"documents_written"`
+	// Wall-clock time of the whole script and the time spent inside operations
+	Duration      time.Duration `json:"duration_ns"`
+	OperationTime time.Duration `json:"operation_time_ns"`
+}
+
+// Computes counts by operation type, documents written, indexes created and durations
+func (r ScriptResult) Summary() Summary {
+	summary := Summary{
+		Operations: len(r.Operations),
+		ByType:     map[string]int{},
+		Duration:   r.Duration,
+	}
+
+	for _, op := range r.Operations {
+		summary.ByType[op.Type]++
+		summary.OperationTime += op.Duration
+
+		switch op.Status {
+		case StatusSucceeded:
+			summary.Succeeded++
+		case StatusSkipped:
+			summary.Skipped++
+		case StatusFailed:
+			summary.Failed++
+		case StatusNotRun:
+			summary.NotRun++
+		}
+		if op.Status != StatusSucceeded {
+			continue
+		}
+
+		summary.DocumentsWritten += op.Documents
+		switch op.Type {
+		case "createCollection":
+			summary.CollectionsCreated++
+		case "createIndex", "createIndexes":
+			summary.IndexesCreated += len(op.IndexKeys)
+		}
+	}
+
+	return summary
+}
+
+// Builds the machine-readable report for this result
+func (r ScriptResult) Report() Report {
+	report := Report{
+		Script:         r.Name,
+		Version:        r.Version,
+		Success:        r.Success,
+		StartedAt:      r.StartedAt,
+		DurationMS:     durationMS(r.Duration),
+		Summary:        r.Summary(),
+		Operations:     make([]OperationReport, 0, len(r.Operations)),
+		Warnings:       r.Warnings,
+		SlowOperations: r.SlowOperations,
+	}
+	if r.Error != nil {
+		report.Error = r.Error.Error()
+	}
+
+	for i, op := range r.Operations {
+		entry := OperationReport{
+			Index:      i,
+			Type:       op.Type,
+			Collection: op.Collection,
+			Operation:  op.Operation,
+			Status:     op.Status,
+			DurationMS: durationMS(op.Duration),
+			Location:   op.Location,
+		}
+		if op.Result != nil {
+			entry.Result = fmt.Sprint(op.Result)
+		}
+		if op.Error != nil {
+			entry.Error = op.Error.Error()
+		}
+		report.Operations = append(report.Operations, entry)
+	}
+
+	return report
+}
+
+// Writes the report as indented JSON
+func (r ScriptResult) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.Report())
+}
+
+// Converts a duration to fractional milliseconds
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Counts the documents an executed operation wrote
+func documentCount(op MongoOperation, output interface{}) int64 {
+	switch op.Type {
+	case "insert":
+		return int64(len(op.Arguments))
+	case "update", "delete", "seed", "import":
+		if count, ok := output.(int64); ok {
+			return count
+		}
+	case "findAndModify":
+		if output != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Collects the key patterns of the indexes an operation defines
+func indexKeys(op MongoOperation) []bson.D {
+	var keys []bson.D
+	if spec, ok := op.IndexSpec.(bson.D); ok {
+		keys = append(keys, spec)
+	}
+	for _, model := range op.IndexModels {
+		if spec, ok := model.Keys.(bson.D); ok {
+			keys = append(keys, spec)
+		}
+	}
+	return keys
+}
+
+// Human-readable summary of what a script changed
+type reportSummary struct {
+	Title       string
+	Status      string
+	Duration    string
+	Collections []string
+	Indexes     []indexSummary
+	Inserted    []insertSummary
+	Failures    []failureSummary
+	Slow        []SlowOperation
+	Warnings    []string
+}
+
+// Index row in a human-readable report
+type indexSummary struct {
+	Collection string
+	Keys       string
+	Status     string
+}
+
+// Inserted document count per collection in a human-readable report
+type insertSummary struct {
+	Collection string
+	Documents  int64
+}
+
+// Failed operation in a human-readable report
+type failureSummary struct {
+	Collection string
+	Operation  string
+	Error      string
+	// Line range and text of the failed statement, when known
+	Lines     string
+	Statement string
+}
+
+// Gathers the summary shown in the Markdown and HTML reports
+func (r ScriptResult) summarize() reportSummary {
+	summary := reportSummary{
+		Title:    r.Name,
+		Status:   "✅ Succeeded",
+		Duration: r.Duration.Round(time.Millisecond).String(),
+		Slow:     r.SlowOperations,
+		Warnings: r.Warnings,
+	}
+	if summary.Title == "" {
+		summary.Title = "Script execution"
+	}
+	if r.Version != "" {
+		summary.Title += " v" + r.Version
+	}
+	if !r.Success {
+		summary.Status = "❌ Failed"
+	}
+
+	inserted := map[string]int64{}
+	var insertOrder []string
+	for _, op := range r.Operations {
+		switch {
+		case op.Status == StatusFailed:
+			failure := failureSummary{Collection: op.Collection, Operation: op.Operation}
+			if op.Error != nil {
+				failure.Error = op.Error.Error()
+			}
+			if op.Location != nil {
+				failure.Lines = op.Location.String()
+				failure.Statement = op.Location.Text
+			}
+			summary.Failures = append(summary.Failures, failure)
+		case op.Status == StatusNotRun:
+			continue
+		case op.Type == "createCollection" && op.Status == StatusSucceeded:
+			summary.Collections = append(summary.Collections, op.Collection)
+		case op.Type == "createIndex" || op.Type == "createIndexes":
+			status := "created"
+			if op.Status == StatusSkipped {
+				status = "already existed"
+			}
+			for _, keys := range op.IndexKeys {
+				summary.Indexes = append(summary.Indexes, indexSummary{Collection: op.Collection, Keys: formatKeys(keys), Status: status})
+			}
+		case op.Type == "insert":
+			if _, ok := inserted[op.Collection]; !ok {
+				insertOrder = append(insertOrder, op.Collection)
+			}
+			inserted[op.Collection] += op.Documents
+		}
+	}
+	for _, collection := range insertOrder {
+		summary.Inserted = append(summary.Inserted, insertSummary{Collection: collection, Documents: inserted[collection]})
+	}
+
+	return summary
+}
+
+// Formats an index key pattern the way it appears in scripts
+func formatKeys(keys bson.D) string {
+	parts := make([]string, 0, len(keys))
+	for _, elem := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %v", elem.Key, elem.Value))
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
+
+// Writes a Markdown summary suitable for pull requests and change tickets
+func (r ScriptResult) WriteMarkdown(w io.Writer) error {
+	summary := r.summarize()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", summary.Title)
+	fmt.Fprintf(&b, "**Status:** %s in %s\n", summary.Status, summary.Duration)
+
+	if len(summary.Collections) > 0 {
+		b.WriteString("\n### Collections created\n\n")
+		for _, collection := range summary.Collections {
+			fmt.Fprintf(&b, "- `%s`\n", collection)
+		}
+	}
+	if len(summary.Indexes) > 0 {
+		b.WriteString("\n### Indexes\n\n| Collection | Keys | Status |\n|---|---|---|\n")
+		for _, index := range summary.Indexes {
+			fmt.Fprintf(&b, "| `%s` | `%s` | %s |\n", index.Collection, index.Keys, index.Status)
+		}
+	}
+	if len(summary.Inserted) > 0 {
+		b.WriteString("\n### Documents inserted\n\n| Collection | Documents |\n|---|---|\n")
+		for _, insert := range summary.Inserted {
+			fmt.Fprintf(&b, "| `%s` | %d |\n", insert.Collection, insert.Documents)
+		}
+	}
+	if len(summary.Failures) > 0 {
+		b.WriteString("\n### Failures\n\n")
+		for _, failure := range summary.Failures {
+			fmt.Fprintf(&b, "- `%s` on `%s`: %s\n", failure.Operation, failure.Collection, failure.Error)
+			if failure.Statement != "" {
+				fmt.Fprintf(&b, "\n  ```javascript\n  // %s\n  %s\n  ```\n", failure.Lines, strings.ReplaceAll(failure.Statement, "\n", "\n  "))
+			}
+		}
+	}
+	if len(summary.Slow) > 0 {
+		b.WriteString("\n### Slow operations\n\n| Namespace | Operation | Duration | Plan | Comment |\n|---|---|---|---|---|\n")
+		for _, slow := range summary.Slow {
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", slow.Namespace, slow.Operation, slow.Duration, slow.PlanSummary, slow.Comment)
+		}
+	}
+	if len(summary.Warnings) > 0 {
+		b.WriteString("\n### Warnings\n\n")
+		for _, warning := range summary.Warnings {
+			fmt.Fprintf(&b, "- %s\n", warning)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Template for the HTML report fragment
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<section class="mongoparser-report">
+<h2>{{.Title}}</h2>
+<p><strong>Status:</strong> {{.Status}} in {{.Duration}}</p>
+{{- if .Collections}}
+<h3>Collections created</h3>
+<ul>{{range .Collections}}<li><code>{{.}}</code></li>{{end}}</ul>
+{{- end}}
+{{- if .Indexes}}
+<h3>Indexes</h3>
+<table><tr><th>Collection</th><th>Keys</th><th>Status</th></tr>
+{{- range .Indexes}}
+<tr><td><code>{{.Collection}}</code></td><td><code>{{.Keys}}</code></td><td>{{.Status}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Inserted}}
+<h3>Documents inserted</h3>
+<table><tr><th>Collection</th><th>Documents</th></tr>
+{{- range .Inserted}}
+<tr><td><code>{{.Collection}}</code></td><td>{{.Documents}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Failures}}
+<h3>Failures</h3>
+<ul>{{range .Failures}}<li><code>{{.Operation}}</code> on <code>{{.Collection}}</code>: {{.Error}}
+{{- if .Statement}}<pre title="{{.Lines}}"><code>{{.Statement}}</code></pre>{{end}}</li>{{end}}</ul>
+{{- end}}
+{{- if .Slow}}
+<h3>Slow operations</h3>
+<table><tr><th>Namespace</th><th>Operation</th><th>Duration</th><th>Plan</th><th>Comment</th></tr>
+{{- range .Slow}}
+<tr><td><code>{{.Namespace}}</code></td><td>{{.Operation}}</td><td>{{.Duration}}</td><td>{{.PlanSummary}}</td><td>{{.Comment}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Warnings}}
+<h3>Warnings</h3>
+<ul>{{range .Warnings}}<li>{{.}}</li>{{end}}</ul>
+{{- end}}
+</section>
+`))
+
+// Writes an HTML summary fragment suitable for embedding in dashboards or tickets
+func (r ScriptResult) WriteHTML(w io.Writer) error {
+	return htmlReportTemplate.Execute(w, r.summarize())
+}
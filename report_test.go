@@ -0,0 +1,119 @@
+package mongoparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScriptResultWriteJSON(t *testing.T) {
+	result := ScriptResult{
+		Success: false,
+		Error:   errors.New("boom"),
+		Name:    "users_setup",
+		Version: "1.2.0",
+		Operations: []OperationResult{
+			{Type: "createCollection", Collection: "users", Operation: "createCollection", Status: StatusSucceeded, Result: "created", Duration: 3 * time.Millisecond},
+			{Type: "createIndex", Collection: "users", Operation: "createIndex", Status: StatusFailed, Error: errors.New("boom")},
+			{Type: "insert", Collection: "users", Operation: "insertOne", Status: StatusNotRun},
+		},
+		Warnings: []string{"index option 'background' is deprecated since MongoDB 4.2"},
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() returned error: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("WriteJSON() produced invalid JSON: %v", err)
+	}
+
+	if report.Script != "users_setup" || report.Success || report.Error != "boom" {
+		t.Errorf("Unexpected report header: %+v", report)
+	}
+
+	if len(report.Operations) != 3 || report.Operations[2].Status != StatusNotRun {
+		t.Errorf("Expected 3 operations ending with a not_run entry, got %+v", report.Operations)
+	}
+
+	if report.Operations[0].DurationMS != 3 {
+		t.Errorf("Expected 3ms duration, got %v", report.Operations[0].DurationMS)
+	}
+
+	if len(report.Warnings) != 1 {
+		t.Errorf("Expected warnings to be reported, got %v", report.Warnings)
+	}
+}
+
+func TestScriptResultWriteMarkdown(t *testing.T) {
+	result := ScriptResult{
+		Success: true,
+		Name:    "shop_setup",
+		Operations: []OperationResult{
+			{Type: "createCollection", Collection: "products", Status: StatusSucceeded},
+			{Type: "createIndex", Collection: "products", Status: StatusSucceeded, IndexKeys: []bson.D{{{Key: "sku", Value: 1}}}},
+			{Type: "createIndex", Collection: "products", Status: StatusSkipped, IndexKeys: []bson.D{{{Key: "name", Value: 1}}}},
+			{Type: "insert", Collection: "products", Operation: "insertMany", Status: StatusSucceeded, Documents: 3},
+			{Type: "insert", Collection: "products", Operation: "insertOne", Status: StatusSucceeded, Documents: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteMarkdown(&buf); err != nil {
+		t.Fatalf("WriteMarkdown() returned error: %v", err)
+	}
+
+	markdown := buf.String()
+	for _, expected := range []string{"## shop_setup", "- `products`", "`{ sku: 1 }` | created", "`{ name: 1 }` | already existed", "| `products` | 4 |"} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("Expected Markdown report to contain %q, got:\n%s", expected, markdown)
+		}
+	}
+
+	buf.Reset()
+	if err := result.WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML() returned error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "<code>{ sku: 1 }</code>") {
+		t.Errorf("Expected HTML report to list index keys, got:\n%s", buf.String())
+	}
+}
+
+func TestScriptResultSummary(t *testing.T) {
+	result := ScriptResult{
+		Duration: 50 * time.Millisecond,
+		Operations: []OperationResult{
+			{Type: "createCollection", Status: StatusSucceeded, Duration: 5 * time.Millisecond},
+			{Type: "createIndexes", Status: StatusSucceeded, IndexKeys: []bson.D{{{Key: "a", Value: 1}}, {{Key: "b", Value: 1}}}, Duration: 10 * time.Millisecond},
+			{Type: "createIndex", Status: StatusSkipped, IndexKeys: []bson.D{{{Key: "c", Value: 1}}}},
+			{Type: "insert", Status: StatusSucceeded, Documents: 25, Duration: 20 * time.Millisecond},
+			{Type: "delete", Status: StatusFailed},
+			{Type: "insert", Status: StatusNotRun},
+		},
+	}
+
+	summary := result.Summary()
+	if summary.Operations != 6 || summary.ByType["insert"] != 2 {
+		t.Errorf("Unexpected operation counts: %+v", summary)
+	}
+
+	if summary.Succeeded != 3 || summary.Skipped != 1 || summary.Failed != 1 || summary.NotRun != 1 {
+		t.Errorf("Unexpected status counts: %+v", summary)
+	}
+
+	if summary.IndexesCreated != 2 || summary.CollectionsCreated != 1 || summary.DocumentsWritten != 25 {
+		t.Errorf("Unexpected totals: %+v", summary)
+	}
+
+	if summary.OperationTime != 35*time.Millisecond || summary.Duration != 50*time.Millisecond {
+		t.Errorf("Unexpected durations: %+v", summary)
+	}
+}
@@ -0,0 +1,84 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Registers the operation that undoes something the current execution created
+func (p *Parser) compensate(op MongoOperation) {
+	if p.undo != nil {
+		*p.undo = append(*p.undo, op)
+	}
+}
+
+// Registers the deletion of documents inserted with known _ids
+func (p *Parser) compensateInsert(op MongoOperation, ids []interface{}) {
+	if len(ids) == 0 {
+		return
+	}
+	p.compensate(MongoOperation{
+		Type:       "delete",
+		Collection: op.Collection,
+		Operation:  "deleteMany",
+		Arguments:  []bson.D{{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A(ids)}}}}},
+	})
+}
+
+// Returns the compensating operations of the current execution, most recent first
+func (p *Parser) rollbackPlan() []MongoOperation {
+	if p.undo == nil {
+		return nil
+	}
+	plan := make([]MongoOperation, 0, len(*p.undo))
+	for i := len(*p.undo) - 1; i >= 0; i-- {
+		plan = append(plan, (*p.undo)[i])
+	}
+	return plan
+}
+
+// Executes the compensating operations of a failed script, restoring the state
+// from before it ran. Updates, deletes and upserted inserts can't be reversed
+// and are left as they are.
+func (p *Parser) Rollback(ctx context.Context, db *mongo.Database, result ScriptResult) error {
+	for _, op := range result.Rollback {
+		if err := p.executeCompensation(ctx, db, op); err != nil {
+			return newOperationError(op, err)
+		}
+	}
+	return nil
+}
+
+// Executes one compensating operation. These bypass the script guards such as
+// delete limits, since they only remove what the script itself created.
+func (p *Parser) executeCompensation(ctx context.Context, db *mongo.Database, op MongoOperation) error {
+	collection := db.Collection(op.Collection)
+	switch op.Type {
+	case "dropCollection":
+		return collection.Drop(ctx)
+	case "dropIndex":
+		name, ok := op.IndexSpec.(string)
+		if !ok {
+			return fmt.Errorf("dropIndex needs an index name, got %T", op.IndexSpec)
+		}
+		_, err := collection.Indexes().DropOne(ctx, name)
+		return err
+	case "dropSearchIndex":
+		name, ok := op.IndexSpec.(string)
+		if !ok {
+			return fmt.Errorf("dropSearchIndex needs an index name, got %T", op.IndexSpec)
+		}
+		return collection.SearchIndexes().DropOne(ctx, name)
+	case "delete":
+		if len(op.Arguments) == 0 {
+			return fmt.Errorf("no filter for compensating delete")
+		}
+		_, err := collection.DeleteMany(ctx, op.Arguments[0])
+		return err
+	default:
+		return fmt.Errorf("unsupported compensating operation: %s", op.Type)
+	}
+}
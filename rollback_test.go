@@ -0,0 +1,38 @@
+package mongoparser
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRollbackPlan(t *testing.T) {
+	parser := NewParser(WithRollback(false))
+	run := *parser
+	run.undo = &[]MongoOperation{}
+
+	run.compensate(MongoOperation{Type: "dropCollection", Collection: "users", Operation: "drop"})
+	run.compensate(MongoOperation{Type: "dropIndex", Collection: "users", Operation: "dropIndex", IndexSpec: "email_1"})
+	run.compensateInsert(MongoOperation{Collection: "users"}, []interface{}{1, 2})
+	run.compensateInsert(MongoOperation{Collection: "users"}, nil)
+
+	plan := run.rollbackPlan()
+	if len(plan) != 3 {
+		t.Fatalf("Expected 3 compensating operations, got %d", len(plan))
+	}
+	if plan[0].Type != "delete" || plan[1].Type != "dropIndex" || plan[2].Type != "dropCollection" {
+		t.Errorf("Expected compensations in reverse order, got %s, %s, %s", plan[0].Type, plan[1].Type, plan[2].Type)
+	}
+
+	filter, _ := lookupKey(plan[0].Arguments[0], "_id")
+	in, _ := lookupKey(filter.(bson.D), "$in")
+	if ids := in.(bson.A); len(ids) != 2 {
+		t.Errorf("Expected delete of both inserted ids, got %v", ids)
+	}
+
+	// Without WithRollback nothing is collected
+	NewParser().compensate(MongoOperation{Type: "dropCollection"})
+	if plan := NewParser().rollbackPlan(); plan != nil {
+		t.Errorf("Expected no rollback plan when rollback is disabled, got %v", plan)
+	}
+}
@@ -0,0 +1,165 @@
+package mongoparser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Default collection recording which scripts have been applied to a database
+const DefaultMigrationCollection = "_migrations"
+
+// Tracks which scripts have been applied to a database and applies new ones
+type Runner struct {
+	parser     *Parser
+	db         *mongo.Database
+	collection string
+}
+
+// Records the latest execution of a script in the migrations collection
+type MigrationRecord struct {
+	Name       string        `bson:"_id" json:"name"`
+	Version    string        `bson:"version,omitempty" json:"version,omitempty"`
+	Checksum   string        `bson:"checksum" json:"checksum"`
+	Canonical  string        `bson:"canonical,omitempty" json:"canonical,omitempty"`
+	Status     string        `bson:"status" json:"status"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	ExecutedAt time.Time     `bson:"executedAt" json:"executed_at"`
+	Duration   time.Duration `bson:"duration" json:"duration"`
+}
+
+// Migration statuses
+const (
+	MigrationApplied = "applied"
+	MigrationFailed  = "failed"
+)
+
+// Creates a runner applying scripts to db with parser, tracking them in the
+// given collection (DefaultMigrationCollection when empty)
+func NewRunner(parser *Parser, db *mongo.Database, collection string) *Runner {
+	if collection == "" {
+		collection = DefaultMigrationCollection
+	}
+	return &Runner{parser: parser, db: db, collection: collection}
+}
+
+// Applies a script unless the same content has already been applied. Applying a
+// script whose content changed since it succeeded is an error, unless only its
+// formatting or comments changed.
+func (r *Runner) Apply(ctx context.Context, script ScriptInfo) ScriptResult {
+	return r.apply(ctx, script, false)
+}
+
+// Applies a script unless the same content has already been applied. A changed
+// script is refused, or executed again when reapply is set.
+func (r *Runner) apply(ctx context.Context, script ScriptInfo, reapply bool) ScriptResult {
+	if script.Metadata == nil {
+		script.Metadata = r.parser.ParseMetadata(script.Content)
+	}
+	name := scriptName(script)
+	checksum := Checksum(script.Content)
+	// Empty when the script doesn't parse; the content checksum decides then
+	canonical, _ := r.parser.CanonicalChecksum(script.Content)
+
+	previous, err := r.record(ctx, name)
+	if err != nil {
+		return ScriptResult{Name: name, Error: err, StartedAt: time.Now()}
+	}
+	if previous != nil && previous.Status == MigrationApplied {
+		changed := previous.Checksum != checksum && (canonical == "" || previous.Canonical != canonical)
+		if !changed {
+			return ScriptResult{
+				Success:   true,
+				Output:    "Script already applied, skipped",
+				Name:      name,
+				Version:   previous.Version,
+				StartedAt: time.Now(),
+			}
+		}
+		if !reapply {
+			return ScriptResult{
+				Name:      name,
+				Error:     fmt.Errorf("script %s changed since it was applied (checksum %s, now %s)", name, previous.Checksum, checksum),
+				StartedAt: time.Now(),
+			}
+		}
+	}
+
+	result := r.parser.ExecuteScript(ctx, r.db, script.Content)
+	if result.Name == "" {
+		result.Name = name
+	}
+
+	record := MigrationRecord{
+		Name:       name,
+		Version:    result.Version,
+		Checksum:   checksum,
+		Canonical:  canonical,
+		Status:     MigrationApplied,
+		ExecutedAt: result.StartedAt,
+		Duration:   result.Duration,
+	}
+	if !result.Success {
+		record.Status = MigrationFailed
+		record.Error = result.Error.Error()
+	}
+	if err := r.save(ctx, record); err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("failed to record migration %s: %v", name, err))
+	}
+	return result
+}
+
+// Lists the recorded scripts ordered by execution time
+func (r *Runner) History(ctx context.Context) ([]MigrationRecord, error) {
+	cursor, err := r.db.Collection(r.collection).Find(ctx, bson.D{},
+		options.Find().SetSort(bson.D{{Key: "executedAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration history: %w", err)
+	}
+
+	var records []MigrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read migration history: %w", err)
+	}
+	return records, nil
+}
+
+// Returns the record of a script, or nil if it has never run
+func (r *Runner) record(ctx context.Context, name string) (*MigrationRecord, error) {
+	var record MigrationRecord
+	err := r.db.Collection(r.collection).FindOne(ctx, bson.D{{Key: "_id", Value: name}}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration record %s: %w", name, err)
+	}
+	return &record, nil
+}
+
+// Stores the latest execution of a script
+func (r *Runner) save(ctx context.Context, record MigrationRecord) error {
+	_, err := r.db.Collection(r.collection).ReplaceOne(ctx, bson.D{{Key: "_id", Value: record.Name}}, record, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Returns the hex SHA-256 checksum identifying a script's content
+func Checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Names a script by its metadata name, falling back to the discovered name
+func scriptName(script ScriptInfo) string {
+	if script.Metadata != nil && script.Metadata.Name != "" {
+		return script.Metadata.Name
+	}
+	return script.Name
+}
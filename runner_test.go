@@ -0,0 +1,220 @@
+package mongoparser_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	mongoparser "github.com/artumont/MongoDBParser"
+	"github.com/artumont/MongoDBParser/mongoparsertest"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const runnerScript = `// METADATA:
+// {"name": "add-users", "version": "1.0.0"}
+db.users.insertOne({ email: "a@example.com" });`
+
+func TestChecksum(t *testing.T) {
+	if mongoparser.Checksum("db.users.insertOne({});") == mongoparser.Checksum("db.users.insertOne({ });") {
+		t.Error("Expected different content to have different checksums")
+	}
+	if len(mongoparser.Checksum("")) != 64 {
+		t.Error("Expected a hex-encoded SHA-256 checksum")
+	}
+}
+
+// Keeps the migrations collection of a recorder in memory, in insertion order
+type migrations struct {
+	records []bson.Raw
+}
+
+// Answers the runner's reads and writes of the migrations collection; other
+// commands get the recorder's default reply
+func (m *migrations) reply(cmd mongoparsertest.Command) bson.D {
+	if cmd.Collection != mongoparser.DefaultMigrationCollection {
+		return nil
+	}
+	switch cmd.Name {
+	case "find":
+		id, filtered := cmd.Lookup("filter", "_id").StringValueOK()
+		batch := bson.A{}
+		for _, record := range m.records {
+			if !filtered || record.Lookup("_id").StringValue() == id {
+				batch = append(batch, record)
+			}
+		}
+		return bson.D{
+			{Key: "cursor", Value: bson.D{{Key: "id", Value: int64(0)}, {Key: "ns", Value: cmd.Database + "." + cmd.Collection}, {Key: "firstBatch", Value: batch}}},
+			{Key: "ok", Value: 1.0},
+		}
+	case "update":
+		statement := cmd.Lookup("updates").Array().Index(0).Value().Document()
+		id := statement.Lookup("q", "_id").StringValue()
+		replacement := statement.Lookup("u").Document()
+		for i, record := range m.records {
+			if record.Lookup("_id").StringValue() == id {
+				m.records[i] = replacement
+				return bson.D{{Key: "n", Value: int32(1)}, {Key: "nModified", Value: int32(1)}, {Key: "ok", Value: 1.0}}
+			}
+		}
+		m.records = append(m.records, replacement)
+		return bson.D{
+			{Key: "n", Value: int32(1)},
+			{Key: "nModified", Value: int32(0)},
+			{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: int32(0)}, {Key: "_id", Value: id}}}},
+			{Key: "ok", Value: 1.0},
+		}
+	}
+	return nil
+}
+
+// Returns the recorded migration of a script
+func (m *migrations) record(t *testing.T, name string) mongoparser.MigrationRecord {
+	t.Helper()
+	for _, raw := range m.records {
+		var record mongoparser.MigrationRecord
+		if err := bson.Unmarshal(raw, &record); err != nil {
+			t.Fatalf("Failed to decode migration record: %v", err)
+		}
+		if record.Name == name {
+			return record
+		}
+	}
+	t.Fatalf("Expected a migration record for %s", name)
+	return mongoparser.MigrationRecord{}
+}
+
+// Counts the inserts into the users collection sent so far
+func userInserts(recorder *mongoparsertest.Recorder) int {
+	n := 0
+	for _, cmd := range recorder.Commands() {
+		if cmd.Name == "insert" && cmd.Collection == "users" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRunnerSkipsAppliedScript(t *testing.T) {
+	recorder := mongoparsertest.NewRecorder(t)
+	store := &migrations{}
+	recorder.Reply(store.reply)
+	runner := mongoparser.NewRunner(mongoparser.NewParser(), recorder.Database("app"), "")
+
+	result := runner.Apply(t.Context(), mongoparser.ScriptInfo{Name: "001_users.js", Content: runnerScript})
+	if !result.Success || userInserts(recorder) != 1 {
+		t.Fatalf("Expected the script to be applied, got %v", result.Error)
+	}
+	record := store.record(t, "add-users")
+	if record.Status != mongoparser.MigrationApplied || record.Version != "1.0.0" || record.Checksum != mongoparser.Checksum(runnerScript) || record.Canonical == "" {
+		t.Errorf("Unexpected migration record: %+v", record)
+	}
+
+	reformatted := strings.Replace(runnerScript, `db.users.insertOne({ email: "a@example.com" });`, "\n// seed the first user\ndb.users.insertOne({email: 'a@example.com'})", 1)
+	for _, content := range []string{runnerScript, reformatted} {
+		result := runner.Apply(t.Context(), mongoparser.ScriptInfo{Name: "001_users.js", Content: content})
+		if !result.Success || result.Output != "Script already applied, skipped" || result.Version != "1.0.0" {
+			t.Errorf("Expected an unchanged script to be skipped, got %+v", result)
+		}
+	}
+	if n := userInserts(recorder); n != 1 {
+		t.Errorf("Expected the script to run once, got %d inserts", n)
+	}
+	if len(store.records) != 1 {
+		t.Errorf("Expected skipped scripts not to be recorded again, got %d records", len(store.records))
+	}
+}
+
+func TestRunnerRefusesChangedScript(t *testing.T) {
+	recorder := mongoparsertest.NewRecorder(t)
+	store := &migrations{}
+	recorder.Reply(store.reply)
+	runner := mongoparser.NewRunner(mongoparser.NewParser(), recorder.Database("app"), "")
+
+	if result := runner.Apply(t.Context(), mongoparser.ScriptInfo{Name: "001_users.js", Content: runnerScript}); !result.Success {
+		t.Fatalf("Expected the script to be applied, got %v", result.Error)
+	}
+
+	changed := strings.Replace(runnerScript, "a@example.com", "b@example.com", 1)
+	result := runner.Apply(t.Context(), mongoparser.ScriptInfo{Name: "001_users.js", Content: changed})
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "script add-users changed since it was applied") {
+		t.Fatalf("Expected a changed script to be refused, got %+v", result)
+	}
+	if n := userInserts(recorder); n != 1 {
+		t.Errorf("Expected the changed script not to run, got %d inserts", n)
+	}
+	if record := store.record(t, "add-users"); record.Checksum != mongoparser.Checksum(runnerScript) {
+		t.Errorf("Expected the applied checksum to be kept, got %s", record.Checksum)
+	}
+}
+
+func TestRunnerRerunsFailedScript(t *testing.T) {
+	recorder := mongoparsertest.NewRecorder(t)
+	store := &migrations{}
+	failing := true
+	recorder.Reply(func(cmd mongoparsertest.Command) bson.D {
+		if failing && cmd.Name == "insert" && cmd.Collection == "users" {
+			return bson.D{{Key: "ok", Value: 0.0}, {Key: "errmsg", Value: "not enough disk space"}, {Key: "code", Value: int32(14031)}}
+		}
+		return store.reply(cmd)
+	})
+	runner := mongoparser.NewRunner(mongoparser.NewParser(), recorder.Database("app"), "")
+
+	result := runner.Apply(t.Context(), mongoparser.ScriptInfo{Name: "001_users.js", Content: runnerScript})
+	if result.Success {
+		t.Fatal("Expected the script to fail")
+	}
+	record := store.record(t, "add-users")
+	if record.Status != mongoparser.MigrationFailed || !strings.Contains(record.Error, "not enough disk space") {
+		t.Errorf("Expected the failure to be recorded, got %+v", record)
+	}
+
+	failing = false
+	result = runner.Apply(t.Context(), mongoparser.ScriptInfo{Name: "001_users.js", Content: runnerScript})
+	if !result.Success {
+		t.Fatalf("Expected a failed script to run again, got %v", result.Error)
+	}
+	if n := userInserts(recorder); n != 2 {
+		t.Errorf("Expected the script to run twice, got %d inserts", n)
+	}
+	if record := store.record(t, "add-users"); record.Status != mongoparser.MigrationApplied || record.Error != "" {
+		t.Errorf("Expected the retry to replace the failure, got %+v", record)
+	}
+}
+
+func TestRunnerHistory(t *testing.T) {
+	recorder := mongoparsertest.NewRecorder(t)
+	store := &migrations{}
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"add-users", "add-orders", "add-index"} {
+		record, err := bson.Marshal(mongoparser.MigrationRecord{
+			Name:       name,
+			Checksum:   mongoparser.Checksum(name),
+			Status:     mongoparser.MigrationApplied,
+			ExecutedAt: start.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.records = append(store.records, record)
+	}
+	recorder.Reply(store.reply)
+
+	history, err := mongoparser.NewRunner(mongoparser.NewParser(), recorder.Database("app"), "").History(t.Context())
+	if err != nil {
+		t.Fatalf("History() returned error: %v", err)
+	}
+	if len(history) != 3 || history[0].Name != "add-users" || history[2].Name != "add-index" || !history[1].ExecutedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+
+	commands := recorder.Commands()
+	if len(commands) != 1 || commands[0].Name != "find" {
+		t.Fatalf("Expected a single find, got %v", commands)
+	}
+	sort, err := commands[0].Lookup("sort").Document().Elements()
+	if err != nil || len(sort) != 2 || sort[0].Key() != "executedAt" || sort[1].Key() != "_id" ||
+		sort[0].Value().AsInt64() != 1 || sort[1].Value().AsInt64() != 1 {
+		t.Errorf("Expected history sorted by execution time, then name, got %s", commands[0].Body)
+	}
+}
@@ -0,0 +1,319 @@
+package mongoparser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Reads complete statements from a script one at a time, so scripts of any
+// size can be executed without holding all of their operations in memory
+type statementScanner struct {
+	reader     *bufio.Reader
+	current    strings.Builder
+	braceLevel int
+	inQuotes   bool
+	quoteChar  rune
+
+	// Leading comment block, read before the first statement
+	leading    strings.Builder
+	headerRead bool
+	pending    string
+	hasPending bool
+
+	// Annotation comments read since the last statement
+	annotations []string
+	// Environments the current ENV section is limited to; nil outside sections
+	environments []string
+
+	// Number of lines and bytes read, and the offset of the line read last
+	line       int
+	offset     int
+	lineOffset int
+	// Where the statement being read, or returned last, sits in the script
+	location SourceLocation
+	text     strings.Builder
+
+	eof bool
+	err error
+}
+
+// Creates a scanner reading script content from r, which may be UTF-8 with or
+// without a byte order mark or UTF-16
+func newStatementScanner(r io.Reader) *statementScanner {
+	return &statementScanner{reader: bufio.NewReader(normalizeEncoding(r))}
+}
+
+// Returns the comment and blank lines preceding the first statement, which is
+// where script metadata lives
+func (s *statementScanner) header() string {
+	if s.headerRead {
+		return s.leading.String()
+	}
+	s.headerRead = true
+
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && (!strings.HasPrefix(trimmed, "//") || isDirective(trimmed) || isAnnotation(trimmed) || isEnvironmentMarker(trimmed)) {
+			s.pending = line
+			s.hasPending = true
+			break
+		}
+		s.leading.WriteString(line)
+		s.leading.WriteByte('\n')
+	}
+	return s.leading.String()
+}
+
+// Reports whether the script contains nothing but whitespace
+func (s *statementScanner) empty() bool {
+	return strings.TrimSpace(s.header()) == "" && !s.hasPending
+}
+
+// Returns the next complete statement, or false once the script is exhausted
+func (s *statementScanner) next() (string, bool) {
+	s.header()
+
+	for {
+		var line string
+		if s.hasPending {
+			line, s.hasPending = s.pending, false
+		} else {
+			var ok bool
+			if line, ok = s.readLine(); !ok {
+				break
+			}
+		}
+
+		indent := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+		line = strings.TrimSpace(line)
+		if isDirective(line) && s.current.Len() == 0 {
+			s.locate(indent, line, true)
+			return line, true
+		}
+		if isAnnotation(line) && s.current.Len() == 0 {
+			s.annotations = append(s.annotations, line)
+			continue
+		}
+		if isEnvironmentMarker(line) && s.current.Len() == 0 {
+			s.environments = parseEnvironments(line)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if line = stripLineComment(line, s.inQuotes, s.quoteChar); line == "" {
+			continue
+		}
+
+		// Add this line to current statement
+		s.locate(indent, line, s.current.Len() == 0)
+		if s.current.Len() > 0 {
+			s.current.WriteRune(' ')
+		}
+		s.current.WriteString(line)
+
+		// Count braces and quotes to determine when statement ends
+		escaped := false
+		for _, char := range line {
+			if escaped {
+				escaped = false
+				continue
+			}
+			switch char {
+			case '\\':
+				escaped = s.inQuotes
+			case '"', '\'':
+				if !s.inQuotes {
+					s.inQuotes = true
+					s.quoteChar = char
+				} else if char == s.quoteChar {
+					s.inQuotes = false
+				}
+			case '{':
+				if !s.inQuotes {
+					s.braceLevel++
+				}
+			case '}':
+				if !s.inQuotes {
+					s.braceLevel--
+				}
+			}
+		}
+
+		// If statement ends with semicolon and braces are balanced, it's complete
+		if strings.HasSuffix(line, ";") && s.braceLevel == 0 && !s.inQuotes {
+			return s.flush(), true
+		}
+	}
+
+	// Return any remaining content as a statement
+	if s.current.Len() > 0 {
+		return s.flush(), true
+	}
+	return "", false
+}
+
+// Removes a trailing // comment from a line, leaving // inside strings such as
+// URLs alone. inQuotes and quoteChar give the quoting state the line starts in.
+func stripLineComment(line string, inQuotes bool, quoteChar rune) string {
+	if !strings.Contains(line, "//") {
+		return line
+	}
+	escaped := false
+	for i, char := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes:
+			if char == '\\' {
+				escaped = true
+			} else if char == quoteChar {
+				inQuotes = false
+			}
+		case char == '"' || char == '\'':
+			inQuotes = true
+			quoteChar = char
+		case char == '/' && strings.HasPrefix(line[i:], "//"):
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return line
+}
+
+// Reports whether a comment line is a directive executed as a statement
+func isDirective(line string) bool {
+	return strings.HasPrefix(line, uploadDirective) ||
+		strings.HasPrefix(line, encryptDirective) ||
+		strings.HasPrefix(line, seedDirective) ||
+		strings.HasPrefix(line, importDirective)
+}
+
+// Parses a directive comment into its operation
+func (p *Parser) parseDirective(line string) (*MongoOperation, error) {
+	switch {
+	case strings.HasPrefix(line, encryptDirective):
+		return p.parseEncrypt(line)
+	case strings.HasPrefix(line, seedDirective):
+		return p.parseSeed(line)
+	case strings.HasPrefix(line, importDirective):
+		return p.parseImport(line)
+	default:
+		return p.parseUpload(line)
+	}
+}
+
+// Extends the location of the current statement by a line whose content,
+// without surrounding whitespace and comments, starts after indent bytes
+func (s *statementScanner) locate(indent int, content string, first bool) {
+	if first {
+		s.text.Reset()
+		s.location = SourceLocation{StartLine: s.line, StartOffset: s.lineOffset + indent}
+	} else {
+		s.text.WriteByte('\n')
+		s.text.WriteString(strings.Repeat(" ", indent))
+	}
+	s.text.WriteString(content)
+	s.location.EndLine = s.line
+	s.location.EndOffset = s.lineOffset + indent + len(content)
+	s.location.Text = s.text.String()
+}
+
+// Returns the annotations preceding the statement last returned by next
+func (s *statementScanner) takeAnnotations() []string {
+	annotations := s.annotations
+	s.annotations = nil
+	return annotations
+}
+
+// Returns the buffered statement and resets the buffer
+func (s *statementScanner) flush() string {
+	statement := s.current.String()
+	s.current.Reset()
+	return statement
+}
+
+// Reads one line without its line ending; lines may be arbitrarily long
+func (s *statementScanner) readLine() (string, bool) {
+	if s.eof {
+		return "", false
+	}
+
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		s.eof = true
+		if err != io.EOF {
+			s.err = err
+			return "", false
+		}
+		if line == "" {
+			return "", false
+		}
+	}
+	s.line++
+	s.lineOffset = s.offset
+	s.offset += len(line)
+	// Windows line endings leave a carriage return before the newline
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), true
+}
+
+// Splits JavaScript content into complete statements
+func (p *Parser) splitIntoStatements(jsContent string) []string {
+	var statements []string
+	scanner := newStatementScanner(strings.NewReader(jsContent))
+	for {
+		statement, ok := scanner.next()
+		if !ok {
+			break
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// Yields the operations of a script in order
+type operationSource interface {
+	// Comment block preceding the first statement, holding script metadata
+	header() string
+	// Reports whether the script contains nothing but whitespace
+	empty() bool
+	// Returns the next operation, or nil once the script is exhausted
+	next() *MongoOperation
+	// Returns the error that stopped reading the script early, if any
+	err() error
+}
+
+// Parses operations from a statement scanner as they are read
+type statementSource struct {
+	parser  *Parser
+	scanner *statementScanner
+}
+
+func (s *statementSource) header() string { return s.scanner.header() }
+func (s *statementSource) empty() bool    { return s.scanner.empty() }
+func (s *statementSource) err() error     { return s.scanner.err }
+
+// Parses statements until one yields an operation
+func (s *statementSource) next() *MongoOperation {
+	for {
+		statement, ok := s.scanner.next()
+		if !ok {
+			return nil
+		}
+		annotations := s.scanner.takeAnnotations()
+		if !s.parser.inEnvironment(s.scanner.environments) {
+			continue
+		}
+		if op := s.parser.parseStatement(statement); op != nil {
+			location := s.scanner.location
+			op.Location = &location
+			s.parser.annotate(op, annotations)
+			return op
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStatementScanner(t *testing.T) {
+	script := `// METADATA:
+// { "name": "seed", "version": "1.0.0" }
+
+db.createCollection("users", {
+    validator: { $jsonSchema: { bsonType: "object" } }
+});
+// trailing comment
+db.users.createIndex({ email: 1 });
+db.users.insertOne({ note: "` + strings.Repeat("x", 100000) + `" })`
+
+	scanner := newStatementScanner(strings.NewReader(script))
+	if header := scanner.header(); !strings.Contains(header, `"name": "seed"`) {
+		t.Errorf("Expected header to contain metadata, got %q", header)
+	}
+
+	var statements []string
+	for {
+		statement, ok := scanner.next()
+		if !ok {
+			break
+		}
+		statements = append(statements, statement)
+	}
+
+	if len(statements) != 3 {
+		t.Fatalf("Expected 3 statements, got %d: %q", len(statements), statements)
+	}
+	if !strings.HasPrefix(statements[0], "db.createCollection(") || !strings.HasSuffix(statements[0], "});") {
+		t.Errorf("Unexpected first statement: %q", statements[0])
+	}
+	if len(statements[2]) < 100000 {
+		t.Errorf("Expected long final statement to be read whole, got %d bytes", len(statements[2]))
+	}
+}
+
+func TestStatementScannerInlineComments(t *testing.T) {
+	script := `db.sites.insertOne({ url: "https://example.com//a", // the homepage
+    note: 'say "hi" // not a comment', quote: "a \" // b" }); // seeded for tests
+db.sites.createIndex({ url: 1 }); // lookups`
+
+	scanner := newStatementScanner(strings.NewReader(script))
+	var statements []string
+	for statement, ok := scanner.next(); ok; statement, ok = scanner.next() {
+		statements = append(statements, statement)
+	}
+
+	expected := []string{
+		`db.sites.insertOne({ url: "https://example.com//a", note: 'say "hi" // not a comment', quote: "a \" // b" });`,
+		`db.sites.createIndex({ url: 1 });`,
+	}
+	if len(statements) != len(expected) {
+		t.Fatalf("Expected %d statements, got %q", len(expected), statements)
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], statements[i])
+		}
+	}
+}
+
+func TestStatementLocations(t *testing.T) {
+	script := "// METADATA:\r\n// {\"name\": \"users\"}\r\n\r\n" +
+		"  db.users.insertOne({\r\n" +
+		"    // the first user\r\n" +
+		"    name: \"Ana\" // inline\r\n" +
+		"  });\r\n" +
+		"// UPLOAD: logo.png -> assets\r\n" +
+		"db.users.drop(); // cleanup\r\n"
+
+	ops, err := NewParser().parseJavaScriptOperations(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 3 {
+		t.Fatalf("Expected 3 operations, got %d", len(ops))
+	}
+
+	expected := []SourceLocation{
+		{StartLine: 4, EndLine: 7, Text: "db.users.insertOne({\n    name: \"Ana\"\n  });"},
+		{StartLine: 8, EndLine: 8, Text: "// UPLOAD: logo.png -> assets"},
+		{StartLine: 9, EndLine: 9, Text: "db.users.drop();"},
+	}
+	for i, op := range ops {
+		location := op.Location
+		if location == nil {
+			t.Fatalf("Operation %d has no location", i)
+		}
+		if location.StartLine != expected[i].StartLine || location.EndLine != expected[i].EndLine || location.Text != expected[i].Text {
+			t.Errorf("Operation %d: expected %+v, got %+v", i, expected[i], *location)
+		}
+	}
+
+	// Offsets point at the statement in the original script
+	first := ops[0].Location
+	if got := script[first.StartOffset:first.EndOffset]; !strings.HasPrefix(got, "db.users.insertOne({") || !strings.HasSuffix(got, "  });") {
+		t.Errorf("Unexpected text at offsets %d-%d: %q", first.StartOffset, first.EndOffset, got)
+	}
+	last := ops[2].Location
+	if got := script[last.StartOffset:last.EndOffset]; got != "db.users.drop();" {
+		t.Errorf("Unexpected text at offsets %d-%d: %q", last.StartOffset, last.EndOffset, got)
+	}
+	if first.String() != "lines 4-7" || last.String() != "line 9" {
+		t.Errorf("Unexpected line ranges %s and %s", first, last)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("disk error")
+}
+
+func TestExecuteReader(t *testing.T) {
+	parser := NewParser()
+
+	result := parser.ExecuteReader(context.Background(), nil, strings.NewReader("  \n\t\n"))
+	if !result.Success || result.Output != "Script is empty, skipped" {
+		t.Errorf("Expected empty script to be skipped, got %+v", result)
+	}
+
+	result = parser.ExecuteReader(context.Background(), nil, strings.NewReader("// METADATA:\n// { \"name\": \"noop\" }\n"))
+	if !result.Success || result.Name != "noop" {
+		t.Errorf("Expected comment-only script to succeed with metadata, got %+v", result)
+	}
+
+	result = parser.ExecuteReader(context.Background(), nil, io.MultiReader(strings.NewReader("// seed data\n"), failingReader{}))
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "disk error") {
+		t.Errorf("Expected read error to fail the script, got %+v", result)
+	}
+}
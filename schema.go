@@ -0,0 +1,181 @@
+package mongoparser
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Schema a set of scripts declares for one collection
+type CollectionSchema struct {
+	Name string
+	// Validator of the latest createCollection, nil when it has none
+	Validator interface{}
+	// $jsonSchema of the validator, nil when the validator doesn't use one
+	JSONSchema bson.D
+	// Options of the latest createCollection, nil when the collection is only
+	// created implicitly by an index
+	Options *options.CreateCollectionOptions
+	// Latest createCollection statement as written, without comments
+	Statement string
+	// Indexes in the order they were declared, without the _id index
+	Indexes []IndexDefinition
+	// Scripts that declared the collection, its validator or its indexes
+	Scripts []string
+}
+
+// Index declared by a script
+type IndexDefinition struct {
+	// Name given by the script, or the server's default name for the keys
+	Name    string
+	Keys    bson.D
+	Options *options.IndexOptions
+	// Script that declared the index last, and where
+	Script   string
+	Location *SourceLocation
+}
+
+// Returns the index with the given name, if the collection has it
+func (c CollectionSchema) Index(name string) (IndexDefinition, bool) {
+	for _, index := range c.Indexes {
+		if index.Name == name {
+			return index, true
+		}
+	}
+	return IndexDefinition{}, false
+}
+
+// Collects the validators and indexes declared across a set of scripts, as
+// they stand after the scripts run in order: later declarations replace
+// earlier ones and dropped collections and indexes are forgotten. Nothing is
+// read from a database.
+type SchemaRegistry struct {
+	collections map[string]*CollectionSchema
+}
+
+// Creates an empty registry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{collections: make(map[string]*CollectionSchema)}
+}
+
+// Parses each script and adds what it declares to a new registry. Scripts are
+// taken in the given order, named by their metadata name when they have one.
+func (p *Parser) SchemaRegistry(scripts []ScriptInfo) (*SchemaRegistry, error) {
+	registry := NewSchemaRegistry()
+	for _, script := range scripts {
+		ops, err := p.ParseScript(script.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse script %s: %w", script.Name, err)
+		}
+		if script.Metadata == nil {
+			script.Metadata = p.ParseMetadata(script.Content)
+		}
+		registry.Add(scriptName(script), ops)
+	}
+	return registry, nil
+}
+
+// Records the collections, validators and indexes declared by the operations
+// of a script, including those inside transactions
+func (r *SchemaRegistry) Add(script string, ops []MongoOperation) {
+	for _, op := range ops {
+		switch op.Type {
+		case "createCollection":
+			collection := r.declare(op.Collection, script)
+			collection.Validator = op.Validator
+			collection.JSONSchema = nil
+			if validator, ok := op.Validator.(bson.D); ok {
+				schema, _ := lookupKey(validator, "$jsonSchema")
+				collection.JSONSchema, _ = schema.(bson.D)
+			}
+			collection.Options = op.CollOptions
+			collection.Statement = op.Statement
+			if op.Location != nil {
+				collection.Statement = op.Location.Text
+			}
+		case "createIndex":
+			if keys, ok := op.IndexSpec.(bson.D); ok {
+				r.declare(op.Collection, script).addIndex(keys, op.IndexOptions, script, op.Location)
+			}
+		case "createIndexes":
+			for _, model := range op.IndexModels {
+				if keys, ok := model.Keys.(bson.D); ok {
+					r.declare(op.Collection, script).addIndex(keys, model.Options, script, op.Location)
+				}
+			}
+		case "dropIndex":
+			if collection, ok := r.collections[op.Collection]; ok {
+				collection.Indexes = slices.DeleteFunc(collection.Indexes, func(index IndexDefinition) bool {
+					if name, ok := op.IndexSpec.(string); ok {
+						return index.Name == name
+					}
+					return valuesEqual(index.Keys, op.IndexSpec)
+				})
+			}
+		case "dropIndexes":
+			if collection, ok := r.collections[op.Collection]; ok {
+				collection.Indexes = nil
+			}
+		case "dropCollection":
+			delete(r.collections, op.Collection)
+		case "dropDatabase":
+			r.collections = make(map[string]*CollectionSchema)
+		case "transaction":
+			r.Add(script, op.Transaction)
+		}
+	}
+}
+
+// Returns the collection's entry, creating it, and notes the script declaring it
+func (r *SchemaRegistry) declare(name, script string) *CollectionSchema {
+	collection, ok := r.collections[name]
+	if !ok {
+		collection = &CollectionSchema{Name: name}
+		r.collections[name] = collection
+	}
+	if !slices.Contains(collection.Scripts, script) {
+		collection.Scripts = append(collection.Scripts, script)
+	}
+	return collection
+}
+
+// Adds an index, replacing an earlier one with the same name or keys
+func (c *CollectionSchema) addIndex(keys bson.D, opts *options.IndexOptions, script string, location *SourceLocation) {
+	name := defaultIndexName(keys)
+	if opts != nil && opts.Name != nil {
+		name = *opts.Name
+	}
+	index := IndexDefinition{Name: name, Keys: keys, Options: opts, Script: script, Location: location}
+	for i, existing := range c.Indexes {
+		if existing.Name == name || valuesEqual(existing.Keys, keys) {
+			c.Indexes[i] = index
+			return
+		}
+	}
+	c.Indexes = append(c.Indexes, index)
+}
+
+// Returns the names of the declared collections, sorted
+func (r *SchemaRegistry) Collections() []string {
+	names := make([]string, 0, len(r.collections))
+	for name := range r.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns what the scripts declare for a collection, if they declare it
+func (r *SchemaRegistry) Collection(name string) (CollectionSchema, bool) {
+	collection, ok := r.collections[name]
+	if !ok {
+		return CollectionSchema{}, false
+	}
+	schema := *collection
+	schema.Indexes = slices.Clone(collection.Indexes)
+	schema.Scripts = slices.Clone(collection.Scripts)
+	return schema, true
+}
@@ -0,0 +1,75 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	scripts := []ScriptInfo{
+		{Name: "001_users.js", Content: `db.createCollection("users", {
+	validator: { $jsonSchema: { bsonType: "object", required: ["email"] } },
+	validationLevel: "moderate"
+});
+db.users.createIndex({ email: 1 }, { unique: true });
+db.users.createIndex({ name: 1 });
+db.createCollection("sessions");`},
+		{Name: "002_orders.js", Content: `db.orders.createIndexes([{ customer: 1 }, { createdAt: -1 }]);
+db.users.dropIndex("name_1");
+db.users.createIndex({ email: 1 }, { unique: true, sparse: true });
+db.sessions.drop();`},
+		{Name: "003_users.js", Content: `// METADATA:
+// {"name": "require-names"}
+
+db.createCollection("users", { validator: { $jsonSchema: { bsonType: "object", required: ["email", "name"] } } });`},
+	}
+
+	registry, err := NewParser().SchemaRegistry(scripts)
+	if err != nil {
+		t.Fatalf("SchemaRegistry() returned error: %v", err)
+	}
+	if names := strings.Join(registry.Collections(), ", "); names != "orders, users" {
+		t.Errorf("Expected orders and users, got %s", names)
+	}
+
+	users, ok := registry.Collection("users")
+	if !ok {
+		t.Fatal("Expected users to be declared")
+	}
+	required, _ := lookupKey(users.JSONSchema, "required")
+	if !valuesEqual(required, bson.A{"email", "name"}) {
+		t.Errorf("Expected the latest validator, got %v", users.JSONSchema)
+	}
+	if users.Options == nil || users.Options.ValidationLevel != nil {
+		t.Errorf("Expected the options of the latest createCollection, got %+v", users.Options)
+	}
+	if strings.Join(users.Scripts, ", ") != "001_users.js, 002_orders.js, require-names" {
+		t.Errorf("Unexpected scripts: %v", users.Scripts)
+	}
+
+	if len(users.Indexes) != 1 {
+		t.Fatalf("Expected the dropped index to be forgotten, got %v", users.Indexes)
+	}
+	email, ok := users.Index("email_1")
+	if !ok || email.Script != "002_orders.js" || email.Options.Sparse == nil || email.Location.StartLine != 3 {
+		t.Errorf("Expected the redeclared index to replace the first one, got %+v", email)
+	}
+
+	orders, _ := registry.Collection("orders")
+	if orders.Options != nil || len(orders.Indexes) != 2 || orders.Indexes[1].Name != "createdAt_-1" {
+		t.Errorf("Expected orders to be declared by its indexes, got %+v", orders)
+	}
+
+	if _, ok := registry.Collection("sessions"); ok {
+		t.Error("Expected the dropped collection to be forgotten")
+	}
+}
+
+func TestSchemaRegistryParseError(t *testing.T) {
+	_, err := NewParser().SchemaRegistry([]ScriptInfo{{Name: "broken.js", Content: `db.users.createIndex({ email: 1 }`}})
+	if err == nil || !strings.Contains(err.Error(), "broken.js") {
+		t.Errorf("Expected the failing script to be named, got %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Similarity functions accepted by vector search fields
+var vectorSimilarities = map[string]bool{
+	"euclidean":  true,
+	"cosine":     true,
+	"dotProduct": true,
+}
+
+// Parses createSearchIndex(name, definition), createSearchIndex(name, type,
+// definition) and createSearchIndex({ name, type, definition })
+func (p *Parser) parseCreateSearchIndex(collection, argsString string) (*MongoOperation, error) {
+	args := p.splitArguments(argsString)
+	var model mongo.SearchIndexModel
+	var err error
+
+	switch len(args) {
+	case 1:
+		model, err = p.parseSearchIndexDocument(args[0])
+	case 2, 3:
+		var name string
+		if err := p.parseJSONLikeString(args[0], &name); err != nil {
+			return nil, fmt.Errorf("search index name must be a string: %w", err)
+		}
+		indexType := "search"
+		if len(args) == 3 {
+			if err := p.parseJSONLikeString(args[1], &indexType); err != nil {
+				return nil, fmt.Errorf("search index type must be a string: %w", err)
+			}
+		}
+		var definition bson.D
+		if err := p.parseJSONLikeString(args[len(args)-1], &definition); err != nil {
+			return nil, fmt.Errorf("failed to parse search index definition: %w", err)
+		}
+		model, err = newSearchIndexModel(name, indexType, definition)
+	default:
+		return nil, fmt.Errorf("createSearchIndex requires a definition")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoOperation{
+		Type:              "createSearchIndex",
+		Collection:        collection,
+		Operation:         "createSearchIndex",
+		SearchIndexModels: []mongo.SearchIndexModel{model},
+	}, nil
+}
+
+// Parses createSearchIndexes([{ name, type, definition }, ...])
+func (p *Parser) parseCreateSearchIndexes(collection, argsString string) (*MongoOperation, error) {
+	var specs []bson.D
+	if err := p.parseJSONLikeString(argsString, &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse search index specifications: %w", err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("createSearchIndexes requires at least one index")
+	}
+
+	op := &MongoOperation{
+		Type:       "createSearchIndex",
+		Collection: collection,
+		Operation:  "createSearchIndexes",
+	}
+	for i, spec := range specs {
+		model, err := searchIndexModelFromDocument(spec)
+		if err != nil {
+			return nil, fmt.Errorf("search index %d: %w", i, err)
+		}
+		op.SearchIndexModels = append(op.SearchIndexModels, model)
+	}
+	return op, nil
+}
+
+// Parses a { name, type, definition } search index document
+func (p *Parser) parseSearchIndexDocument(input string) (mongo.SearchIndexModel, error) {
+	var spec bson.D
+	if err := p.parseJSONLikeString(input, &spec); err != nil {
+		return mongo.SearchIndexModel{}, fmt.Errorf("failed to parse search index: %w", err)
+	}
+	return searchIndexModelFromDocument(spec)
+}
+
+// Builds a search index model from a { name, type, definition } document
+func searchIndexModelFromDocument(spec bson.D) (mongo.SearchIndexModel, error) {
+	fields, _ := asMap(spec)
+	name, _ := fields["name"].(string)
+	indexType := "search"
+	if value, ok := fields["type"]; ok {
+		if indexType, ok = value.(string); !ok {
+			return mongo.SearchIndexModel{}, fmt.Errorf("search index type must be a string")
+		}
+	}
+	definition, ok := fields["definition"].(bson.D)
+	if !ok {
+		return mongo.SearchIndexModel{}, fmt.Errorf("search index requires a definition document")
+	}
+	return newSearchIndexModel(name, indexType, definition)
+}
+
+// Validates a search index definition and builds its model. Unnamed indexes get
+// the server's default name, "default".
+func newSearchIndexModel(name, indexType string, definition bson.D) (mongo.SearchIndexModel, error) {
+	if name == "" {
+		name = "default"
+	}
+	switch indexType {
+	case "search":
+	case "vectorSearch":
+		if err := validateVectorDefinition(definition); err != nil {
+			return mongo.SearchIndexModel{}, fmt.Errorf("vector search index %s: %w", name, err)
+		}
+	default:
+		return mongo.SearchIndexModel{}, fmt.Errorf("unknown search index type %q", indexType)
+	}
+
+	return mongo.SearchIndexModel{
+		Definition: definition,
+		Options:    options.SearchIndexes().SetName(name).SetType(indexType),
+	}, nil
+}
+
+// Checks the fields of a vector search definition: vector fields need a path,
+// a positive numDimensions and a known similarity; filter fields need a path
+func validateVectorDefinition(definition bson.D) error {
+	value, ok := lookupKey(definition, "fields")
+	if !ok {
+		return fmt.Errorf("definition requires fields")
+	}
+	fields, ok := value.(bson.A)
+	if !ok || len(fields) == 0 {
+		return fmt.Errorf("fields must be a non-empty array")
+	}
+
+	vectors := 0
+	for i, item := range fields {
+		field, ok := asMap(item)
+		if !ok {
+			return fmt.Errorf("field %d must be a document", i)
+		}
+		if path, _ := field["path"].(string); path == "" {
+			return fmt.Errorf("field %d requires a path", i)
+		}
+		switch field["type"] {
+		case "vector":
+			vectors++
+			dimensions, ok := toFloat64(field["numDimensions"])
+			if !ok || dimensions < 1 || dimensions != float64(int64(dimensions)) {
+				return fmt.Errorf("field %d requires a positive integer numDimensions", i)
+			}
+			similarity, _ := field["similarity"].(string)
+			if !vectorSimilarities[similarity] {
+				return fmt.Errorf("field %d similarity must be euclidean, cosine or dotProduct, got %q", i, similarity)
+			}
+		case "filter":
+		default:
+			return fmt.Errorf("field %d type must be vector or filter, got %v", i, field["type"])
+		}
+	}
+	if vectors == 0 {
+		return fmt.Errorf("definition requires at least one vector field")
+	}
+	return nil
+}
+
+// Creates search indexes, handling existing indexes of the same name under the
+// index conflict policy
+func (p *Parser) executeCreateSearchIndex(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if len(op.SearchIndexModels) == 0 {
+		return nil, fmt.Errorf("no search indexes to create")
+	}
+	view := p.collection(db, op).SearchIndexes()
+
+	existing, err := p.listSearchIndexes(ctx, view)
+	if err != nil {
+		return nil, err
+	}
+
+	var created, updated []string
+	for _, model := range op.SearchIndexModels {
+		name := *model.Options.Name
+		current, ok := existing[name]
+		if !ok {
+			if _, err := view.CreateOne(ctx, model); err != nil {
+				return nil, fmt.Errorf("failed to create search index %s: %w", name, err)
+			}
+			p.compensate(MongoOperation{Type: "dropSearchIndex", Collection: op.Collection, Operation: "dropSearchIndex", IndexSpec: name})
+			created = append(created, name)
+			continue
+		}
+
+		if p.indexPolicy == ConflictFail {
+			return nil, fmt.Errorf("search index %s already exists on %s", name, op.Collection)
+		}
+		currentType, _ := lookupKey(current, "type")
+		definition, _ := lookupKey(current, "latestDefinition")
+		sameType := currentType == nil || currentType == *model.Options.Type
+		if sameType && valuesEqual(definition, model.Definition) {
+			continue
+		}
+
+		switch {
+		case p.indexPolicy == ConflictSkip:
+			return nil, fmt.Errorf("search index %s on %s differs from the script", name, op.Collection)
+		case sameType:
+			if err := view.UpdateOne(ctx, name, model.Definition); err != nil {
+				return nil, fmt.Errorf("failed to update search index %s: %w", name, err)
+			}
+		default:
+			if err := view.DropOne(ctx, name); err != nil {
+				return nil, fmt.Errorf("failed to drop search index %s: %w", name, err)
+			}
+			if _, err := view.CreateOne(ctx, model); err != nil {
+				return nil, fmt.Errorf("failed to recreate search index %s: %w", name, err)
+			}
+		}
+		updated = append(updated, name)
+	}
+
+	if len(created) == 0 && len(updated) == 0 {
+		return skipped("Search indexes already exist"), nil
+	}
+	parts := make([]string, 0, 2)
+	if len(created) > 0 {
+		parts = append(parts, "created "+strings.Join(created, ", "))
+	}
+	if len(updated) > 0 {
+		parts = append(parts, "updated "+strings.Join(updated, ", "))
+	}
+	return fmt.Sprintf("Search indexes on %s: %s", op.Collection, strings.Join(parts, "; ")), nil
+}
+
+// Fetches the search indexes of a collection by name
+func (p *Parser) listSearchIndexes(ctx context.Context, view mongo.SearchIndexView) (map[string]bson.D, error) {
+	cursor, err := view.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list search indexes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []bson.D
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, fmt.Errorf("failed to read search indexes: %w", err)
+	}
+	byName := make(map[string]bson.D, len(indexes))
+	for _, index := range indexes {
+		if name, ok := lookupKey(index, "name"); ok {
+			if nameStr, ok := name.(string); ok {
+				byName[nameStr] = index
+			}
+		}
+	}
+	return byName, nil
+}
+
+// Drops a search index by name
+func (p *Parser) executeDropSearchIndex(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	name, ok := op.IndexSpec.(string)
+	if !ok {
+		return nil, fmt.Errorf("dropSearchIndex needs an index name, got %T", op.IndexSpec)
+	}
+	if err := p.collection(db, op).SearchIndexes().DropOne(ctx, name); err != nil {
+		if hasErrorCode(err, CodeIndexNotFound) {
+			return skipped(fmt.Sprintf("Search index %s not found on %s", name, op.Collection)), nil
+		}
+		return nil, err
+	}
+	return fmt.Sprintf("Search index %s dropped from %s", name, op.Collection), nil
+}
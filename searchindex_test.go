@@ -0,0 +1,71 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVectorSearchIndex(t *testing.T) {
+	parser := NewParser()
+
+	statements := []string{
+		`db.docs.createSearchIndex("embeddings", "vectorSearch", {
+			fields: [
+				{ type: "vector", path: "embedding", numDimensions: 1536, similarity: "cosine" },
+				{ type: "filter", path: "tenant" }
+			]
+		})`,
+		`db.docs.createSearchIndex({ name: "embeddings", type: "vectorSearch", definition: { fields: [{ type: "vector", path: "embedding", numDimensions: 1536, similarity: "cosine" }] } })`,
+	}
+	for _, statement := range statements {
+		op, err := parser.parseMongoStatement(statement)
+		if err != nil {
+			t.Fatalf("parseMongoStatement() returned error: %v", err)
+		}
+		if op.Type != "createSearchIndex" || len(op.SearchIndexModels) != 1 {
+			t.Fatalf("Expected one search index, got %+v", op)
+		}
+		opts := op.SearchIndexModels[0].Options
+		if *opts.Name != "embeddings" || *opts.Type != "vectorSearch" {
+			t.Errorf("Expected vectorSearch index embeddings, got %s %s", *opts.Name, *opts.Type)
+		}
+	}
+
+	op, err := parser.parseMongoStatement(`db.docs.createSearchIndexes([
+		{ definition: { mappings: { dynamic: true } } },
+		{ name: "vec", type: "vectorSearch", definition: { fields: [{ type: "vector", path: "v", numDimensions: 3, similarity: "dotProduct" }] } }
+	])`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+	if len(op.SearchIndexModels) != 2 || *op.SearchIndexModels[0].Options.Name != "default" || *op.SearchIndexModels[0].Options.Type != "search" {
+		t.Errorf("Expected default search index and vector index, got %+v", op.SearchIndexModels)
+	}
+}
+
+func TestParseVectorSearchIndexInvalid(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		fields string
+		err    string
+	}{
+		{`[]`, "non-empty"},
+		{`[{ type: "vector", path: "v", similarity: "cosine" }]`, "numDimensions"},
+		{`[{ type: "vector", path: "v", numDimensions: 2.5, similarity: "cosine" }]`, "numDimensions"},
+		{`[{ type: "vector", path: "v", numDimensions: 3, similarity: "manhattan" }]`, "similarity"},
+		{`[{ type: "vector", numDimensions: 3, similarity: "cosine" }]`, "path"},
+		{`[{ type: "filter", path: "tenant" }]`, "at least one vector"},
+	}
+
+	for _, tt := range tests {
+		_, err := parser.parseMongoStatement(`db.docs.createSearchIndex("vec", "vectorSearch", { fields: ` + tt.fields + ` })`)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("fields %s: expected error containing %q, got %v", tt.fields, tt.err, err)
+		}
+	}
+
+	if _, err := parser.parseMongoStatement(`db.docs.createSearchIndex("x", "lexical", { mappings: {} })`); err == nil {
+		t.Error("Expected unknown index type to fail")
+	}
+}
@@ -0,0 +1,345 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Directive generating synthetic documents from a template, or from the
+// collection's validator with from=validator:
+// // SEED: users count=1000 template={ name: "{{name}}", email: "{{email}}" } seed=42
+const seedDirective = "// SEED:"
+
+// Matches {{generator args...}} placeholders in template strings
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z]+)((?:\s+[^\s}]+)*)\s*\}\}`)
+
+// Describes the documents a SEED directive generates
+type SeedSpec struct {
+	Count    int    `json:"count"`
+	Template bson.D `json:"template"`
+	// Generate documents from the $jsonSchema of the collection's validator
+	// instead of a template
+	FromValidator bool `json:"from_validator,omitempty"`
+	// Random seed making the generated documents reproducible; 0 picks one at random
+	RandomSeed uint64 `json:"random_seed,omitempty"`
+}
+
+// Word lists the generators draw from
+var (
+	seedFirstNames = []string{"Ada", "Alan", "Barbara", "Claude", "Donald", "Edsger", "Frances", "Grace", "Hedy", "John", "Katherine", "Ken", "Linus", "Margaret", "Niklaus", "Radia", "Shafi", "Tim", "Vint", "Yukihiro"}
+	seedLastNames  = []string{"Lovelace", "Turing", "Liskov", "Shannon", "Knuth", "Dijkstra", "Allen", "Hopper", "Lamarr", "McCarthy", "Johnson", "Thompson", "Torvalds", "Hamilton", "Wirth", "Perlman", "Goldwasser", "Berners-Lee", "Matsumoto", "Ritchie"}
+	seedWords      = []string{"alpha", "bravo", "cobalt", "delta", "ember", "falcon", "granite", "harbor", "indigo", "juniper", "kelp", "lumen", "meadow", "nimbus", "orchid", "pebble", "quartz", "river", "summit", "tundra"}
+	seedCities     = []string{"Amsterdam", "Berlin", "Buenos Aires", "Cairo", "Lagos", "Lisbon", "London", "Madrid", "Montreal", "Mumbai", "Nairobi", "Oslo", "Paris", "Seoul", "Sydney", "Tokyo"}
+	seedCountries  = []string{"Argentina", "Canada", "Egypt", "France", "Germany", "India", "Japan", "Kenya", "Netherlands", "Nigeria", "Norway", "Portugal", "South Korea", "Spain", "United Kingdom"}
+	seedDomains    = []string{"example.com", "example.org", "example.net"}
+)
+
+// Parses a SEED directive
+func (p *Parser) parseSeed(directive string) (*MongoOperation, error) {
+	spec := strings.TrimSpace(strings.TrimPrefix(directive, seedDirective))
+	collection, rest, _ := strings.Cut(spec, " ")
+	if collection == "" {
+		return nil, fmt.Errorf("SEED directive requires a collection")
+	}
+
+	params, err := splitDirectiveParams(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := &SeedSpec{}
+	for key, value := range params {
+		switch key {
+		case "count":
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return nil, fmt.Errorf("SEED count must be a positive integer, got %q", value)
+			}
+			seed.Count = count
+		case "template":
+			if err := p.parseJSONLikeString(value, &seed.Template); err != nil {
+				return nil, fmt.Errorf("failed to parse SEED template: %w", err)
+			}
+		case "seed":
+			randomSeed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("SEED seed must be a non-negative integer, got %q", value)
+			}
+			seed.RandomSeed = randomSeed
+		case "from":
+			if value != "validator" {
+				return nil, fmt.Errorf("SEED from must be validator, got %q", value)
+			}
+			seed.FromValidator = true
+		default:
+			return nil, fmt.Errorf("unknown SEED parameter %q", key)
+		}
+	}
+	if seed.Count == 0 {
+		return nil, fmt.Errorf("SEED directive requires count")
+	}
+	if seed.Template == nil && !seed.FromValidator {
+		return nil, fmt.Errorf("SEED directive requires template or from=validator")
+	}
+	if seed.Template != nil && seed.FromValidator {
+		return nil, fmt.Errorf("SEED directive takes either template or from=validator")
+	}
+	if err := validateTemplate(seed.Template); err != nil {
+		return nil, err
+	}
+
+	return &MongoOperation{
+		Type:       "seed",
+		Collection: collection,
+		Operation:  "seed",
+		Seed:       seed,
+	}, nil
+}
+
+// Splits key=value directive parameters; values starting with { or [ extend to
+// their matching bracket so they may contain spaces
+func splitDirectiveParams(input string) (map[string]string, error) {
+	params := map[string]string{}
+	input = strings.TrimSpace(input)
+	for input != "" {
+		key, rest, found := strings.Cut(input, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("expected key=value, got %q", input)
+		}
+
+		end := strings.IndexAny(rest, " \t")
+		if strings.HasPrefix(rest, "{") || strings.HasPrefix(rest, "[") {
+			end = matchingBracket(rest)
+			if end == -1 {
+				return nil, fmt.Errorf("unbalanced value for %s", key)
+			}
+			end++
+		}
+		if end == -1 {
+			end = len(rest)
+		}
+		params[key] = rest[:end]
+		input = strings.TrimSpace(rest[end:])
+	}
+	return params, nil
+}
+
+// Returns the index of the bracket closing the one at the start of s, or -1
+func matchingBracket(s string) int {
+	depth := 0
+	var quote rune
+	for i, char := range s {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '{' || char == '[':
+			depth++
+		case char == '}' || char == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Checks that every placeholder of a template names a known generator
+func validateTemplate(value interface{}) error {
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			if err := validateTemplate(elem.Value); err != nil {
+				return err
+			}
+		}
+	case bson.A:
+		for _, item := range v {
+			if err := validateTemplate(item); err != nil {
+				return err
+			}
+		}
+	case string:
+		for _, match := range placeholderPattern.FindAllStringSubmatch(v, -1) {
+			if _, err := generateValue(rand.New(rand.NewPCG(0, 0)), 0, match[1], strings.Fields(match[2])); err != nil {
+				return fmt.Errorf("SEED template: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Generates and inserts the documents of a SEED directive in chunks, so large
+// counts never sit in memory at once
+func (p *Parser) executeSeed(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if op.Seed == nil || op.Seed.Count <= 0 {
+		return nil, fmt.Errorf("seed operation requires a count and a template or validator")
+	}
+
+	if op.Seed.FromValidator {
+		schema, err := validatorSchema(ctx, db, op.Collection)
+		if err != nil {
+			return nil, err
+		}
+		return p.insertStream(ctx, db, op, op.Seed.Count, fixtureStream(schema, op.Seed.Count, op.Seed.RandomSeed))
+	}
+
+	randomSeed := op.Seed.RandomSeed
+	if randomSeed == 0 {
+		randomSeed = rand.Uint64()
+	}
+	rng := rand.New(rand.NewPCG(randomSeed, randomSeed))
+
+	index := 0
+	return p.insertStream(ctx, db, op, op.Seed.Count, func() (bson.D, error) {
+		if index == op.Seed.Count {
+			return nil, io.EOF
+		}
+		doc, err := renderTemplate(rng, index, op.Seed.Template)
+		index++
+		if err != nil {
+			return nil, err
+		}
+		return doc.(bson.D), nil
+	})
+}
+
+// Fills the placeholders of a template for the document at index
+func renderTemplate(rng *rand.Rand, index int, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bson.D:
+		doc := make(bson.D, len(v))
+		for i, elem := range v {
+			rendered, err := renderTemplate(rng, index, elem.Value)
+			if err != nil {
+				return nil, err
+			}
+			doc[i] = bson.E{Key: elem.Key, Value: rendered}
+		}
+		return doc, nil
+	case bson.A:
+		arr := make(bson.A, len(v))
+		for i, item := range v {
+			rendered, err := renderTemplate(rng, index, item)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = rendered
+		}
+		return arr, nil
+	case string:
+		matches := placeholderPattern.FindAllStringSubmatchIndex(v, -1)
+		if len(matches) == 0 {
+			return v, nil
+		}
+		// A string that is a single placeholder keeps the generated type
+		if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(v) {
+			return generateValue(rng, index, v[matches[0][2]:matches[0][3]], strings.Fields(v[matches[0][4]:matches[0][5]]))
+		}
+		var out strings.Builder
+		last := 0
+		for _, match := range matches {
+			out.WriteString(v[last:match[0]])
+			generated, err := generateValue(rng, index, v[match[2]:match[3]], strings.Fields(v[match[4]:match[5]]))
+			if err != nil {
+				return nil, err
+			}
+			out.WriteString(fmt.Sprint(generated))
+			last = match[1]
+		}
+		out.WriteString(v[last:])
+		return out.String(), nil
+	default:
+		return v, nil
+	}
+}
+
+// Produces one generated value
+func generateValue(rng *rand.Rand, index int, generator string, args []string) (interface{}, error) {
+	pick := func(values []string) string { return values[rng.IntN(len(values))] }
+
+	switch generator {
+	case "index":
+		return int64(index), nil
+	case "name":
+		return pick(seedFirstNames) + " " + pick(seedLastNames), nil
+	case "firstName":
+		return pick(seedFirstNames), nil
+	case "lastName":
+		return pick(seedLastNames), nil
+	case "email":
+		return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(pick(seedFirstNames)), strings.ToLower(pick(seedLastNames)), index, pick(seedDomains)), nil
+	case "word":
+		return pick(seedWords), nil
+	case "sentence":
+		words := make([]string, 4+rng.IntN(6))
+		for i := range words {
+			words[i] = pick(seedWords)
+		}
+		sentence := strings.Join(words, " ")
+		return strings.ToUpper(sentence[:1]) + sentence[1:] + ".", nil
+	case "city":
+		return pick(seedCities), nil
+	case "country":
+		return pick(seedCountries), nil
+	case "phone":
+		return fmt.Sprintf("+1-555-%03d-%04d", rng.IntN(1000), rng.IntN(10000)), nil
+	case "uuid":
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = byte(rng.IntN(256))
+		}
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	case "objectId":
+		return primitive.NewObjectID(), nil
+	case "bool":
+		return rng.IntN(2) == 1, nil
+	case "pick":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("pick requires at least one choice")
+		}
+		return pick(args), nil
+	case "int", "float":
+		low, high := 0.0, 1000.0
+		if generator == "float" {
+			high = 1
+		}
+		if len(args) == 2 {
+			var errLow, errHigh error
+			low, errLow = strconv.ParseFloat(args[0], 64)
+			high, errHigh = strconv.ParseFloat(args[1], 64)
+			if errLow != nil || errHigh != nil || high < low {
+				return nil, fmt.Errorf("%s takes a numeric min and max", generator)
+			}
+		} else if len(args) != 0 {
+			return nil, fmt.Errorf("%s takes a numeric min and max", generator)
+		}
+		if generator == "int" {
+			return int64(low) + rng.Int64N(int64(high)-int64(low)+1), nil
+		}
+		return low + rng.Float64()*(high-low), nil
+	case "date":
+		// Spread over the year before now, truncated to milliseconds like BSON dates
+		offset := time.Duration(rng.Int64N(int64(365 * 24 * time.Hour)))
+		return time.Now().Add(-offset).Truncate(time.Millisecond), nil
+	default:
+		return nil, fmt.Errorf("unknown generator %q", generator)
+	}
+}
@@ -0,0 +1,95 @@
+package mongoparser
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseSeedDirective(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`// SEED: users count=1000 template={ name: "{{name}}", email: "{{email}}", age: "{{int 18 90}}" } seed=42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 1 || ops[0].Type != "seed" || ops[0].Collection != "users" {
+		t.Fatalf("Expected seed operation, got %+v", ops)
+	}
+	seed := ops[0].Seed
+	if seed.Count != 1000 || seed.RandomSeed != 42 || len(seed.Template) != 3 {
+		t.Errorf("Unexpected seed spec %+v", seed)
+	}
+
+	op, err := parser.parseSeed(`// SEED: users count=50 from=validator`)
+	if err != nil || !op.Seed.FromValidator || op.Seed.Template != nil {
+		t.Errorf("Expected a seed from the validator, got %+v, %v", op, err)
+	}
+}
+
+func TestParseSeedDirectiveInvalid(t *testing.T) {
+	parser := NewParser()
+
+	tests := []struct {
+		directive string
+		err       string
+	}{
+		{`// SEED: users template={ a: 1 }`, "requires count"},
+		{`// SEED: users count=10`, "requires template"},
+		{`// SEED: users count=-1 template={ a: 1 }`, "positive integer"},
+		{`// SEED: users count=1 template={ a: "{{nope}}" }`, "unknown generator"},
+		{`// SEED: users count=1 template={ a: 1 } colour=red`, "unknown SEED parameter"},
+		{`// SEED: users count=1 template={ a: 1`, "unbalanced"},
+		{`// SEED: users count=1 from=schema`, "from must be validator"},
+		{`// SEED: users count=1 template={ a: 1 } from=validator`, "either template or from=validator"},
+	}
+	for _, tt := range tests {
+		_, err := parser.parseSeed(tt.directive)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.directive, tt.err, err)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	template := bson.D{
+		{Key: "_id", Value: "{{index}}"},
+		{Key: "label", Value: "user-{{index}}"},
+		{Key: "age", Value: "{{int 18 20}}"},
+		{Key: "plan", Value: "{{pick free pro}}"},
+		{Key: "tags", Value: bson.A{"{{word}}", "fixed"}},
+		{Key: "joined", Value: "{{date}}"},
+	}
+
+	render := func(seed uint64) bson.D {
+		value, err := renderTemplate(rand.New(rand.NewPCG(seed, seed)), 7, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value.(bson.D)
+	}
+
+	doc := render(1)
+	if doc[0].Value != int64(7) || doc[1].Value != "user-7" {
+		t.Errorf("Expected index placeholders to render, got %v and %v", doc[0].Value, doc[1].Value)
+	}
+	if age, ok := doc[2].Value.(int64); !ok || age < 18 || age > 20 {
+		t.Errorf("Expected int between 18 and 20, got %v", doc[2].Value)
+	}
+	if plan := doc[3].Value; plan != "free" && plan != "pro" {
+		t.Errorf("Expected a picked plan, got %v", plan)
+	}
+	if tags := doc[4].Value.(bson.A); tags[1] != "fixed" {
+		t.Errorf("Expected literal array items to be kept, got %v", tags)
+	}
+	if _, ok := doc[5].Value.(time.Time); !ok {
+		t.Errorf("Expected a date, got %T", doc[5].Value)
+	}
+
+	if again := render(1); !valuesEqual(again[:5], doc[:5]) {
+		t.Errorf("Expected the same seed to generate the same document, got %v and %v", again, doc)
+	}
+}
@@ -0,0 +1,197 @@
+package mongoparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Shell helpers whose output is captured instead of being skipped
+var printHelpers = map[string]bool{
+	"print":         true,
+	"printjson":     true,
+	"console.log":   true,
+	"console.info":  true,
+	"console.warn":  true,
+	"console.error": true,
+}
+
+// Splits a helper call such as print(...) or console.log(...) into its name and
+// argument text. ok is false when the statement is not a single call.
+func splitCall(statement string) (name, args string, ok bool) {
+	statement = strings.TrimSuffix(strings.TrimSpace(statement), ";")
+	open := strings.Index(statement, "(")
+	if open <= 0 || !strings.HasSuffix(statement, ")") {
+		return "", "", false
+	}
+	name = strings.TrimSpace(statement[:open])
+	for _, char := range name {
+		if !isAlphaNum(char) && char != '.' {
+			return "", "", false
+		}
+	}
+	return name, statement[open+1 : len(statement)-1], true
+}
+
+// Reports whether op is a shell helper that runs locally rather than against the database
+func isShellOperation(op MongoOperation) bool {
+	switch op.Type {
+	case "print", "assert", "sleep", "encrypt":
+		return true
+	default:
+		return false
+	}
+}
+
+// Parses shell helper statements; handled is false for statements that are not helpers
+func (p *Parser) parseShellStatement(statement string) (op *MongoOperation, handled bool, err error) {
+	name, args, ok := splitCall(statement)
+	switch {
+	case !ok:
+		return nil, false, nil
+	case printHelpers[name]:
+		return p.parsePrint(name, args), true, nil
+	case strings.HasSuffix(name, ".withTransaction"):
+		op, err := p.parseWithTransaction(args)
+		return op, true, err
+	case name == "sleep":
+		op, err := p.parseSleep(args)
+		return op, true, err
+	case isAssertHelper(name):
+		op, err := p.parseAssert(name, args)
+		return op, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// Parses print, printjson and console.* calls, rendering their arguments once so
+// every execution prints the same text
+func (p *Parser) parsePrint(name, argsString string) *MongoOperation {
+	var parts []string
+	for _, arg := range p.splitArguments(argsString) {
+		arg = strings.TrimSpace(arg)
+		if arg == "" {
+			continue
+		}
+		var value interface{}
+		if err := p.parseJSONLikeString(arg, &value); err != nil {
+			p.warnf("cannot evaluate %s argument %s, printing it as written", name, arg)
+			parts = append(parts, arg)
+			continue
+		}
+		parts = append(parts, renderValue(value, name == "printjson"))
+	}
+
+	return &MongoOperation{
+		Type:      "print",
+		Operation: name,
+		Message:   strings.Join(parts, " "),
+	}
+}
+
+// Writes the message of a print helper to the configured output and returns it
+func (p *Parser) executePrint(op MongoOperation) (interface{}, error) {
+	if p.printOutput != nil {
+		if _, err := io.WriteString(p.printOutput, op.Message+"\n"); err != nil {
+			return nil, fmt.Errorf("failed to write %s output: %w", op.Operation, err)
+		}
+	}
+	return op.Message, nil
+}
+
+// Parses sleep(ms)
+func (p *Parser) parseSleep(argsString string) (*MongoOperation, error) {
+	var value interface{}
+	if err := p.parseJSONLikeString(argsString, &value); err != nil {
+		return nil, fmt.Errorf("sleep requires a duration in milliseconds: %w", err)
+	}
+	ms, ok := toFloat64(value)
+	if !ok || ms < 0 {
+		return nil, fmt.Errorf("sleep requires a non-negative duration in milliseconds")
+	}
+	return &MongoOperation{
+		Type:      "sleep",
+		Operation: "sleep",
+		Sleep:     time.Duration(ms * float64(time.Millisecond)),
+	}, nil
+}
+
+// Pauses for the duration of a sleep statement, returning early with the
+// context's error when it is cancelled
+func (p *Parser) executeSleep(ctx context.Context, op MongoOperation) (interface{}, error) {
+	if err := sleepContext(ctx, op.Sleep); err != nil {
+		return nil, err
+	}
+	return op.Sleep.String(), nil
+}
+
+// Renders a parsed value the way the shell prints it: strings as is, everything
+// else as JSON, indented when pretty is set
+func renderValue(value interface{}, pretty bool) string {
+	if s, ok := value.(string); ok && !pretty {
+		return s
+	}
+
+	var buf bytes.Buffer
+	writeJSON(&buf, value)
+	if !pretty {
+		return buf.String()
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		return buf.String()
+	}
+	return indented.String()
+}
+
+// Writes value as JSON, keeping the key order of documents
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case bson.D:
+		buf.WriteByte('{')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(strconv.Quote(elem.Key))
+			buf.WriteByte(':')
+			writeJSON(buf, elem.Value)
+		}
+		buf.WriteByte('}')
+	case bson.M:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		doc := make(bson.D, 0, len(keys))
+		for _, key := range keys {
+			doc = append(doc, bson.E{Key: key, Value: v[key]})
+		}
+		writeJSON(buf, doc)
+	case bson.A:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, item)
+		}
+		buf.WriteByte(']')
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			encoded = []byte(strconv.Quote(fmt.Sprint(v)))
+		}
+		buf.Write(encoded)
+	}
+}
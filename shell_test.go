@@ -0,0 +1,105 @@
+package mongoparser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePrintStatements(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+print("seeding users", 3);
+printjson({ name: "Ada", tags: ["admin"] });
+console.log('done');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"seeding users 3",
+		"{\n  \"name\": \"Ada\",\n  \"tags\": [\n    \"admin\"\n  ]\n}",
+		"done",
+	}
+	if len(ops) != len(expected) {
+		t.Fatalf("Expected %d operations, got %d", len(expected), len(ops))
+	}
+	for i, op := range ops {
+		if op.Type != "print" || op.Message != expected[i] {
+			t.Errorf("Operation %d: expected print %q, got %s %q", i, expected[i], op.Type, op.Message)
+		}
+	}
+}
+
+func TestSplitCall(t *testing.T) {
+	tests := []struct {
+		statement string
+		name      string
+		args      string
+		ok        bool
+	}{
+		{`print("x");`, "print", `"x"`, true},
+		{`console.log(1, 2)`, "console.log", "1, 2", true},
+		{`db.users.find()`, "db.users.find", "", true},
+		{`var x = print("y")`, "", "", false},
+		{`print`, "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, args, ok := splitCall(tt.statement)
+		if ok != tt.ok || name != tt.name || args != tt.args {
+			t.Errorf("splitCall(%q) = %q, %q, %v; want %q, %q, %v", tt.statement, name, args, ok, tt.name, tt.args, tt.ok)
+		}
+	}
+}
+
+func TestExecutePrintCapture(t *testing.T) {
+	var out bytes.Buffer
+	parser := NewParser(WithPrintOutput(&out))
+
+	result := parser.ExecuteScript(context.Background(), nil, `print("step", 1);
+print("step", 2);`)
+	if !result.Success {
+		t.Fatalf("Expected success, got %v", result.Error)
+	}
+
+	if out.String() != "step 1\nstep 2\n" {
+		t.Errorf("Unexpected print output %q", out.String())
+	}
+	outputs, ok := result.Output.([]interface{})
+	if !ok || len(outputs) != 2 || outputs[1] != "step 2" {
+		t.Errorf("Expected printed lines in Output, got %v", result.Output)
+	}
+}
+
+func TestParseSleep(t *testing.T) {
+	parser := NewParser()
+	op, handled, err := parser.parseShellStatement("sleep(1500);")
+	if !handled || err != nil {
+		t.Fatalf("Expected sleep to be parsed, got %v", err)
+	}
+	if op.Type != "sleep" || op.Sleep != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s sleep, got %s %v", op.Type, op.Sleep)
+	}
+
+	if _, _, err := parser.parseShellStatement(`sleep("soon")`); err == nil {
+		t.Error("Expected non-numeric sleep to fail")
+	}
+}
+
+func TestExecuteSleepHonorsContext(t *testing.T) {
+	parser := NewParser()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := parser.ExecuteScript(ctx, nil, "sleep(60000);")
+	if result.Success || !errors.Is(result.Error, context.DeadlineExceeded) {
+		t.Errorf("Expected sleep to stop at the deadline, got %v", result.Error)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Sleep ignored the context, took %v", elapsed)
+	}
+}
@@ -0,0 +1,79 @@
+package mongoparser
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Marks the comment line holding an embedded script signature
+const signaturePrefix = "// SIGNATURE:"
+
+var (
+	// Returned when signature checks are enabled and a script carries no signature
+	ErrUnsigned = errors.New("script is not signed")
+	// Returned when a script's signature does not match its content
+	ErrInvalidSignature = errors.New("script signature is invalid")
+)
+
+// Verifies a script against the configured public key. With a nil detached
+// signature the script must embed one in a "// SIGNATURE: <base64>" line, which
+// covers the script with that line removed.
+func (p *Parser) VerifyScript(content string, detached []byte) error {
+	if p.signatureKey == nil {
+		return errors.New("no signature key configured")
+	}
+
+	body, signature := content, detached
+	if signature == nil {
+		var err error
+		if body, signature, err = splitSignature(content); err != nil {
+			return err
+		}
+	}
+
+	if !ed25519.Verify(p.signatureKey, []byte(body), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Appends an embedded signature of content made with key, producing a script
+// that passes VerifyScript with the matching public key
+func SignScript(content string, key ed25519.PrivateKey) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	signature := ed25519.Sign(key, []byte(content))
+	return content + signaturePrefix + " " + base64.StdEncoding.EncodeToString(signature) + "\n"
+}
+
+// Separates the embedded signature line from the signed content
+func splitSignature(content string) (string, []byte, error) {
+	var body strings.Builder
+	var encoded string
+	found := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), signaturePrefix) {
+			if found {
+				return "", nil, fmt.Errorf("%w: more than one signature line", ErrInvalidSignature)
+			}
+			found = true
+			encoded = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), signaturePrefix))
+			continue
+		}
+		body.WriteString(line)
+	}
+	if !found {
+		return "", nil, ErrUnsigned
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return body.String(), signature, nil
+}
@@ -0,0 +1,54 @@
+package mongoparser
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignedScripts(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	parser := NewParser(WithSignatureKey(public))
+
+	script := "// METADATA:\n// { \"name\": \"approved\" }\n"
+	signed := SignScript(script, private)
+
+	if err := parser.VerifyScript(signed, nil); err != nil {
+		t.Errorf("Expected embedded signature to verify, got %v", err)
+	}
+	if metadata := parser.ParseMetadata(signed); metadata == nil || metadata.Name != "approved" {
+		t.Errorf("Expected metadata to be unaffected by the signature, got %+v", metadata)
+	}
+
+	tampered := strings.Replace(signed, "approved", "tampered", 1)
+	if err := parser.VerifyScript(tampered, nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected tampered script to fail verification, got %v", err)
+	}
+
+	if err := parser.VerifyScript(script, nil); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected unsigned script to be rejected, got %v", err)
+	}
+
+	detached := ed25519.Sign(private, []byte(script))
+	if err := parser.VerifyScript(script, detached); err != nil {
+		t.Errorf("Expected detached signature to verify, got %v", err)
+	}
+
+	result := parser.ExecuteScript(context.Background(), nil, script)
+	if result.Success || !errors.Is(result.Error, ErrUnsigned) {
+		t.Errorf("Expected unsigned script not to run, got %+v", result)
+	}
+	result = parser.ExecuteScript(context.Background(), nil, signed)
+	if !result.Success || result.Name != "approved" {
+		t.Errorf("Expected signed script to run, got %+v", result)
+	}
+	result = parser.ExecuteReader(context.Background(), nil, strings.NewReader(tampered))
+	if result.Success {
+		t.Error("Expected tampered script read from a reader not to run")
+	}
+}
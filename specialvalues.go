@@ -0,0 +1,135 @@
+package mongoparser
+
+import (
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Decides what happens to Infinity, NaN and undefined values in scripts
+type SpecialValuePolicy int
+
+const (
+	// Keeps Infinity and NaN as doubles and stores undefined as null, like the shell
+	SpecialValuesKeep SpecialValuePolicy = iota
+	// Fails the statement, naming the field holding the value
+	SpecialValuesError
+	// Leaves the field out of its document; array elements become null
+	SpecialValuesSkip
+	// Replaces the value with null
+	SpecialValuesNull
+)
+
+// Stands for JavaScript's undefined until the special value policy resolves it
+type undefinedValue struct{}
+
+// Describes a value the special value policy applies to, or returns "" for
+// any other value
+func specialValueName(value interface{}) string {
+	switch v := value.(type) {
+	case undefinedValue:
+		return "undefined"
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+	}
+	return ""
+}
+
+// Applies the special value policy to a decoded value. Values without any
+// special value are returned as is.
+func (p *Parser) resolveSpecialValues(value interface{}) (interface{}, error) {
+	if !containsSpecialValue(value) {
+		return value, nil
+	}
+	resolved, _, err := p.resolveSpecialValue(value, "")
+	return resolved, err
+}
+
+// Reports whether value holds Infinity, NaN or undefined anywhere
+func containsSpecialValue(value interface{}) bool {
+	switch v := value.(type) {
+	case bson.D:
+		for _, elem := range v {
+			if containsSpecialValue(elem.Value) {
+				return true
+			}
+		}
+		return false
+	case bson.A:
+		for _, item := range v {
+			if containsSpecialValue(item) {
+				return true
+			}
+		}
+		return false
+	default:
+		return specialValueName(value) != ""
+	}
+}
+
+// Resolves special values within value at path and reports whether the field
+// holding it should be left out
+func (p *Parser) resolveSpecialValue(value interface{}, path string) (interface{}, bool, error) {
+	if name := specialValueName(value); name != "" {
+		switch p.specialValues {
+		case SpecialValuesError:
+			if path == "" {
+				return nil, false, fmt.Errorf("value is %s", name)
+			}
+			return nil, false, fmt.Errorf("field %s is %s", path, name)
+		case SpecialValuesSkip:
+			return nil, true, nil
+		case SpecialValuesNull:
+			return nil, false, nil
+		default:
+			if name == "undefined" {
+				return nil, false, nil
+			}
+			return value, false, nil
+		}
+	}
+
+	switch v := value.(type) {
+	case bson.D:
+		doc := make(bson.D, 0, len(v))
+		for _, elem := range v {
+			resolved, skip, err := p.resolveSpecialValue(elem.Value, joinPath(path, elem.Key))
+			if err != nil {
+				return nil, false, err
+			}
+			if !skip {
+				doc = append(doc, bson.E{Key: elem.Key, Value: resolved})
+			}
+		}
+		return doc, false, nil
+	case bson.A:
+		arr := make(bson.A, len(v))
+		for i, item := range v {
+			// Skipped array elements become null so later indexes keep their position
+			resolved, _, err := p.resolveSpecialValue(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, false, err
+			}
+			arr[i] = resolved
+		}
+		return arr, false, nil
+	default:
+		return value, false, nil
+	}
+}
+
+// Appends a field name to a dotted path
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,99 @@
+package mongoparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSpecialValuePolicies(t *testing.T) {
+	input := `{ max: Infinity, min: -Infinity, score: NaN, note: undefined, deleted: null, list: [1, undefined] }`
+
+	tests := []struct {
+		policy   SpecialValuePolicy
+		expected bson.D
+	}{
+		{SpecialValuesKeep, bson.D{
+			{Key: "max", Value: math.Inf(1)},
+			{Key: "min", Value: math.Inf(-1)},
+			{Key: "score", Value: math.NaN()},
+			{Key: "note", Value: nil},
+			{Key: "deleted", Value: nil},
+			{Key: "list", Value: bson.A{1.0, nil}},
+		}},
+		{SpecialValuesSkip, bson.D{
+			{Key: "deleted", Value: nil},
+			{Key: "list", Value: bson.A{1.0, nil}},
+		}},
+		{SpecialValuesNull, bson.D{
+			{Key: "max", Value: nil},
+			{Key: "min", Value: nil},
+			{Key: "score", Value: nil},
+			{Key: "note", Value: nil},
+			{Key: "deleted", Value: nil},
+			{Key: "list", Value: bson.A{1.0, nil}},
+		}},
+	}
+	for _, tt := range tests {
+		for _, json5 := range []bool{false, true} {
+			opts := []Option{WithSpecialValues(tt.policy)}
+			if json5 {
+				opts = append(opts, WithJSON5())
+			}
+			var doc bson.D
+			if err := NewParser(opts...).parseJSONLikeString(input, &doc); err != nil {
+				t.Fatalf("policy %d: %v", tt.policy, err)
+			}
+			if !specialValuesEqual(doc, tt.expected) {
+				t.Errorf("policy %d (json5 %t): expected %v, got %v", tt.policy, json5, tt.expected, doc)
+			}
+		}
+	}
+}
+
+func TestSpecialValuesError(t *testing.T) {
+	parser := NewParser(WithSpecialValues(SpecialValuesError))
+	var doc bson.D
+	err := parser.parseJSONLikeString(`{ stats: { ratio: 1, values: [2, NaN] } }`, &doc)
+	if err == nil || !strings.Contains(err.Error(), "field stats.values[1] is NaN") {
+		t.Errorf("Expected error naming the field, got %v", err)
+	}
+	if err := parser.parseJSONLikeString(`{ deletedAt: null }`, &doc); err != nil {
+		t.Errorf("Expected null to be accepted, got %v", err)
+	}
+}
+
+// Compares documents treating NaN as equal to NaN
+func specialValuesEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case bson.D:
+		y, ok := b.(bson.D)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if x[i].Key != y[i].Key || !specialValuesEqual(x[i].Value, y[i].Value) {
+				return false
+			}
+		}
+		return true
+	case bson.A:
+		y, ok := b.(bson.A)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !specialValuesEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		y, ok := b.(float64)
+		return ok && (x == y || math.IsNaN(x) && math.IsNaN(y))
+	default:
+		return a == b
+	}
+}
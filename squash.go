@@ -0,0 +1,161 @@
+package mongoparser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Matches the session variable a withTransaction statement runs on
+var transactionSessionPattern = regexp.MustCompile(`^(?:await\s+)?([A-Za-z_$][\w$]*)\.withTransaction\(`)
+
+// Operation types a squashed script keeps in their original order, after the
+// schema, as long as their collection isn't dropped later
+var squashKeptTypes = map[string]bool{
+	"insert": true, "update": true, "delete": true, "findAndModify": true,
+	"seed": true, "import": true, "upload": true, "encrypt": true,
+	"createSearchIndex": true, "dropSearchIndex": true, "transaction": true,
+}
+
+// Operation types whose effect the squashed schema already describes
+var squashSchemaTypes = map[string]bool{
+	"createCollection": true, "createIndex": true, "createIndexes": true,
+	"dropIndex": true, "dropIndexes": true, "dropCollection": true, "dropDatabase": true,
+}
+
+// Statement of a script kept by Squash
+type squashedStatement struct {
+	script      string
+	collections []string
+	text        string
+	// Session variable of a session.withTransaction block, empty for other statements
+	session string
+}
+
+// Merges a sequence of migration scripts into a single bootstrap script for
+// new environments. The schema comes first, as the scripts leave it: each
+// collection's latest createCollection statement and its surviving indexes,
+// so later statements supersede earlier ones and dropped collections and
+// indexes disappear. Data statements such as inserts, updates and SEED
+// directives follow in their original order, unless a later script drops
+// their collection. Statements that only observe, such as assertions and
+// prints, are left out and listed in a comment at the top. Statements are
+// copied as written, without their comments, and the session variables of
+// transactions are declared ahead of them.
+func (p *Parser) Squash(scripts []ScriptInfo) (string, error) {
+	registry := NewSchemaRegistry()
+	var kept []squashedStatement
+	var names, omitted []string
+
+	for _, script := range scripts {
+		ops, err := p.ParseScript(script.Content)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse script %s: %w", script.Name, err)
+		}
+		if script.Metadata == nil {
+			script.Metadata = p.ParseMetadata(script.Content)
+		}
+		name := scriptName(script)
+		names = append(names, name)
+		registry.Add(name, ops)
+
+		for _, op := range ops {
+			switch {
+			case op.Type == "dropCollection":
+				kept = slices.DeleteFunc(kept, func(statement squashedStatement) bool {
+					return slices.Contains(statement.collections, op.Collection)
+				})
+			case op.Type == "dropDatabase":
+				kept = nil
+			case squashSchemaTypes[op.Type]:
+			case squashKeptTypes[op.Type]:
+				kept = append(kept, squashedStatement{
+					script:      name,
+					collections: operationCollections(op),
+					text:        statementText(op),
+					session:     transactionSession(op),
+				})
+			default:
+				where := name
+				if op.Location != nil {
+					where += " " + op.Location.String()
+				}
+				omitted = append(omitted, fmt.Sprintf("%s: %s", where, strings.Join(strings.Fields(statementText(op)), " ")))
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Squashed from %d scripts: %s\n", len(scripts), strings.Join(names, ", "))
+	if len(omitted) > 0 {
+		b.WriteString("// Left out:\n")
+		for _, statement := range omitted {
+			fmt.Fprintf(&b, "//   %s\n", statement)
+		}
+	}
+
+	for _, change := range DiffSchemas(NewSchemaRegistry(), registry) {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimSuffix(strings.TrimSpace(change.Statement), ";")+";")
+	}
+
+	// Transactions are copied as written, so each session variable they use is declared
+	var sessions []string
+	for _, statement := range kept {
+		if statement.session != "" && !slices.Contains(sessions, statement.session) {
+			sessions = append(sessions, statement.session)
+		}
+	}
+	if len(sessions) > 0 {
+		b.WriteString("\n")
+	}
+	for _, session := range sessions {
+		fmt.Fprintf(&b, "const %s = db.getMongo().startSession();\n", session)
+	}
+	script := ""
+	for _, statement := range kept {
+		if statement.script != script {
+			script = statement.script
+			fmt.Fprintf(&b, "\n// From %s\n", script)
+		}
+		fmt.Fprintf(&b, "%s\n", statement.text)
+	}
+	for _, session := range sessions {
+		fmt.Fprintf(&b, "%s.endSession();\n", session)
+	}
+	return b.String(), nil
+}
+
+// Returns the session variable a transaction runs on, or "" for other operations
+func transactionSession(op MongoOperation) string {
+	if op.Type != "transaction" {
+		return ""
+	}
+	if match := transactionSessionPattern.FindStringSubmatch(statementText(op)); match != nil {
+		return match[1]
+	}
+	return "session"
+}
+
+// Returns the collections an operation touches, including those of the
+// operations of a transaction
+func operationCollections(op MongoOperation) []string {
+	collections := []string{op.Collection}
+	for _, nested := range op.Transaction {
+		collections = append(collections, operationCollections(nested)...)
+	}
+	return collections
+}
+
+// Returns an operation's statement as written, without comments
+func statementText(op MongoOperation) string {
+	text := op.Statement
+	if op.Location != nil && op.Location.Text != "" {
+		text = op.Location.Text
+	}
+	text = strings.TrimSpace(text)
+	if isDirective(text) || strings.HasSuffix(text, ";") || strings.HasSuffix(text, "}") {
+		return text
+	}
+	return text + ";"
+}
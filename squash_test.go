@@ -0,0 +1,120 @@
+package mongoparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSquash(t *testing.T) {
+	scripts := []ScriptInfo{
+		{Name: "001_users.js", Content: `db.createCollection("users", { validator: { $jsonSchema: { required: ["email"] } } });
+db.users.createIndex({ email: 1 }, { unique: true });
+db.users.createIndex({ name: 1 });
+db.users.insertOne({ email: "admin@example.com" });
+db.createCollection("audit");
+db.audit.insertOne({ event: "created" });`},
+		{Name: "002_plans.js", Content: `// METADATA:
+// {"name": "add-plans"}
+
+db.plans.insertMany([{ _id: "free" }, { _id: "pro" }]);
+db.users.dropIndex("name_1");
+const session = db.getMongo().startSession();
+session.withTransaction(() => {
+    db.users.updateMany({}, { $set: { plan: "free" } });
+});
+session.endSession();
+print("done");`},
+		{Name: "003_users.js", Content: `db.createCollection("users", {
+  validator: { $jsonSchema: { required: ["email", "plan"] } }
+});
+db.audit.drop();`},
+	}
+
+	squashed, err := NewParser().Squash(scripts)
+	if err != nil {
+		t.Fatalf("Squash() returned error: %v", err)
+	}
+
+	for _, expected := range []string{
+		"// Squashed from 3 scripts: 001_users.js, add-plans, 003_users.js",
+		`//   add-plans line 11: print("done");`,
+		`required: ["email", "plan"]`,
+		`db.users.createIndex({ email: 1 }, { unique: true });`,
+		"// From 001_users.js\ndb.users.insertOne({ email: \"admin@example.com\" });",
+		"// From add-plans\ndb.plans.insertMany",
+	} {
+		if !strings.Contains(squashed, expected) {
+			t.Errorf("Expected squashed script to contain %q, got:\n%s", expected, squashed)
+		}
+	}
+	for _, unexpected := range []string{"name_1", "audit", `required: ["email"] `} {
+		if strings.Contains(squashed, unexpected) {
+			t.Errorf("Expected %q to be superseded, got:\n%s", unexpected, squashed)
+		}
+	}
+
+	ops, err := NewParser().ParseScript(squashed)
+	if err != nil {
+		t.Fatalf("Squashed script does not parse: %v\n%s", err, squashed)
+	}
+	var types []string
+	for _, op := range ops {
+		types = append(types, op.Type)
+	}
+	if strings.Join(types, ", ") != "createCollection, createIndex, insert, insert, transaction" {
+		t.Errorf("Unexpected operations: %v\n%s", types, squashed)
+	}
+}
+
+func TestSquashSessionNames(t *testing.T) {
+	scripts := []ScriptInfo{
+		{Name: "001_accounts.js", Content: `db.createCollection("accounts");
+const s = db.getMongo().startSession();
+s.withTransaction(() => {
+    s.getDatabase("app").accounts.insertOne({ _id: "a", balance: 10 });
+});
+s.endSession();`},
+		{Name: "002_transfers.js", Content: `const txn = db.getMongo().startSession();
+txn.withTransaction(() => {
+    db.accounts.updateOne({ _id: "a" }, { $inc: { balance: -5 } });
+});
+txn.endSession();
+const s = db.getMongo().startSession();
+s.withTransaction(() => {
+    db.accounts.updateOne({ _id: "a" }, { $inc: { balance: 5 } });
+});
+s.endSession();`},
+	}
+
+	squashed, err := NewParser().Squash(scripts)
+	if err != nil {
+		t.Fatalf("Squash() returned error: %v", err)
+	}
+	for _, expected := range []string{
+		"const s = db.getMongo().startSession();\nconst txn = db.getMongo().startSession();\n",
+		`s.withTransaction(() => {`,
+		`txn.withTransaction(() => {`,
+		"s.endSession();\ntxn.endSession();\n",
+	} {
+		if !strings.Contains(squashed, expected) {
+			t.Errorf("Expected squashed script to contain %q, got:\n%s", expected, squashed)
+		}
+	}
+	if strings.Contains(squashed, "const session") || strings.Count(squashed, "const s =") != 1 {
+		t.Errorf("Expected each session variable to be declared once, got:\n%s", squashed)
+	}
+
+	ops, err := NewParser().ParseScript(squashed)
+	if err != nil {
+		t.Fatalf("Squashed script does not parse: %v\n%s", err, squashed)
+	}
+	transactions := 0
+	for _, op := range ops {
+		if op.Type == "transaction" {
+			transactions++
+		}
+	}
+	if transactions != 3 {
+		t.Errorf("Expected 3 transactions, got %d:\n%s", transactions, squashed)
+	}
+}
@@ -0,0 +1,56 @@
+package mongoparser
+
+import (
+	"context"
+	"strings"
+)
+
+// Applies the scripts selected by tags with ApplyAll. A script is selected when
+// it carries any of the plain tags (or there are none) and none of the tags
+// prefixed with "!", so RunTagged(ctx, scripts, "indexes", "!seed") runs index
+// scripts that are not also seed scripts. Tags come from the manifest and from
+// the "tags" field of the script metadata.
+func (r *Runner) RunTagged(ctx context.Context, scripts []ScriptInfo, tags ...string) []ScriptResult {
+	var selected []ScriptInfo
+	for _, script := range scripts {
+		if script.Metadata == nil {
+			script.Metadata = r.parser.ParseMetadata(script.Content)
+		}
+		if matchTags(scriptTags(script), tags) {
+			selected = append(selected, script)
+		}
+	}
+	return r.ApplyAll(ctx, selected)
+}
+
+// Returns the tags of a script from its manifest entry and metadata
+func scriptTags(script ScriptInfo) []string {
+	tags := script.Tags
+	if script.Metadata != nil {
+		tags = append(append([]string(nil), tags...), script.Metadata.Tags...)
+	}
+	return tags
+}
+
+// Reports whether tags satisfy the selection: any included tag, no excluded one
+func matchTags(tags, selection []string) bool {
+	has := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		has[tag] = true
+	}
+
+	included, required := false, false
+	for _, tag := range selection {
+		if excluded, ok := strings.CutPrefix(tag, "!"); ok {
+			if has[excluded] {
+				return false
+			}
+			continue
+		}
+		required = true
+		if has[tag] {
+			included = true
+		}
+	}
+	return included || !required
+}
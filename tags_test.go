@@ -0,0 +1,45 @@
+package mongoparser
+
+import "testing"
+
+func TestMatchTags(t *testing.T) {
+	cases := []struct {
+		tags, selection []string
+		match           bool
+	}{
+		{[]string{"indexes"}, []string{"indexes"}, true},
+		{[]string{"seed"}, []string{"indexes"}, false},
+		{[]string{"indexes", "seed"}, []string{"indexes", "!seed"}, false},
+		{[]string{"schema"}, []string{"!seed"}, true},
+		{nil, []string{"!seed"}, true},
+		{nil, []string{"indexes"}, false},
+		{[]string{"seed"}, nil, true},
+		{[]string{"users"}, []string{"indexes", "users"}, true},
+	}
+	for _, c := range cases {
+		if got := matchTags(c.tags, c.selection); got != c.match {
+			t.Errorf("matchTags(%v, %v) = %v, expected %v", c.tags, c.selection, got, c.match)
+		}
+	}
+}
+
+func TestScriptTags(t *testing.T) {
+	script := ScriptInfo{
+		Tags:     []string{"indexes"},
+		Metadata: &ScriptMetadata{Tags: []string{"users"}},
+	}
+	tags := scriptTags(script)
+	if len(tags) != 2 || tags[0] != "indexes" || tags[1] != "users" {
+		t.Errorf("Expected manifest and metadata tags, got %v", tags)
+	}
+}
+
+func TestRunTaggedSelectsNothing(t *testing.T) {
+	runner := NewRunner(NewParser(), nil, "")
+	scripts := []ScriptInfo{
+		{Name: "001_seed.js", Content: "// METADATA:\n// {\"tags\": [\"seed\"]}\ndb.users.insertOne({});"},
+	}
+	if results := runner.RunTagged(t.Context(), scripts, "indexes"); len(results) != 0 {
+		t.Errorf("Expected no scripts to run, got %+v", results)
+	}
+}
@@ -0,0 +1,65 @@
+package mongoparser
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Spaces write calls evenly to stay under an operations-per-second budget. It is
+// shared by all executions of a Parser so parallel scripts draw from one budget.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Creates a limiter allowing opsPerSecond calls per second
+func newRateLimiter(opsPerSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / opsPerSecond)}
+}
+
+// Blocks until the next call slot, or until ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, slot.Sub(now))
+}
+
+// Sleeps for d unless ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Waits as required by the configured rate limit and delay before sending a
+// write. Insert chunks count as separate writes, so chunked backfills are paced
+// too; first reports whether this is the first write of the script.
+func (p *Parser) throttle(ctx context.Context, first bool) error {
+	if !first && p.operationDelay > 0 {
+		if err := sleepContext(ctx, p.operationDelay); err != nil {
+			return err
+		}
+	}
+	if p.rateLimiter != nil {
+		return p.rateLimiter.wait(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+
+	// The first call is immediate, the following four are spaced 10ms apart
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected calls to be spaced out, took only %v", elapsed)
+	}
+}
+
+func TestThrottleCancelled(t *testing.T) {
+	parser := NewParser(WithOperationDelay(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := parser.throttle(ctx, true); err != nil {
+		t.Errorf("Expected no delay before the first operation, got %v", err)
+	}
+	if err := parser.throttle(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancelled context to abort the delay, got %v", err)
+	}
+}
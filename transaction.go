@@ -0,0 +1,194 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Matches db.getMongo().startSession(), which only sets up the session a
+// withTransaction block runs in
+var startSessionPattern = regexp.MustCompile(`^db\.getMongo\(\)\.startSession\(.*\)$`)
+
+// Matches session.getDatabase("name"). at the start of an operation inside a
+// transaction, capturing the argument
+var sessionDatabasePattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*\.getDatabase\(([^)]*)\)\.`)
+
+// Parses session.withTransaction(() => { ... }, options) into an operation that
+// runs the enclosed statements inside a single driver transaction
+func (p *Parser) parseWithTransaction(argsString string) (*MongoOperation, error) {
+	args := p.splitArguments(argsString)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("withTransaction requires a callback")
+	}
+
+	callback := strings.TrimSpace(args[0])
+	open := strings.Index(callback, "{")
+	if open == -1 || !strings.HasSuffix(callback, "}") {
+		return nil, fmt.Errorf("withTransaction callback must be a function with a block body")
+	}
+	if signature := callback[:open]; !strings.Contains(signature, "=>") && !strings.Contains(signature, "function") {
+		return nil, fmt.Errorf("withTransaction callback must be a function with a block body")
+	}
+
+	op := &MongoOperation{
+		Type:      "transaction",
+		Operation: "withTransaction",
+	}
+
+	for _, statement := range splitStatements(callback[open+1 : len(callback)-1]) {
+		statement = strings.TrimPrefix(statement, "await ")
+		var database string
+		if match := sessionDatabasePattern.FindStringSubmatch(statement); match != nil {
+			database = unquoteDatabaseName(match[1])
+			if database == "" {
+				p.warnf("session.getDatabase(%s) is not a database name, using the target database", match[1])
+			}
+			statement = "db." + statement[len(match[0]):]
+		}
+		nested := p.parseStatement(statement)
+		if nested == nil {
+			continue
+		}
+		nested.Database = database
+		if nested.Type == "transaction" {
+			return nil, fmt.Errorf("transactions cannot be nested")
+		}
+		op.Transaction = append(op.Transaction, *nested)
+	}
+	if len(op.Transaction) == 0 {
+		return nil, fmt.Errorf("withTransaction callback contains no operations")
+	}
+
+	if len(args) > 1 {
+		txnOptions, err := p.parseOptionsDocument(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction options: %w", err)
+		}
+		if err := p.parseConcernOptions(txnOptions, op); err != nil {
+			return nil, err
+		}
+	}
+
+	return op, nil
+}
+
+// Returns the database name of a quoted getDatabase() argument, or "" when the
+// argument isn't a string literal
+func unquoteDatabaseName(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if len(arg) < 2 || (arg[0] != '"' && arg[0] != '\'') || arg[len(arg)-1] != arg[0] {
+		return ""
+	}
+	return arg[1 : len(arg)-1]
+}
+
+// Splits a block body into its statements at top-level semicolons
+func splitStatements(body string) []string {
+	var statements []string
+	var current strings.Builder
+	depth := 0
+	var quote rune
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for _, char := range body {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'' || char == '`':
+			quote = char
+		case char == '(' || char == '{' || char == '[':
+			depth++
+		case char == ')' || char == '}' || char == ']':
+			depth--
+		case char == ';' && depth == 0:
+			flush()
+			continue
+		}
+		current.WriteRune(char)
+	}
+	flush()
+
+	return statements
+}
+
+// Runs the operations of a withTransaction block in one transaction, retrying
+// the whole block on transient errors as the shell does
+func (p *Parser) executeTransaction(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if len(op.Transaction) == 0 {
+		return nil, fmt.Errorf("transaction operation requires operations")
+	}
+	// Statements run on the target database, so naming another one is a mistake
+	for _, nested := range op.Transaction {
+		if nested.Database != "" && nested.Database != db.Name() {
+			return nil, fmt.Errorf("%s on %s: session.getDatabase(%q) does not match the target database %s", nested.Operation, nested.Collection, nested.Database, db.Name())
+		}
+	}
+
+	// A causally consistent script session also covers its transactions
+	session := mongo.SessionFromContext(ctx)
+	if session == nil {
+		var err error
+		session, err = db.Client().StartSession()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start session: %w", err)
+		}
+		defer session.EndSession(context.WithoutCancel(ctx))
+	}
+
+	txnOpts := options.Transaction()
+	if op.WriteConcern != nil {
+		txnOpts.SetWriteConcern(op.WriteConcern)
+	}
+	if op.ReadConcern != nil {
+		txnOpts.SetReadConcern(op.ReadConcern)
+	}
+	if op.ReadPreference != nil {
+		txnOpts.SetReadPreference(op.ReadPreference)
+	}
+
+	// Compensations of an attempt that was aborted and retried must not pile up
+	undoLen := 0
+	if p.undo != nil {
+		undoLen = len(*p.undo)
+	}
+
+	return session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if p.undo != nil {
+			*p.undo = (*p.undo)[:undoLen]
+		}
+
+		outputs := make([]interface{}, 0, len(op.Transaction))
+		for _, nested := range op.Transaction {
+			nested.Collection = p.collectionName(nested.Collection)
+			if nested.Comment == "" {
+				nested.Comment = op.Comment
+			}
+			nested, err := p.applyMiddleware(nested)
+			if err != nil {
+				return nil, err
+			}
+			output, err := p.executeMongoOperation(sc, db, nested)
+			if err != nil {
+				return nil, fmt.Errorf("%s on %s: %w", nested.Operation, nested.Collection, err)
+			}
+			if nested.Variable != "" && p.variables != nil {
+				p.variables[nested.Variable] = output
+			}
+			outputs = append(outputs, output)
+		}
+		return outputs, nil
+	}, txnOpts)
+}
@@ -0,0 +1,99 @@
+package mongoparser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestParseWithTransaction(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+const session = db.getMongo().startSession();
+session.withTransaction(() => {
+	const accounts = session.getDatabase("bank").accounts;
+	session.getDatabase("bank").accounts.updateOne({ _id: 1 }, { $inc: { balance: -100 } });
+	db.accounts.updateOne({ _id: 2 }, { $inc: { balance: 100 } });
+	db.transfers.insertOne({ from: 1, to: 2, amount: 100 });
+}, { writeConcern: { w: "majority" } });
+session.endSession();`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 1 {
+		t.Fatalf("Expected a single transaction operation, got %d", len(ops))
+	}
+	txn := ops[0]
+	if txn.Type != "transaction" || len(txn.Transaction) != 3 {
+		t.Fatalf("Expected transaction with 3 operations, got %s with %d", txn.Type, len(txn.Transaction))
+	}
+
+	expected := []string{"updateOne", "updateOne", "insertOne"}
+	for i, op := range txn.Transaction {
+		if op.Operation != expected[i] || op.Collection == "" {
+			t.Errorf("Operation %d: expected %s, got %s on %q", i, expected[i], op.Operation, op.Collection)
+		}
+	}
+	if txn.Transaction[0].Collection != "accounts" || txn.Transaction[0].Database != "bank" {
+		t.Errorf("Expected session database prefix to be stripped and kept as the database, got %q on %q", txn.Transaction[0].Collection, txn.Transaction[0].Database)
+	}
+	if txn.Transaction[1].Database != "" {
+		t.Errorf("Expected db. statements not to name a database, got %q", txn.Transaction[1].Database)
+	}
+	if txn.WriteConcern == nil {
+		t.Error("Expected transaction write concern to be parsed")
+	}
+}
+
+func TestTransactionOnAnotherDatabase(t *testing.T) {
+	// Connect does not dial until an operation runs, and the mismatch is refused first
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	result := NewParser().ExecuteScript(t.Context(), client.Database("app"), `const session = db.getMongo().startSession();
+session.withTransaction(() => {
+	session.getDatabase("bank").accounts.updateOne({ _id: 1 }, { $inc: { balance: -100 } });
+});`)
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), `updateOne on accounts: session.getDatabase("bank") does not match the target database app`) {
+		t.Errorf("Expected a transaction on another database to be refused, got %v", result.Error)
+	}
+}
+
+func TestParseWithTransactionInvalid(t *testing.T) {
+	parser := NewParser()
+
+	statements := []string{
+		`session.withTransaction()`,
+		`session.withTransaction(() => { print("nothing to do"); })`,
+		`session.withTransaction(callback)`,
+	}
+	for _, statement := range statements {
+		op, handled, err := parser.parseShellStatement(statement)
+		if !handled {
+			t.Errorf("Expected %s to be handled", statement)
+		}
+		if statement == statements[1] {
+			if err != nil || len(op.Transaction) != 1 {
+				t.Errorf("Expected shell helpers to run inside transactions, got %v", err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Expected error for %s", statement)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(` db.a.insertOne({ note: "x;y" }); db.b.updateOne({}, { $set: { a: [1, 2] } });  `)
+	if len(statements) != 2 || statements[0] != `db.a.insertOne({ note: "x;y" })` {
+		t.Errorf("Unexpected statements %q", statements)
+	}
+}
@@ -0,0 +1,185 @@
+package mongoparser
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Writes a TypeScript declaration file with an interface for each collection
+// whose validator has a $jsonSchema, for Node and front-end code reading the
+// same database. Properties that aren't required are optional, bsonType
+// lists and enums become unions, and nested objects are written inline. BSON
+// types without a JavaScript counterpart, such as ObjectId, are imported from
+// the bson package. Interfaces get an _id property when the schema doesn't
+// declare one.
+func (r *SchemaRegistry) WriteTypeScript(w io.Writer) error {
+	g := &tsGenerator{imports: make(map[string]bool)}
+	names := make(map[string]bool)
+
+	var body strings.Builder
+	for _, name := range r.Collections() {
+		collection := r.collections[name]
+		if collection.JSONSchema == nil {
+			continue
+		}
+		typeName := singular(exportedName(name))
+		for i := 2; names[typeName]; i++ {
+			typeName = fmt.Sprintf("%s%d", singular(exportedName(name)), i)
+		}
+		names[typeName] = true
+
+		fmt.Fprintf(&body, "\n/** Document of the %s collection. */\n", name)
+		fmt.Fprintf(&body, "export interface %s %s\n", typeName, g.object(collection.JSONSchema, "", true))
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by mongoparser; DO NOT EDIT.\n")
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for name := range g.imports {
+			imports = append(imports, name)
+		}
+		sort.Strings(imports)
+		fmt.Fprintf(&b, "\nimport type { %s } from \"bson\";\n", strings.Join(imports, ", "))
+	}
+	b.WriteString(body.String())
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// TypeScript types generated from $jsonSchema validators
+type tsGenerator struct {
+	// Types imported from the bson package
+	imports map[string]bool
+}
+
+// Returns an object type literal for an object schema, indented by indent
+func (g *tsGenerator) object(schema bson.D, indent string, document bool) string {
+	properties := schemaProperties(schema)
+	var b strings.Builder
+	b.WriteString("{\n")
+	if document && !hasProperty(properties, "_id") {
+		g.imports["ObjectId"] = true
+		fmt.Fprintf(&b, "%s  _id: ObjectId;\n", indent)
+	}
+	for _, property := range properties {
+		if description := schemaDescription(property.schema); description != "" {
+			fmt.Fprintf(&b, "%s  /** %s */\n", indent, strings.ReplaceAll(description, "*/", "*\\/"))
+		}
+		optional := "?"
+		if property.required {
+			optional = ""
+		}
+		fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, tsPropertyName(property.name), optional, g.propertyType(property.schema, indent+"  "))
+	}
+	fmt.Fprintf(&b, "%s}", indent)
+	return b.String()
+}
+
+// Returns the TypeScript type of a property schema
+func (g *tsGenerator) propertyType(schema bson.D, indent string) string {
+	types, nullable := allowedTypes(schema)
+
+	var union []string
+	if values, ok := lookupKey(schema, "enum"); ok {
+		union = tsLiterals(values)
+	}
+	if union == nil {
+		for _, name := range types {
+			union = append(union, g.bsonType(name, schema, indent))
+		}
+	}
+	if len(union) == 0 {
+		return "unknown"
+	}
+	if nullable {
+		union = append(union, "null")
+	}
+	return strings.Join(union, " | ")
+}
+
+// Returns the TypeScript type of a BSON type, as the Node driver decodes it
+func (g *tsGenerator) bsonType(name string, schema bson.D, indent string) string {
+	switch name {
+	case "string":
+		return "string"
+	case "int", "long", "double", "number":
+		return "number"
+	case "bool":
+		return "boolean"
+	case "date":
+		return "Date"
+	case "regex":
+		return "RegExp"
+	case "objectId", "decimal", "binData", "timestamp":
+		g.imports[bsonClasses[name]] = true
+		return bsonClasses[name]
+	case "object":
+		if len(schemaProperties(schema)) == 0 {
+			return "Record<string, unknown>"
+		}
+		return g.object(schema, indent, false)
+	case "array":
+		items, _ := lookupKey(schema, "items")
+		itemSchema, ok := items.(bson.D)
+		if !ok {
+			return "unknown[]"
+		}
+		item := g.propertyType(itemSchema, indent)
+		if strings.Contains(item, " | ") {
+			return "(" + item + ")[]"
+		}
+		return item + "[]"
+	default:
+		return "unknown"
+	}
+}
+
+// Classes of the bson package for BSON types without a JavaScript counterpart
+var bsonClasses = map[string]string{
+	"objectId": "ObjectId", "decimal": "Decimal128", "binData": "Binary", "timestamp": "Timestamp",
+}
+
+// Returns the literal types of enum values, or nil when one of them can't be
+// written as a literal
+func tsLiterals(values interface{}) []string {
+	list, ok := values.(bson.A)
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	literals := make([]string, 0, len(list))
+	for _, value := range list {
+		switch v := value.(type) {
+		case string:
+			literals = append(literals, fmt.Sprintf("%q", v))
+		case bool:
+			literals = append(literals, fmt.Sprint(v))
+		case nil:
+			literals = append(literals, "null")
+		default:
+			number, ok := toFloat64(v)
+			if !ok {
+				return nil
+			}
+			literals = append(literals, fmt.Sprint(number))
+		}
+	}
+	return literals
+}
+
+// Names TypeScript accepts unquoted as property names
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Quotes a property name when it isn't an identifier
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
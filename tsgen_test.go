@@ -0,0 +1,62 @@
+package mongoparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTypeScript(t *testing.T) {
+	registry, err := NewParser().SchemaRegistry([]ScriptInfo{{Name: "001_init.js", Content: `db.createCollection("users", { validator: { $jsonSchema: {
+	bsonType: "object",
+	required: ["email", "status"],
+	properties: {
+		email: { bsonType: "string", description: "Login address" },
+		status: { enum: ["active", "disabled"] },
+		age: { bsonType: ["int", "null"] },
+		managerId: { bsonType: "objectId" },
+		address: { bsonType: "object", required: ["city"], properties: { city: { bsonType: "string" } } },
+		tags: { bsonType: "array", items: { bsonType: ["string", "int"] } },
+		"display-name": { type: "string" },
+		settings: { bsonType: "object" },
+		createdAt: { bsonType: "date" }
+	}
+} } });
+db.createCollection("logs");`}})
+	if err != nil {
+		t.Fatalf("SchemaRegistry() returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := registry.WriteTypeScript(&out); err != nil {
+		t.Fatalf("WriteTypeScript() returned error: %v", err)
+	}
+
+	expected := `// Code generated by mongoparser; DO NOT EDIT.
+
+import type { ObjectId } from "bson";
+
+/** Document of the users collection. */
+export interface User {
+  _id: ObjectId;
+  /** Login address */
+  email: string;
+  status: "active" | "disabled";
+  age?: number | null;
+  managerId?: ObjectId;
+  address?: {
+    city: string;
+  };
+  tags?: (string | number)[];
+  "display-name"?: string;
+  settings?: Record<string, unknown>;
+  createdAt?: Date;
+}
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected declarations:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "interface Log") {
+		t.Error("Expected collections without a $jsonSchema to be skipped")
+	}
+}
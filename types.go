@@ -1,10 +1,15 @@
 package mongoparser
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 // Represents metadata about a setup script
@@ -14,9 +19,15 @@ type ScriptMetadata struct {
 	Version      string    `json:"version,omitempty"`
 	Author       string    `json:"author,omitempty"`
 	Dependencies []string  `json:"dependencies,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
 	ExecutedAt   time.Time `json:"executed_at"`
 	Status       string    `json:"status"`
 	Error        string    `json:"error,omitempty"`
+
+	// Defaults applied to operations that do not set their own concerns
+	WriteConcern   map[string]interface{} `json:"write_concern,omitempty"`
+	ReadConcern    string                 `json:"read_concern,omitempty"`
+	ReadPreference string                 `json:"read_preference,omitempty"`
 }
 
 // Represents a discovered script
@@ -26,6 +37,9 @@ type ScriptInfo struct {
 	Content      string
 	Metadata     *ScriptMetadata
 	Dependencies []string
+	Tags         []string
+	// Database the script targets; empty for the Runner's database
+	Database string
 }
 
 // Represents the result of script execution
@@ -33,16 +47,109 @@ type ScriptResult struct {
 	Success bool
 	Output  interface{}
 	Error   error
+
+	// Script name and version taken from metadata, when present
+	Name    string
+	Version string
+
+	Operations []OperationResult
+	Warnings   []string
+	StartedAt  time.Time
+	Duration   time.Duration
+
+	// Operations undoing what a failed script created, most recent first
+	Rollback      []MongoOperation
+	RolledBack    bool
+	RollbackError error
+
+	// Operations the profiler recorded as slow, when WithProfiler is set
+	SlowOperations []SlowOperation
+}
+
+// Execution status of a single operation
+const (
+	StatusSucceeded = "succeeded"
+	StatusSkipped   = "skipped"
+	StatusFailed    = "failed"
+	StatusNotRun    = "not_run"
+)
+
+// Represents the outcome of a single operation within a script
+type OperationResult struct {
+	Type       string
+	Collection string
+	Operation  string
+	Status     string
+	Result     interface{}
+	Error      error
+	Duration   time.Duration
+
+	// Documents inserted, modified or deleted by the operation
+	Documents int64
+	// Key patterns of indexes the operation defined
+	IndexKeys []bson.D
+	// Statement the operation was parsed from
+	Location *SourceLocation
+}
+
+// Locates the statement an operation was parsed from in its script. Lines are
+// 1-based and inclusive; byte offsets count bytes of the script as UTF-8 without
+// a byte order mark, the end being exclusive.
+type SourceLocation struct {
+	StartLine   int `json:"start_line"`
+	EndLine     int `json:"end_line"`
+	StartOffset int `json:"start_offset"`
+	EndOffset   int `json:"end_offset"`
+	// Statement as written, with its line breaks and indentation but without comments
+	Text string `json:"text"`
+}
+
+// Formats the line range as "line 3" or "lines 3-7"
+func (l SourceLocation) String() string {
+	if l.EndLine > l.StartLine {
+		return fmt.Sprintf("lines %d-%d", l.StartLine, l.EndLine)
+	}
+	return fmt.Sprintf("line %d", l.StartLine)
 }
 
 // Represents a MongoDB operation parsed from JavaScript
 type MongoOperation struct {
-	Type         string                           `json:"type"`
-	Collection   string                           `json:"collection"`
-	Operation    string                           `json:"operation"`
-	Arguments    []bson.M                         `json:"arguments,omitempty"`
-	IndexSpec    interface{}                      `json:"index_spec,omitempty"` // Can be bson.M or bson.D
-	IndexOptions *options.IndexOptions            `json:"index_options,omitempty"`
-	Validator    interface{}                      `json:"validator,omitempty"` // Can be bson.M or map[string]interface{}
-	CollOptions  *options.CreateCollectionOptions `json:"coll_options,omitempty"`
+	Type                 string                           `json:"type"`
+	Collection           string                           `json:"collection"`
+	Operation            string                           `json:"operation"`
+	Arguments            []bson.D                         `json:"arguments,omitempty"`
+	InsertManyOptions    *options.InsertManyOptions       `json:"insert_many_options,omitempty"`
+	UpdatePipeline       []bson.D                         `json:"update_pipeline,omitempty"`
+	UpdateOptions        *options.UpdateOptions           `json:"update_options,omitempty"`
+	DeleteOptions        *options.DeleteOptions           `json:"delete_options,omitempty"`
+	ChangeStreamOptions  *options.ChangeStreamOptions     `json:"change_stream_options,omitempty"`
+	FindAndModifyOptions *FindAndModifyOptions            `json:"find_and_modify_options,omitempty"`
+	Assertion            *Assertion                       `json:"assertion,omitempty"`
+	Sleep                time.Duration                    `json:"sleep,omitempty"`
+	Ignored              bool                             `json:"ignored,omitempty"`
+	ContinueOnError      bool                             `json:"continue_on_error,omitempty"`
+	Timeout              time.Duration                    `json:"timeout,omitempty"`
+	Transaction          []MongoOperation                 `json:"transaction,omitempty"`
+	EncryptedFields      []string                         `json:"encrypted_fields,omitempty"`
+	Seed                 *SeedSpec                        `json:"seed,omitempty"`
+	Import               *ImportSpec                      `json:"import,omitempty"`
+	IndexSpec            interface{}                      `json:"index_spec,omitempty"` // Usually bson.D to keep field order
+	IndexOptions         *options.IndexOptions            `json:"index_options,omitempty"`
+	IndexModels          []mongo.IndexModel               `json:"index_models,omitempty"`
+	CreateIndexesOptions *options.CreateIndexesOptions    `json:"create_indexes_options,omitempty"`
+	SearchIndexModels    []mongo.SearchIndexModel         `json:"search_index_models,omitempty"`
+	Validator            interface{}                      `json:"validator,omitempty"` // Usually bson.D to keep key order
+	CollOptions          *options.CreateCollectionOptions `json:"coll_options,omitempty"`
+	WriteConcern         *writeconcern.WriteConcern       `json:"write_concern,omitempty"`
+	ReadConcern          *readconcern.ReadConcern         `json:"read_concern,omitempty"`
+	ReadPreference       *readpref.ReadPref               `json:"read_preference,omitempty"`
+	RawCollOptions       bson.D                           `json:"raw_coll_options,omitempty"` // Set when options need to be passed through verbatim
+	Values               []interface{}                    `json:"values,omitempty"`           // Decoded arguments of a registered custom operation
+	Message              string                           `json:"message,omitempty"`          // Rendered output of print helpers
+	Source               string                           `json:"source,omitempty"`           // Local file a directive reads
+	Variable             string                           `json:"variable,omitempty"`         // Name the script binds the result to
+	Statement            string                           `json:"statement,omitempty"`        // Script text the operation was parsed from
+	Location             *SourceLocation                  `json:"location,omitempty"`         // Where the statement sits in the script
+	Comment              string                           `json:"comment,omitempty"`          // $comment sent with the operation's commands
+	Database             string                           `json:"database,omitempty"`         // Database a transaction statement names with session.getDatabase()
 }
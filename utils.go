@@ -1,9 +1,17 @@
 package mongoparser
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"reflect"
+	"strconv"
 	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Parses JSON-like strings with JavaScript syntax
@@ -13,12 +21,197 @@ func (p *Parser) parseJSONLikeString(input string, target interface{}) error {
 		return fmt.Errorf("empty input")
 	}
 
-	// Convert JavaScript-style object notation to valid JSON
-	// Handle simple cases first
-	input = p.normalizeJavaScriptObject(input)
+	var value interface{}
+	var err error
+	if p.json5 {
+		value, err = parseJSON5(input, p.numbers, p.functions)
+	} else {
+		// Convert JavaScript-style object notation to valid JSON
+		// Handle simple cases first
+		input = p.normalizeJavaScriptObject(input)
+
+		switch target.(type) {
+		case *bson.D, *[]bson.D, *interface{}:
+			// Ordered targets are decoded token by token so key order survives
+			value, err = p.decodeOrdered(input)
+		default:
+			// Try to unmarshal as JSON
+			return json.Unmarshal([]byte(input), target)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	value, err = p.resolveSpecialValues(value)
+	if err != nil {
+		return err
+	}
+	return assignOrdered(value, target)
+}
+
+// Stores a decoded ordered value in target
+func assignOrdered(value interface{}, target interface{}) error {
+	switch t := target.(type) {
+	case *bson.D:
+		doc, ok := value.(bson.D)
+		if !ok {
+			return fmt.Errorf("expected a document")
+		}
+		*t = doc
+		return nil
+	case *[]bson.D:
+		arr, ok := value.(bson.A)
+		if !ok {
+			return fmt.Errorf("expected an array of documents")
+		}
+		docs := make([]bson.D, 0, len(arr))
+		for i, item := range arr {
+			doc, ok := item.(bson.D)
+			if !ok {
+				return fmt.Errorf("expected a document at array index %d", i)
+			}
+			docs = append(docs, doc)
+		}
+		*t = docs
+		return nil
+	case *interface{}:
+		*t = value
+		return nil
+	case *string:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a string")
+		}
+		*t = text
+		return nil
+	default:
+		return fmt.Errorf("cannot decode into %T", target)
+	}
+}
+
+// Parses an options document into a map for key lookups; nested documents stay ordered
+func (p *Parser) parseOptionsDocument(input string) (map[string]interface{}, error) {
+	var doc bson.D
+	if err := p.parseJSONLikeString(input, &doc); err != nil {
+		return nil, err
+	}
+	m, _ := asMap(doc)
+	return m, nil
+}
+
+// Decodes normalized JSON into ordered values, using bson.D for objects and bson.A for arrays
+func (p *Parser) decodeOrdered(input string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+
+	value, err := p.decodeOrderedValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure nothing follows the decoded value
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected content after value")
+	}
 
-	// Try to unmarshal as JSON
-	return json.Unmarshal([]byte(input), target)
+	return value, nil
+}
+
+// Decodes the next value from the token stream
+func (p *Parser) decodeOrderedValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			doc := bson.D{}
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected object key, got %v", keyToken)
+				}
+				value, err := p.decodeOrderedValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				doc = append(doc, bson.E{Key: key, Value: value})
+			}
+			// Consume the closing brace
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return doc, nil
+		case '[':
+			arr := bson.A{}
+			for decoder.More() {
+				value, err := p.decodeOrderedValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			// Consume the closing bracket
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		default:
+			return nil, fmt.Errorf("unexpected delimiter %v", t)
+		}
+	case json.Number:
+		return decodeNumber(t.String(), p.numbers)
+	case string:
+		if source, ok := strings.CutPrefix(t, expressionMarker); ok {
+			return compileExpression(source, p.functions)
+		}
+		return t, nil
+	default:
+		// Strings, booleans and null
+		return t, nil
+	}
+}
+
+// Decodes a number literal: as float64 by default, with integers beyond
+// float64 precision kept as int64 and fractions as Decimal128 when mode asks
+func decodeNumber(text string, mode NumberMode) (interface{}, error) {
+	text = strings.TrimPrefix(text, "+")
+	if mode != NumberFloat64 {
+		if !strings.ContainsAny(text, ".eE") {
+			if number, err := strconv.ParseInt(text, 10, 64); err == nil && (number >= 1<<53 || number <= -(1<<53)) {
+				return number, nil
+			}
+		} else if mode == NumberDecimal128 {
+			return primitive.ParseDecimal128(text)
+		}
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// Returns the keys and values of a document-like value for lookups
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.D:
+		m := make(map[string]interface{}, len(v))
+		for _, elem := range v {
+			m[elem.Key] = elem.Value
+		}
+		return m, true
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	default:
+		return nil, false
+	}
 }
 
 // Normalizes JavaScript object notation to JSON
@@ -36,15 +229,17 @@ func (p *Parser) normalizeJavaScriptObject(input string) string {
 	// For simple cases like index specifications
 	if strings.Contains(input, "{") && strings.Contains(input, ":") {
 		// This is likely a simple object, try to add quotes around unquoted keys
-		return p.addQuotesToKeys(input)
+		input = p.addQuotesToKeys(input)
 	}
 
-	return input
+	// Values such as new Date() become expressions evaluated at execution time
+	return wrapExpressions(input)
 }
 
 // Adds quotes around unquoted object keys
 func (p *Parser) addQuotesToKeys(input string) string {
-	result := ""
+	var result strings.Builder
+	result.Grow(len(input) + len(input)/8)
 	inQuotes := false
 	i := 0
 
@@ -53,14 +248,19 @@ func (p *Parser) addQuotesToKeys(input string) string {
 
 		if char == '"' {
 			inQuotes = !inQuotes
-			result += string(char)
+			result.WriteByte(char)
 			i++
 			continue
 		}
 
 		if inQuotes {
-			result += string(char)
-			i++
+			// Copy the whole quoted run at once
+			end := strings.IndexByte(input[i:], '"')
+			if end < 0 {
+				end = len(input) - i
+			}
+			result.WriteString(input[i : i+end])
+			i += end
 			continue
 		}
 
@@ -81,18 +281,24 @@ func (p *Parser) addQuotesToKeys(input string) string {
 			// Check if followed by colon
 			if i < len(input) && input[i] == ':' {
 				// This is an unquoted key, add quotes
-				result += `"` + key + `"`
+				result.WriteByte('"')
+				result.WriteString(key)
+				result.WriteByte('"')
 			} else {
-				// Not a key, just add the identifier as is
-				result += key
+				// Not a key, just add the identifier as is, keeping words such as
+				// new Date apart
+				result.WriteString(key)
+				if i > keyStart+len(key) && i < len(input) && isAlphaStart(rune(input[i])) {
+					result.WriteByte(' ')
+				}
 			}
 		} else {
-			result += string(char)
+			result.WriteByte(char)
 			i++
 		}
 	}
 
-	return result
+	return result.String()
 }
 
 // Helper function for character checking
@@ -123,12 +329,12 @@ func (p *Parser) splitArguments(argsString string) []string {
 				inQuotes = false
 			}
 			current.WriteRune(char)
-		case '{':
+		case '{', '[':
 			if !inQuotes {
 				braceLevel++
 			}
 			current.WriteRune(char)
-		case '}':
+		case '}', ']':
 			if !inQuotes {
 				braceLevel--
 			}
@@ -193,3 +399,138 @@ func (p *Parser) removeTrailingCommas(input string) string {
 
 	return result.String()
 }
+
+// Converts a parsed collation document into driver collation options
+func (p *Parser) parseCollation(value interface{}) (*options.Collation, error) {
+	collationMap, ok := asMap(value)
+	if !ok {
+		return nil, fmt.Errorf("collation must be a document")
+	}
+
+	collation := &options.Collation{}
+	for key, raw := range collationMap {
+		switch key {
+		case "locale", "caseFirst", "alternate", "maxVariable":
+			str, ok := raw.(string)
+			if !ok {
+				return nil, fmt.Errorf("collation %s must be a string", key)
+			}
+			switch key {
+			case "locale":
+				collation.Locale = str
+			case "caseFirst":
+				collation.CaseFirst = str
+			case "alternate":
+				collation.Alternate = str
+			case "maxVariable":
+				collation.MaxVariable = str
+			}
+		case "caseLevel", "numericOrdering", "normalization", "backwards":
+			flag, ok := raw.(bool)
+			if !ok {
+				return nil, fmt.Errorf("collation %s must be a boolean", key)
+			}
+			switch key {
+			case "caseLevel":
+				collation.CaseLevel = flag
+			case "numericOrdering":
+				collation.NumericOrdering = flag
+			case "normalization":
+				collation.Normalization = flag
+			case "backwards":
+				collation.Backwards = flag
+			}
+		case "strength":
+			strength, err := p.convertToNumber(raw)
+			if err != nil {
+				return nil, fmt.Errorf("collation strength must be a number")
+			}
+			strengthInt, ok := strength.(int)
+			if !ok {
+				return nil, fmt.Errorf("collation strength must be an integer")
+			}
+			collation.Strength = strengthInt
+		default:
+			return nil, fmt.Errorf("unknown collation field '%s'", key)
+		}
+	}
+
+	if collation.Locale == "" {
+		return nil, fmt.Errorf("collation requires a locale")
+	}
+
+	return collation, nil
+}
+
+// Returns the value stored under key in a document
+func lookupKey(doc bson.D, key string) (interface{}, bool) {
+	for _, elem := range doc {
+		if elem.Key == key {
+			return elem.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Compares two BSON-like values, treating numbers of different types as equal when their values match
+func valuesEqual(a, b interface{}) bool {
+	if numA, ok := toFloat64(a); ok {
+		numB, ok := toFloat64(b)
+		return ok && numA == numB
+	}
+
+	switch va := a.(type) {
+	case bson.D:
+		vb, ok := b.(bson.D)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if va[i].Key != vb[i].Key || !valuesEqual(va[i].Value, vb[i].Value) {
+				return false
+			}
+		}
+		return true
+	case bson.A:
+		vb, ok := b.(bson.A)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !valuesEqual(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	case primitive.Binary:
+		vb, ok := b.(primitive.Binary)
+		return ok && va.Subtype == vb.Subtype && bytes.Equal(va.Data, vb.Data)
+	case []byte:
+		vb, ok := b.([]byte)
+		return ok && bytes.Equal(va, vb)
+	default:
+		// Maps, slices and the like can't be compared with ==
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// Converts any numeric value to float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case primitive.Decimal128:
+		number, err := strconv.ParseFloat(v.String(), 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,37 @@
+package mongoparser
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestValuesEqual(t *testing.T) {
+	equal := [][2]interface{}{
+		{int32(1), 1.0},
+		{primitive.Binary{Subtype: 4, Data: []byte{1, 2}}, primitive.Binary{Subtype: 4, Data: []byte{1, 2}}},
+		{[]byte("key"), []byte("key")},
+		{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}},
+		{bson.A{primitive.Binary{Data: []byte{1}}, bson.D{{Key: "k", Value: []byte{2}}}}, bson.A{primitive.Binary{Data: []byte{1}}, bson.D{{Key: "k", Value: []byte{2}}}}},
+	}
+	for _, pair := range equal {
+		if !valuesEqual(pair[0], pair[1]) {
+			t.Errorf("Expected %v and %v to be equal", pair[0], pair[1])
+		}
+	}
+
+	different := [][2]interface{}{
+		{primitive.Binary{Subtype: 4, Data: []byte{1, 2}}, primitive.Binary{Subtype: 0, Data: []byte{1, 2}}},
+		{primitive.Binary{Data: []byte{1, 2}}, primitive.Binary{Data: []byte{1, 3}}},
+		{primitive.Binary{Data: []byte{1}}, []byte{1}},
+		{map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}},
+		{bson.A{[]byte{1}}, bson.A{[]byte{2}}},
+		{"1", 1},
+	}
+	for _, pair := range different {
+		if valuesEqual(pair[0], pair[1]) {
+			t.Errorf("Expected %v and %v to differ", pair[0], pair[1])
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package mongoparser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// An existing collection whose validator settings differ from the script's
+type ValidatorDrift struct {
+	Collection string
+	// What differs, such as "validationLevel strict, script has moderate"
+	Differences []string
+	// Validator of the collection, nil when it has none
+	Existing bson.D
+	// Validator the script defines, nil when it only changes the level or action
+	Script interface{}
+}
+
+// Decides whether a drifted validator may be replaced with collMod
+type ValidatorUpdateConfirmFunc func(ctx context.Context, drift ValidatorDrift) bool
+
+// Returns the options of an existing collection as listCollections reports
+// them, or nil when the collection isn't listed
+func collectionOptions(ctx context.Context, db *mongo.Database, name string) (bson.D, error) {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: name}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection %s: %w", name, err)
+	}
+	for _, spec := range specs {
+		if spec.Name != name || spec.Options == nil {
+			continue
+		}
+		var options bson.D
+		if err := bson.Unmarshal(spec.Options, &options); err != nil {
+			return nil, fmt.Errorf("failed to decode options of collection %s: %w", name, err)
+		}
+		return options, nil
+	}
+	return nil, nil
+}
+
+// Returns the collMod fields needed to bring a collection with the existing
+// options in line with the script, and a description of each difference.
+// Fields the script leaves out keep their current value, so a new validator
+// is applied with the collection's own validationLevel and validationAction.
+func validatorChanges(existing bson.D, op MongoOperation) (bson.D, []string) {
+	var changes bson.D
+	var differences []string
+
+	if op.Validator != nil {
+		current, _ := lookupKey(existing, "validator")
+		if !sameDocument(current, op.Validator) {
+			changes = append(changes, bson.E{Key: "validator", Value: op.Validator})
+			if current == nil {
+				differences = append(differences, "no validator, script has one")
+			} else {
+				differences = append(differences, "validator differs from the script")
+			}
+		}
+	}
+
+	if op.CollOptions == nil {
+		return changes, differences
+	}
+	settings := []struct {
+		field    string
+		value    interface{}
+		fallback interface{}
+	}{
+		{"validationLevel", op.CollOptions.ValidationLevel, "strict"},
+		{"validationAction", op.CollOptions.ValidationAction, "error"},
+		{"expireAfterSeconds", op.CollOptions.ExpireAfterSeconds, nil},
+	}
+	for _, setting := range settings {
+		desired := dereference(setting.value)
+		if desired == nil {
+			continue
+		}
+		current, ok := lookupKey(existing, setting.field)
+		if !ok {
+			current = setting.fallback
+		}
+		if current != nil && valuesEqual(current, desired) {
+			continue
+		}
+		changes = append(changes, bson.E{Key: setting.field, Value: desired})
+		if current == nil {
+			differences = append(differences, fmt.Sprintf("no %s, script has %v", setting.field, desired))
+		} else {
+			differences = append(differences, fmt.Sprintf("%s %v, script has %v", setting.field, current, desired))
+		}
+	}
+	return changes, differences
+}
+
+// Returns the value a *string or *int64 option points to, or nil
+func dereference(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *string:
+		if v != nil {
+			return *v
+		}
+	case *int64:
+		if v != nil {
+			return *v
+		}
+	}
+	return nil
+}
+
+// Compares two documents after a round trip through BSON, so the script's
+// values and those decoded from the server have the same types
+func sameDocument(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	normalized := make([]interface{}, 0, 2)
+	for _, value := range []interface{}{a, b} {
+		data, err := bson.Marshal(bson.D{{Key: "v", Value: value}})
+		if err != nil {
+			return false
+		}
+		var doc bson.D
+		if err := bson.Unmarshal(data, &doc); err != nil {
+			return false
+		}
+		normalized = append(normalized, doc[0].Value)
+	}
+	return valuesEqual(normalized[0], normalized[1])
+}
+
+// Asks the configured confirmation before a drifted validator is replaced,
+// failing when it is refused
+func (p *Parser) confirmValidatorUpdate(ctx context.Context, existing bson.D, op MongoOperation, differences []string) error {
+	if p.confirmValidator == nil {
+		return nil
+	}
+
+	drift := ValidatorDrift{Collection: op.Collection, Differences: differences, Script: op.Validator}
+	if validator, ok := lookupKey(existing, "validator"); ok {
+		drift.Existing, _ = validator.(bson.D)
+	}
+	if !p.confirmValidator(ctx, drift) {
+		return fmt.Errorf("validator of collection %s differs from the script (%s) and updating it was not confirmed",
+			op.Collection, strings.Join(differences, "; "))
+	}
+	log.Printf("Validator of collection %s differs from the script (%s); update confirmed", op.Collection, strings.Join(differences, "; "))
+	return nil
+}
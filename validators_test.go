@@ -0,0 +1,70 @@
+package mongoparser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidatorChanges(t *testing.T) {
+	op, err := NewParser().parseMongoStatement(`db.createCollection("users", {
+		validator: { $jsonSchema: { required: ["email"], properties: { age: { minimum: 18 } } } },
+		validationAction: "warn"
+	})`)
+	if err != nil {
+		t.Fatalf("parseMongoStatement() returned error: %v", err)
+	}
+
+	// As the server reports it, with int32 numbers and the default level
+	existing := bson.D{
+		{Key: "validator", Value: bson.D{{Key: "$jsonSchema", Value: bson.D{
+			{Key: "required", Value: bson.A{"email"}},
+			{Key: "properties", Value: bson.D{{Key: "age", Value: bson.D{{Key: "minimum", Value: int32(18)}}}}},
+		}}}},
+		{Key: "validationLevel", Value: "moderate"},
+		{Key: "validationAction", Value: "warn"},
+	}
+	if changes, differences := validatorChanges(existing, *op); len(changes) != 0 {
+		t.Errorf("Expected a matching validator to need no changes, got %v (%v)", changes, differences)
+	}
+
+	existing[0].Value = bson.D{{Key: "$jsonSchema", Value: bson.D{{Key: "required", Value: bson.A{"name"}}}}}
+	existing[2].Value = "error"
+	changes, differences := validatorChanges(existing, *op)
+	if len(changes) != 2 || changes[0].Key != "validator" || changes[1].Key != "validationAction" {
+		t.Errorf("Expected the validator and action to change, got %v", changes)
+	}
+	if _, ok := lookupKey(changes, "validationLevel"); ok {
+		t.Errorf("Expected the collection's validationLevel to be kept, got %v", changes)
+	}
+	if strings.Join(differences, "; ") != "validator differs from the script; validationAction error, script has warn" {
+		t.Errorf("Unexpected differences: %v", differences)
+	}
+
+	if _, differences := validatorChanges(nil, *op); len(differences) != 2 || differences[0] != "no validator, script has one" {
+		t.Errorf("Expected a collection without a validator to drift, got %v", differences)
+	}
+}
+
+func TestConfirmValidatorUpdate(t *testing.T) {
+	existing := bson.D{{Key: "validator", Value: bson.D{{Key: "a", Value: bson.D{{Key: "$exists", Value: true}}}}}}
+	op := MongoOperation{Type: "createCollection", Collection: "users", Validator: bson.D{{Key: "b", Value: bson.D{{Key: "$exists", Value: true}}}}}
+
+	var drift ValidatorDrift
+	p := NewParser(WithValidatorReconciliation(func(ctx context.Context, d ValidatorDrift) bool {
+		drift = d
+		return false
+	}))
+	err := p.confirmValidatorUpdate(context.Background(), existing, op, []string{"validator differs from the script"})
+	if err == nil || !strings.Contains(err.Error(), "updating it was not confirmed") {
+		t.Errorf("Expected a refused update to fail, got %v", err)
+	}
+	if drift.Collection != "users" || drift.Existing == nil || drift.Script == nil {
+		t.Errorf("Expected the drift to describe both validators, got %+v", drift)
+	}
+	if p.collectionPolicy != ConflictUpdate {
+		t.Errorf("Expected validator reconciliation to update existing collections")
+	}
+}
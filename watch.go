@@ -0,0 +1,167 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Receives the events of a change stream opened by a watch statement. Returning
+// an error closes the stream and fails the script.
+type ChangeEventFunc func(ctx context.Context, op MongoOperation, event bson.D) error
+
+// Parses db.coll.watch(pipeline, options) and db.watch(pipeline, options)
+func (p *Parser) parseWatch(collection, argsString string) (*MongoOperation, error) {
+	op := &MongoOperation{
+		Type:       "watch",
+		Collection: collection,
+		Operation:  "watch",
+	}
+
+	args := p.splitArguments(argsString)
+	if len(args) > 0 && args[0] != "" {
+		var pipeline []bson.D
+		if err := p.parseJSONLikeString(args[0], &pipeline); err != nil {
+			return nil, fmt.Errorf("failed to parse watch pipeline: %w", err)
+		}
+		op.Arguments = pipeline
+	}
+
+	if len(args) > 1 {
+		watchOptions, err := p.parseOptionsDocument(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse watch options: %w", err)
+		}
+		opts, err := parseChangeStreamOptions(watchOptions)
+		if err != nil {
+			return nil, err
+		}
+		op.ChangeStreamOptions = opts
+	}
+
+	return op, nil
+}
+
+// Converts a parsed watch options document into driver change stream options
+func parseChangeStreamOptions(watchOptions map[string]interface{}) (*options.ChangeStreamOptions, error) {
+	opts := options.ChangeStream()
+	for _, key := range []string{"fullDocument", "fullDocumentBeforeChange"} {
+		value, ok := watchOptions[key]
+		if !ok {
+			continue
+		}
+		mode, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("watch %s option must be a string", key)
+		}
+		if key == "fullDocument" {
+			opts.SetFullDocument(options.FullDocument(mode))
+		} else {
+			opts.SetFullDocumentBeforeChange(options.FullDocument(mode))
+		}
+	}
+	if value, ok := watchOptions["batchSize"]; ok {
+		size, ok := toFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("watch batchSize option must be a number")
+		}
+		opts.SetBatchSize(int32(size))
+	}
+	if value, ok := watchOptions["maxAwaitTimeMS"]; ok {
+		ms, ok := toFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("watch maxAwaitTimeMS option must be a number")
+		}
+		opts.SetMaxAwaitTime(time.Duration(ms) * time.Millisecond)
+	}
+	for _, key := range []string{"resumeAfter", "startAfter"} {
+		value, ok := watchOptions[key]
+		if !ok {
+			continue
+		}
+		token, ok := value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("watch %s option must be a resume token document", key)
+		}
+		if key == "resumeAfter" {
+			opts.SetResumeAfter(token)
+		} else {
+			opts.SetStartAfter(token)
+		}
+	}
+	return opts, nil
+}
+
+// Consumes a change stream for the configured duration, passing each event to
+// the configured handler. Without a handler watch statements are interactive
+// only and are skipped.
+func (p *Parser) executeWatch(ctx context.Context, db *mongo.Database, op MongoOperation) (interface{}, error) {
+	if p.changeStreamHandler == nil {
+		p.warnf("watch on %s is interactive-only and was skipped; configure WithChangeStream to consume it", watchTarget(op))
+		return skipped("watch is interactive-only"), nil
+	}
+
+	pipeline := mongo.Pipeline(op.Arguments)
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+	opts := op.ChangeStreamOptions
+	if opts == nil {
+		opts = options.ChangeStream()
+	}
+
+	var watchCtx context.Context
+	var cancel context.CancelFunc
+	if p.changeStreamDuration > 0 {
+		watchCtx, cancel = context.WithTimeout(ctx, p.changeStreamDuration)
+	} else {
+		watchCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var stream *mongo.ChangeStream
+	var err error
+	if op.Collection == "" {
+		stream, err = p.database(db, op).Watch(watchCtx, pipeline, opts)
+	} else {
+		stream, err = p.collection(db, op).Watch(watchCtx, pipeline, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close(context.WithoutCancel(ctx))
+
+	var events int64
+	for stream.Next(watchCtx) {
+		var event bson.D
+		if err := stream.Decode(&event); err != nil {
+			return events, err
+		}
+		events++
+		if err := p.changeStreamHandler(ctx, op, event); err != nil {
+			return events, fmt.Errorf("change event handler: %w", err)
+		}
+	}
+
+	// Running out of time is how a bounded watch ends
+	if err := stream.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return events, err
+	}
+	if err := ctx.Err(); err != nil {
+		return events, err
+	}
+	return events, nil
+}
+
+// Describes what a watch statement observes
+func watchTarget(op MongoOperation) string {
+	if op.Collection == "" {
+		return "the database"
+	}
+	return op.Collection
+}
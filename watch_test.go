@@ -0,0 +1,43 @@
+package mongoparser
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestParseWatch(t *testing.T) {
+	parser := NewParser()
+	ops, err := parser.parseJavaScriptOperations(`
+db.orders.watch([{ $match: { operationType: "insert" } }], { fullDocument: "updateLookup", batchSize: 10 });
+db.watch();`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operations, got %d", len(ops))
+	}
+	watch := ops[0]
+	if watch.Type != "watch" || watch.Collection != "orders" || len(watch.Arguments) != 1 {
+		t.Errorf("Expected collection watch with one stage, got %+v", watch)
+	}
+	if opts := watch.ChangeStreamOptions; opts == nil || opts.FullDocument == nil || *opts.FullDocument != options.UpdateLookup || *opts.BatchSize != 10 {
+		t.Errorf("Expected change stream options to be parsed, got %+v", opts)
+	}
+	if ops[1].Type != "watch" || ops[1].Collection != "" {
+		t.Errorf("Expected database watch, got %+v", ops[1])
+	}
+}
+
+func TestExecuteWatchInteractiveOnly(t *testing.T) {
+	parser := NewParser()
+	result := parser.ExecuteScript(context.Background(), nil, `db.orders.watch();`)
+	if !result.Success {
+		t.Fatalf("Expected watch to be skipped, got %v", result.Error)
+	}
+	if result.Operations[0].Status != StatusSkipped || len(result.Warnings) != 1 {
+		t.Errorf("Expected skipped watch with a warning, got %+v and %v", result.Operations[0], result.Warnings)
+	}
+}
@@ -0,0 +1,124 @@
+package mongoparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// How long Watch waits for a burst of file events to settle before re-applying
+const watchDebounce = 200 * time.Millisecond
+
+// Receives the result of every script Watch applies
+type WatchFunc func(result ScriptResult)
+
+// Applies the .js scripts below dir, then re-parses and re-applies every script
+// whose content changes until ctx ends. Scripts are applied in path order and a
+// pass stops at the first failure; a failed script is retried on the next change.
+// Unlike Apply, changed scripts that were already applied run again, so pair
+// Watch with WithCollectionConflictPolicy and WithIndexConflictPolicy set to
+// ConflictUpdate to reconcile edited validators and indexes. handler may be nil.
+func (r *Runner) Watch(ctx context.Context, dir string, handler WatchFunc) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	defer watcher.Close()
+
+	if err := watchTree(watcher, dir); err != nil {
+		return err
+	}
+
+	applied := make(map[string]string)
+	r.applyChanged(ctx, dir, applied, handler)
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			settled = time.After(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		case <-settled:
+			settled = nil
+			r.applyChanged(ctx, dir, applied, handler)
+		}
+	}
+}
+
+// Applies the scripts of dir whose checksum differs from the one recorded in
+// applied, recording the checksum of every script that succeeds
+func (r *Runner) applyChanged(ctx context.Context, dir string, applied map[string]string, handler WatchFunc) {
+	scripts, err := r.Discover(os.DirFS(dir), "**/*.js")
+	if err != nil {
+		if handler != nil {
+			handler(ScriptResult{Name: dir, Error: err, StartedAt: time.Now()})
+		}
+		return
+	}
+
+	for _, script := range changedScripts(scripts, applied) {
+		result := r.apply(ctx, script, true)
+		if handler != nil {
+			handler(result)
+		}
+		if !result.Success {
+			return
+		}
+		applied[script.Path] = Checksum(script.Content)
+	}
+}
+
+// Returns the scripts whose content differs from the checksum recorded for their path
+func changedScripts(scripts []ScriptInfo, applied map[string]string) []ScriptInfo {
+	var changed []ScriptInfo
+	for _, script := range scripts {
+		if applied[script.Path] != Checksum(script.Content) {
+			changed = append(changed, script)
+		}
+	}
+	return changed
+}
+
+// Adds dir and every directory below it to watcher, since fsnotify doesn't
+// watch recursively
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories removed while walking are picked up on the next event
+			if name != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		return watcher.Add(name)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	return nil
+}
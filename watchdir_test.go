@@ -0,0 +1,62 @@
+package mongoparser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestChangedScripts(t *testing.T) {
+	scripts := []ScriptInfo{
+		{Path: "001_users.js", Content: "db.createCollection('users');"},
+		{Path: "002_orders.js", Content: "db.createCollection('orders');"},
+	}
+	applied := map[string]string{"001_users.js": Checksum(scripts[0].Content)}
+
+	changed := changedScripts(scripts, applied)
+	if len(changed) != 1 || changed[0].Path != "002_orders.js" {
+		t.Fatalf("Expected only the new script to be applied, got %+v", changed)
+	}
+
+	applied["002_orders.js"] = Checksum(scripts[1].Content)
+	scripts[0].Content = "db.createCollection('users', { capped: true, size: 1024 });"
+	changed = changedScripts(scripts, applied)
+	if len(changed) != 1 || changed[0].Path != "001_users.js" {
+		t.Errorf("Expected only the edited script to be applied, got %+v", changed)
+	}
+}
+
+func TestWatchTree(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "schema", "users")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "001_users.js"), []byte("db.createCollection('users');"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Skipf("fsnotify unavailable: %v", err)
+	}
+	defer watcher.Close()
+
+	if err := watchTree(watcher, dir); err != nil {
+		t.Fatalf("watchTree() returned error: %v", err)
+	}
+
+	watched := watcher.WatchList()
+	for _, expected := range []string{dir, filepath.Join(dir, "schema"), nested} {
+		if !slices.Contains(watched, expected) {
+			t.Errorf("Expected %s to be watched, got %v", expected, watched)
+		}
+	}
+
+	if err := watchTree(watcher, filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}